@@ -0,0 +1,211 @@
+package encryption
+
+// Envelope encryption for sensitive fields like SSNs. Every value gets its
+// own data key, which is wrapped by a master key and stored alongside the
+// ciphertext with the master key's ID, so master keys can be rotated:
+//
+//	enc:v1:<master key id>:<wrapped data key>:<ciphertext>
+//
+// Values without the prefix are treated as plaintext, so rows written before
+// encryption was added still read fine until they're re-encrypted.
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const prefix = "enc:v1:"
+
+var ErrMalformed = errors.New("malformed encrypted value")
+var ErrUnknownKey = errors.New("unknown master key")
+
+var ring *keyring = nil
+var ringErr error = nil
+var ringOnce sync.Once
+
+// getKeyring loads the master keys the first time they're needed.
+func getKeyring() (*keyring, error) {
+	ringOnce.Do(func() {
+		if ring == nil {
+			ring, ringErr = loadKeyring()
+		}
+	})
+	return ring, ringErr
+}
+
+// Mock stores values as plaintext so tests can expect exact queries, then
+// returns a function pointer for teardown.
+func Mock() func() {
+	ogEncrypt := encrypt
+	encrypt = func(plaintext string) (string, error) {
+		return plaintext, nil
+	}
+
+	return func() {
+		encrypt = ogEncrypt
+	}
+}
+
+// Mockable functions
+
+// encrypt encrypts values stored through Encrypt.
+var encrypt func(plaintext string) (string, error) = EncryptValue
+
+// Setup loads the master keys, so a bad key config is caught at startup
+// instead of on the first request that touches sensitive data.
+func Setup() error {
+	_, err := getKeyring()
+	return err
+}
+
+// seal encrypts with AES-GCM, putting the nonce in front of the ciphertext.
+func seal(key []byte, plaintext []byte, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts something encrypted by seal.
+func open(key []byte, sealed []byte, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrMalformed
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], additionalData)
+}
+
+// encryptWith encrypts a value with a new data key wrapped by the ring's
+// current master key.
+func encryptWith(ring *keyring, plaintext string) (string, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	header := prefix + ring.current + ":"
+	wrappedKey, err := seal(ring.keys[ring.current], dataKey, []byte(header))
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataKey, []byte(plaintext), []byte(header))
+	if err != nil {
+		return "", err
+	}
+
+	return header + base64.StdEncoding.EncodeToString(wrappedKey) + ":" + base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// decryptWith decrypts a value, unwrapping its data key with the master key
+// it names.
+func decryptWith(ring *keyring, stored string) (string, error) {
+	if !IsEncrypted(stored) {
+		return stored, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(stored, prefix), ":")
+	if len(parts) != 3 {
+		return "", ErrMalformed
+	}
+	keyId := parts[0]
+	masterKey, ok := ring.keys[keyId]
+	if !ok {
+		return "", fmt.Errorf("%w %s", ErrUnknownKey, keyId)
+	}
+	wrappedKey, wrappedErr := base64.StdEncoding.DecodeString(parts[1])
+	ciphertext, ciphertextErr := base64.StdEncoding.DecodeString(parts[2])
+	if wrappedErr != nil || ciphertextErr != nil {
+		return "", ErrMalformed
+	}
+
+	header := prefix + keyId + ":"
+	dataKey, err := open(masterKey, wrappedKey, []byte(header))
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataKey, ciphertext, []byte(header))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// IsEncrypted checks if a stored value was encrypted.
+func IsEncrypted(stored string) bool {
+	return strings.HasPrefix(stored, prefix)
+}
+
+// KeyID returns the ID of the master key a stored value was encrypted with,
+// or an empty string for plaintext.
+func KeyID(stored string) string {
+	if !IsEncrypted(stored) {
+		return ""
+	}
+	keyId, _, _ := strings.Cut(strings.TrimPrefix(stored, prefix), ":")
+	return keyId
+}
+
+// NeedsReencrypt checks if a stored value is plaintext or was encrypted with
+// a master key that is no longer current.
+func NeedsReencrypt(stored string) (bool, error) {
+	ring, err := getKeyring()
+	if err != nil {
+		return false, err
+	}
+	return stored != "" && KeyID(stored) != ring.current, nil
+}
+
+// EncryptValue encrypts a value for storage. Empty values stay empty so
+// unanswered fields still look unanswered.
+func EncryptValue(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	ring, err := getKeyring()
+	if err != nil {
+		return "", err
+	}
+	return encryptWith(ring, plaintext)
+}
+
+// Encrypt is EncryptValue for building queries. Nothing should be saved if
+// it fails, since falling back to storing plaintext would be worse.
+func Encrypt(plaintext string) (string, error) {
+	return encrypt(plaintext)
+}
+
+// Decrypt decrypts a stored value. Plaintext values are returned as is.
+// Nothing built from a value that fails should be saved back, or the blank
+// would overwrite what's stored.
+func Decrypt(stored string) (string, error) {
+	if !IsEncrypted(stored) {
+		return stored, nil
+	}
+	ring, err := getKeyring()
+	if err != nil {
+		return "", err
+	}
+	return decryptWith(ring, stored)
+}
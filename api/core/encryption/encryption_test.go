@@ -0,0 +1,117 @@
+package encryption
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testKeyring(t *testing.T, keys string, current string) *keyring {
+	ring, err := parseKeys(keys, current)
+	assert.NoError(t, err, "Failed to parse keys")
+	return ring
+}
+
+func key(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), keySize)))
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	ring := testKeyring(t, "a:"+key('a'), "")
+
+	stored, err := encryptWith(ring, "123456789")
+	assert.NoError(t, err, "Failed to encrypt")
+	assert.True(t, IsEncrypted(stored), "Value should be encrypted")
+	assert.NotContains(t, stored, "123456789", "Plaintext should not be stored")
+	assert.Equal(t, "a", KeyID(stored), "Bad key ID")
+
+	plaintext, err := decryptWith(ring, stored)
+	assert.NoError(t, err, "Failed to decrypt")
+	assert.Equal(t, "123456789", plaintext, "Bad plaintext")
+
+	again, _ := encryptWith(ring, "123456789")
+	assert.NotEqual(t, stored, again, "Every value should get its own data key")
+}
+
+func TestDecryptPlaintext(t *testing.T) {
+	ring := testKeyring(t, "a:"+key('a'), "")
+
+	plaintext, err := decryptWith(ring, "123456789")
+	assert.NoError(t, err, "Plaintext should pass through")
+	assert.Equal(t, "123456789", plaintext, "Plaintext should not change")
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldRing := testKeyring(t, "a:"+key('a'), "")
+	stored, _ := encryptWith(oldRing, "secret")
+
+	newRing := testKeyring(t, "a:"+key('a')+",b:"+key('b'), "")
+	assert.Equal(t, "b", newRing.current, "Last key should be current")
+
+	plaintext, err := decryptWith(newRing, stored)
+	assert.NoError(t, err, "Old values should still decrypt")
+	assert.Equal(t, "secret", plaintext, "Bad plaintext")
+
+	rotated, _ := encryptWith(newRing, plaintext)
+	assert.Equal(t, "b", KeyID(rotated), "New values should use the current key")
+
+	_, err = decryptWith(testKeyring(t, "b:"+key('b'), ""), stored)
+	assert.ErrorIs(t, err, ErrUnknownKey, "Removed keys should not decrypt")
+}
+
+func TestTamperedValue(t *testing.T) {
+	ring := testKeyring(t, "a:"+key('a')+",b:"+key('b'), "a")
+	stored, _ := encryptWith(ring, "secret")
+
+	// Claiming another master key breaks the authentication
+	tampered := strings.Replace(stored, "enc:v1:a:", "enc:v1:b:", 1)
+	_, err := decryptWith(ring, tampered)
+	assert.Error(t, err, "Tampered value should not decrypt")
+}
+
+func TestParseKeysErrors(t *testing.T) {
+	_, err := parseKeys("a", "")
+	assert.Error(t, err, "Keys need an ID")
+
+	_, err = parseKeys("a:"+base64.StdEncoding.EncodeToString([]byte("short")), "")
+	assert.Error(t, err, "Keys must be 32 bytes")
+
+	_, err = parseKeys("a:"+key('a'), "b")
+	assert.Error(t, err, "Current key must exist")
+}
+
+func TestLoadKeyringRequiresKeys(t *testing.T) {
+	t.Setenv("ENCRYPTION_MASTER_KEYS", "")
+	t.Setenv("ENCRYPTION_KEY_FILE", "")
+	_, err := loadKeyring()
+	assert.ErrorIs(t, err, ErrNoMasterKeys, "Missing keys should fail")
+
+	path := filepath.Join(t.TempDir(), "local_kms.json")
+	t.Setenv("ENCRYPTION_KEY_FILE", path)
+	_, err = loadKeyring()
+	assert.ErrorIs(t, err, ErrNoMasterKeys, "A missing key file should fail")
+	_, statErr := os.Stat(path)
+	assert.True(t, os.IsNotExist(statErr), "Keys should never be made up on the spot")
+}
+
+func TestKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "local_kms.json")
+	assert.NoError(t, WriteKeyFile(path), "Failed to write key file")
+	assert.Error(t, WriteKeyFile(path), "Existing key files should not be replaced")
+
+	t.Setenv("ENCRYPTION_MASTER_KEYS", "")
+	t.Setenv("ENCRYPTION_KEY_FILE", path)
+	ring, err := loadKeyring()
+	assert.NoError(t, err, "Failed to load key file")
+	assert.Equal(t, "local-1", ring.current, "Bad current key")
+
+	again, _ := loadKeyring()
+	stored, _ := encryptWith(ring, "secret")
+	plaintext, err := decryptWith(again, stored)
+	assert.NoError(t, err, "Every load of the key file should read the same data")
+	assert.Equal(t, "secret", plaintext, "Bad plaintext")
+}
@@ -0,0 +1,147 @@
+package encryption
+
+// Master keys that wrap the data keys. They come from the environment in
+// production, and from a local key file standing in for a KMS in
+// development.
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Master keys are AES-256 keys
+const keySize = 32
+
+var ErrNoMasterKeys = errors.New("no master keys configured")
+
+// keyring holds every master key by ID, and which one new data keys are
+// wrapped with. Old keys stay around so existing data can still be read
+// after rotating.
+type keyring struct {
+	current string
+	keys    map[string][]byte
+}
+
+// localKeyFile is the format of the local stand-in KMS key file.
+type localKeyFile struct {
+	Current string            `json:"current"`
+	Keys    map[string]string `json:"keys"`
+}
+
+func decodeKey(id string, encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("master key %s is not base64: %v", id, err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("master key %s must be %d bytes", id, keySize)
+	}
+	return key, nil
+}
+
+// parseKeys reads master keys in the form "id:base64key,id:base64key".
+func parseKeys(value string, current string) (*keyring, error) {
+	ring := &keyring{keys: map[string][]byte{}}
+	ids := []string{}
+	for _, entry := range strings.Split(value, ",") {
+		id, encoded, found := strings.Cut(strings.TrimSpace(entry), ":")
+		if !found || id == "" {
+			return nil, fmt.Errorf("master keys must be in the form id:base64key")
+		}
+		key, err := decodeKey(id, encoded)
+		if err != nil {
+			return nil, err
+		}
+		ring.keys[id] = key
+		ids = append(ids, id)
+	}
+
+	// The last key listed is the newest unless it's set explicitly
+	ring.current = current
+	if ring.current == "" {
+		ring.current = ids[len(ids)-1]
+	}
+	if _, ok := ring.keys[ring.current]; !ok {
+		return nil, fmt.Errorf("current master key %s was not found", ring.current)
+	}
+	return ring, nil
+}
+
+// loadKeyFile reads the local stand-in KMS key file. It has to exist, since
+// a key made up on the spot couldn't read anything stored before.
+func loadKeyFile(path string) (*keyring, error) {
+	contents, readErr := os.ReadFile(path)
+	if os.IsNotExist(readErr) {
+		return nil, fmt.Errorf("%w: key file %s doesn't exist, create it with go run ./core/reencrypt/ -new-key-file %s", ErrNoMasterKeys, path, path)
+	} else if readErr != nil {
+		return nil, readErr
+	}
+
+	var file localKeyFile
+	if err := json.Unmarshal(contents, &file); err != nil {
+		return nil, fmt.Errorf("failed to read key file %s: %v", path, err)
+	}
+	if len(file.Keys) == 0 {
+		return nil, fmt.Errorf("%w: key file %s has no keys", ErrNoMasterKeys, path)
+	}
+
+	ids := make([]string, 0, len(file.Keys))
+	for id := range file.Keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	entries := make([]string, len(ids))
+	for i, id := range ids {
+		entries[i] = id + ":" + file.Keys[id]
+	}
+	return parseKeys(strings.Join(entries, ","), file.Current)
+}
+
+// WriteKeyFile creates a local stand-in KMS key file with a new key, for
+// development. It won't replace an existing one.
+func WriteKeyFile(path string) error {
+	key, err := GenerateKey()
+	if err != nil {
+		return err
+	}
+	file := localKeyFile{
+		Current: "local-1",
+		Keys:    map[string]string{"local-1": key},
+	}
+	contents, _ := json.MarshalIndent(file, "", "  ")
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = out.Write(contents)
+	return err
+}
+
+// loadKeyring gets the master keys from ENCRYPTION_MASTER_KEYS. Only in
+// development, with ENCRYPTION_KEY_FILE set, do they come from the local key
+// file instead.
+func loadKeyring() (*keyring, error) {
+	if keys := os.Getenv("ENCRYPTION_MASTER_KEYS"); keys != "" {
+		return parseKeys(keys, os.Getenv("ENCRYPTION_MASTER_KEY_ID"))
+	}
+	if path := os.Getenv("ENCRYPTION_KEY_FILE"); path != "" {
+		return loadKeyFile(path)
+	}
+	return nil, fmt.Errorf("%w: set ENCRYPTION_MASTER_KEYS", ErrNoMasterKeys)
+}
+
+// GenerateKey makes a new base64 master key for ENCRYPTION_MASTER_KEYS.
+func GenerateKey() (string, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
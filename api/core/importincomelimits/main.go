@@ -0,0 +1,131 @@
+package main
+
+// Loads HUD's income limits for a year from the CSV they publish, replacing
+// any limits already loaded for that year:
+//
+//	go run ./core/importincomelimits/ -file Section8-FY24.csv -year 2024 [-dry-run]
+
+import (
+	"api/core/server/ami"
+	db "api/db"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/joho/godotenv"
+)
+
+// stats counts what happened to the limits of each state.
+type stats struct {
+	states   int
+	counties int
+	rows     int
+	failed   int
+}
+
+func (s stats) String() string {
+	return fmt.Sprintf("%d states, %d counties, %d limits imported, %d states failed", s.states, s.counties, s.rows, s.failed)
+}
+
+// importState replaces the state's limits for the year in one transaction, so
+// a failed import leaves the old limits in place.
+func importState(client *db.PrismaClient, year int, state string, limits []ami.Limits) error {
+	txs := []db.PrismaTransaction{
+		client.IncomeLimit.FindMany(
+			db.IncomeLimit.Year.Equals(year),
+			db.IncomeLimit.State.Equals(state),
+		).Delete().Tx(),
+	}
+	for _, limit := range limits {
+		txs = append(txs, client.IncomeLimit.CreateOne(
+			db.IncomeLimit.Year.Set(limit.Year),
+			db.IncomeLimit.State.Set(limit.State),
+			db.IncomeLimit.CountyKey.Set(ami.CountyKey(limit.County)),
+			db.IncomeLimit.County.Set(limit.County),
+			db.IncomeLimit.MedianIncome.Set(limit.MedianIncome),
+			db.IncomeLimit.HouseholdSize.Set(limit.HouseholdSize),
+			db.IncomeLimit.ExtremelyLow.Set(limit.ExtremelyLow),
+			db.IncomeLimit.VeryLow.Set(limit.VeryLow),
+			db.IncomeLimit.Low.Set(limit.Low),
+			db.IncomeLimit.AreaName.Set(limit.AreaName),
+		).Tx())
+	}
+	return client.Prisma.Transaction(txs...).Exec(context.Background())
+}
+
+func importLimits(client *db.PrismaClient, year int, limits []ami.Limits, dryRun bool) stats {
+	var result stats
+	states := []string{}
+	byState := map[string][]ami.Limits{}
+	for _, limit := range limits {
+		if _, ok := byState[limit.State]; !ok {
+			states = append(states, limit.State)
+		}
+		byState[limit.State] = append(byState[limit.State], limit)
+	}
+
+	for _, state := range states {
+		stateLimits := byState[state]
+		if !dryRun {
+			if err := importState(client, year, state, stateLimits); err != nil {
+				fmt.Printf("[ERROR] Failed to import income limits for %s: %v\n", state, err)
+				result.failed++
+				continue
+			}
+		}
+		result.states++
+		result.counties += len(stateLimits) / ami.MaxHouseholdSize
+		result.rows += len(stateLimits)
+	}
+	return result
+}
+
+func main() {
+	file := flag.String("file", "", "HUD income limits CSV to import")
+	year := flag.Int("year", 0, "Fiscal year the limits are for")
+	dryRun := flag.Bool("dry-run", false, "Read the file and count the limits without importing them")
+	flag.Parse()
+
+	if *file == "" || *year == 0 {
+		fmt.Println("[ERROR] -file and -year are required")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	reader, err := os.Open(*file)
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to open %s: %v\n", *file, err)
+		os.Exit(1)
+	}
+	limits, err := ami.ParseCSV(reader, *year)
+	reader.Close()
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to read %s: %v\n", *file, err)
+		os.Exit(1)
+	}
+
+	var client *db.PrismaClient
+	if !*dryRun {
+		currentWorkDirectory, _ := os.Getwd()
+		godotenv.Load(currentWorkDirectory + "/../.env")
+
+		client = db.NewClient()
+		if err := client.Prisma.Connect(); err != nil {
+			fmt.Printf("[ERROR] Failed to connect to database: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	result := importLimits(client, *year, limits, *dryRun)
+	if client != nil {
+		client.Prisma.Disconnect()
+	}
+	fmt.Printf("IncomeLimit %d: %s\n", *year, result)
+	if *dryRun {
+		fmt.Println("Dry run, nothing was changed")
+	}
+	if result.failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+// Moves applications saved before clients could apply to more than one
+// program into a housing application each. The move in date and preference
+// rankings go to the new housing application, everything else stays shared
+// in ApplicationData. Safe to run more than once:
+//
+//	go run ./core/migrateapplications/ [-dry-run]
+
+import (
+	db "api/db"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/joho/godotenv"
+)
+
+// Rows are read in batches so large tables don't have to fit in memory.
+const batchSize = 100
+
+// stats counts what happened to the applications.
+type stats struct {
+	checked  int
+	migrated int
+	skipped  int
+	failed   int
+}
+
+func (s stats) String() string {
+	return fmt.Sprintf("%d checked, %d migrated, %d skipped, %d failed", s.checked, s.migrated, s.skipped, s.failed)
+}
+
+// migrateApplication gives the owner of the application data a housing
+// application with its preferences. Owners that already have a housing
+// application have saved since it was added, so their old preferences are
+// out of date and left alone. Returns whether anything was, or in a dry run
+// would be, migrated.
+func migrateApplication(client *db.PrismaClient, row *db.ApplicationDataModel, dryRun bool) (bool, error) {
+	existing, err := client.HousingApplication.FindFirst(
+		db.HousingApplication.UserID.Equals(row.UserID),
+	).Exec(context.Background())
+	if err != nil && err != db.ErrNotFound {
+		return false, err
+	}
+	if existing != nil {
+		return false, nil
+	}
+	if dryRun {
+		return true, nil
+	}
+
+	rankings, err := client.HousingPreferenceRanking.FindMany(
+		db.HousingPreferenceRanking.ApplicationID.Equals(row.ID),
+	).Exec(context.Background())
+	if err != nil {
+		return false, err
+	}
+
+	// Moved in one transaction, so a failed run can't leave a housing
+	// application without its rankings, which the next run would skip
+	ids := make([]int, len(rankings))
+	links := make([]db.HousingPreferenceRankingWhereParam, len(rankings))
+	for i, ranking := range rankings {
+		ids[i] = ranking.ID
+		links[i] = db.HousingPreferenceRanking.ID.Equals(ranking.ID)
+	}
+	params := []db.HousingApplicationSetParam{
+		db.HousingApplication.DesiredMoveInDate.Set(row.DesiredMoveInDate),
+	}
+	if len(links) > 0 {
+		params = append(params, db.HousingApplication.HousingPreferenceRankings.Link(links...))
+	}
+	txs := []db.PrismaTransaction{
+		client.HousingApplication.CreateOne(
+			db.HousingApplication.User.Link(
+				db.User.ID.Equals(row.UserID),
+			),
+			params...,
+		).Tx(),
+		client.HousingPreferenceRanking.FindMany(
+			db.HousingPreferenceRanking.ID.In(ids),
+		).Update(
+			db.HousingPreferenceRanking.ApplicationID.SetOptional(nil),
+		).Tx(),
+	}
+	if err := client.Prisma.Transaction(txs...).Exec(context.Background()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func migrateApplications(client *db.PrismaClient, dryRun bool) (stats, error) {
+	var result stats
+	lastId := 0
+	for {
+		rows, err := client.ApplicationData.FindMany(
+			db.ApplicationData.ID.Gt(lastId),
+		).OrderBy(
+			db.ApplicationData.ID.Order(db.SortOrderAsc),
+		).Take(batchSize).Exec(context.Background())
+		if err != nil {
+			return result, err
+		}
+		if len(rows) == 0 {
+			return result, nil
+		}
+
+		for _, row := range rows {
+			lastId = row.ID
+			result.checked++
+
+			migrated, err := migrateApplication(client, &row, dryRun)
+			if err != nil {
+				fmt.Printf("[ERROR] Failed to migrate application data %d: %v\n", row.ID, err)
+				result.failed++
+				continue
+			}
+			if migrated {
+				result.migrated++
+			} else {
+				result.skipped++
+			}
+		}
+	}
+}
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "Count the applications that need migrating without changing them")
+	flag.Parse()
+
+	currentWorkDirectory, _ := os.Getwd()
+	godotenv.Load(currentWorkDirectory + "/../.env")
+
+	client := db.NewClient()
+	if err := client.Prisma.Connect(); err != nil {
+		fmt.Printf("[ERROR] Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+
+	result, err := migrateApplications(client, *dryRun)
+	client.Prisma.Disconnect()
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to migrate applications: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("ApplicationData: %s\n", result)
+	if *dryRun {
+		fmt.Println("Dry run, nothing was changed")
+	}
+	if result.failed > 0 {
+		os.Exit(1)
+	}
+}
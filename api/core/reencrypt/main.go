@@ -0,0 +1,371 @@
+package main
+
+// Re-encrypts sensitive fields with the current master key. Run this after
+// adding a new master key, or once to encrypt rows stored before field
+// encryption was added:
+//
+//	go run ./core/reencrypt/ [-dry-run]
+//
+// For development, it also makes the local key file:
+//
+//	go run ./core/reencrypt/ -new-key-file local_kms.json
+
+import (
+	"api/core/encryption"
+	db "api/db"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/joho/godotenv"
+)
+
+// Rows are read in batches so large tables don't have to fit in memory.
+const batchSize = 100
+
+// stats counts what happened to the rows of one table.
+type stats struct {
+	checked int
+	updated int
+	failed  int
+}
+
+func (s stats) String() string {
+	return fmt.Sprintf("%d checked, %d re-encrypted, %d failed", s.checked, s.updated, s.failed)
+}
+
+// reencryptValues re-encrypts the values that are plaintext or were
+// encrypted with an old master key. Returns whether anything changed.
+func reencryptValues(values ...*string) (bool, error) {
+	changed := false
+	for _, value := range values {
+		needsReencrypt, err := encryption.NeedsReencrypt(*value)
+		if err != nil {
+			return false, err
+		}
+		if !needsReencrypt {
+			continue
+		}
+
+		plaintext, err := encryption.Decrypt(*value)
+		if err != nil {
+			return false, err
+		}
+		*value, err = encryption.EncryptValue(plaintext)
+		if err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+func reencryptApplications(client *db.PrismaClient, dryRun bool) (stats, error) {
+	var result stats
+	lastId := 0
+	for {
+		rows, err := client.ApplicationData.FindMany(
+			db.ApplicationData.ID.Gt(lastId),
+		).OrderBy(
+			db.ApplicationData.ID.Order(db.SortOrderAsc),
+		).Take(batchSize).Exec(context.Background())
+		if err != nil {
+			return result, err
+		}
+		if len(rows) == 0 {
+			return result, nil
+		}
+
+		for _, row := range rows {
+			lastId = row.ID
+			result.checked++
+
+			ssn := row.Ssn
+			changed, err := reencryptValues(&ssn)
+			if err != nil {
+				fmt.Printf("[ERROR] Failed to re-encrypt application data %d: %v\n", row.ID, err)
+				result.failed++
+				continue
+			}
+			if !changed {
+				continue
+			}
+			result.updated++
+			if dryRun {
+				continue
+			}
+
+			_, err = client.ApplicationData.FindUnique(
+				db.ApplicationData.ID.Equals(row.ID),
+			).Update(
+				db.ApplicationData.Ssn.Set(ssn),
+			).Exec(context.Background())
+			if err != nil {
+				fmt.Printf("[ERROR] Failed to update application data %d: %v\n", row.ID, err)
+				result.failed++
+			}
+		}
+	}
+}
+
+func reencryptFamilyMembers(client *db.PrismaClient, dryRun bool) (stats, error) {
+	var result stats
+	lastId := 0
+	for {
+		rows, err := client.FamilyMember.FindMany(
+			db.FamilyMember.ID.Gt(lastId),
+		).OrderBy(
+			db.FamilyMember.ID.Order(db.SortOrderAsc),
+		).Take(batchSize).Exec(context.Background())
+		if err != nil {
+			return result, err
+		}
+		if len(rows) == 0 {
+			return result, nil
+		}
+
+		for _, row := range rows {
+			lastId = row.ID
+			result.checked++
+
+			ssn := row.Ssn
+			changed, err := reencryptValues(&ssn)
+			if err != nil {
+				fmt.Printf("[ERROR] Failed to re-encrypt family member %d: %v\n", row.ID, err)
+				result.failed++
+				continue
+			}
+			if !changed {
+				continue
+			}
+			result.updated++
+			if dryRun {
+				continue
+			}
+
+			_, err = client.FamilyMember.FindUnique(
+				db.FamilyMember.ID.Equals(row.ID),
+			).Update(
+				db.FamilyMember.Ssn.Set(ssn),
+			).Exec(context.Background())
+			if err != nil {
+				fmt.Printf("[ERROR] Failed to update family member %d: %v\n", row.ID, err)
+				result.failed++
+			}
+		}
+	}
+}
+
+func reencryptCrimes(client *db.PrismaClient, dryRun bool) (stats, error) {
+	var result stats
+	lastId := 0
+	for {
+		rows, err := client.CrimeEntry.FindMany(
+			db.CrimeEntry.ID.Gt(lastId),
+		).OrderBy(
+			db.CrimeEntry.ID.Order(db.SortOrderAsc),
+		).Take(batchSize).Exec(context.Background())
+		if err != nil {
+			return result, err
+		}
+		if len(rows) == 0 {
+			return result, nil
+		}
+
+		for _, row := range rows {
+			lastId = row.ID
+			result.checked++
+
+			year, crime, city, state := row.Year, row.Crime, row.City, row.State
+			changed, err := reencryptValues(&year, &crime, &city, &state)
+			if err != nil {
+				fmt.Printf("[ERROR] Failed to re-encrypt crime entry %d: %v\n", row.ID, err)
+				result.failed++
+				continue
+			}
+			if !changed {
+				continue
+			}
+			result.updated++
+			if dryRun {
+				continue
+			}
+
+			_, err = client.CrimeEntry.FindUnique(
+				db.CrimeEntry.ID.Equals(row.ID),
+			).Update(
+				db.CrimeEntry.Year.Set(year),
+				db.CrimeEntry.Crime.Set(crime),
+				db.CrimeEntry.City.Set(city),
+				db.CrimeEntry.State.Set(state),
+			).Exec(context.Background())
+			if err != nil {
+				fmt.Printf("[ERROR] Failed to update crime entry %d: %v\n", row.ID, err)
+				result.failed++
+			}
+		}
+	}
+}
+
+func reencryptIncome(client *db.PrismaClient, dryRun bool) (stats, error) {
+	var result stats
+	lastId := 0
+	for {
+		rows, err := client.IncomeAssetEntry.FindMany(
+			db.IncomeAssetEntry.ID.Gt(lastId),
+		).OrderBy(
+			db.IncomeAssetEntry.ID.Order(db.SortOrderAsc),
+		).Take(batchSize).Exec(context.Background())
+		if err != nil {
+			return result, err
+		}
+		if len(rows) == 0 {
+			return result, nil
+		}
+
+		for _, row := range rows {
+			lastId = row.ID
+			result.checked++
+
+			amount, monthlyOrValue := row.Amount, row.MonthlyOrValue
+			changed, err := reencryptValues(&amount, &monthlyOrValue)
+			if err != nil {
+				fmt.Printf("[ERROR] Failed to re-encrypt income entry %d: %v\n", row.ID, err)
+				result.failed++
+				continue
+			}
+			if !changed {
+				continue
+			}
+			result.updated++
+			if dryRun {
+				continue
+			}
+
+			_, err = client.IncomeAssetEntry.FindUnique(
+				db.IncomeAssetEntry.ID.Equals(row.ID),
+			).Update(
+				db.IncomeAssetEntry.Amount.Set(amount),
+				db.IncomeAssetEntry.MonthlyOrValue.Set(monthlyOrValue),
+			).Exec(context.Background())
+			if err != nil {
+				fmt.Printf("[ERROR] Failed to update income entry %d: %v\n", row.ID, err)
+				result.failed++
+			}
+		}
+	}
+}
+
+// reencryptVersions re-encrypts the application snapshots saved for the
+// version history, which hold every SSN in the application at the time.
+func reencryptVersions(client *db.PrismaClient, dryRun bool) (stats, error) {
+	var result stats
+	lastId := 0
+	for {
+		rows, err := client.ApplicationVersion.FindMany(
+			db.ApplicationVersion.ID.Gt(lastId),
+		).OrderBy(
+			db.ApplicationVersion.ID.Order(db.SortOrderAsc),
+		).Take(batchSize).Exec(context.Background())
+		if err != nil {
+			return result, err
+		}
+		if len(rows) == 0 {
+			return result, nil
+		}
+
+		for _, row := range rows {
+			lastId = row.ID
+			result.checked++
+
+			data := row.Data
+			changed, err := reencryptValues(&data)
+			if err != nil {
+				fmt.Printf("[ERROR] Failed to re-encrypt application version %d: %v\n", row.ID, err)
+				result.failed++
+				continue
+			}
+			if !changed {
+				continue
+			}
+			result.updated++
+			if dryRun {
+				continue
+			}
+
+			_, err = client.ApplicationVersion.FindUnique(
+				db.ApplicationVersion.ID.Equals(row.ID),
+			).Update(
+				db.ApplicationVersion.Data.Set(data),
+			).Exec(context.Background())
+			if err != nil {
+				fmt.Printf("[ERROR] Failed to update application version %d: %v\n", row.ID, err)
+				result.failed++
+			}
+		}
+	}
+}
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "Count the rows that need re-encrypting without changing them")
+	newKeyFile := flag.String("new-key-file", "", "Create a local key file with a new master key for development, then exit")
+	flag.Parse()
+
+	if *newKeyFile != "" {
+		if err := encryption.WriteKeyFile(*newKeyFile); err != nil {
+			fmt.Printf("[ERROR] Failed to create key file: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Created %s, set ENCRYPTION_KEY_FILE=%s to use it\n", *newKeyFile, *newKeyFile)
+		return
+	}
+
+	currentWorkDirectory, _ := os.Getwd()
+	godotenv.Load(currentWorkDirectory + "/../.env")
+
+	if err := encryption.Setup(); err != nil {
+		fmt.Printf("[ERROR] Failed to load master keys: %v\n", err)
+		os.Exit(1)
+	}
+
+	client := db.NewClient()
+	if err := client.Prisma.Connect(); err != nil {
+		fmt.Printf("[ERROR] Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+
+	tables := []struct {
+		name      string
+		reencrypt func(*db.PrismaClient, bool) (stats, error)
+	}{
+		{"ApplicationData", reencryptApplications},
+		{"FamilyMember", reencryptFamilyMembers},
+		{"CrimeEntry", reencryptCrimes},
+		{"IncomeAssetEntry", reencryptIncome},
+		{"ApplicationVersion", reencryptVersions},
+	}
+
+	failed := false
+	for _, table := range tables {
+		result, err := table.reencrypt(client, *dryRun)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to re-encrypt %s: %v\n", table.name, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("%s: %s\n", table.name, result)
+		if result.failed > 0 {
+			failed = true
+		}
+	}
+
+	client.Prisma.Disconnect()
+
+	if *dryRun {
+		fmt.Println("Dry run, nothing was changed")
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+// Re-encryption unit tests
+
+import (
+	"api/core/encryption"
+	"api/db"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReencryptVersions(t *testing.T) {
+	t.Setenv("ENCRYPTION_MASTER_KEYS", "a:"+base64.StdEncoding.EncodeToString([]byte(strings.Repeat("a", 32))))
+	assert.NoError(t, encryption.Setup(), "Failed to load master keys")
+
+	current, encryptErr := encryption.EncryptValue(`{"personal_info":{"ssn":"123456789"}}`)
+	assert.NoError(t, encryptErr, "Failed to encrypt snapshot")
+
+	client, mock, ensure := db.NewMock()
+	defer ensure(t)
+
+	versions := []db.ApplicationVersionModel{
+		{InnerApplicationVersion: db.InnerApplicationVersion{ID: 1, Data: `{"personal_info":{"ssn":"987654321"}}`}},
+		{InnerApplicationVersion: db.InnerApplicationVersion{ID: 2, Data: current}},
+	}
+	mock.ApplicationVersion.Expect(
+		client.ApplicationVersion.FindMany(
+			db.ApplicationVersion.ID.Gt(0),
+		).OrderBy(
+			db.ApplicationVersion.ID.Order(db.SortOrderAsc),
+		).Take(batchSize),
+	).ReturnsMany(versions)
+	mock.ApplicationVersion.Expect(
+		client.ApplicationVersion.FindMany(
+			db.ApplicationVersion.ID.Gt(2),
+		).OrderBy(
+			db.ApplicationVersion.ID.Order(db.SortOrderAsc),
+		).Take(batchSize),
+	).ReturnsMany([]db.ApplicationVersionModel{})
+
+	result, err := reencryptVersions(client, true)
+	assert.NoError(t, err, "Failed to re-encrypt versions")
+	assert.Equal(t, stats{checked: 2, updated: 1}, result, "Only the plaintext snapshot should need re-encrypting")
+}
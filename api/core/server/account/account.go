@@ -102,6 +102,9 @@ type Validate2FAAuthResponse struct {
 
 type DeleteAccountRequest struct {
 	Email string `json:"email"`
+	// Caseworkers have to either transfer their clients first or confirm
+	// the clients should be released without a caseworker.
+	ReleaseCaseload bool `json:"release_caseload"`
 }
 
 type DeleteAccountResponse struct {
@@ -115,6 +118,7 @@ type LinkAccountsRequest struct {
 
 type LinkAccountsResponse struct {
 	Success bool   `json:"success"`
+	Status  string `json:"status"`
 	Error   string `json:"error"`
 }
 
@@ -769,34 +773,44 @@ func CreateDefaultCWAccount(client *db.PrismaClient) (*db.UserModel, error) {
 	).Exec(context.Background())
 }
 
-func GetAccountLinkCode(c echo.Context, client *db.PrismaClient) error {
-	user := ValidateAuth(c, client)
-	if user == nil {
-		return c.JSON(500, GetAccountLinkResponse{Success: false, Code: "", Error: "Failed to authenticate"})
-	}
-
+// GetOrCreateLinkCode returns the link code for a user, assigning a new one
+// if the user doesn't have one yet.
+func GetOrCreateLinkCode(client *db.PrismaClient, user *db.UserModel) (string, error) {
 	link, linkErr := client.UserLinkCode.FindUnique(
 		db.UserLinkCode.UserID.Equals(user.ID),
 	).Exec(context.Background())
 
 	if linkErr == nil {
 		fmt.Printf("Reusing link code: %s\n", link.Code)
-		return c.JSON(200, GetAccountLinkResponse{Success: true, Code: link.Code})
-	} else {
-		code := generateCode(12)
-		fmt.Printf("Assigning new link code: %s\n", code)
-		_, linkErr := client.UserLinkCode.CreateOne(
-			db.UserLinkCode.User.Link(
-				db.User.ID.Equals(user.ID),
-			),
-			db.UserLinkCode.Code.Set(code),
-		).Exec(context.Background())
+		return link.Code, nil
+	}
 
-		if linkErr != nil {
-			return c.JSON(500, GetAccountLinkResponse{Success: false, Code: "", Error: "Failed to generate link code"})
-		}
-		return c.JSON(200, GetAccountLinkResponse{Success: true, Code: code})
+	code := generateCode(12)
+	fmt.Printf("Assigning new link code: %s\n", code)
+	_, linkErr = client.UserLinkCode.CreateOne(
+		db.UserLinkCode.User.Link(
+			db.User.ID.Equals(user.ID),
+		),
+		db.UserLinkCode.Code.Set(code),
+	).Exec(context.Background())
+
+	if linkErr != nil {
+		return "", linkErr
 	}
+	return code, nil
+}
+
+func GetAccountLinkCode(c echo.Context, client *db.PrismaClient) error {
+	user := ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(500, GetAccountLinkResponse{Success: false, Code: "", Error: "Failed to authenticate"})
+	}
+
+	code, codeErr := GetOrCreateLinkCode(client, user)
+	if codeErr != nil {
+		return c.JSON(500, GetAccountLinkResponse{Success: false, Code: "", Error: "Failed to generate link code"})
+	}
+	return c.JSON(200, GetAccountLinkResponse{Success: true, Code: code})
 }
 
 func GetAccountLinkCodeMeta(c echo.Context, client *db.PrismaClient) error {
@@ -840,8 +854,6 @@ func DeleteAccount(c echo.Context, client *db.PrismaClient) error {
 		})
 	}
 
-	fmt.Println("DELETE endpoint hit")
-	fmt.Println("DEBUG: Received request to delete account for email:", request.Email)
 
 	// find the user by email (include personal info ID)
 	user, userErr := client.User.FindUnique(
@@ -854,10 +866,26 @@ func DeleteAccount(c echo.Context, client *db.PrismaClient) error {
 		})
 	}
 
-	fmt.Println("DEBUG: Found user ID", user.ID, "with personalInfoID", user.PersonalInfoID)
+
+	if user.Type == db.UserTypeCaseWorker && !request.ReleaseCaseload {
+		caseload, caseloadErr := client.UserLink.FindMany(
+			db.UserLink.CaseworkerID.Equals(user.ID),
+		).Exec(context.Background())
+		if caseloadErr != nil {
+			return c.JSON(http.StatusInternalServerError, DeleteAccountResponse{
+				Success: false,
+				Error:   "Failed to check caseload",
+			})
+		}
+		if len(caseload) > 0 {
+			return c.JSON(http.StatusConflict, DeleteAccountResponse{
+				Success: false,
+				Error:   "Caseload must be transferred or released before deleting account",
+			})
+		}
+	}
 
 	// delete TwoFactorCodes
-	fmt.Println("DEBUG: Deleting TwoFactorCodes...")
 	_, err = client.TwoFactorCode.FindMany(
 		db.TwoFactorCode.UserID.Equals(user.ID),
 	).Delete().Exec(context.Background())
@@ -869,7 +897,6 @@ func DeleteAccount(c echo.Context, client *db.PrismaClient) error {
 	}
 
 	// delete UserLink (if exists)
-	fmt.Println("DEBUG: Deleting UserLink as client...")
 	_, err = client.UserLink.
 		FindMany(
 			db.UserLink.ClientID.Equals(user.ID),
@@ -883,7 +910,6 @@ func DeleteAccount(c echo.Context, client *db.PrismaClient) error {
 		})
 	}
 
-	fmt.Println("DEBUG: Deleting UserLink as caseworker...")
 	_, err = client.UserLink.
 		FindMany(
 			db.UserLink.CaseworkerID.Equals(user.ID),
@@ -897,9 +923,61 @@ func DeleteAccount(c echo.Context, client *db.PrismaClient) error {
 		})
 	}
 
+	_, err = client.LinkRequest.FindMany(
+		db.LinkRequest.Or(
+			db.LinkRequest.ClientID.Equals(user.ID),
+			db.LinkRequest.CaseworkerID.Equals(user.ID),
+		),
+	).Delete().Exec(context.Background())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, DeleteAccountResponse{
+			Success: false,
+			Error:   "Failed to delete link requests",
+		})
+	}
+
+	_, err = client.TrustedOrganization.FindMany(
+		db.TrustedOrganization.UserID.Equals(user.ID),
+	).Delete().Exec(context.Background())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, DeleteAccountResponse{
+			Success: false,
+			Error:   "Failed to delete trusted organizations",
+		})
+	}
+
+	_, err = client.CaseloadTransfer.FindMany(
+		db.CaseloadTransfer.ClientID.Equals(user.ID),
+	).Delete().Exec(context.Background())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, DeleteAccountResponse{
+			Success: false,
+			Error:   "Failed to delete caseload transfers",
+		})
+	}
+
+	_, err = client.CaseNote.FindMany(
+		db.CaseNote.ClientID.Equals(user.ID),
+	).Delete().Exec(context.Background())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, DeleteAccountResponse{
+			Success: false,
+			Error:   "Failed to delete case notes",
+		})
+	}
+
+	_, err = client.CaseTask.FindMany(
+		db.CaseTask.ClientID.Equals(user.ID),
+	).Delete().Exec(context.Background())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, DeleteAccountResponse{
+			Success: false,
+			Error:   "Failed to delete case tasks",
+		})
+	}
+
 	// delete FamilyLinks for user's personalInfo (if exists)
 	if user.PersonalInfoID != 0 {
-		fmt.Println("DEBUG: Deleting FamilyLinks for personalInfoID:", user.PersonalInfoID)
 		_, err = client.FamilyLink.FindMany(
 			db.FamilyLink.PersonalInfoID.Equals(user.PersonalInfoID),
 		).Delete().Exec(context.Background())
@@ -911,7 +989,6 @@ func DeleteAccount(c echo.Context, client *db.PrismaClient) error {
 		}
 	}
 
-	fmt.Printf("DEBUG: Deleting UserLink Codes...\n")
 	_, err = client.UserLinkCode.FindMany(
 		db.UserLinkCode.UserID.Equals(user.ID),
 	).Delete().Exec(context.Background())
@@ -1035,8 +1112,19 @@ func DeleteAccount(c echo.Context, client *db.PrismaClient) error {
 		})
 	}
 
+	// Their preferences and status history go with them
+	_, delHousingAppsErr := client.HousingApplication.FindMany(
+		db.HousingApplication.UserID.Equals(user.ID),
+	).Delete().Exec(context.Background())
+	if delHousingAppsErr != nil {
+		fmt.Printf("[ERROR] Failed to delete housing applications for user %d: %v\n", user.ID, delHousingAppsErr)
+		return c.JSON(http.StatusInternalServerError, DeleteAccountResponse{
+			Success: false,
+			Error:   "Failed to delete applications",
+		})
+	}
+
 	// delete the User
-	fmt.Println("DEBUG: Deleting User...")
 	_, err = client.User.FindUnique(
 		db.User.ID.Equals(user.ID),
 	).Delete().Exec(context.Background())
@@ -1050,12 +1138,11 @@ func DeleteAccount(c echo.Context, client *db.PrismaClient) error {
 
 	// delete PersonalInfo (after deleting User)
 	if user.PersonalInfoID != 0 {
-		fmt.Println("DEBUG: Deleting PersonalInfo...")
 		_, err = client.PersonalInfo.FindUnique(
 			db.PersonalInfo.ID.Equals(user.PersonalInfoID),
 		).Delete().Exec(context.Background())
 		if err != nil {
-			fmt.Println("DEBUG: Failed to delete PersonalInfo:", err)
+			fmt.Printf("[ERROR] Failed to delete personal info for user %d: %v\n", user.ID, err)
 			return c.JSON(http.StatusInternalServerError, DeleteAccountResponse{
 				Success: false,
 				Error:   "Failed to delete personal info",
@@ -1090,24 +1177,41 @@ func LinkAccountsHandler(c echo.Context, client *db.PrismaClient) error {
 		return c.JSON(500, LinkAccountsResponse{Success: false, Error: "Failed to find link owner"})
 	}
 
-	if userA.Type != db.UserTypeCaseWorker || linkOwner.User().Type != db.UserTypeClient {
+	linkClient := linkOwner.User()
+	if userA.Type != db.UserTypeCaseWorker || linkClient.Type != db.UserTypeClient {
 		return c.JSON(500, LinkAccountsResponse{Success: false, Error: "Invalid account types"})
 	}
 
-	_, userLinkErr := client.UserLink.CreateOne(
-		db.UserLink.Client.Link(
-			db.User.ID.Equals(linkOwner.User().ID),
-		),
-		db.UserLink.Caseworker.Link(
-			db.User.ID.Equals(userA.ID),
+	_, existingErr := client.UserLink.FindUnique(
+		db.UserLink.UserlinkID(
+			db.UserLink.ClientID.Equals(linkClient.ID),
+			db.UserLink.CaseworkerID.Equals(userA.ID),
 		),
 	).Exec(context.Background())
+	if existingErr == nil {
+		return c.JSON(400, LinkAccountsResponse{Success: false, Error: "Accounts are already linked"})
+	}
 
-	if userLinkErr != nil {
+	trusted, trustedErr := isTrustedCaseworker(client, linkClient, userA)
+	if trustedErr != nil {
+		fmt.Printf("[ERROR] Failed to get trusted organizations for user %d: %v\n", linkClient.ID, trustedErr)
 		return c.JSON(500, LinkAccountsResponse{Success: false, Error: "Failed to link accounts"})
 	}
 
-	return c.JSON(200, LinkAccountsResponse{Success: true, Error: ""})
+	// Clients can skip approving caseworkers from organizations they trust
+	if trusted {
+		if userLinkErr := createLink(client, linkClient.ID, userA.ID); userLinkErr != nil {
+			return c.JSON(500, LinkAccountsResponse{Success: false, Error: "Failed to link accounts"})
+		}
+		return c.JSON(200, LinkAccountsResponse{Success: true, Status: string(db.LinkRequestStatusApproved), Error: ""})
+	}
+
+	if requestErr := createLinkRequest(client, linkClient, userA); requestErr != nil {
+		fmt.Printf("[ERROR] Failed to create link request for user %d: %v\n", linkClient.ID, requestErr)
+		return c.JSON(500, LinkAccountsResponse{Success: false, Error: "Failed to request link"})
+	}
+
+	return c.JSON(200, LinkAccountsResponse{Success: true, Status: string(db.LinkRequestStatusPending), Error: ""})
 }
 
 func getLinkedClients(client *db.PrismaClient, user *db.UserModel) ([]BaseUserInfo, error) {
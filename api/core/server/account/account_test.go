@@ -44,14 +44,20 @@ var mock_userA = db.UserModel{
 var mock_linkCode = "abcdefghijkl"
 var mock_2faCode = "123456"
 var mock_2faExpiry = time.Now().Add(time.Hour * 50)
+var mock_now = time.Date(2025, time.April, 1, 12, 0, 0, 0, time.UTC)
 
 // setup initializes state for running unit tests,
 // then returns a function pointer for teardown
 func setup() func() {
 	accountMockTeardown := Mock(mock_lastAuth, mock_authCode, mock_linkCode, mock_2faCode, mock_2faExpiry)
+	ogNow := now
+	now = func() time.Time {
+		return mock_now
+	}
 
 	return func() {
 		accountMockTeardown()
+		now = ogNow
 	}
 }
 
@@ -813,12 +819,138 @@ func TestLinkAccounts(t *testing.T) {
 	}
 	// userClient.RelationsUser.LinkCode = &clientLinkCode
 
+	linkRequest := db.LinkRequestModel{
+		InnerLinkRequest: db.InnerLinkRequest{
+			ID:           1,
+			ClientID:     userClient.ID,
+			CaseworkerID: userCaseworker.ID,
+			Status:       db.LinkRequestStatusPending,
+			CreatedAt:    mock_now,
+			ExpiresAt:    mock_now.Add(linkRequestExpiry),
+		},
+	}
+
+	// Mock auth
+	mock.User.Expect(
+		client.User.FindFirst(
+			db.User.Email.Equals(userCaseworker.InnerUser.Email),
+			db.User.AuthCode.Equals(*userCaseworker.InnerUser.AuthCode),
+		),
+	).Returns(userCaseworker)
+
+	// Mock find userlink code
+	mock.UserLinkCode.Expect(
+		client.UserLinkCode.FindUnique(
+			db.UserLinkCode.Code.Equals(mock_linkCode),
+		).With(
+			db.UserLinkCode.User.Fetch(),
+		),
+	).Returns(clientLinkCode)
+
+	// Mock check for an existing link
+	mock.UserLink.Expect(
+		client.UserLink.FindUnique(
+			db.UserLink.UserlinkID(
+				db.UserLink.ClientID.Equals(userClient.ID),
+				db.UserLink.CaseworkerID.Equals(userCaseworker.ID),
+			),
+		),
+	).Errors(db.ErrNotFound)
+
+	// Mock find trusted organizations, the client trusts none
+	mock.TrustedOrganization.Expect(
+		client.TrustedOrganization.FindMany(
+			db.TrustedOrganization.UserID.Equals(userClient.ID),
+		),
+	).ReturnsMany([]db.TrustedOrganizationModel{})
+
+	// Mock check for an open link request
+	mock.LinkRequest.Expect(
+		client.LinkRequest.FindFirst(
+			db.LinkRequest.ClientID.Equals(userClient.ID),
+			db.LinkRequest.CaseworkerID.Equals(userCaseworker.ID),
+			db.LinkRequest.Status.Equals(db.LinkRequestStatusPending),
+		),
+	).Errors(db.ErrNotFound)
+
+	// Mock create link request
+	mock.LinkRequest.Expect(
+		client.LinkRequest.CreateOne(
+			db.LinkRequest.Client.Link(
+				db.User.ID.Equals(userClient.ID),
+			),
+			db.LinkRequest.Caseworker.Link(
+				db.User.ID.Equals(userCaseworker.ID),
+			),
+			db.LinkRequest.ExpiresAt.Set(mock_now.Add(linkRequestExpiry)),
+		),
+	).Returns(linkRequest)
+
+	// Mock find caseworker name for the email
+	mock.PersonalInfo.Expect(
+		client.PersonalInfo.FindUnique(
+			db.PersonalInfo.ID.Equals(userCaseworker.PersonalInfoID),
+		),
+	).Returns(mock_personalDataA)
+
+	request := LinkAccountsRequest{
+		LinkCode: mock_linkCode,
+	}
+
+	rec, c, reqErr := testutil.PrepareRequestJSON(http.MethodPost, "/api/account/link", request)
+	c.Request().Header.Set(EmailHeaderKey, userCaseworker.InnerUser.Email)
+	c.Request().Header.Set(AuthHeaderKey, *userCaseworker.InnerUser.AuthCode)
+	assert.NoError(t, reqErr, "Failed to prepare request")
+	assert.NoError(t, LinkAccountsHandler(c, client), "Error while creating link")
+	assert.Equal(t, 200, rec.Code, "Bad status code")
+
+	var response LinkAccountsResponse
+	unmarshalErr := json.Unmarshal(rec.Body.Bytes(), &response)
+	assert.Nil(t, unmarshalErr, "Error while parsing response body")
+
+	assert.Empty(t, response.Error, "Error message found")
+	assert.True(t, response.Success, "Unsuccessful")
+	assert.Equal(t, string(db.LinkRequestStatusPending), response.Status, "Link should wait for approval")
+}
+
+func TestLinkAccountsTrusted(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	// client := db.NewClient()
+	client, mock, ensure := db.NewMock()
+	defer ensure(t)
+
+	userClient := mock_userA
+	userCaseworker := mock_userA
+	userCaseworker.InnerUser.ID = 2
+	userCaseworker.InnerUser.Email = "caseworker@ihfa.org"
+	userCaseworker.InnerUser.LastAuth = &mock_lastAuth
+	userCaseworker.InnerUser.AuthCode = &mock_authCode
+	userCaseworker.InnerUser.Type = db.UserTypeCaseWorker
+
+	clientLinkCode := db.UserLinkCodeModel{
+		InnerUserLinkCode: db.InnerUserLinkCode{
+			UserID: userClient.ID,
+			Code:   mock_linkCode,
+		},
+		RelationsUserLinkCode: db.RelationsUserLinkCode{
+			User: &userClient,
+		},
+	}
+
 	userLink := db.UserLinkModel{
 		InnerUserLink: db.InnerUserLink{
 			ClientID:     userClient.ID,
 			CaseworkerID: userCaseworker.ID,
 		},
-		RelationsUserLink: db.RelationsUserLink{},
+	}
+
+	trustedOrg := db.TrustedOrganizationModel{
+		InnerTrustedOrganization: db.InnerTrustedOrganization{
+			UserID: userClient.ID,
+			Domain: "@ihfa.org",
+		},
 	}
 
 	// Mock auth
@@ -838,6 +970,23 @@ func TestLinkAccounts(t *testing.T) {
 		),
 	).Returns(clientLinkCode)
 
+	// Mock check for an existing link
+	mock.UserLink.Expect(
+		client.UserLink.FindUnique(
+			db.UserLink.UserlinkID(
+				db.UserLink.ClientID.Equals(userClient.ID),
+				db.UserLink.CaseworkerID.Equals(userCaseworker.ID),
+			),
+		),
+	).Errors(db.ErrNotFound)
+
+	// Mock find trusted organizations
+	mock.TrustedOrganization.Expect(
+		client.TrustedOrganization.FindMany(
+			db.TrustedOrganization.UserID.Equals(userClient.ID),
+		),
+	).ReturnsMany([]db.TrustedOrganizationModel{trustedOrg})
+
 	// Mock create user link
 	mock.UserLink.Expect(
 		client.UserLink.CreateOne(
@@ -867,6 +1016,138 @@ func TestLinkAccounts(t *testing.T) {
 
 	assert.Empty(t, response.Error, "Error message found")
 	assert.True(t, response.Success, "Unsuccessful")
+	assert.Equal(t, string(db.LinkRequestStatusApproved), response.Status, "Trusted caseworker should link right away")
+}
+
+func TestApproveLinkRequest(t *testing.T) {
+	client, _, ensure := db.NewMock()
+	defer ensure(t)
+
+	linkRequest := db.LinkRequestModel{
+		InnerLinkRequest: db.InnerLinkRequest{
+			ID:           1,
+			ClientID:     mock_userA.ID,
+			CaseworkerID: 2,
+			Status:       db.LinkRequestStatusPending,
+			CreatedAt:    mock_now,
+			ExpiresAt:    mock_now.Add(time.Hour),
+		},
+	}
+
+	// Marking the request approved and linking the accounts happen together
+	txs := respondWrites(client, &linkRequest, true, true, mock_now)
+	assert.Len(t, txs, 2, "Approving should mark the request and link the accounts")
+
+	// Accounts that are already linked only have the request marked
+	txs = respondWrites(client, &linkRequest, true, false, mock_now)
+	assert.Len(t, txs, 1, "Linked accounts should only have the request marked")
+
+	txs = respondWrites(client, &linkRequest, false, true, mock_now)
+	assert.Len(t, txs, 1, "Rejecting should only mark the request")
+}
+
+func TestRespondLinkRequestNotClient(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	client, mock, ensure := db.NewMock()
+	defer ensure(t)
+
+	userCaseworker := mock_userA
+	userCaseworker.InnerUser.LastAuth = &mock_lastAuth
+	userCaseworker.InnerUser.AuthCode = &mock_authCode
+	userCaseworker.InnerUser.Type = db.UserTypeCaseWorker
+
+	// Mock auth
+	mock.User.Expect(
+		client.User.FindFirst(
+			db.User.Email.Equals(userCaseworker.InnerUser.Email),
+			db.User.AuthCode.Equals(*userCaseworker.InnerUser.AuthCode),
+		),
+	).Returns(userCaseworker)
+
+	rec, c, reqErr := testutil.PrepareRequestJSON(http.MethodPost, "/api/account/link/approve", RespondLinkRequestRequest{RequestID: 1})
+	c.Request().Header.Set(EmailHeaderKey, userCaseworker.InnerUser.Email)
+	c.Request().Header.Set(AuthHeaderKey, *userCaseworker.InnerUser.AuthCode)
+	assert.NoError(t, reqErr, "Failed to prepare request")
+	assert.NoError(t, ApproveLinkRequestHandler(c, client), "Error while approving link request")
+	assert.Equal(t, 400, rec.Code, "Bad status code")
+
+	var response RespondLinkRequestResponse
+	unmarshalErr := json.Unmarshal(rec.Body.Bytes(), &response)
+	assert.Nil(t, unmarshalErr, "Error while parsing response body")
+
+	assert.Equal(t, "Only clients can respond to link requests", response.Error, "Caseworkers should be refused")
+	assert.False(t, response.Success, "Caseworkers should be unsuccessful")
+}
+
+func TestApproveExpiredLinkRequest(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	// client := db.NewClient()
+	client, mock, ensure := db.NewMock()
+	defer ensure(t)
+
+	userClient := mock_userA
+	userClient.InnerUser.LastAuth = &mock_lastAuth
+	userClient.InnerUser.AuthCode = &mock_authCode
+
+	linkRequest := db.LinkRequestModel{
+		InnerLinkRequest: db.InnerLinkRequest{
+			ID:           1,
+			ClientID:     userClient.ID,
+			CaseworkerID: 2,
+			Status:       db.LinkRequestStatusPending,
+			CreatedAt:    mock_now.Add(-linkRequestExpiry * 2),
+			ExpiresAt:    mock_now.Add(-linkRequestExpiry),
+		},
+	}
+	expiredRequest := linkRequest
+	expiredRequest.InnerLinkRequest.Status = db.LinkRequestStatusExpired
+
+	// Mock auth
+	mock.User.Expect(
+		client.User.FindFirst(
+			db.User.Email.Equals(userClient.InnerUser.Email),
+			db.User.AuthCode.Equals(*userClient.InnerUser.AuthCode),
+		),
+	).Returns(userClient)
+
+	// Mock find link request
+	mock.LinkRequest.Expect(
+		client.LinkRequest.FindFirst(
+			db.LinkRequest.ID.Equals(linkRequest.ID),
+			db.LinkRequest.ClientID.Equals(userClient.ID),
+		),
+	).Returns(linkRequest)
+
+	// Mock mark link request expired
+	mock.LinkRequest.Expect(
+		client.LinkRequest.FindUnique(
+			db.LinkRequest.ID.Equals(linkRequest.ID),
+		).Update(
+			db.LinkRequest.Status.Set(db.LinkRequestStatusExpired),
+		),
+	).Returns(expiredRequest)
+
+	request := RespondLinkRequestRequest{
+		RequestID: linkRequest.ID,
+	}
+
+	rec, c, reqErr := testutil.PrepareRequestJSON(http.MethodPost, "/api/account/link/approve", request)
+	c.Request().Header.Set(EmailHeaderKey, userClient.InnerUser.Email)
+	c.Request().Header.Set(AuthHeaderKey, *userClient.InnerUser.AuthCode)
+	assert.NoError(t, reqErr, "Failed to prepare request")
+	assert.NoError(t, ApproveLinkRequestHandler(c, client), "Error while approving link request")
+	assert.Equal(t, 400, rec.Code, "Bad status code")
+
+	var response RespondLinkRequestResponse
+	unmarshalErr := json.Unmarshal(rec.Body.Bytes(), &response)
+	assert.Nil(t, unmarshalErr, "Error while parsing response body")
+
+	assert.Equal(t, "Link request has expired", response.Error, "Expired request should be refused")
+	assert.False(t, response.Success, "Expired request should be unsuccessful")
 }
 
 func TestGetAccountClients(t *testing.T) {
@@ -1004,3 +1285,52 @@ func TestUnlinkAccount(t *testing.T) {
 	assert.Empty(t, response.Error, "Error message found")
 	assert.True(t, response.Success, "Unsuccessful")
 }
+
+func TestDeleteCaseworkerWithCaseload(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	// client := db.NewClient()
+	client, mock, ensure := db.NewMock()
+	defer ensure(t)
+
+	userCaseworker := mock_userA
+	userCaseworker.InnerUser.Type = db.UserTypeCaseWorker
+
+	userLink := db.UserLinkModel{
+		InnerUserLink: db.InnerUserLink{
+			ClientID:     2,
+			CaseworkerID: userCaseworker.ID,
+		},
+	}
+
+	// Mock find user
+	mock.User.Expect(
+		client.User.FindUnique(
+			db.User.Email.Equals(userCaseworker.InnerUser.Email),
+		),
+	).Returns(userCaseworker)
+
+	// Mock find caseload
+	mock.UserLink.Expect(
+		client.UserLink.FindMany(
+			db.UserLink.CaseworkerID.Equals(userCaseworker.ID),
+		),
+	).ReturnsMany([]db.UserLinkModel{userLink})
+
+	request := DeleteAccountRequest{
+		Email: userCaseworker.InnerUser.Email,
+	}
+
+	rec, c, reqErr := testutil.PrepareRequestJSON(http.MethodPost, "/api/account/delete-account", request)
+	assert.NoError(t, reqErr, "Failed to prepare request")
+	assert.NoError(t, DeleteAccount(c, client), "Error while deleting account")
+	assert.Equal(t, http.StatusConflict, rec.Code, "Bad status code")
+
+	var response DeleteAccountResponse
+	unmarshalErr := json.Unmarshal(rec.Body.Bytes(), &response)
+	assert.Nil(t, unmarshalErr, "Error while parsing response body")
+
+	assert.NotEmpty(t, response.Error, "Caseload should block deleting the account")
+	assert.False(t, response.Success, "Deleting a caseworker with clients should be unsuccessful")
+}
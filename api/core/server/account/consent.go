@@ -0,0 +1,138 @@
+package account
+
+// Clients can let a linked caseworker edit their application for them, like
+// when doing intake at a desk. Being linked only lets a caseworker look.
+
+import (
+	"api/core/server/email"
+	db "api/db"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/labstack/echo"
+)
+
+var (
+	ErrNotLinked     = errors.New("not a caseworker for account")
+	ErrNoEditConsent = errors.New("client hasn't allowed edits")
+)
+
+type SetEditConsentRequest struct {
+	CaseworkerEmail string `json:"caseworker_email"`
+	CanEdit         bool   `json:"can_edit"`
+}
+
+type SetEditConsentResponse struct {
+	Success bool   `json:"success"`
+	CanEdit bool   `json:"can_edit"`
+	Error   string `json:"error"`
+}
+
+// SetEditConsentHandler lets a client allow, or stop allowing, one of their
+// caseworkers to edit their application.
+func SetEditConsentHandler(c echo.Context, client *db.PrismaClient) error {
+	user := ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, SetEditConsentResponse{Success: false, Error: "Failed to authenticate"})
+	}
+	if user.Type != db.UserTypeClient {
+		return c.JSON(400, SetEditConsentResponse{Success: false, Error: "Only clients can allow edits"})
+	}
+
+	var request SetEditConsentRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&request); err != nil {
+		return c.JSON(500, SetEditConsentResponse{Success: false, Error: "Failed to parse request body"})
+	}
+
+	caseworker, caseworkerErr := client.User.FindUnique(
+		db.User.Email.Equals(request.CaseworkerEmail),
+	).Exec(context.Background())
+	if caseworkerErr != nil {
+		return c.JSON(400, SetEditConsentResponse{Success: false, Error: "Not linked to caseworker"})
+	}
+
+	_, updateErr := client.UserLink.FindUnique(
+		db.UserLink.UserlinkID(
+			db.UserLink.ClientID.Equals(user.ID),
+			db.UserLink.CaseworkerID.Equals(caseworker.ID),
+		),
+	).Update(
+		db.UserLink.CanEdit.Set(request.CanEdit),
+	).Exec(context.Background())
+	if updateErr == db.ErrNotFound {
+		return c.JSON(400, SetEditConsentResponse{Success: false, Error: "Not linked to caseworker"})
+	}
+	if updateErr != nil {
+		fmt.Printf("[ERROR] Failed to set edit consent for client %d, caseworker %d: %v\n", user.ID, caseworker.ID, updateErr)
+		return c.JSON(500, SetEditConsentResponse{Success: false, Error: "Failed to update consent"})
+	}
+
+	return c.JSON(200, SetEditConsentResponse{Success: true, CanEdit: request.CanEdit, Error: ""})
+}
+
+// FindEditableOwner finds the user whose data is being changed, which is
+// either the user themselves or a client who lets them edit. Returns
+// ErrNotLinked or ErrNoEditConsent if the user can't edit it.
+func FindEditableOwner(client *db.PrismaClient, user *db.UserModel, ownerEmail string) (*db.UserModel, error) {
+	if ownerEmail == "" || ownerEmail == user.Email {
+		return user, nil
+	}
+
+	userLink, userLinkErr := client.UserLink.FindFirst(
+		db.UserLink.Caseworker.Where(
+			db.User.Email.Equals(user.Email),
+		),
+		db.UserLink.Client.Where(
+			db.User.Email.Equals(ownerEmail),
+		),
+	).With(
+		db.UserLink.Client.Fetch(),
+	).Exec(context.Background())
+	if userLinkErr == db.ErrNotFound {
+		return nil, ErrNotLinked
+	}
+	if userLinkErr != nil {
+		return nil, userLinkErr
+	}
+	if !userLink.CanEdit {
+		return nil, ErrNoEditConsent
+	}
+	return userLink.Client(), nil
+}
+
+// EditAccessError returns the status code and error message to respond with
+// when FindEditableOwner fails.
+func EditAccessError(err error, ownerEmail string) (int, string) {
+	switch err {
+	case ErrNotLinked:
+		fmt.Printf("[ERROR] Not a caseworker for owner email: %s\n", ownerEmail)
+		return 400, "Not a caseworker for account"
+	case ErrNoEditConsent:
+		return 403, "Client hasn't allowed you to edit their application"
+	default:
+		fmt.Printf("[ERROR] Failed to check edit access for owner email %s: %v\n", ownerEmail, err)
+		return 500, "Failed to check edit access"
+	}
+}
+
+// NotifyClientOfEdits emails a client what a caseworker changed for them.
+// Failing to send is logged, the changes are already saved.
+func NotifyClientOfEdits(client *db.PrismaClient, caseworker *db.UserModel, owner *db.UserModel, changes []string) {
+	if len(changes) == 0 || caseworker.ID == owner.ID {
+		return
+	}
+
+	name := caseworker.Email
+	personalInfo, personalInfoErr := client.PersonalInfo.FindUnique(
+		db.PersonalInfo.ID.Equals(caseworker.PersonalInfoID),
+	).Exec(context.Background())
+	if personalInfoErr == nil {
+		name = fmt.Sprintf("%s %s", personalInfo.FirstName, personalInfo.LastName)
+	}
+
+	if err := email.SendCaseworkerEditEmail(owner.Email, name, caseworker.Email, changes); err != nil {
+		fmt.Printf("[ERROR] Failed to notify user %d of edits by caseworker %d: %v\n", owner.ID, caseworker.ID, err)
+	}
+}
@@ -0,0 +1,432 @@
+package account
+
+// Link requests, so clients approve caseworkers before they get access.
+
+import (
+	"api/core/server/email"
+	db "api/db"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// How long a client has to respond to a link request.
+const linkRequestExpiry = time.Hour * 72
+
+type LinkRequestInfo struct {
+	ID           int          `json:"id"`
+	User         BaseUserInfo `json:"user"`
+	Organization string       `json:"organization"`
+	CreatedAt    time.Time    `json:"created_at"`
+	ExpiresAt    time.Time    `json:"expires_at"`
+}
+
+type GetLinkRequestsResponse struct {
+	Success  bool              `json:"success"`
+	Requests []LinkRequestInfo `json:"requests"`
+	Error    string            `json:"error"`
+}
+
+type RespondLinkRequestRequest struct {
+	RequestID int `json:"request_id"`
+}
+
+type RespondLinkRequestResponse struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+}
+
+type GetTrustedOrgsResponse struct {
+	Success   bool     `json:"success"`
+	Trusted   []string `json:"trusted"`
+	Available []string `json:"available"`
+	Error     string   `json:"error"`
+}
+
+type SetTrustedOrgsRequest struct {
+	Domains []string `json:"domains"`
+}
+
+type SetTrustedOrgsResponse struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+}
+
+// now returns the current time. Mockable so expiry times can be tested.
+var now func() time.Time = time.Now
+
+// emailDomain returns the domain part of an email, including the @, which
+// is how caseworker organizations are identified.
+func emailDomain(address string) string {
+	index := strings.LastIndex(address, "@")
+	if index < 0 {
+		return ""
+	}
+	return strings.ToLower(address[index:])
+}
+
+// isTrustedCaseworker checks if a client has chosen to auto-approve link
+// requests from the caseworker's organization.
+func isTrustedCaseworker(client *db.PrismaClient, linkClient *db.UserModel, caseworker *db.UserModel) (bool, error) {
+	trustedOrgs, trustedErr := client.TrustedOrganization.FindMany(
+		db.TrustedOrganization.UserID.Equals(linkClient.ID),
+	).Exec(context.Background())
+	if trustedErr != nil {
+		return false, trustedErr
+	}
+
+	domain := emailDomain(caseworker.Email)
+	for _, org := range trustedOrgs {
+		if org.Domain == domain {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// createLink links a client and caseworker.
+func createLink(client *db.PrismaClient, clientId int, caseworkerId int) error {
+	_, userLinkErr := client.UserLink.CreateOne(
+		db.UserLink.Client.Link(
+			db.User.ID.Equals(clientId),
+		),
+		db.UserLink.Caseworker.Link(
+			db.User.ID.Equals(caseworkerId),
+		),
+	).Exec(context.Background())
+	return userLinkErr
+}
+
+// createLinkRequest opens a link request from a caseworker to a client and
+// emails the client about it. If the caseworker already has a request open
+// with the client, that request is renewed instead of adding another one.
+func createLinkRequest(client *db.PrismaClient, linkClient *db.UserModel, caseworker *db.UserModel) error {
+	expiresAt := now().Add(linkRequestExpiry)
+
+	existing, existingErr := client.LinkRequest.FindFirst(
+		db.LinkRequest.ClientID.Equals(linkClient.ID),
+		db.LinkRequest.CaseworkerID.Equals(caseworker.ID),
+		db.LinkRequest.Status.Equals(db.LinkRequestStatusPending),
+	).Exec(context.Background())
+	if existingErr != nil && existingErr != db.ErrNotFound {
+		return existingErr
+	}
+
+	if existingErr == nil {
+		_, renewErr := client.LinkRequest.FindUnique(
+			db.LinkRequest.ID.Equals(existing.ID),
+		).Update(
+			db.LinkRequest.ExpiresAt.Set(expiresAt),
+		).Exec(context.Background())
+		if renewErr != nil {
+			return renewErr
+		}
+	} else {
+		_, createErr := client.LinkRequest.CreateOne(
+			db.LinkRequest.Client.Link(
+				db.User.ID.Equals(linkClient.ID),
+			),
+			db.LinkRequest.Caseworker.Link(
+				db.User.ID.Equals(caseworker.ID),
+			),
+			db.LinkRequest.ExpiresAt.Set(expiresAt),
+		).Exec(context.Background())
+		if createErr != nil {
+			return createErr
+		}
+	}
+
+	caseworkerName := caseworker.Email
+	info, infoErr := client.PersonalInfo.FindUnique(
+		db.PersonalInfo.ID.Equals(caseworker.PersonalInfoID),
+	).Exec(context.Background())
+	if infoErr == nil {
+		caseworkerName = fmt.Sprintf("%s %s", info.FirstName, info.LastName)
+	}
+
+	// If it fails, it's fine. The request still shows up in the app.
+	email.SendLinkRequestEmail(linkClient.Email, caseworkerName, caseworker.Email)
+	return nil
+}
+
+// expireLinkRequests marks a user's pending link requests that have run out
+// of time as expired.
+func expireLinkRequests(client *db.PrismaClient, user *db.UserModel) error {
+	var owner db.LinkRequestWhereParam
+	if user.Type == db.UserTypeCaseWorker {
+		owner = db.LinkRequest.CaseworkerID.Equals(user.ID)
+	} else {
+		owner = db.LinkRequest.ClientID.Equals(user.ID)
+	}
+
+	_, expireErr := client.LinkRequest.FindMany(
+		owner,
+		db.LinkRequest.Status.Equals(db.LinkRequestStatusPending),
+		db.LinkRequest.ExpiresAt.Before(now()),
+	).Update(
+		db.LinkRequest.Status.Set(db.LinkRequestStatusExpired),
+	).Exec(context.Background())
+	return expireErr
+}
+
+// GetLinkRequestsHandler lists pending link requests. Clients see the
+// requests waiting on them, and caseworkers see the requests they've sent.
+func GetLinkRequestsHandler(c echo.Context, client *db.PrismaClient) error {
+	user := ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, GetLinkRequestsResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	if user.Type != db.UserTypeClient && user.Type != db.UserTypeCaseWorker {
+		return c.JSON(500, GetLinkRequestsResponse{Success: false, Error: "Invalid user type"})
+	}
+
+	if expireErr := expireLinkRequests(client, user); expireErr != nil {
+		fmt.Printf("[ERROR] Failed to expire link requests for user %d: %v\n", user.ID, expireErr)
+		return c.JSON(500, GetLinkRequestsResponse{Success: false, Error: "Failed to get link requests"})
+	}
+
+	isCaseworker := user.Type == db.UserTypeCaseWorker
+	var owner db.LinkRequestWhereParam
+	var other db.LinkRequestRelationWith
+	if isCaseworker {
+		owner = db.LinkRequest.CaseworkerID.Equals(user.ID)
+		other = db.LinkRequest.Client.Fetch().With(db.User.PersonalInfo.Fetch())
+	} else {
+		owner = db.LinkRequest.ClientID.Equals(user.ID)
+		other = db.LinkRequest.Caseworker.Fetch().With(db.User.PersonalInfo.Fetch())
+	}
+
+	linkRequests, linkRequestsErr := client.LinkRequest.FindMany(
+		owner,
+		db.LinkRequest.Status.Equals(db.LinkRequestStatusPending),
+	).With(
+		other,
+	).OrderBy(
+		db.LinkRequest.CreatedAt.Order(db.SortOrderDesc),
+	).Exec(context.Background())
+	if linkRequestsErr != nil {
+		fmt.Printf("[ERROR] Failed to get link requests for user %d: %v\n", user.ID, linkRequestsErr)
+		return c.JSON(500, GetLinkRequestsResponse{Success: false, Error: "Failed to get link requests"})
+	}
+
+	requests := make([]LinkRequestInfo, len(linkRequests))
+	for i, linkRequest := range linkRequests {
+		var otherUser *db.UserModel
+		var caseworkerEmail string
+		if isCaseworker {
+			otherUser = linkRequest.Client()
+			caseworkerEmail = user.Email
+		} else {
+			otherUser = linkRequest.Caseworker()
+			caseworkerEmail = otherUser.Email
+		}
+		requests[i] = LinkRequestInfo{
+			ID: linkRequest.ID,
+			User: BaseUserInfo{
+				FirstName: otherUser.PersonalInfo().FirstName,
+				LastName:  otherUser.PersonalInfo().LastName,
+				Email:     otherUser.Email,
+			},
+			Organization: emailDomain(caseworkerEmail),
+			CreatedAt:    linkRequest.CreatedAt,
+			ExpiresAt:    linkRequest.ExpiresAt,
+		}
+	}
+
+	return c.JSON(200, GetLinkRequestsResponse{Success: true, Requests: requests, Error: ""})
+}
+
+// respondToLinkRequest approves or rejects one of the client's pending link
+// requests, linking the accounts if it's approved.
+func respondToLinkRequest(c echo.Context, client *db.PrismaClient, approve bool) error {
+	user := ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, RespondLinkRequestResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	var request RespondLinkRequestRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&request); err != nil {
+		return c.JSON(500, RespondLinkRequestResponse{Success: false, Error: "Failed to parse request body"})
+	}
+
+	if user.Type != db.UserTypeClient {
+		return c.JSON(400, RespondLinkRequestResponse{Success: false, Error: "Only clients can respond to link requests"})
+	}
+
+	linkRequest, linkRequestErr := client.LinkRequest.FindFirst(
+		db.LinkRequest.ID.Equals(request.RequestID),
+		db.LinkRequest.ClientID.Equals(user.ID),
+	).Exec(context.Background())
+	if linkRequestErr != nil {
+		return c.JSON(400, RespondLinkRequestResponse{Success: false, Error: "Failed to find link request"})
+	}
+
+	if linkRequest.Status != db.LinkRequestStatusPending {
+		return c.JSON(400, RespondLinkRequestResponse{Success: false, Error: "Link request is no longer pending"})
+	}
+
+	respondedAt := now()
+	if linkRequest.ExpiresAt.Before(respondedAt) {
+		client.LinkRequest.FindUnique(
+			db.LinkRequest.ID.Equals(linkRequest.ID),
+		).Update(
+			db.LinkRequest.Status.Set(db.LinkRequestStatusExpired),
+		).Exec(context.Background())
+		return c.JSON(400, RespondLinkRequestResponse{Success: false, Error: "Link request has expired"})
+	}
+
+	needsLink := false
+	if approve {
+		_, existingErr := client.UserLink.FindUnique(
+			db.UserLink.UserlinkID(
+				db.UserLink.ClientID.Equals(linkRequest.ClientID),
+				db.UserLink.CaseworkerID.Equals(linkRequest.CaseworkerID),
+			),
+		).Exec(context.Background())
+		if existingErr == db.ErrNotFound {
+			needsLink = true
+		} else if existingErr != nil {
+			fmt.Printf("[ERROR] Failed to check link for link request %d: %v\n", linkRequest.ID, existingErr)
+			return c.JSON(500, RespondLinkRequestResponse{Success: false, Error: "Failed to link accounts"})
+		}
+	}
+
+	txs := respondWrites(client, linkRequest, approve, needsLink, respondedAt)
+	if txErr := client.Prisma.Transaction(txs...).Exec(context.Background()); txErr != nil {
+		fmt.Printf("[ERROR] Failed to respond to link request %d: %v\n", linkRequest.ID, txErr)
+		return c.JSON(500, RespondLinkRequestResponse{Success: false, Error: "Failed to update link request"})
+	}
+
+	return c.JSON(200, RespondLinkRequestResponse{Success: true, Error: ""})
+}
+
+// respondWrites builds the writes that mark a link request approved or
+// rejected, and for approvals that still need it, link the accounts. They're
+// run together, so accounts are only linked if the request is marked
+// approved.
+func respondWrites(client *db.PrismaClient, linkRequest *db.LinkRequestModel, approve bool, needsLink bool, respondedAt time.Time) []db.PrismaTransaction {
+	status := db.LinkRequestStatusRejected
+	if approve {
+		status = db.LinkRequestStatusApproved
+	}
+	txs := []db.PrismaTransaction{
+		client.LinkRequest.FindUnique(
+			db.LinkRequest.ID.Equals(linkRequest.ID),
+		).Update(
+			db.LinkRequest.Status.Set(status),
+			db.LinkRequest.RespondedAt.Set(respondedAt),
+		).Tx(),
+	}
+	if approve && needsLink {
+		txs = append(txs, client.UserLink.CreateOne(
+			db.UserLink.Client.Link(
+				db.User.ID.Equals(linkRequest.ClientID),
+			),
+			db.UserLink.Caseworker.Link(
+				db.User.ID.Equals(linkRequest.CaseworkerID),
+			),
+		).Tx())
+	}
+	return txs
+}
+
+// ApproveLinkRequestHandler approves a pending link request and links the
+// client with the caseworker who sent it.
+func ApproveLinkRequestHandler(c echo.Context, client *db.PrismaClient) error {
+	return respondToLinkRequest(c, client, true)
+}
+
+// RejectLinkRequestHandler rejects a pending link request.
+func RejectLinkRequestHandler(c echo.Context, client *db.PrismaClient) error {
+	return respondToLinkRequest(c, client, false)
+}
+
+// GetTrustedOrgsHandler lists the organizations a client auto-approves link
+// requests from, along with the organizations they can choose from.
+func GetTrustedOrgsHandler(c echo.Context, client *db.PrismaClient) error {
+	user := ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, GetTrustedOrgsResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	trustedOrgs, trustedErr := client.TrustedOrganization.FindMany(
+		db.TrustedOrganization.UserID.Equals(user.ID),
+	).Exec(context.Background())
+	if trustedErr != nil {
+		fmt.Printf("[ERROR] Failed to get trusted organizations for user %d: %v\n", user.ID, trustedErr)
+		return c.JSON(500, GetTrustedOrgsResponse{Success: false, Error: "Failed to get trusted organizations"})
+	}
+
+	trusted := make([]string, len(trustedOrgs))
+	for i, org := range trustedOrgs {
+		trusted[i] = org.Domain
+	}
+
+	return c.JSON(200, GetTrustedOrgsResponse{Success: true, Trusted: trusted, Available: acceptableCaseworkerDomains, Error: ""})
+}
+
+// SetTrustedOrgsHandler replaces the organizations a client auto-approves
+// link requests from.
+func SetTrustedOrgsHandler(c echo.Context, client *db.PrismaClient) error {
+	user := ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, SetTrustedOrgsResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	var request SetTrustedOrgsRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&request); err != nil {
+		return c.JSON(500, SetTrustedOrgsResponse{Success: false, Error: "Failed to parse request body"})
+	}
+
+	if user.Type != db.UserTypeClient {
+		return c.JSON(400, SetTrustedOrgsResponse{Success: false, Error: "Only clients can trust organizations"})
+	}
+
+	domains := make([]string, 0, len(request.Domains))
+	for _, domain := range request.Domains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if !strings.HasPrefix(domain, "@") {
+			domain = "@" + domain
+		}
+		acceptable := false
+		for _, acceptableDomain := range acceptableCaseworkerDomains {
+			if domain == acceptableDomain {
+				acceptable = true
+				break
+			}
+		}
+		if !acceptable {
+			return c.JSON(400, SetTrustedOrgsResponse{Success: false, Error: fmt.Sprintf("Unknown organization %s", domain)})
+		}
+		if !slices.Contains(domains, domain) {
+			domains = append(domains, domain)
+		}
+	}
+
+	// Replaced all at once, so a failure leaves the old organizations trusted
+	txs := []db.PrismaTransaction{
+		client.TrustedOrganization.FindMany(
+			db.TrustedOrganization.UserID.Equals(user.ID),
+		).Delete().Tx(),
+	}
+	for _, domain := range domains {
+		txs = append(txs, client.TrustedOrganization.CreateOne(
+			db.TrustedOrganization.User.Link(
+				db.User.ID.Equals(user.ID),
+			),
+			db.TrustedOrganization.Domain.Set(domain),
+		).Tx())
+	}
+	if txErr := client.Prisma.Transaction(txs...).Exec(context.Background()); txErr != nil {
+		fmt.Printf("[ERROR] Failed to update trusted organizations for user %d: %v\n", user.ID, txErr)
+		return c.JSON(500, SetTrustedOrgsResponse{Success: false, Error: "Failed to update trusted organizations"})
+	}
+
+	return c.JSON(200, SetTrustedOrgsResponse{Success: true, Error: ""})
+}
@@ -0,0 +1,93 @@
+package account
+
+// Re-prompting for 2FA before sensitive actions, like revealing an SSN, even
+// when the user is already logged in.
+
+import (
+	"api/core/server/email"
+	"api/db"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// How long a 2FA re-prompt counts as recent.
+const reauthWindow = time.Minute * 5
+
+type VerifyReauthRequest struct {
+	Code string `json:"code"`
+}
+
+type ReauthResponse struct {
+	Success   bool      `json:"success"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Error     string    `json:"error"`
+}
+
+// HasRecentReauth checks if the user passed a 2FA re-prompt recently.
+func HasRecentReauth(user *db.UserModel) bool {
+	reauthAt, ok := user.ReauthAt()
+	return ok && now().Before(reauthAt.Add(reauthWindow))
+}
+
+// RequestReauthHandler emails the user a 2FA code to confirm it's them
+// before a sensitive action.
+func RequestReauthHandler(c echo.Context, client *db.PrismaClient) error {
+	user := ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, ReauthResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	twoFACode, expiry := generate2FACode()
+	_, createErr := client.TwoFactorCode.CreateOne(
+		db.TwoFactorCode.User.Link(
+			db.User.ID.Equals(user.ID),
+		),
+		db.TwoFactorCode.Code.Set(twoFACode),
+		db.TwoFactorCode.ExpiresAt.Set(expiry),
+	).Exec(context.Background())
+	if createErr != nil {
+		fmt.Printf("[ERROR] Failed to create 2FA code for user %d: %v\n", user.ID, createErr)
+		return c.JSON(500, ReauthResponse{Success: false, Error: "Failed to generate 2FA code"})
+	}
+
+	if sendErr := email.Send2FACodeEmail(user.Email, twoFACode); sendErr != nil {
+		return c.JSON(500, ReauthResponse{Success: false, Error: "Failed to send 2FA code"})
+	}
+
+	return c.JSON(200, ReauthResponse{Success: true, ExpiresAt: expiry, Error: ""})
+}
+
+// VerifyReauthHandler checks the code from RequestReauthHandler. Sensitive
+// actions are allowed for a few minutes after.
+func VerifyReauthHandler(c echo.Context, client *db.PrismaClient) error {
+	user := ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, ReauthResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	var request VerifyReauthRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&request); err != nil {
+		return c.JSON(500, ReauthResponse{Success: false, Error: "Failed to parse request body"})
+	}
+
+	if !ValidateAuth2FA(user.Email, request.Code, client) {
+		return c.JSON(401, ReauthResponse{Success: false, Error: "Invalid 2FA code"})
+	}
+
+	reauthAt := now()
+	_, updateErr := client.User.FindUnique(
+		db.User.ID.Equals(user.ID),
+	).Update(
+		db.User.ReauthAt.Set(reauthAt),
+	).Exec(context.Background())
+	if updateErr != nil {
+		fmt.Printf("[ERROR] Failed to save 2FA re-prompt for user %d: %v\n", user.ID, updateErr)
+		return c.JSON(500, ReauthResponse{Success: false, Error: "Failed to complete 2FA"})
+	}
+
+	return c.JSON(200, ReauthResponse{Success: true, ExpiresAt: reauthAt.Add(reauthWindow), Error: ""})
+}
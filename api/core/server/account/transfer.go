@@ -0,0 +1,302 @@
+package account
+
+// Moving clients from one caseworker to another, so clients don't have to
+// share their link code again when their caseworker changes.
+
+import (
+	"api/core/server/email"
+	db "api/db"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+type TransferCaseloadRequest struct {
+	FromEmail    string   `json:"from_email"`
+	ToEmail      string   `json:"to_email"`
+	ClientEmails []string `json:"client_emails"`
+	Reason       string   `json:"reason"`
+}
+
+type TransferCaseloadResponse struct {
+	Success     bool           `json:"success"`
+	Transferred []BaseUserInfo `json:"transferred"`
+	Error       string         `json:"error"`
+}
+
+type CaseloadTransferInfo struct {
+	ID             int          `json:"id"`
+	Client         BaseUserInfo `json:"client"`
+	FromCaseworker string       `json:"from_caseworker"`
+	ToCaseworker   string       `json:"to_caseworker"`
+	TransferredBy  string       `json:"transferred_by"`
+	Reason         string       `json:"reason"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+type GetCaseloadTransfersResponse struct {
+	Success   bool                   `json:"success"`
+	Transfers []CaseloadTransferInfo `json:"transfers"`
+	Error     string                 `json:"error"`
+}
+
+// fullName returns a user's first and last name, falling back to their email.
+func fullName(user *db.UserModel) string {
+	info := user.PersonalInfo()
+	if info == nil {
+		return user.Email
+	}
+	return fmt.Sprintf("%s %s", info.FirstName, info.LastName)
+}
+
+// findCaseworker finds a caseworker account by email, with their personal info.
+func findCaseworker(client *db.PrismaClient, email string) (*db.UserModel, error) {
+	caseworker, caseworkerErr := client.User.FindUnique(
+		db.User.Email.Equals(email),
+	).With(
+		db.User.PersonalInfo.Fetch(),
+	).Exec(context.Background())
+	if caseworkerErr != nil {
+		return nil, caseworkerErr
+	}
+	if caseworker.Type != db.UserTypeCaseWorker {
+		return nil, fmt.Errorf("%s is not a caseworker", email)
+	}
+	return caseworker, nil
+}
+
+// Tasks that still need to be done, which go with a client to their new
+// caseworker.
+var openTaskStatuses = []db.TaskStatus{
+	db.TaskStatusOpen,
+	db.TaskStatusInProgress,
+}
+
+// transferWrites builds the writes that move one client's link from one
+// caseworker to another, with the old caseworker's open tasks for them, and
+// record the transfer. Clients already linked to the new caseworker only
+// lose the old link.
+func transferWrites(client *db.PrismaClient, user *db.UserModel, link *db.UserLinkModel, fromCaseworker *db.UserModel, toCaseworker *db.UserModel, alreadyLinked bool, reason string) []db.PrismaTransaction {
+	txs := []db.PrismaTransaction{
+		client.UserLink.FindUnique(
+			db.UserLink.UserlinkID(
+				db.UserLink.ClientID.Equals(link.ClientID),
+				db.UserLink.CaseworkerID.Equals(fromCaseworker.ID),
+			),
+		).Delete().Tx(),
+		client.CaseloadTransfer.CreateOne(
+			db.CaseloadTransfer.Client.Link(
+				db.User.ID.Equals(link.ClientID),
+			),
+			db.CaseloadTransfer.FromCaseworker.Link(
+				db.User.ID.Equals(fromCaseworker.ID),
+			),
+			db.CaseloadTransfer.ToCaseworker.Link(
+				db.User.ID.Equals(toCaseworker.ID),
+			),
+			db.CaseloadTransfer.TransferredBy.Link(
+				db.User.ID.Equals(user.ID),
+			),
+			db.CaseloadTransfer.Reason.Set(reason),
+		).Tx(),
+		// Reminders are sent again, to the new caseworker
+		client.CaseTask.FindMany(
+			db.CaseTask.ClientID.Equals(link.ClientID),
+			db.CaseTask.AssigneeID.Equals(fromCaseworker.ID),
+			db.CaseTask.Status.In(openTaskStatuses),
+		).Update(
+			db.CaseTask.AssigneeID.Set(toCaseworker.ID),
+			db.CaseTask.ReminderSentAt.SetOptional(nil),
+		).Tx(),
+	}
+	// The client's consent to edit goes with them to the new caseworker
+	if !alreadyLinked {
+		txs = append(txs, client.UserLink.CreateOne(
+			db.UserLink.Client.Link(
+				db.User.ID.Equals(link.ClientID),
+			),
+			db.UserLink.Caseworker.Link(
+				db.User.ID.Equals(toCaseworker.ID),
+			),
+			db.UserLink.CanEdit.Set(link.CanEdit),
+		).Tx())
+	} else if link.CanEdit {
+		txs = append(txs, client.UserLink.FindUnique(
+			db.UserLink.UserlinkID(
+				db.UserLink.ClientID.Equals(link.ClientID),
+				db.UserLink.CaseworkerID.Equals(toCaseworker.ID),
+			),
+		).Update(
+			db.UserLink.CanEdit.Set(true),
+		).Tx())
+	}
+	return txs
+}
+
+// TransferCaseloadHandler moves clients from one caseworker to another.
+// Caseworkers can hand off their own clients, and admins can move clients
+// between any two caseworkers. Leaving out client_emails moves the whole
+// caseload.
+func TransferCaseloadHandler(c echo.Context, client *db.PrismaClient) error {
+	user := ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, TransferCaseloadResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	var request TransferCaseloadRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&request); err != nil {
+		return c.JSON(500, TransferCaseloadResponse{Success: false, Error: "Failed to parse request body"})
+	}
+
+	fromEmail := request.FromEmail
+	if user.Type == db.UserTypeCaseWorker {
+		if fromEmail != "" && fromEmail != user.Email {
+			return c.JSON(400, TransferCaseloadResponse{Success: false, Error: "Caseworkers can only transfer their own caseload"})
+		}
+		fromEmail = user.Email
+	} else if user.Type != db.UserTypeAdmin {
+		return c.JSON(400, TransferCaseloadResponse{Success: false, Error: "Not allowed to transfer caseloads"})
+	}
+
+	if fromEmail == "" || request.ToEmail == "" {
+		return c.JSON(400, TransferCaseloadResponse{Success: false, Error: "Both caseworkers are required"})
+	}
+	if fromEmail == request.ToEmail {
+		return c.JSON(400, TransferCaseloadResponse{Success: false, Error: "Cannot transfer a caseload to the same caseworker"})
+	}
+
+	fromCaseworker, fromErr := findCaseworker(client, fromEmail)
+	if fromErr != nil {
+		return c.JSON(400, TransferCaseloadResponse{Success: false, Error: "Failed to find caseworker to transfer from"})
+	}
+	toCaseworker, toErr := findCaseworker(client, request.ToEmail)
+	if toErr != nil {
+		return c.JSON(400, TransferCaseloadResponse{Success: false, Error: "Failed to find caseworker to transfer to"})
+	}
+
+	filters := []db.UserLinkWhereParam{
+		db.UserLink.CaseworkerID.Equals(fromCaseworker.ID),
+	}
+	if len(request.ClientEmails) > 0 {
+		filters = append(filters, db.UserLink.Client.Where(
+			db.User.Email.In(request.ClientEmails),
+		))
+	}
+
+	links, linksErr := client.UserLink.FindMany(
+		filters...,
+	).With(
+		db.UserLink.Client.Fetch().With(
+			db.User.PersonalInfo.Fetch(),
+		),
+	).Exec(context.Background())
+	if linksErr != nil {
+		fmt.Printf("[ERROR] Failed to get caseload for caseworker %d: %v\n", fromCaseworker.ID, linksErr)
+		return c.JSON(500, TransferCaseloadResponse{Success: false, Error: "Failed to get caseload"})
+	}
+
+	for _, clientEmail := range request.ClientEmails {
+		if !slices.ContainsFunc(links, func(link db.UserLinkModel) bool { return link.Client().Email == clientEmail }) {
+			return c.JSON(400, TransferCaseloadResponse{Success: false, Error: fmt.Sprintf("%s is not linked to %s", clientEmail, fromEmail)})
+		}
+	}
+	if len(links) == 0 {
+		return c.JSON(400, TransferCaseloadResponse{Success: false, Error: "No clients to transfer"})
+	}
+
+	// Clients already linked to the new caseworker only lose the old link
+	existingLinks, existingErr := client.UserLink.FindMany(
+		db.UserLink.CaseworkerID.Equals(toCaseworker.ID),
+	).Exec(context.Background())
+	if existingErr != nil {
+		fmt.Printf("[ERROR] Failed to get caseload for caseworker %d: %v\n", toCaseworker.ID, existingErr)
+		return c.JSON(500, TransferCaseloadResponse{Success: false, Error: "Failed to get caseload"})
+	}
+
+	transferred := make([]BaseUserInfo, len(links))
+	for i, link := range links {
+		linkClient := link.Client()
+
+		alreadyLinked := slices.ContainsFunc(existingLinks, func(existing db.UserLinkModel) bool { return existing.ClientID == linkClient.ID })
+		txs := transferWrites(client, user, &link, fromCaseworker, toCaseworker, alreadyLinked, request.Reason)
+		if txErr := client.Prisma.Transaction(txs...).Exec(context.Background()); txErr != nil {
+			fmt.Printf("[ERROR] Failed to transfer client %d to caseworker %d: %v\n", linkClient.ID, toCaseworker.ID, txErr)
+			return c.JSON(500, TransferCaseloadResponse{Success: false, Transferred: transferred[:i], Error: "Failed to transfer caseload"})
+		}
+
+		// If it fails, it's fine. The client is still linked to the new caseworker.
+		email.SendCaseloadTransferEmail(linkClient.Email, fullName(fromCaseworker), fullName(toCaseworker), toCaseworker.Email)
+
+		transferred[i] = BaseUserInfo{
+			FirstName: linkClient.PersonalInfo().FirstName,
+			LastName:  linkClient.PersonalInfo().LastName,
+			Email:     linkClient.Email,
+		}
+	}
+
+	return c.JSON(200, TransferCaseloadResponse{Success: true, Transferred: transferred, Error: ""})
+}
+
+// GetCaseloadTransfersHandler lists the transfer history the user was part
+// of. Admins see every transfer.
+func GetCaseloadTransfersHandler(c echo.Context, client *db.PrismaClient) error {
+	user := ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, GetCaseloadTransfersResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	filters := []db.CaseloadTransferWhereParam{}
+	if user.Type != db.UserTypeAdmin {
+		filters = append(filters, db.CaseloadTransfer.Or(
+			db.CaseloadTransfer.ClientID.Equals(user.ID),
+			db.CaseloadTransfer.FromCaseworkerID.Equals(user.ID),
+			db.CaseloadTransfer.ToCaseworkerID.Equals(user.ID),
+		))
+	}
+
+	history, historyErr := client.CaseloadTransfer.FindMany(
+		filters...,
+	).With(
+		db.CaseloadTransfer.Client.Fetch().With(
+			db.User.PersonalInfo.Fetch(),
+		),
+		db.CaseloadTransfer.FromCaseworker.Fetch(),
+		db.CaseloadTransfer.ToCaseworker.Fetch(),
+		db.CaseloadTransfer.TransferredBy.Fetch(),
+	).OrderBy(
+		db.CaseloadTransfer.CreatedAt.Order(db.SortOrderDesc),
+	).Exec(context.Background())
+	if historyErr != nil {
+		fmt.Printf("[ERROR] Failed to get caseload transfers for user %d: %v\n", user.ID, historyErr)
+		return c.JSON(500, GetCaseloadTransfersResponse{Success: false, Error: "Failed to get caseload transfers"})
+	}
+
+	transfers := make([]CaseloadTransferInfo, len(history))
+	for i, transfer := range history {
+		transfers[i] = CaseloadTransferInfo{
+			ID: transfer.ID,
+			Client: BaseUserInfo{
+				FirstName: transfer.Client().PersonalInfo().FirstName,
+				LastName:  transfer.Client().PersonalInfo().LastName,
+				Email:     transfer.Client().Email,
+			},
+			Reason:    transfer.Reason,
+			CreatedAt: transfer.CreatedAt,
+		}
+		if from, ok := transfer.FromCaseworker(); ok {
+			transfers[i].FromCaseworker = from.Email
+		}
+		if to, ok := transfer.ToCaseworker(); ok {
+			transfers[i].ToCaseworker = to.Email
+		}
+		if by, ok := transfer.TransferredBy(); ok {
+			transfers[i].TransferredBy = by.Email
+		}
+	}
+
+	return c.JSON(200, GetCaseloadTransfersResponse{Success: true, Transfers: transfers, Error: ""})
+}
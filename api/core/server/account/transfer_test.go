@@ -0,0 +1,143 @@
+package account
+
+// Caseload transfer unit tests
+
+import (
+	testutil "api/core/test_util"
+	"api/db"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var mock_caseworkerA = db.UserModel{
+	InnerUser: db.InnerUser{
+		ID:             2,
+		Email:          "caseworker@ihfa.org",
+		LastAuth:       &mock_lastAuth,
+		AuthCode:       &mock_authCode,
+		Type:           db.UserTypeCaseWorker,
+		PersonalInfoID: 2,
+	},
+}
+var mock_caseworkerB = db.UserModel{
+	InnerUser: db.InnerUser{
+		ID:             3,
+		Email:          "caseworker2@ihfa.org",
+		Type:           db.UserTypeCaseWorker,
+		PersonalInfoID: 3,
+	},
+}
+
+// transferCaseload forms an http test on the TransferCaseload endpoint as
+// caseworker A
+func transferCaseload(t *testing.T, client *db.PrismaClient, request TransferCaseloadRequest) (int, TransferCaseloadResponse) {
+	rec, c, reqErr := testutil.PrepareRequestJSON(http.MethodPost, "/api/account/caseload/transfer", request)
+	c.Request().Header.Set(EmailHeaderKey, mock_caseworkerA.Email)
+	c.Request().Header.Set(AuthHeaderKey, mock_authCode)
+	assert.NoError(t, reqErr, "Failed to prepare request")
+	assert.NoError(t, TransferCaseloadHandler(c, client), "Error while transferring caseload")
+
+	var response TransferCaseloadResponse
+	unmarshalErr := json.Unmarshal(rec.Body.Bytes(), &response)
+	assert.Nil(t, unmarshalErr, "Error while parsing response body")
+	return rec.Code, response
+}
+
+func TestTransferOtherCaseload(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	client, mock, ensure := db.NewMock()
+	defer ensure(t)
+
+	// Mock auth
+	mock.User.Expect(
+		client.User.FindFirst(
+			db.User.Email.Equals(mock_caseworkerA.Email),
+			db.User.AuthCode.Equals(mock_authCode),
+		),
+	).Returns(mock_caseworkerA)
+
+	code, response := transferCaseload(t, client, TransferCaseloadRequest{
+		FromEmail: mock_caseworkerB.Email,
+		ToEmail:   mock_caseworkerA.Email,
+	})
+	assert.Equal(t, 400, code, "Bad status code")
+	assert.False(t, response.Success, "Caseworkers should not take other caseloads")
+	assert.Equal(t, "Caseworkers can only transfer their own caseload", response.Error, "Bad error message")
+}
+
+func TestTransferUnlinkedClient(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	client, mock, ensure := db.NewMock()
+	defer ensure(t)
+
+	// Mock auth
+	mock.User.Expect(
+		client.User.FindFirst(
+			db.User.Email.Equals(mock_caseworkerA.Email),
+			db.User.AuthCode.Equals(mock_authCode),
+		),
+	).Returns(mock_caseworkerA)
+
+	// Mock find both caseworkers
+	for _, caseworker := range []db.UserModel{mock_caseworkerA, mock_caseworkerB} {
+		mock.User.Expect(
+			client.User.FindUnique(
+				db.User.Email.Equals(caseworker.Email),
+			).With(
+				db.User.PersonalInfo.Fetch(),
+			),
+		).Returns(caseworker)
+	}
+
+	// Mock find caseload, the client isn't in it
+	mock.UserLink.Expect(
+		client.UserLink.FindMany(
+			db.UserLink.CaseworkerID.Equals(mock_caseworkerA.ID),
+			db.UserLink.Client.Where(
+				db.User.Email.In([]string{mock_userA.Email}),
+			),
+		).With(
+			db.UserLink.Client.Fetch().With(
+				db.User.PersonalInfo.Fetch(),
+			),
+		),
+	).ReturnsMany([]db.UserLinkModel{})
+
+	code, response := transferCaseload(t, client, TransferCaseloadRequest{
+		ToEmail:      mock_caseworkerB.Email,
+		ClientEmails: []string{mock_userA.Email},
+	})
+	assert.Equal(t, 400, code, "Bad status code")
+	assert.False(t, response.Success, "Unlinked clients should not be transferred")
+	assert.Equal(t, "test@gmail.com is not linked to caseworker@ihfa.org", response.Error, "Bad error message")
+	assert.Empty(t, response.Transferred, "No one should be transferred")
+}
+
+func TestTransferWritesMoveOpenTasks(t *testing.T) {
+	client, _, ensure := db.NewMock()
+	defer ensure(t)
+
+	link := db.UserLinkModel{
+		InnerUserLink: db.InnerUserLink{
+			ClientID:     mock_userA.ID,
+			CaseworkerID: mock_caseworkerA.ID,
+			CanEdit:      true,
+		},
+	}
+
+	// Unlinking, recording the transfer, moving the open tasks and linking
+	// the new caseworker all happen together
+	txs := transferWrites(client, &mock_caseworkerA, &link, &mock_caseworkerA, &mock_caseworkerB, false, "")
+	assert.Len(t, txs, 4, "The client's open tasks should move with their link")
+
+	// Clients already linked to the new caseworker still take their tasks
+	txs = transferWrites(client, &mock_caseworkerA, &link, &mock_caseworkerA, &mock_caseworkerB, true, "")
+	assert.Len(t, txs, 4, "The client's open tasks should move with their link")
+}
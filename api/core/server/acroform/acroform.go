@@ -0,0 +1,388 @@
+package acroform
+
+// Reading and filling the fields of fillable PDF forms (AcroForms), like the
+// applications partner agencies hand out. Filled values are appended as an
+// incremental update and viewers are asked to redraw the fields, so the rest
+// of the form is kept exactly as the agency made it.
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type FieldType string
+
+const (
+	FieldText      FieldType = "text"
+	FieldCheckbox  FieldType = "checkbox"
+	FieldRadio     FieldType = "radio"
+	FieldChoice    FieldType = "choice"
+	FieldSignature FieldType = "signature"
+	FieldButton    FieldType = "button"
+)
+
+// Field flags, from the PDF spec.
+const (
+	flagReadOnly   = 1 << 0
+	flagRequired   = 1 << 1
+	flagRadio      = 1 << 15
+	flagPushbutton = 1 << 16
+)
+
+var (
+	ErrNoForm       = errors.New("pdf has no form fields")
+	ErrUnknownField = errors.New("no such form field")
+	ErrInvalidValue = errors.New("invalid value for form field")
+	ErrNotFillable  = errors.New("form field can't be filled")
+)
+
+// Field is a form field, named by its fully qualified name, the names of it
+// and its parents joined with dots.
+type Field struct {
+	Name     string    `json:"name"`
+	Type     FieldType `json:"type"`
+	Required bool      `json:"required"`
+	ReadOnly bool      `json:"read_only"`
+	// What checkboxes, radio buttons and choice fields can be set to. For a
+	// checkbox, that's the value it has when checked.
+	Options []string `json:"options"`
+	Value   string   `json:"value"`
+
+	ref ref
+	// Widget annotations showing the field, which may be the field itself
+	widgets []ref
+}
+
+// inherited are the attributes fields take from their parents when they
+// don't have their own.
+type inherited struct {
+	name    string
+	kind    name
+	flags   int
+	value   interface{}
+	options interface{}
+}
+
+// readFields walks the field tree from the form's /Fields.
+func readFields(doc *document) ([]Field, error) {
+	catalog := doc.getDict(doc.trailer["Root"])
+	form := doc.getDict(catalog["AcroForm"])
+	if form == nil {
+		return nil, ErrNoForm
+	}
+	roots, _ := doc.get(form["Fields"])
+	rootFields, _ := roots.(array)
+	if len(rootFields) == 0 {
+		return nil, ErrNoForm
+	}
+
+	fields := []Field{}
+	seen := map[int]bool{}
+	var walk func(value interface{}, parent inherited) error
+	walk = func(value interface{}, parent inherited) error {
+		reference, ok := value.(ref)
+		if !ok {
+			return fmt.Errorf("%w: form field isn't an indirect object", ErrInvalidPDF)
+		}
+		if seen[reference.num] {
+			return nil
+		}
+		seen[reference.num] = true
+		field := doc.getDict(reference)
+		if field == nil {
+			return nil
+		}
+
+		current := parent
+		if partial, ok := field["T"].(text); ok {
+			current.name = decodeText(partial)
+			if parent.name != "" {
+				current.name = parent.name + "." + current.name
+			}
+		}
+		if kind, ok := field["FT"].(name); ok {
+			current.kind = kind
+		}
+		if flags, ok := field["Ff"].(number); ok {
+			current.flags, _ = flags.int()
+		}
+		if fieldValue, ok := field["V"]; ok {
+			current.value = fieldValue
+		}
+		if options, ok := field["Opt"]; ok {
+			current.options = options
+		}
+
+		// Kids with names are fields, the rest are widgets
+		kidsValue, _ := doc.get(field["Kids"])
+		kids, _ := kidsValue.(array)
+		widgets := []ref{}
+		childFields := array{}
+		for _, kid := range kids {
+			kidDict := doc.getDict(kid)
+			if _, named := kidDict["T"]; named {
+				childFields = append(childFields, kid)
+			} else if kidRef, ok := kid.(ref); ok && kidDict != nil {
+				widgets = append(widgets, kidRef)
+			}
+		}
+		for _, child := range childFields {
+			if err := walk(child, current); err != nil {
+				return err
+			}
+		}
+		if len(childFields) > 0 && len(widgets) == 0 {
+			return nil
+		}
+		if len(kids) == 0 {
+			widgets = append(widgets, reference)
+		}
+		if current.name == "" {
+			return nil
+		}
+		fields = append(fields, doc.toField(reference, current, widgets))
+		return nil
+	}
+
+	for _, root := range rootFields {
+		if err := walk(root, inherited{}); err != nil {
+			return nil, err
+		}
+	}
+	if len(fields) == 0 {
+		return nil, ErrNoForm
+	}
+	return fields, nil
+}
+
+func (doc *document) toField(reference ref, attributes inherited, widgets []ref) Field {
+	field := Field{
+		Name:     attributes.name,
+		Required: attributes.flags&flagRequired != 0,
+		ReadOnly: attributes.flags&flagReadOnly != 0,
+		Options:  []string{},
+		ref:      reference,
+		widgets:  widgets,
+	}
+
+	switch attributes.kind {
+	case "Tx":
+		field.Type = FieldText
+	case "Ch":
+		field.Type = FieldChoice
+	case "Sig":
+		field.Type = FieldSignature
+	case "Btn":
+		switch {
+		case attributes.flags&flagPushbutton != 0:
+			field.Type = FieldButton
+		case attributes.flags&flagRadio != 0:
+			field.Type = FieldRadio
+		default:
+			field.Type = FieldCheckbox
+		}
+	default:
+		field.Type = FieldText
+	}
+
+	switch field.Type {
+	case FieldCheckbox, FieldRadio:
+		for _, widget := range widgets {
+			for _, state := range doc.onStates(widget) {
+				if !slices.Contains(field.Options, state) {
+					field.Options = append(field.Options, state)
+				}
+			}
+		}
+	case FieldChoice:
+		optionsValue, _ := doc.get(attributes.options)
+		options, _ := optionsValue.(array)
+		for _, option := range options {
+			option, _ = doc.get(option)
+			// Options are either values, or [value, what's shown] pairs
+			if pair, ok := option.(array); ok && len(pair) > 0 {
+				option, _ = doc.get(pair[0])
+			}
+			if value, ok := option.(text); ok {
+				field.Options = append(field.Options, decodeText(value))
+			}
+		}
+	}
+
+	fieldValue, _ := doc.get(attributes.value)
+	switch v := fieldValue.(type) {
+	case text:
+		field.Value = decodeText(v)
+	case name:
+		if v != "Off" {
+			field.Value = string(v)
+		}
+	case array:
+		values := []string{}
+		for _, item := range v {
+			if value, ok := item.(text); ok {
+				values = append(values, decodeText(value))
+			}
+		}
+		field.Value = strings.Join(values, ", ")
+	}
+	return field
+}
+
+// onStates are the appearance states a checkbox or radio button widget can
+// be switched to, besides off.
+func (doc *document) onStates(widget ref) []string {
+	appearances := doc.getDict(doc.getDict(widget)["AP"])
+	normal := doc.getDict(appearances["N"])
+	states := []string{}
+	for state := range normal {
+		if state != "Off" {
+			states = append(states, string(state))
+		}
+	}
+	sort.Strings(states)
+	return states
+}
+
+// copyDict copies a dictionary so it can be changed without touching the
+// parsed original.
+func copyDict(d dict) dict {
+	copied := dict{}
+	for key, value := range d {
+		copied[key] = value
+	}
+	return copied
+}
+
+// Read lists the form's fields.
+func Read(pdf []byte) ([]Field, error) {
+	doc, err := load(pdf)
+	if err != nil {
+		return nil, err
+	}
+	return readFields(doc)
+}
+
+// IsChecked reads a value for a checkbox. Blank, "off", "no" and "false"
+// leave it unchecked.
+func IsChecked(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "off", "no", "false", "0":
+		return false
+	}
+	return true
+}
+
+// Fill sets form fields by name, and returns the filled PDF. A checkbox is
+// checked by any value IsChecked accepts, radio buttons and choice fields
+// take one of their options.
+func Fill(pdf []byte, values map[string]string) ([]byte, error) {
+	doc, err := load(pdf)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := readFields(doc)
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]Field{}
+	for _, field := range fields {
+		byName[field.Name] = field
+	}
+
+	names := make([]string, 0, len(values))
+	for fieldName := range values {
+		names = append(names, fieldName)
+	}
+	sort.Strings(names)
+	for _, fieldName := range names {
+		field, ok := byName[fieldName]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownField, fieldName)
+		}
+		if err := doc.fill(field, values[fieldName]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Fields are shown with appearance streams drawn for their old values,
+	// so viewers are asked to draw new ones
+	catalogRef := doc.trailer["Root"].(ref)
+	if formRef, ok := doc.getDict(catalogRef)["AcroForm"].(ref); ok {
+		form := copyDict(doc.getDict(formRef))
+		form["NeedAppearances"] = true
+		doc.change(formRef, form)
+	} else {
+		catalog := copyDict(doc.getDict(catalogRef))
+		form := copyDict(doc.getDict(catalog["AcroForm"]))
+		form["NeedAppearances"] = true
+		catalog["AcroForm"] = form
+		doc.change(catalogRef, catalog)
+	}
+	return doc.update(), nil
+}
+
+func (doc *document) fill(field Field, value string) error {
+	fieldDict := copyDict(doc.getDict(field.ref))
+
+	switch field.Type {
+	case FieldText:
+		fieldDict["V"] = encodeText(value)
+		doc.change(field.ref, fieldDict)
+		// The old appearance would show the old value
+		for _, widget := range field.widgets {
+			widgetDict := copyDict(doc.getDict(widget))
+			delete(widgetDict, "AP")
+			doc.change(widget, widgetDict)
+		}
+
+	case FieldChoice:
+		if value != "" && len(field.Options) > 0 && !slices.Contains(field.Options, value) {
+			return fmt.Errorf("%w: %s can't be %q", ErrInvalidValue, field.Name, value)
+		}
+		fieldDict["V"] = encodeText(value)
+		doc.change(field.ref, fieldDict)
+		for _, widget := range field.widgets {
+			widgetDict := copyDict(doc.getDict(widget))
+			delete(widgetDict, "AP")
+			doc.change(widget, widgetDict)
+		}
+
+	case FieldCheckbox, FieldRadio:
+		state := "Off"
+		switch {
+		case field.Type == FieldRadio && value != "":
+			if !slices.Contains(field.Options, value) {
+				return fmt.Errorf("%w: %s can't be %q", ErrInvalidValue, field.Name, value)
+			}
+			state = value
+		case field.Type == FieldCheckbox && IsChecked(value):
+			state = "Yes"
+			if slices.Contains(field.Options, value) {
+				state = value
+			} else if len(field.Options) > 0 {
+				state = field.Options[0]
+			}
+		}
+		fieldDict["V"] = name(state)
+		doc.change(field.ref, fieldDict)
+		// Each widget shows its own state, or off
+		for _, widget := range field.widgets {
+			// Changes are seen by getDict, so a widget that's also the field
+			// keeps its new value
+			widgetDict := copyDict(doc.getDict(widget))
+			widgetDict["AS"] = name("Off")
+			if slices.Contains(doc.onStates(widget), state) {
+				widgetDict["AS"] = name(state)
+			}
+			doc.change(widget, widgetDict)
+		}
+
+	default:
+		return fmt.Errorf("%w: %s is a %s", ErrNotFillable, field.Name, field.Type)
+	}
+	return nil
+}
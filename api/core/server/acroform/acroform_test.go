@@ -0,0 +1,253 @@
+package acroform
+
+// AcroForm unit tests
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The objects of a one page form, numbered from 1.
+var formObjects = []string{
+	"<< /Type /Catalog /Pages 2 0 R /AcroForm << /Fields [4 0 R 5 0 R 6 0 R 8 0 R 12 0 R 13 0 R] >> >>",
+	"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+	"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Annots [4 0 R 5 0 R 7 0 R 9 0 R 10 0 R 12 0 R 13 0 R] >>",
+	"<< /FT /Tx /T (Name) /Ff 2 /Type /Annot /Subtype /Widget /Rect [50 700 300 720] /P 3 0 R >>",
+	"<< /FT /Btn /T (Veteran) /V /Off /AS /Off /Type /Annot /Subtype /Widget /Rect [50 650 60 660] /AP << /N << /Yes 11 0 R /Off 11 0 R >> >> >>",
+	"<< /T (applicant) /Kids [7 0 R] >>",
+	"<< /FT /Tx /T (phone) /Parent 6 0 R /Type /Annot /Subtype /Widget /Rect [50 600 300 620] /AP << /N 11 0 R >> >>",
+	"<< /FT /Btn /Ff 49152 /T (Housing) /V /Off /Kids [9 0 R 10 0 R] >>",
+	"<< /Parent 8 0 R /Type /Annot /Subtype /Widget /Rect [50 550 60 560] /AS /Off /AP << /N << /Rent 11 0 R /Off 11 0 R >> >> >>",
+	"<< /Parent 8 0 R /Type /Annot /Subtype /Widget /Rect [80 550 90 560] /AS /Off /AP << /N << /Own 11 0 R /Off 11 0 R >> >> >>",
+	"<< /Type /XObject /Subtype /Form /BBox [0 0 10 10] /Length 8 >>\nstream\n0 0 m S\n\nendstream",
+	"<< /FT /Sig /T (Signature) /Type /Annot /Subtype /Widget /Rect [50 100 300 130] >>",
+	"<< /FT /Ch /T (County) /Opt [(Ada) [(CAN) (Canyon)]] /Type /Annot /Subtype /Widget /Rect [50 500 300 520] >>",
+}
+
+// Objects that go in an object stream when the form is compressed, which
+// can't hold streams.
+var compressible = map[int]bool{4: true, 5: true, 6: true, 7: true, 8: true, 9: true, 10: true, 12: true, 13: true}
+
+// buildPDF writes out objects with a cross-reference table, or when
+// compressed, with an object stream and an xref stream like newer writers.
+func buildPDF(objects []string, compressed bool) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	offsets := map[int]int{}
+	var objectStream, objectIndex bytes.Buffer
+	inStream := []int{}
+	for i, object := range objects {
+		num := i + 1
+		if compressed && compressible[num] {
+			fmt.Fprintf(&objectIndex, "%d %d ", num, objectStream.Len())
+			objectStream.WriteString(object + "\n")
+			inStream = append(inStream, num)
+			continue
+		}
+		offsets[num] = buffer.Len()
+		fmt.Fprintf(&buffer, "%d 0 obj\n%s\nendobj\n", num, object)
+	}
+
+	size := len(objects) + 1
+	if !compressed {
+		xref := buffer.Len()
+		fmt.Fprintf(&buffer, "xref\n0 %d\n0000000000 65535 f \n", size)
+		for num := 1; num < size; num++ {
+			fmt.Fprintf(&buffer, "%010d 00000 n \n", offsets[num])
+		}
+		fmt.Fprintf(&buffer, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", size, xref)
+		return buffer.Bytes()
+	}
+
+	streamNum, xrefNum := size, size+1
+	contents := deflate(append(objectIndex.Bytes(), objectStream.Bytes()...))
+	offsets[streamNum] = buffer.Len()
+	fmt.Fprintf(&buffer, "%d 0 obj\n<< /Type /ObjStm /N %d /First %d /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream\nendobj\n",
+		streamNum, len(inStream), objectIndex.Len(), len(contents), contents)
+
+	// Rows of type, offset and index, with the PNG up predictor
+	offsets[xrefNum] = buffer.Len()
+	rows := []byte{}
+	previous := make([]byte, 7)
+	for num := 0; num <= xrefNum; num++ {
+		row := make([]byte, 7)
+		switch {
+		case num == 0:
+		case compressed && compressible[num]:
+			row = []byte{2, 0, 0, 0, byte(streamNum), 0, byte(indexOf(inStream, num))}
+		default:
+			offset := offsets[num]
+			row = []byte{1, byte(offset >> 24), byte(offset >> 16), byte(offset >> 8), byte(offset), 0, 0}
+		}
+		rows = append(rows, 2)
+		for i := range row {
+			rows = append(rows, row[i]-previous[i])
+		}
+		previous = row
+	}
+	xrefData := deflate(rows)
+	fmt.Fprintf(&buffer, "%d 0 obj\n<< /Type /XRef /Size %d /W [1 4 2] /Root 1 0 R /Filter /FlateDecode /DecodeParms << /Predictor 12 /Columns 7 >> /Length %d >>\nstream\n%s\nendstream\nendobj\n",
+		xrefNum, xrefNum+1, len(xrefData), xrefData)
+	fmt.Fprintf(&buffer, "startxref\n%d\n%%%%EOF\n", offsets[xrefNum])
+	return buffer.Bytes()
+}
+
+func deflate(data []byte) []byte {
+	var buffer bytes.Buffer
+	writer := zlib.NewWriter(&buffer)
+	writer.Write(data)
+	writer.Close()
+	return buffer.Bytes()
+}
+
+func indexOf(values []int, value int) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
+
+func findField(fields []Field, fieldName string) Field {
+	for _, field := range fields {
+		if field.Name == fieldName {
+			return field
+		}
+	}
+	return Field{}
+}
+
+func TestRead(t *testing.T) {
+	for _, compressed := range []bool{false, true} {
+		fields, err := Read(buildPDF(formObjects, compressed))
+		assert.NoError(t, err, "Form should be read, compressed: %v", compressed)
+		assert.Len(t, fields, 6, "Every field should be found, compressed: %v", compressed)
+
+		name := findField(fields, "Name")
+		assert.Equal(t, FieldText, name.Type, "Text field type should be read")
+		assert.True(t, name.Required, "Required flag should be read")
+		assert.Equal(t, FieldText, findField(fields, "applicant.phone").Type, "Kids should be named after their parents")
+		assert.Equal(t, []string{"Yes"}, findField(fields, "Veteran").Options, "Checkbox on state should be read")
+		assert.Equal(t, FieldRadio, findField(fields, "Housing").Type, "Radio flag should be read")
+		assert.Equal(t, []string{"Rent", "Own"}, findField(fields, "Housing").Options, "Radio options should come from its widgets")
+		assert.Equal(t, []string{"Ada", "CAN"}, findField(fields, "County").Options, "Choices should be read")
+		assert.Equal(t, FieldSignature, findField(fields, "Signature").Type, "Signature type should be read")
+		assert.Empty(t, findField(fields, "Veteran").Value, "Unchecked checkbox should have no value")
+	}
+}
+
+func TestFill(t *testing.T) {
+	for _, compressed := range []bool{false, true} {
+		form := buildPDF(formObjects, compressed)
+		filled, err := Fill(form, map[string]string{
+			"Name":            "José Núñez",
+			"applicant.phone": "(208) 555-0100",
+			"Veteran":         "true",
+			"Housing":         "Rent",
+			"County":          "CAN",
+		})
+		assert.NoError(t, err, "Form should be filled, compressed: %v", compressed)
+		assert.True(t, bytes.HasPrefix(filled, form), "Filling should only append to the form")
+
+		fields, err := Read(filled)
+		assert.NoError(t, err, "Filled form should be read")
+		assert.Equal(t, "José Núñez", findField(fields, "Name").Value, "Text should be filled")
+		assert.Equal(t, "(208) 555-0100", findField(fields, "applicant.phone").Value, "Nested field should be filled")
+		assert.Equal(t, "Yes", findField(fields, "Veteran").Value, "Checkbox should be checked")
+		assert.Equal(t, "Rent", findField(fields, "Housing").Value, "Radio button should be picked")
+		assert.Equal(t, "CAN", findField(fields, "County").Value, "Choice should be picked")
+
+		doc, err := load(filled)
+		assert.NoError(t, err, "Filled form should load")
+		formDict := doc.getDict(doc.getDict(doc.trailer["Root"])["AcroForm"])
+		assert.Equal(t, true, formDict["NeedAppearances"], "Viewers should be asked to redraw fields")
+		assert.Equal(t, name("Rent"), doc.getDict(ref{num: 9})["AS"], "Picked radio button should show as on")
+		assert.Equal(t, name("Off"), doc.getDict(ref{num: 10})["AS"], "Other radio button should show as off")
+		assert.NotContains(t, doc.getDict(ref{num: 7}), name("AP"), "Old appearance of filled text should be dropped")
+
+		refilled, err := Fill(filled, map[string]string{"Veteran": "no"})
+		assert.NoError(t, err, "Filled form should be filled again")
+		fields, _ = Read(refilled)
+		assert.Empty(t, findField(fields, "Veteran").Value, "Checkbox should be unchecked")
+		assert.Equal(t, "José Núñez", findField(fields, "Name").Value, "Earlier values should be kept")
+	}
+}
+
+func TestFillErrors(t *testing.T) {
+	form := buildPDF(formObjects, false)
+	_, err := Fill(form, map[string]string{"Missing": "x"})
+	assert.ErrorIs(t, err, ErrUnknownField, "Unknown fields should be rejected")
+	_, err = Fill(form, map[string]string{"Housing": "Shelter"})
+	assert.ErrorIs(t, err, ErrInvalidValue, "Radio buttons should only take their options")
+	_, err = Fill(form, map[string]string{"Signature": "José"})
+	assert.ErrorIs(t, err, ErrNotFillable, "Signatures should not be filled")
+
+	_, err = Read(buildPDF(formObjects[:3], false))
+	assert.ErrorIs(t, err, ErrNoForm, "Fields that aren't there should not be read")
+	_, err = Read([]byte("not a pdf"))
+	assert.ErrorIs(t, err, ErrInvalidPDF, "Other files should be rejected")
+}
+
+func TestParseStrings(t *testing.T) {
+	p := &parser{data: []byte(`(a \(nested\) \101\n (pair)) <FEFF00E9> <41 4>`)}
+	literal, err := p.object()
+	assert.NoError(t, err, "Literal string should parse")
+	assert.Equal(t, "a (nested) A\n (pair)", decodeText(literal.(text)), "Escapes and balanced parens should be read")
+	utf16, _ := p.object()
+	assert.Equal(t, "é", decodeText(utf16.(text)), "UTF-16 strings should be decoded")
+	hex, _ := p.object()
+	assert.Equal(t, "A@", decodeText(hex.(text)), "Odd hex digits should be padded")
+}
+
+// xrefStreamPDF is a file whose only section is an uncompressed xref stream
+// with the given dictionary entries and rows.
+func xrefStreamPDF(entries string, rows []byte) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("%PDF-1.7\n")
+	offset := buffer.Len()
+	fmt.Fprintf(&buffer, "1 0 obj\n<< /Type /XRef /Root 1 0 R %s /Length %d >>\nstream\n%s\nendstream\nendobj\n", entries, len(rows), rows)
+	fmt.Fprintf(&buffer, "startxref\n%d\n%%%%EOF\n", offset)
+	return buffer.Bytes()
+}
+
+func TestReadCraftedFiles(t *testing.T) {
+	crafted := map[string][]byte{
+		"negative width":      xrefStreamPDF("/W [1 -4 2] /Size 2", make([]byte, 14)),
+		"wide field":          xrefStreamPDF("/W [1 9 2] /Size 1", make([]byte, 12)),
+		"empty rows":          xrefStreamPDF("/W [0 0 0] /Size 2000000000", nil),
+		"too many rows":       xrefStreamPDF("/W [1 4 2] /Size 2000000000", make([]byte, 14)),
+		"negative index":      xrefStreamPDF("/W [1 4 2] /Index [-5 1]", make([]byte, 7)),
+		"offset past the end": xrefStreamPDF("/W [1 4 2] /Size 2", []byte{0, 0, 0, 0, 0, 0, 0, 1, 0x7f, 0xff, 0xff, 0xff, 0, 0}),
+		"compressed cycle":    xrefStreamPDF("/W [1 1 1] /Size 3", []byte{0, 0, 0, 2, 2, 0, 2, 1, 0}),
+		"stream length":       []byte("1 0 obj << /Length 9223372036854775807 >> stream\nendstream\nendobj\nstartxref 0"),
+		"nested arrays":       append(append([]byte("1 0 obj "), bytes.Repeat([]byte("["), 100000)...), []byte("\nstartxref 0")...),
+	}
+	for name, pdf := range crafted {
+		_, err := Read(pdf)
+		assert.Error(t, err, "Crafted file should be rejected: %s", name)
+	}
+}
+
+func FuzzRead(f *testing.F) {
+	f.Add(buildPDF(formObjects, false))
+	f.Add(buildPDF(formObjects, true))
+	f.Add(xrefStreamPDF("/W [1 4 2] /Size 1", make([]byte, 7)))
+	f.Add([]byte("not a pdf"))
+	f.Fuzz(func(t *testing.T, pdf []byte) {
+		// Anything goes, as long as it doesn't panic or hang
+		if fields, err := Read(pdf); err == nil {
+			values := map[string]string{}
+			for _, field := range fields {
+				if field.Type == FieldText {
+					values[field.Name] = "x"
+				}
+			}
+			Fill(pdf, values)
+		}
+	})
+}
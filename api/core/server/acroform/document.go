@@ -0,0 +1,543 @@
+package acroform
+
+// Finding objects in a PDF through its cross-reference sections, and
+// appending changed objects as an incremental update.
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+var ErrEncrypted = errors.New("encrypted pdfs can't be filled")
+
+type xrefEntry struct {
+	// Byte offset, or the number of the object stream holding the object
+	offset int
+	gen    int
+	// Index in the object stream, for compressed objects
+	index      int
+	compressed bool
+}
+
+type document struct {
+	data    []byte
+	entries map[int]xrefEntry
+	// The newest trailer, or the dictionary of the newest xref stream
+	trailer dict
+	// Offset of the newest cross-reference section
+	lastXref int
+	// Whether the newest section is an xref stream, which an update has to
+	// follow with another
+	xrefStream bool
+	objects    map[int]interface{}
+	// Objects changed by filling, written out by update
+	changed map[int]interface{}
+}
+
+func load(data []byte) (*document, error) {
+	doc := &document{
+		data:    data,
+		entries: map[int]xrefEntry{},
+		objects: map[int]interface{}{},
+		changed: map[int]interface{}{},
+	}
+
+	start := bytes.LastIndex(data, []byte("startxref"))
+	if start < 0 {
+		return nil, fmt.Errorf("%w: no startxref", ErrInvalidPDF)
+	}
+	p := &parser{data: data, pos: start + len("startxref")}
+	offset, err := strconv.Atoi(p.keyword())
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid startxref", ErrInvalidPDF)
+	}
+	doc.lastXref = offset
+
+	// Newer sections come first, and their entries win
+	seen := map[int]bool{}
+	for first := true; offset > 0 && !seen[offset]; first = false {
+		seen[offset] = true
+		current, err := doc.readSection(offset)
+		if err != nil {
+			return nil, err
+		}
+		if first {
+			doc.trailer = current.trailer
+			doc.xrefStream = current.stream
+		}
+		// Hybrid files list their newer objects in an xref stream, which
+		// wins over the table
+		if hybrid, ok := current.trailer["XRefStm"].(number); ok {
+			if hybridOffset, ok := hybrid.int(); ok {
+				hybridSection, err := doc.readSection(hybridOffset)
+				if err != nil {
+					return nil, err
+				}
+				doc.addEntries(hybridSection)
+			}
+		}
+		doc.addEntries(current)
+		offset = 0
+		if prev, ok := current.trailer["Prev"].(number); ok {
+			offset, _ = prev.int()
+		}
+	}
+
+	if _, ok := doc.trailer["Encrypt"]; ok {
+		return nil, ErrEncrypted
+	}
+	if _, ok := doc.trailer["Root"].(ref); !ok {
+		return nil, fmt.Errorf("%w: no document catalog", ErrInvalidPDF)
+	}
+	return doc, nil
+}
+
+// section is one cross-reference table or stream, and its trailer.
+type section struct {
+	trailer dict
+	// An xref stream rather than a table
+	stream  bool
+	entries map[int]xrefEntry
+}
+
+func (doc *document) addEntries(s section) {
+	for num, entry := range s.entries {
+		if _, ok := doc.entries[num]; !ok {
+			doc.entries[num] = entry
+		}
+	}
+}
+
+// readSection reads the cross-reference table or stream at offset.
+func (doc *document) readSection(offset int) (section, error) {
+	if offset < 0 || offset >= len(doc.data) {
+		return section{}, fmt.Errorf("%w: cross-reference offset out of range", ErrInvalidPDF)
+	}
+	p := &parser{data: doc.data, pos: offset, resolve: doc.resolve}
+	start := p.pos
+	if p.keyword() != "xref" {
+		p.pos = start
+		return doc.readXrefStream(p)
+	}
+
+	entries := map[int]xrefEntry{}
+	for {
+		start := p.pos
+		first, firstErr := strconv.Atoi(p.keyword())
+		if firstErr != nil {
+			p.pos = start
+			break
+		}
+		count, err := strconv.Atoi(p.keyword())
+		if err != nil {
+			return section{}, p.errorf("invalid cross-reference subsection")
+		}
+		for i := 0; i < count; i++ {
+			entryOffset, offsetErr := strconv.Atoi(p.keyword())
+			gen, genErr := strconv.Atoi(p.keyword())
+			kind := p.keyword()
+			if offsetErr != nil || genErr != nil || (kind != "n" && kind != "f") {
+				return section{}, p.errorf("invalid cross-reference entry")
+			}
+			if kind == "n" {
+				entries[first+i] = xrefEntry{offset: entryOffset, gen: gen}
+			} else {
+				entries[first+i] = xrefEntry{offset: -1, gen: gen}
+			}
+		}
+	}
+
+	if err := p.expect("trailer"); err != nil {
+		return section{}, err
+	}
+	trailer, err := p.object()
+	if err != nil {
+		return section{}, err
+	}
+	trailerDict, ok := trailer.(dict)
+	if !ok {
+		return section{}, p.errorf("invalid trailer")
+	}
+	return section{trailer: trailerDict, entries: entries}, nil
+}
+
+func (doc *document) readXrefStream(p *parser) (section, error) {
+	_, value, err := indirectObject(p)
+	if err != nil {
+		return section{}, err
+	}
+	xref, ok := value.(stream)
+	if !ok || xref.dict["Type"] != name("XRef") {
+		return section{}, p.errorf("expected a cross-reference section")
+	}
+	data, err := decodeStream(xref)
+	if err != nil {
+		return section{}, err
+	}
+
+	// Fields are at most 8 bytes, so they fit in an int, and every row has
+	// an offset
+	widths := []int{}
+	if w, ok := xref.dict["W"].(array); ok {
+		for _, width := range w {
+			n, _ := width.(number)
+			value, ok := n.int()
+			if !ok || value < 0 || value > 8 {
+				return section{}, p.errorf("invalid cross-reference stream widths")
+			}
+			widths = append(widths, value)
+		}
+	}
+	if len(widths) != 3 || widths[1] == 0 {
+		return section{}, p.errorf("invalid cross-reference stream widths")
+	}
+	size, _ := xref.dict["Size"].(number)
+	index := array{number("0"), size}
+	if i, ok := xref.dict["Index"].(array); ok {
+		index = i
+	}
+
+	field := func(row []byte, i int) int {
+		start := 0
+		for _, width := range widths[:i] {
+			start += width
+		}
+		value := 0
+		for _, b := range row[start : start+widths[i]] {
+			value = value<<8 | int(b)
+		}
+		return value
+	}
+	entries := map[int]xrefEntry{}
+	rowWidth := widths[0] + widths[1] + widths[2]
+	rows := len(data) / rowWidth
+	row := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		firstNumber, _ := index[i].(number)
+		countNumber, _ := index[i+1].(number)
+		first, firstOk := firstNumber.int()
+		count, countOk := countNumber.int()
+		if !firstOk || !countOk || first < 0 || count < 0 {
+			return section{}, p.errorf("invalid cross-reference stream index")
+		}
+		if count > rows-row {
+			return section{}, p.errorf("cross-reference stream too short")
+		}
+		for j := 0; j < count; j++ {
+			entry := data[row*rowWidth : (row+1)*rowWidth]
+			row++
+			// The type defaults to 1 when its width is 0
+			kind := 1
+			if widths[0] > 0 {
+				kind = field(entry, 0)
+			}
+			switch kind {
+			case 0:
+				entries[first+j] = xrefEntry{offset: -1}
+			case 1:
+				entries[first+j] = xrefEntry{offset: field(entry, 1), gen: field(entry, 2)}
+			case 2:
+				entries[first+j] = xrefEntry{offset: field(entry, 1), index: field(entry, 2), compressed: true}
+			}
+		}
+	}
+	return section{trailer: xref.dict, stream: true, entries: entries}, nil
+}
+
+// indirectObject reads "12 0 obj ... endobj".
+func indirectObject(p *parser) (ref, interface{}, error) {
+	num, numErr := strconv.Atoi(p.keyword())
+	gen, genErr := strconv.Atoi(p.keyword())
+	if numErr != nil || genErr != nil {
+		return ref{}, nil, p.errorf("expected an object")
+	}
+	if err := p.expect("obj"); err != nil {
+		return ref{}, nil, err
+	}
+	value, err := p.object()
+	if err != nil {
+		return ref{}, nil, err
+	}
+	return ref{num: num, gen: gen}, value, nil
+}
+
+// maxStreamSize caps how far a stream is inflated, so a small file can't
+// decompress into gigabytes.
+const maxStreamSize = 64 << 20
+
+// decodeStream undoes a stream's filters. Only Flate is supported, as the
+// only filter forms' structure is stored with.
+func decodeStream(s stream) ([]byte, error) {
+	filters := array{}
+	switch filter := s.dict["Filter"].(type) {
+	case name:
+		filters = array{filter}
+	case array:
+		filters = filter
+	}
+	if len(filters) == 0 {
+		return s.data, nil
+	}
+	if len(filters) > 1 || filters[0] != name("FlateDecode") {
+		return nil, fmt.Errorf("%w: unsupported stream filter %v", ErrInvalidPDF, filters)
+	}
+
+	reader, err := zlib.NewReader(bytes.NewReader(s.data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPDF, err)
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, maxStreamSize+1))
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPDF, err)
+	}
+	if len(data) > maxStreamSize {
+		return nil, fmt.Errorf("%w: stream too large", ErrInvalidPDF)
+	}
+
+	params, _ := s.dict["DecodeParms"].(dict)
+	if decodeParams, ok := s.dict["DecodeParms"].(array); ok && len(decodeParams) > 0 {
+		params, _ = decodeParams[0].(dict)
+	}
+	predictor, _ := params["Predictor"].(number)
+	if value, _ := predictor.int(); value < 10 {
+		return data, nil
+	}
+	columns := 1
+	if value, ok := params["Columns"].(number); ok {
+		columns, _ = value.int()
+	}
+	return unpredict(data, columns)
+}
+
+// unpredict undoes PNG predictors, which xref streams are often written
+// with. Each row starts with the predictor it uses.
+func unpredict(data []byte, columns int) ([]byte, error) {
+	rowWidth := columns + 1
+	if columns <= 0 || columns > len(data) || len(data)%rowWidth != 0 {
+		return nil, fmt.Errorf("%w: invalid predictor columns", ErrInvalidPDF)
+	}
+	decoded := make([]byte, 0, len(data)/rowWidth*columns)
+	previous := make([]byte, columns)
+	for start := 0; start < len(data); start += rowWidth {
+		predictor := data[start]
+		row := append([]byte{}, data[start+1:start+rowWidth]...)
+		for i := range row {
+			left, upLeft := byte(0), byte(0)
+			if i > 0 {
+				left = row[i-1]
+				upLeft = previous[i-1]
+			}
+			up := previous[i]
+			switch predictor {
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			}
+		}
+		decoded = append(decoded, row...)
+		previous = row
+	}
+	return decoded, nil
+}
+
+func paeth(left, up, upLeft byte) byte {
+	estimate := int(left) + int(up) - int(upLeft)
+	distance := func(b byte) int {
+		d := estimate - int(b)
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+	switch {
+	case distance(left) <= distance(up) && distance(left) <= distance(upLeft):
+		return left
+	case distance(up) <= distance(upLeft):
+		return up
+	}
+	return upLeft
+}
+
+// resolve gets the object a reference points to, with changes made so far.
+func (doc *document) resolve(reference ref) (interface{}, error) {
+	if value, ok := doc.changed[reference.num]; ok {
+		return value, nil
+	}
+	if value, ok := doc.objects[reference.num]; ok {
+		return value, nil
+	}
+	entry, ok := doc.entries[reference.num]
+	if !ok || entry.offset < 0 {
+		// References to missing objects are null
+		return nil, nil
+	}
+
+	// Keep resolving references, like a stream's length or an object
+	// stream, from failing forever on a cycle
+	doc.objects[reference.num] = nil
+	var value interface{}
+	var err error
+	if entry.compressed {
+		value, err = doc.compressedObject(entry)
+	} else if entry.offset >= len(doc.data) {
+		err = fmt.Errorf("%w: object %d offset out of range", ErrInvalidPDF, reference.num)
+	} else {
+		var found ref
+		found, value, err = indirectObject(&parser{data: doc.data, pos: entry.offset, resolve: doc.resolve})
+		if err == nil && found.num != reference.num {
+			err = fmt.Errorf("%w: object %d not at its offset", ErrInvalidPDF, reference.num)
+		}
+	}
+	if err != nil {
+		delete(doc.objects, reference.num)
+		return nil, err
+	}
+	doc.objects[reference.num] = value
+	return value, nil
+}
+
+// compressedObject reads an object out of an object stream.
+func (doc *document) compressedObject(entry xrefEntry) (interface{}, error) {
+	container, err := doc.resolve(ref{num: entry.offset})
+	if err != nil {
+		return nil, err
+	}
+	objectStream, ok := container.(stream)
+	if !ok {
+		return nil, fmt.Errorf("%w: object stream %d not found", ErrInvalidPDF, entry.offset)
+	}
+	data, err := decodeStream(objectStream)
+	if err != nil {
+		return nil, err
+	}
+
+	firstNumber, _ := objectStream.dict["First"].(number)
+	first, ok := firstNumber.int()
+	if !ok || first < 0 || first > len(data) {
+		return nil, fmt.Errorf("%w: invalid object stream %d", ErrInvalidPDF, entry.offset)
+	}
+	p := &parser{data: data}
+	offset := -1
+	for i := 0; i <= entry.index; i++ {
+		p.keyword()
+		offset, err = strconv.Atoi(p.keyword())
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid object stream %d", ErrInvalidPDF, entry.offset)
+		}
+	}
+	if offset < 0 || offset >= len(data)-first {
+		return nil, fmt.Errorf("%w: invalid object stream %d", ErrInvalidPDF, entry.offset)
+	}
+	p.pos = first + offset
+	return p.object()
+}
+
+// get resolves a value if it's a reference.
+func (doc *document) get(value interface{}) (interface{}, error) {
+	if reference, ok := value.(ref); ok {
+		return doc.resolve(reference)
+	}
+	return value, nil
+}
+
+func (doc *document) getDict(value interface{}) dict {
+	resolved, _ := doc.get(value)
+	d, _ := resolved.(dict)
+	return d
+}
+
+// change replaces an object, to be written out by update.
+func (doc *document) change(reference ref, value interface{}) {
+	doc.changed[reference.num] = value
+}
+
+func (doc *document) size() int {
+	size, _ := doc.trailer["Size"].(number)
+	value, _ := size.int()
+	for num := range doc.entries {
+		value = max(value, num+1)
+	}
+	return value
+}
+
+func (doc *document) generation(num int) int {
+	if entry, ok := doc.entries[num]; ok && !entry.compressed {
+		return entry.gen
+	}
+	return 0
+}
+
+// update appends the changed objects to the file, with a cross-reference
+// section for them. The rest of the file is left as it was, so anything
+// this package doesn't understand is kept.
+func (doc *document) update() []byte {
+	var buffer bytes.Buffer
+	buffer.Write(doc.data)
+	if len(doc.data) > 0 && doc.data[len(doc.data)-1] != '\n' {
+		buffer.WriteByte('\n')
+	}
+
+	nums := make([]int, 0, len(doc.changed))
+	for num := range doc.changed {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	offsets := map[int]int{}
+	for _, num := range nums {
+		offsets[num] = buffer.Len()
+		fmt.Fprintf(&buffer, "%d %d obj\n", num, doc.generation(num))
+		write(&buffer, doc.changed[num])
+		buffer.WriteString("\nendobj\n")
+	}
+
+	trailer := dict{"Size": doc.size(), "Prev": doc.lastXref}
+	for _, key := range []name{"Root", "Info", "ID"} {
+		if value, ok := doc.trailer[key]; ok {
+			trailer[key] = value
+		}
+	}
+
+	xrefOffset := buffer.Len()
+	if doc.xrefStream {
+		// The xref stream is an object too, and lists itself
+		xrefNum := doc.size()
+		trailer["Size"] = xrefNum + 1
+		offsets[xrefNum] = xrefOffset
+		nums = append(nums, xrefNum)
+
+		index := array{}
+		var rows bytes.Buffer
+		for _, num := range nums {
+			index = append(index, num, 1)
+			offset := offsets[num]
+			gen := doc.generation(num)
+			rows.Write([]byte{1, byte(offset >> 24), byte(offset >> 16), byte(offset >> 8), byte(offset), byte(gen >> 8), byte(gen)})
+		}
+		trailer["Type"] = name("XRef")
+		trailer["W"] = array{1, 4, 2}
+		trailer["Index"] = index
+		fmt.Fprintf(&buffer, "%d 0 obj\n", xrefNum)
+		write(&buffer, stream{dict: trailer, data: rows.Bytes()})
+		buffer.WriteString("\nendobj\n")
+	} else {
+		buffer.WriteString("xref\n")
+		for _, num := range nums {
+			fmt.Fprintf(&buffer, "%d 1\n%010d %05d n \n", num, offsets[num], doc.generation(num))
+		}
+		buffer.WriteString("trailer\n")
+		write(&buffer, trailer)
+		buffer.WriteString("\n")
+	}
+	fmt.Fprintf(&buffer, "startxref\n%d\n%%%%EOF\n", xrefOffset)
+	return buffer.Bytes()
+}
@@ -0,0 +1,446 @@
+package acroform
+
+// PDF objects, and reading and writing them as text.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+)
+
+var ErrInvalidPDF = errors.New("invalid pdf")
+
+type name string
+
+// number keeps the text of a number, so it's written back as it was read.
+type number string
+
+type ref struct {
+	num int
+	gen int
+}
+
+type dict map[name]interface{}
+
+type array []interface{}
+
+// text is a string object, decoded from its literal or hex form.
+type text []byte
+
+type stream struct {
+	dict dict
+	// Still encoded with the stream's filters
+	data []byte
+}
+
+func (n number) int() (int, bool) {
+	value, err := strconv.Atoi(string(n))
+	return value, err == nil
+}
+
+// decodeText reads a text string, which is UTF-16 with a byte order mark or
+// PDFDocEncoding. PDFDocEncoding is read as Latin-1, which it matches for
+// everything a form is likely to use.
+func decodeText(value text) string {
+	if len(value) >= 2 && value[0] == 0xfe && value[1] == 0xff {
+		units := make([]uint16, 0, len(value)/2)
+		for i := 2; i+1 < len(value); i += 2 {
+			units = append(units, uint16(value[i])<<8|uint16(value[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	runes := make([]rune, len(value))
+	for i, b := range value {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// encodeText makes a text string, as UTF-16 if it isn't all ASCII.
+func encodeText(value string) text {
+	ascii := true
+	for _, r := range value {
+		if r > 0x7e {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return text(value)
+	}
+	encoded := []byte{0xfe, 0xff}
+	for _, unit := range utf16.Encode([]rune(value)) {
+		encoded = append(encoded, byte(unit>>8), byte(unit))
+	}
+	return encoded
+}
+
+func isWhitespace(b byte) bool {
+	return b == 0 || b == '\t' || b == '\n' || b == '\f' || b == '\r' || b == ' '
+}
+
+func isDelimiter(b byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), b) >= 0
+}
+
+func isRegular(b byte) bool {
+	return !isWhitespace(b) && !isDelimiter(b)
+}
+
+// parser reads objects from a PDF, starting at pos.
+type parser struct {
+	data []byte
+	pos  int
+	// Looks up the lengths of streams given as references
+	resolve func(ref) (interface{}, error)
+	// How many arrays and dictionaries the current object is inside
+	depth int
+}
+
+// maxDepth caps how deeply arrays and dictionaries nest, so a file of
+// brackets can't run the parser out of stack.
+const maxDepth = 100
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s at offset %d", ErrInvalidPDF, fmt.Sprintf(format, args...), p.pos)
+}
+
+func (p *parser) skipSpace() {
+	if p.pos < 0 || p.pos > len(p.data) {
+		// Offsets come from the file, so they can point anywhere
+		p.pos = len(p.data)
+	}
+	for p.pos < len(p.data) {
+		switch {
+		case isWhitespace(p.data[p.pos]):
+			p.pos++
+		case p.data[p.pos] == '%':
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// keyword reads a run of regular characters, like a number or "obj".
+func (p *parser) keyword() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.data) && isRegular(p.data[p.pos]) {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+func (p *parser) expect(keyword string) error {
+	if found := p.keyword(); found != keyword {
+		return p.errorf("expected %s, found %q", keyword, found)
+	}
+	return nil
+}
+
+func isInteger(token string) bool {
+	if token == "" {
+		return false
+	}
+	for _, c := range token {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *parser) object() (interface{}, error) {
+	p.skipSpace()
+	if p.pos < 0 || p.pos >= len(p.data) {
+		return nil, p.errorf("unexpected end of file")
+	}
+	if p.depth >= maxDepth {
+		return nil, p.errorf("objects nested too deeply")
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+
+	switch c := p.data[p.pos]; {
+	case c == '/':
+		return p.name()
+	case c == '(':
+		return p.literal()
+	case c == '<' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '<':
+		return p.dictOrStream()
+	case c == '<':
+		return p.hex()
+	case c == '[':
+		p.pos++
+		values := array{}
+		for {
+			p.skipSpace()
+			if p.pos < len(p.data) && p.data[p.pos] == ']' {
+				p.pos++
+				return values, nil
+			}
+			value, err := p.object()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+	}
+
+	token := p.keyword()
+	switch token {
+	case "":
+		return nil, p.errorf("unexpected %q", p.data[p.pos])
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	if isInteger(token) {
+		// An integer can start a reference, "12 0 R"
+		start := p.pos
+		gen := p.keyword()
+		if isInteger(gen) && p.keyword() == "R" {
+			num, _ := strconv.Atoi(token)
+			genNum, _ := strconv.Atoi(gen)
+			return ref{num: num, gen: genNum}, nil
+		}
+		p.pos = start
+	}
+	if _, err := strconv.ParseFloat(token, 64); err != nil {
+		return nil, p.errorf("unexpected %q", token)
+	}
+	return number(token), nil
+}
+
+func (p *parser) name() (name, error) {
+	p.pos++
+	var value []byte
+	for p.pos < len(p.data) && isRegular(p.data[p.pos]) {
+		c := p.data[p.pos]
+		if c == '#' && p.pos+2 < len(p.data) {
+			if decoded, err := strconv.ParseUint(string(p.data[p.pos+1:p.pos+3]), 16, 8); err == nil {
+				value = append(value, byte(decoded))
+				p.pos += 3
+				continue
+			}
+		}
+		value = append(value, c)
+		p.pos++
+	}
+	return name(value), nil
+}
+
+func (p *parser) literal() (text, error) {
+	p.pos++
+	value := []byte{}
+	depth := 1
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return value, nil
+			}
+		case '\\':
+			if p.pos >= len(p.data) {
+				continue
+			}
+			escaped := p.data[p.pos]
+			p.pos++
+			switch escaped {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// A line continuation
+				if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if escaped >= '0' && escaped <= '7' {
+					octal := int(escaped - '0')
+					for i := 0; i < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+						octal = octal*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					c = byte(octal)
+				} else {
+					c = escaped
+				}
+			}
+		}
+		value = append(value, c)
+	}
+	return nil, p.errorf("unterminated string")
+}
+
+func (p *parser) hex() (text, error) {
+	p.pos++
+	digits := []byte{}
+	for p.pos < len(p.data) && p.data[p.pos] != '>' {
+		if c := p.data[p.pos]; !isWhitespace(c) {
+			digits = append(digits, c)
+		}
+		p.pos++
+	}
+	if p.pos >= len(p.data) {
+		return nil, p.errorf("unterminated hex string")
+	}
+	p.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	value := make(text, len(digits)/2)
+	for i := range value {
+		decoded, err := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		if err != nil {
+			return nil, p.errorf("invalid hex string")
+		}
+		value[i] = byte(decoded)
+	}
+	return value, nil
+}
+
+func (p *parser) dictOrStream() (interface{}, error) {
+	p.pos += 2
+	values := dict{}
+	for {
+		p.skipSpace()
+		if bytes.HasPrefix(p.data[p.pos:], []byte(">>")) {
+			p.pos += 2
+			break
+		}
+		if p.pos >= len(p.data) || p.data[p.pos] != '/' {
+			return nil, p.errorf("expected a name in dictionary")
+		}
+		key, _ := p.name()
+		value, err := p.object()
+		if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+
+	// A stream follows its dictionary
+	start := p.pos
+	if p.keyword() != "stream" {
+		p.pos = start
+		return values, nil
+	}
+	if p.pos < len(p.data) && p.data[p.pos] == '\r' {
+		p.pos++
+	}
+	if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+		p.pos++
+	}
+
+	length := -1
+	lengthValue := values["Length"]
+	if reference, ok := lengthValue.(ref); ok && p.resolve != nil {
+		lengthValue, _ = p.resolve(reference)
+	}
+	if n, ok := lengthValue.(number); ok {
+		length, _ = n.int()
+	}
+	end := p.pos + length
+	if length < 0 || length > len(p.data)-p.pos || !bytes.Contains(p.data[end:min(end+12, len(p.data))], []byte("endstream")) {
+		// Some writers get the length wrong, so fall back to looking for the end
+		found := bytes.Index(p.data[p.pos:], []byte("endstream"))
+		if found < 0 {
+			return nil, p.errorf("unterminated stream")
+		}
+		end = p.pos + found
+		for end > p.pos && (p.data[end-1] == '\n' || p.data[end-1] == '\r') {
+			end--
+		}
+	}
+	data := p.data[p.pos:end]
+	p.pos = end
+	if err := p.expect("endstream"); err != nil {
+		return nil, err
+	}
+	return stream{dict: values, data: data}, nil
+}
+
+// write writes an object as PDF text.
+func write(buffer *bytes.Buffer, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		buffer.WriteString("null")
+	case bool:
+		buffer.WriteString(strconv.FormatBool(v))
+	case number:
+		buffer.WriteString(string(v))
+	case int:
+		buffer.WriteString(strconv.Itoa(v))
+	case name:
+		buffer.WriteByte('/')
+		for _, c := range []byte(v) {
+			if c < 0x21 || c > 0x7e || c == '#' || isDelimiter(c) {
+				fmt.Fprintf(buffer, "#%02X", c)
+			} else {
+				buffer.WriteByte(c)
+			}
+		}
+	case text:
+		fmt.Fprintf(buffer, "<%X>", []byte(v))
+	case ref:
+		fmt.Fprintf(buffer, "%d %d R", v.num, v.gen)
+	case array:
+		buffer.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buffer.WriteByte(' ')
+			}
+			write(buffer, item)
+		}
+		buffer.WriteByte(']')
+	case dict:
+		// Sorted, so the output doesn't change from run to run
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, string(key))
+		}
+		sort.Strings(keys)
+		buffer.WriteString("<<")
+		for _, key := range keys {
+			write(buffer, name(key))
+			buffer.WriteByte(' ')
+			write(buffer, v[name(key)])
+		}
+		buffer.WriteString(">>")
+	case stream:
+		streamDict := dict{}
+		for key, item := range v.dict {
+			streamDict[key] = item
+		}
+		streamDict["Length"] = len(v.data)
+		write(buffer, streamDict)
+		buffer.WriteString("\nstream\n")
+		buffer.Write(v.data)
+		buffer.WriteString("\nendstream")
+	}
+}
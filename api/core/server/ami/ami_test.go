@@ -0,0 +1,73 @@
+package ami
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+const limitsHeader = "fips,State_Alpha,County_Name,hud_area_name,median2024," +
+	"l50_1,l50_2,l50_3,l50_4,l50_5,l50_6,l50_7,l50_8," +
+	"ELI_1,ELI_2,ELI_3,ELI_4,ELI_5,ELI_6,ELI_7,ELI_8," +
+	"l80_1,l80_2,l80_3,l80_4,l80_5,l80_6,l80_7,l80_8\n"
+
+const adaRow = `1600199999,ID,Ada County,"Boise City, ID HUD Metro FMR Area",104100,` +
+	"36450,41650,46850,52050,56250,60400,64600,68750," +
+	"21900,25000,28150,31200,36580,41960,47340,52720," +
+	"58300,66600,74950,83250,89950,96600,103250,109900\n"
+
+func TestParseCSV(t *testing.T) {
+	limits, err := ParseCSV(strings.NewReader(limitsHeader+adaRow+adaRow), 2024)
+	assert.NoError(t, err, "HUD's CSV should be parsed")
+	assert.Len(t, limits, MaxHouseholdSize, "Counties listed twice should only be read once")
+
+	four := limits[3]
+	assert.Equal(t, 2024, four.Year, "Year should be set")
+	assert.Equal(t, "ID", four.State, "State should be read")
+	assert.Equal(t, "Ada County", four.County, "County should be read")
+	assert.Equal(t, "Boise City, ID HUD Metro FMR Area", four.AreaName, "Area name should be read")
+	assert.Equal(t, 104100, four.MedianIncome, "Median income should be read")
+	assert.Equal(t, 4, four.HouseholdSize, "Household size should be set")
+	assert.Equal(t, 31200, four.ExtremelyLow, "30% limit should be read")
+	assert.Equal(t, 52050, four.VeryLow, "50% limit should be read")
+	assert.Equal(t, 83250, four.Low, "80% limit should be read")
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	_, err := ParseCSV(strings.NewReader("State_Alpha,County_Name,median2024\n"), 2024)
+	assert.ErrorIs(t, err, ErrMissingColumn, "Files without limits should be rejected")
+
+	_, err = ParseCSV(strings.NewReader(limitsHeader+strings.Replace(adaRow, "104100", "n/a", 1)), 2024)
+	assert.Error(t, err, "Invalid amounts should be rejected")
+}
+
+func TestCountyKey(t *testing.T) {
+	assert.Equal(t, "ada", CountyKey(" Ada County "), "County suffix and case should not matter")
+	assert.Equal(t, CountyKey("Ada County"), CountyKey("ada"), "Counties should match without the suffix")
+}
+
+func TestForHouseholdSize(t *testing.T) {
+	four := Limits{HouseholdSize: 4, ExtremelyLow: 31200, VeryLow: 52050, Low: 83250}
+
+	nine := ForHouseholdSize(four, 9)
+	assert.Equal(t, 9, nine.HouseholdSize, "Household size should be set")
+	assert.Equal(t, 43700, nine.ExtremelyLow, "Limits should be 140% of the 4 person limits")
+	assert.Equal(t, 72900, nine.VeryLow, "Limits should round up to the nearest $50")
+	assert.Equal(t, 116550, nine.Low, "Limits should round up to the nearest $50")
+
+	exact := ForHouseholdSize(Limits{VeryLow: 50000}, 9)
+	assert.Equal(t, 70000, exact.VeryLow, "Exact limits should not be rounded up")
+}
+
+func TestEligibleBands(t *testing.T) {
+	limits := Limits{MedianIncome: 104100, ExtremelyLow: 31200, VeryLow: 52050, Low: 83250}
+
+	assert.Equal(t, []string{Band30, Band50, Band80}, EligibleBands(limits, decimal.NewFromInt(31200)), "Income at the limit should be eligible")
+	assert.Equal(t, []string{Band50, Band80}, EligibleBands(limits, decimal.NewFromInt(40000)), "Income over the 30% limit should be in the higher bands")
+	assert.Equal(t, []string{}, EligibleBands(limits, decimal.NewFromInt(90000)), "Income over the 80% limit should not be eligible")
+
+	assert.Equal(t, 39, PercentOfMedian(limits, decimal.NewFromInt(40000)), "Percent of median should round up")
+	assert.Equal(t, 0, PercentOfMedian(Limits{}, decimal.NewFromInt(40000)), "Missing median should not divide by zero")
+}
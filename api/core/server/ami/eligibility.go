@@ -0,0 +1,60 @@
+package ami
+
+// Comparing a household's income to the limits for its size.
+
+import "github.com/shopspring/decimal"
+
+// AMI bands, as a percent of area median income.
+const (
+	Band30 = "30"
+	Band50 = "50"
+	Band80 = "80"
+)
+
+// ForHouseholdSize works out the limits for a household from the 4 person
+// limits, the way HUD does for households over 8: each person past 4 adds 8%
+// of the 4 person limit, rounded up to the nearest $50. Households of 8 or
+// fewer should use HUD's own row for their size instead.
+func ForHouseholdSize(fourPerson Limits, size int) Limits {
+	// In whole percents, so rounding up doesn't pick up float errors
+	percent := 100 + 8*(size-4)
+	scale := func(limit int) int {
+		scaled := limit * percent
+		return (scaled + 50*100 - 1) / (50 * 100) * 50
+	}
+	limits := fourPerson
+	limits.HouseholdSize = size
+	limits.ExtremelyLow = scale(fourPerson.ExtremelyLow)
+	limits.VeryLow = scale(fourPerson.VeryLow)
+	limits.Low = scale(fourPerson.Low)
+	return limits
+}
+
+// EligibleBands lists the AMI bands the household's yearly income is within,
+// lowest first. A household within the 30% band is within all of them.
+func EligibleBands(limits Limits, annualIncome decimal.Decimal) []string {
+	bands := []string{}
+	for _, band := range []struct {
+		name  string
+		limit int
+	}{
+		{Band30, limits.ExtremelyLow},
+		{Band50, limits.VeryLow},
+		{Band80, limits.Low},
+	} {
+		if annualIncome.LessThanOrEqual(decimal.NewFromInt(int64(band.limit))) {
+			bands = append(bands, band.name)
+		}
+	}
+	return bands
+}
+
+// PercentOfMedian is the household's income as a whole percent of the area
+// median income, rounded up.
+func PercentOfMedian(limits Limits, annualIncome decimal.Decimal) int {
+	if limits.MedianIncome <= 0 {
+		return 0
+	}
+	percent := annualIncome.Mul(decimal.NewFromInt(100)).Div(decimal.NewFromInt(int64(limits.MedianIncome)))
+	return int(percent.Ceil().IntPart())
+}
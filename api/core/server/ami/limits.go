@@ -0,0 +1,182 @@
+package ami
+
+// Reading HUD's income limits from the CSV they publish each year
+// (https://www.huduser.gov/portal/datasets/il.html). Each row is a county, or
+// a town in New England, with its median income and the 30% ("ELI_1" to
+// "ELI_8"), 50% ("l50_1" to "l50_8") and 80% ("l80_1" to "l80_8") limits for
+// households of 1 to 8 people.
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// HUD publishes limits for households of up to 8 people.
+const MaxHouseholdSize = 8
+
+// Limits are HUD's yearly income limits for one household size in a county.
+type Limits struct {
+	Year          int
+	State         string
+	County        string
+	AreaName      string
+	MedianIncome  int
+	HouseholdSize int
+	// 30% of area median income
+	ExtremelyLow int
+	// 50% of area median income
+	VeryLow int
+	// 80% of area median income
+	Low int
+}
+
+var ErrMissingColumn = errors.New("missing column")
+
+// CountyKey is how a county is looked up, so "Ada County" and "ada" match.
+func CountyKey(county string) string {
+	key := strings.ToLower(strings.TrimSpace(county))
+	key = strings.TrimSuffix(key, " county")
+	return strings.TrimSpace(key)
+}
+
+// parseDollars reads a whole dollar amount like "$58,350".
+func parseDollars(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	value = strings.TrimPrefix(value, "$")
+	value = strings.ReplaceAll(value, ",", "")
+	return strconv.Atoi(value)
+}
+
+// columns finds columns by their lowercased header.
+type columns map[string]int
+
+func (c columns) find(names ...string) (int, error) {
+	for _, name := range names {
+		if i, ok := c[name]; ok {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s", ErrMissingColumn, names[0])
+}
+
+// medianColumn finds the median income column, which is named after the year,
+// like "median2024".
+func (c columns) medianColumn(year int) (int, error) {
+	if i, err := c.find(fmt.Sprintf("median%d", year), "median"); err == nil {
+		return i, nil
+	}
+	for name, i := range c {
+		if strings.HasPrefix(name, "median") {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: median%d", ErrMissingColumn, year)
+}
+
+// ParseCSV reads HUD's income limits CSV for the year. Counties listed more
+// than once, like New England towns, keep their first row. Returns a Limits
+// for each county and household size from 1 to 8.
+func ParseCSV(reader io.Reader, year int) ([]Limits, error) {
+	r := csv.NewReader(reader)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	cols := columns{}
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	stateCol, err := cols.find("state_alpha", "state")
+	if err != nil {
+		return nil, err
+	}
+	countyCol, err := cols.find("county_name", "county")
+	if err != nil {
+		return nil, err
+	}
+	medianCol, err := cols.medianColumn(year)
+	if err != nil {
+		return nil, err
+	}
+	// Not every year's file names the area
+	areaCol, areaErr := cols.find("hud_area_name", "metro_area_name")
+
+	limitCols := map[string][]int{}
+	for _, prefix := range []string{"eli", "l50", "l80"} {
+		for size := 1; size <= MaxHouseholdSize; size++ {
+			i, err := cols.find(fmt.Sprintf("%s_%d", prefix, size))
+			if err != nil {
+				return nil, err
+			}
+			limitCols[prefix] = append(limitCols[prefix], i)
+		}
+	}
+
+	limits := []Limits{}
+	seen := map[string]bool{}
+	line := 1
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return limits, nil
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		field := func(i int) string {
+			if i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		state := strings.ToUpper(field(stateCol))
+		county := field(countyCol)
+		if state == "" || county == "" {
+			continue
+		}
+		key := state + "|" + CountyKey(county)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		median, err := parseDollars(field(medianCol))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid median income %q", line, field(medianCol))
+		}
+		areaName := ""
+		if areaErr == nil {
+			areaName = field(areaCol)
+		}
+
+		for size := 1; size <= MaxHouseholdSize; size++ {
+			amounts := map[string]int{}
+			for prefix, indexes := range limitCols {
+				amount, err := parseDollars(field(indexes[size-1]))
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid %s_%d limit %q", line, prefix, size, field(indexes[size-1]))
+				}
+				amounts[prefix] = amount
+			}
+			limits = append(limits, Limits{
+				Year:          year,
+				State:         state,
+				County:        county,
+				AreaName:      areaName,
+				MedianIncome:  median,
+				HouseholdSize: size,
+				ExtremelyLow:  amounts["eli"],
+				VeryLow:       amounts["l50"],
+				Low:           amounts["l80"],
+			})
+		}
+	}
+}
@@ -0,0 +1,483 @@
+package applicationpdf
+
+// Renders a client's housing application as a printable PDF, for housing
+// providers that still want paper. The fonts are embedded, so it renders the
+// same everywhere without anything being fetched.
+
+import (
+	"api/core/server/data/application"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+//go:embed fonts/DejaVuSansCondensed.ttf
+var regularFont []byte
+
+//go:embed fonts/DejaVuSansCondensed-Bold.ttf
+var boldFont []byte
+
+const fontFamily = "DejaVu"
+
+// Page layout, in mm on US Letter paper.
+const margin = 18.0
+const labelWidth = 62.0
+const lineHeight = 5.0
+
+// Space a signature block needs, so it's never split across pages.
+const signatureHeight = 24.0
+
+const certification = "I certify that the information in this application is true and complete to the best of my " +
+	"knowledge. I understand that false statements or information are punishable under federal law and may be " +
+	"grounds for denial of this application or termination of assistance. I authorize the housing provider to " +
+	"verify the information given, including income, assets and rental history."
+
+// Document is an uploaded file listed in the application's index of
+// documents on file.
+type Document struct {
+	Name string
+	Type string
+}
+
+// Input is everything printed on the application.
+type Input struct {
+	Data        application.ApplicationData
+	Members     []application.FamilyMember
+	Documents   []Document
+	Program     string
+	Status      string
+	GeneratedAt time.Time
+}
+
+// writer draws the parts of the application, breaking pages as it goes.
+type writer struct {
+	pdf   *gofpdf.Fpdf
+	width float64
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "Yes"
+	}
+	return "No"
+}
+
+// explained is a yes or no answer, with the explanation after a yes.
+func explained(value bool, explanation string) string {
+	if value && strings.TrimSpace(explanation) != "" {
+		return "Yes - " + strings.TrimSpace(explanation)
+	}
+	return yesNo(value)
+}
+
+func fullName(firstName string, lastName string) string {
+	return strings.TrimSpace(firstName + " " + lastName)
+}
+
+func orBlank(value string) string {
+	if strings.TrimSpace(value) == "" {
+		return "-"
+	}
+	return value
+}
+
+// ensureSpace starts a new page if there's less than height left on this one.
+func (w *writer) ensureSpace(height float64) {
+	_, pageHeight := w.pdf.GetPageSize()
+	_, _, _, bottom := w.pdf.GetMargins()
+	if w.pdf.GetY()+height > pageHeight-bottom {
+		w.pdf.AddPage()
+	}
+}
+
+// heading starts a section, on a new page if there isn't room for some of it
+// under the heading.
+func (w *writer) heading(title string) {
+	w.ensureSpace(30)
+	w.pdf.Ln(4)
+	w.pdf.SetFont(fontFamily, "B", 12)
+	w.pdf.SetFillColor(230, 236, 242)
+	w.pdf.CellFormat(w.width, 7, title, "", 1, "L", true, 0, "")
+	w.pdf.Ln(2)
+}
+
+func (w *writer) subheading(title string) {
+	w.ensureSpace(14)
+	w.pdf.SetFont(fontFamily, "B", 10)
+	w.pdf.CellFormat(w.width, 6, title, "B", 1, "L", false, 0, "")
+	w.pdf.Ln(1)
+}
+
+// field prints a label and its value, wrapping long values.
+func (w *writer) field(label string, value string) {
+	w.pdf.SetFont(fontFamily, "", 9.5)
+	lines := w.pdf.SplitText(orBlank(value), w.width-labelWidth)
+	w.ensureSpace(float64(len(lines)) * lineHeight)
+
+	y := w.pdf.GetY()
+	w.pdf.SetFont(fontFamily, "B", 9.5)
+	w.pdf.MultiCell(labelWidth, lineHeight, label, "", "L", false)
+	labelBottom := w.pdf.GetY()
+
+	w.pdf.SetXY(margin+labelWidth, y)
+	w.pdf.SetFont(fontFamily, "", 9.5)
+	w.pdf.MultiCell(w.width-labelWidth, lineHeight, orBlank(value), "", "L", false)
+	w.pdf.SetY(max(labelBottom, w.pdf.GetY()))
+}
+
+func (w *writer) paragraph(text string) {
+	w.pdf.SetFont(fontFamily, "", 9.5)
+	w.pdf.MultiCell(w.width, lineHeight, text, "", "L", false)
+	w.pdf.Ln(2)
+}
+
+func (w *writer) tableHeader(headers []string, widths []float64) {
+	w.pdf.SetFont(fontFamily, "B", 9)
+	w.pdf.SetFillColor(242, 242, 242)
+	for i, header := range headers {
+		w.pdf.CellFormat(widths[i], 6, header, "1", 0, "L", true, 0, "")
+	}
+	w.pdf.Ln(-1)
+}
+
+// table prints rows with the given column widths, as a share of the page
+// width. Cells wrap, and the header is printed again on each new page.
+func (w *writer) table(headers []string, shares []float64, rows [][]string) {
+	if len(rows) == 0 {
+		w.paragraph("None listed.")
+		return
+	}
+	widths := make([]float64, len(shares))
+	for i, share := range shares {
+		widths[i] = w.width * share
+	}
+
+	w.ensureSpace(6 + lineHeight)
+	w.tableHeader(headers, widths)
+	_, pageHeight := w.pdf.GetPageSize()
+	_, _, _, bottom := w.pdf.GetMargins()
+	for _, row := range rows {
+		w.pdf.SetFont(fontFamily, "", 9)
+		height := lineHeight
+		for i, cell := range row {
+			lines := w.pdf.SplitText(orBlank(cell), widths[i]-2)
+			height = max(height, float64(len(lines))*lineHeight)
+		}
+		if w.pdf.GetY()+height > pageHeight-bottom {
+			w.pdf.AddPage()
+			w.tableHeader(headers, widths)
+			w.pdf.SetFont(fontFamily, "", 9)
+		}
+
+		x, y := margin, w.pdf.GetY()
+		for i, cell := range row {
+			w.pdf.Rect(x, y, widths[i], height, "D")
+			w.pdf.SetXY(x, y)
+			w.pdf.MultiCell(widths[i], lineHeight, orBlank(cell), "", "L", false)
+			x += widths[i]
+		}
+		w.pdf.SetXY(margin, y+height)
+	}
+	w.pdf.Ln(2)
+}
+
+// signature prints lines for someone to sign and date, with their name under.
+func (w *writer) signature(name string) {
+	w.ensureSpace(signatureHeight)
+	w.pdf.Ln(10)
+	y := w.pdf.GetY()
+	signatureWidth := w.width * 0.62
+	dateX := margin + signatureWidth + 10
+	w.pdf.SetLineWidth(0.3)
+	w.pdf.Line(margin, y, margin+signatureWidth, y)
+	w.pdf.Line(dateX, y, margin+w.width, y)
+
+	w.pdf.SetFont(fontFamily, "", 8.5)
+	w.pdf.SetXY(margin, y+1)
+	w.pdf.CellFormat(signatureWidth, 4, "Signature of "+name, "", 0, "L", false, 0, "")
+	w.pdf.SetXY(dateX, y+1)
+	w.pdf.CellFormat(margin+w.width-dateX, 4, "Date", "", 1, "L", false, 0, "")
+	w.pdf.SetX(margin)
+	w.pdf.Ln(4)
+}
+
+func (w *writer) residence(title string, residence application.ResidenceData) {
+	w.subheading(title)
+	address := strings.TrimSpace(fmt.Sprintf("%s, %s, %s %s", residence.Address, residence.City, residence.State, residence.ZipCode))
+	w.field("Address", strings.Trim(address, ", "))
+	w.field("Dates", fmt.Sprintf("%s to %s", orBlank(residence.DateIn), orBlank(residence.DateOut)))
+	residenceType := residence.ResidenceType
+	if residence.OtherResidenceType != "" {
+		residenceType = residence.OtherResidenceType
+	}
+	w.field("Type of residence", residenceType)
+	w.field("Landlord", strings.TrimSpace(residence.LandlordName+"  "+residence.LandlordPhone))
+	w.field("Monthly payment", residence.MonthlyPayment)
+	w.field("Whole household lives here", yesNo(residence.AllReside))
+	if len(residence.NonResidingMembers) > 0 {
+		names := []string{}
+		for _, member := range residence.NonResidingMembers {
+			names = append(names, fullName(member.FirstName, member.LastName))
+		}
+		w.field("Members living elsewhere", strings.Join(names, ", "))
+	}
+	w.pdf.Ln(2)
+}
+
+// memberNames lists the names of members flagged by a question.
+func memberNames(members []application.FamilyMember) string {
+	names := []string{}
+	for _, member := range members {
+		names = append(names, fullName(member.FirstName, member.LastName))
+	}
+	return strings.Join(names, ", ")
+}
+
+// flagged is a yes or no question about household members, naming them after
+// a yes.
+func flagged(value bool, members []application.FamilyMember) string {
+	return explained(value, memberNames(members))
+}
+
+// signers are the adults who have to sign: the applicant, then every other
+// household member who is 18 or older on the day.
+func signers(input Input) []string {
+	applicant := fullName(input.Data.PersonalInfo.FirstName, input.Data.PersonalInfo.LastName)
+	names := []string{orBlank(applicant)}
+	adult := input.GeneratedAt.AddDate(-18, 0, 0)
+	for _, member := range input.Members {
+		if member.Relationship == "Self" {
+			continue
+		}
+		birthday, err := application.ParseDate(member.Birthday)
+		// Members without a readable birthday sign too, to be safe
+		if err == nil && birthday.After(adult) {
+			continue
+		}
+		names = append(names, orBlank(fullName(member.FirstName, member.LastName)))
+	}
+	return names
+}
+
+func (w *writer) applicant(input Input) {
+	info := input.Data.PersonalInfo
+	w.heading("Applicant")
+	w.field("Name", fullName(info.FirstName, info.LastName))
+	w.field("Date of birth", info.Dob)
+	w.field("Social Security number", info.SSN)
+	w.field("Gender", info.Gender)
+	w.field("Email", info.Email)
+	w.field("Phone", info.Phone)
+	w.field("Address", info.Address)
+	w.field("Student", yesNo(info.IsStudent))
+	w.field("Veteran", yesNo(info.IsVeteran))
+	w.field("Has a disability", yesNo(info.HasDisability))
+
+	w.heading("Application")
+	w.field("Program", input.Program)
+	w.field("Status", input.Status)
+	w.field("Desired move in date", input.Data.HousingPreferences.DesiredMoveInDate)
+	preferences := []string{}
+	for preference, rank := range input.Data.HousingPreferences.Rankings {
+		preferences = append(preferences, fmt.Sprintf("%s: %s", rank, preference))
+	}
+	sort.Strings(preferences)
+	w.field("Housing preferences", strings.Join(preferences, "\n"))
+}
+
+func (w *writer) household(input Input) {
+	household := input.Data.Household
+	w.heading("Household members")
+	rows := [][]string{}
+	for _, member := range input.Members {
+		rows = append(rows, []string{
+			fullName(member.FirstName, member.LastName),
+			member.Relationship,
+			member.Birthday,
+			member.Gender,
+			member.SSN,
+		})
+	}
+	w.table([]string{"Name", "Relationship", "Date of birth", "Gender", "SSN"}, []float64{0.3, 0.18, 0.18, 0.14, 0.2}, rows)
+
+	w.heading("Household")
+	w.field("Pets", explained(household.HasPet, household.PetDescription))
+	w.field("Smoker in the household", yesNo(household.IsSmoker))
+	w.field("Lives in more than one residence", yesNo(household.MoreThanOneResidence))
+	w.field("Members temporarily absent", explained(len(household.AbsentMembers) > 0, strings.TrimSpace(memberNames(household.AbsentMembers)+" "+household.AbsentMembersExplanation)))
+	w.field("Expected household changes", explained(household.CompositionChanges, household.CompositionExplanation))
+	w.field("Custody arrangements", explained(household.Custody, household.CustodyExplanation))
+	w.field("Elderly (62 or older)", yesNo(household.ElderlyEligibility))
+	w.field("Disabled", yesNo(household.DisabledEligibility))
+	w.field("Received HUD assistance since January 2010", flagged(household.ReceivedHudJan2010, household.HudRecipients))
+	if household.HudPropertyName != "" {
+		w.field("HUD property", household.HudPropertyName)
+	}
+	accessibility := []string{}
+	for _, need := range []struct {
+		name   string
+		needed bool
+	}{
+		{"mobility", household.MobilityAccessibility},
+		{"vision", household.VisionAccessibility},
+		{"hearing", household.HearingAccessibility},
+	} {
+		if need.needed {
+			accessibility = append(accessibility, need.name)
+		}
+	}
+	w.field("Accessible unit needed", explained(household.NeedsAccessibility, strings.TrimSpace(strings.Join(accessibility, ", ")+" "+memberNames(household.AccessibilityMembers))))
+	w.field("Special accommodations", explained(household.NeedsSpecialAccommodations, strings.TrimSpace(household.AccommodationDescription+" "+memberNames(household.MembersNeedingHelp))))
+}
+
+func (w *writer) history(input Input) {
+	history := input.Data.History
+	w.heading("Residence history")
+	if history.CurrentResidence != nil {
+		w.residence("Current residence", *history.CurrentResidence)
+	}
+	for i, residence := range history.PreviousResidences {
+		w.residence(fmt.Sprintf("Previous residence %d", i+1), residence)
+	}
+	if history.CurrentResidence == nil && len(history.PreviousResidences) == 0 {
+		w.paragraph("None listed.")
+	}
+
+	w.heading("Background")
+	w.field("Housing assistance terminated", explained(history.AssistanceTerminated, history.AssistanceExplanation))
+	w.field("Evicted", explained(history.Evicted, history.EvicitionExplanation))
+	owes := explained(history.OwesMoney, history.DebtExplanation)
+	if history.OwesMoney {
+		owes += fmt.Sprintf(" (making payments: %s)", yesNo(history.MakingPayments))
+	}
+	w.field("Owes money to a housing provider", owes)
+	w.field("Bed bugs", yesNo(history.BedBugs))
+	w.field("Lifetime sex offender registration", flagged(history.IsLifetimeSexOffender, history.LifetimeOffenders))
+	w.field("Violent criminal activity", flagged(history.IsViolentOffender, history.ViolentOffenders))
+	w.field("Meth production conviction", flagged(history.IsMethConviction, history.MethOffenders))
+	w.field("Drug charges", flagged(history.HasDrugCharges, history.DrugOffenders))
+
+	if len(history.OtherCrimes) > 0 {
+		w.subheading("Other convictions")
+		rows := [][]string{}
+		for _, crime := range history.OtherCrimes {
+			rows = append(rows, []string{
+				fullName(crime.FamilyMember.FirstName, crime.FamilyMember.LastName),
+				crime.Crime,
+				crime.Year,
+				strings.Trim(crime.City+", "+crime.State, ", "),
+			})
+		}
+		w.table([]string{"Member", "Offense", "Year", "Where"}, []float64{0.28, 0.36, 0.12, 0.24}, rows)
+	}
+}
+
+func (w *writer) income(input Input) {
+	income := input.Data.Income
+	summary := application.SummarizeIncome(income)
+	annual := map[int]string{}
+	for _, entry := range summary.Entries {
+		if entry.Category != application.IncomeAsset {
+			annual[entry.Index] = "$" + entry.Annual.StringFixed(2)
+		}
+	}
+
+	w.heading("Income and assets")
+	rows := [][]string{}
+	for i, entry := range income.IncomeAssetEntires {
+		rows = append(rows, []string{
+			fullName(entry.FamilyMember.FirstName, entry.FamilyMember.LastName),
+			entry.Type,
+			entry.Source,
+			entry.Amount,
+			entry.FrequencyOrLocation,
+			annual[i],
+		})
+	}
+	w.table([]string{"Member", "Type", "Source", "Amount", "Frequency / location", "Yearly"}, []float64{0.2, 0.1, 0.22, 0.14, 0.2, 0.14}, rows)
+
+	household := summary.Household
+	w.field("Yearly earned income", "$"+household.EarnedAnnual.StringFixed(2))
+	w.field("Yearly benefits", "$"+household.BenefitsAnnual.StringFixed(2))
+	w.field("Total yearly income", "$"+household.TotalAnnual.StringFixed(2))
+	w.field("Total value of assets", "$"+household.AssetValue.StringFixed(2))
+	w.field("Receives government assistance", explained(income.ReceivesGovAssistance, income.AssistanceProgramName))
+	w.field("Receives assistance at current property", yesNo(income.ReceivesFromCurrentProperty))
+}
+
+func (w *writer) documents(input Input) {
+	w.heading("Documents on file")
+	rows := [][]string{}
+	for _, document := range input.Documents {
+		rows = append(rows, []string{document.Name, document.Type})
+	}
+	w.table([]string{"Document", "Type"}, []float64{0.65, 0.35}, rows)
+}
+
+func (w *writer) certification(input Input) {
+	w.heading("Certification")
+	w.paragraph(certification)
+	for _, name := range signers(input) {
+		w.signature(name)
+	}
+}
+
+// Render renders the application, paginated with the applicant's name and
+// page numbers on every page.
+func Render(input Input) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "Letter", "")
+	pdf.AddUTF8FontFromBytes(fontFamily, "", regularFont)
+	pdf.AddUTF8FontFromBytes(fontFamily, "B", boldFont)
+	pdf.SetTitle("Housing Application", true)
+	pdf.SetCreator("QRHome", true)
+	pdf.SetCreationDate(input.GeneratedAt)
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetAutoPageBreak(true, margin)
+	pdf.AliasNbPages("")
+
+	applicant := fullName(input.Data.PersonalInfo.FirstName, input.Data.PersonalInfo.LastName)
+	pageWidth, _ := pdf.GetPageSize()
+	w := &writer{pdf: pdf, width: pageWidth - 2*margin}
+
+	pdf.SetHeaderFuncMode(func() {
+		pdf.SetFont(fontFamily, "", 8)
+		pdf.SetTextColor(90, 90, 90)
+		pdf.CellFormat(w.width/2, 5, "Housing Application - "+orBlank(applicant), "", 0, "L", false, 0, "")
+		pdf.CellFormat(w.width/2, 5, input.Program, "", 1, "R", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+		pdf.Ln(2)
+	}, true)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-margin + 4)
+		pdf.SetFont(fontFamily, "", 8)
+		pdf.SetTextColor(90, 90, 90)
+		pdf.CellFormat(w.width/2, 5, "Generated "+input.GeneratedAt.Format("January 2, 2006"), "", 0, "L", false, 0, "")
+		pdf.CellFormat(w.width/2, 5, fmt.Sprintf("Page %d of {nb}", pdf.PageNo()), "", 0, "R", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	})
+
+	pdf.AddPage()
+	pdf.SetFont(fontFamily, "B", 18)
+	pdf.CellFormat(w.width, 10, "Housing Application", "", 1, "L", false, 0, "")
+
+	w.applicant(input)
+	w.household(input)
+	w.history(input)
+	w.income(input)
+	w.documents(input)
+	w.certification(input)
+
+	if err := pdf.Error(); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := pdf.Output(&out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
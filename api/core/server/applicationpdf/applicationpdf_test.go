@@ -0,0 +1,82 @@
+package applicationpdf
+
+// Application PDF unit tests
+
+import (
+	"api/core/server/data/application"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var mock_input = Input{
+	Data: application.ApplicationData{
+		PersonalInfo: application.PersonalInfo{FirstName: "José", LastName: "Núñez", Dob: "1980-04-02", SSN: "***-**-6789"},
+		HousingPreferences: application.HousingPreferences{
+			Rankings:          map[string]string{"Downtown": "1", "Near schools": "2"},
+			DesiredMoveInDate: "2026-12-01",
+		},
+		History: application.HistoryData{
+			CurrentResidence: &application.ResidenceData{Address: "1 Main St", City: "Boise", State: "ID", ZipCode: "83702"},
+			OwesMoney:        true,
+		},
+		Income: application.IncomeAndAssetsData{IncomeAssetEntires: []application.IncomeAndAssetData{
+			{Type: "Income", Source: "Warehouse", Amount: "500", FrequencyOrLocation: "Weekly"},
+		}},
+	},
+	Members: []application.FamilyMember{
+		{ID: 1, FirstName: "José", LastName: "Núñez", Birthday: "1980-04-02", Relationship: "Self"},
+		{ID: 2, FirstName: "Ana", LastName: "Núñez", Birthday: "1982-09-12", Relationship: "Spouse"},
+		{ID: 3, FirstName: "Luz", LastName: "Núñez", Birthday: "2015-01-30", Relationship: "Child"},
+	},
+	Documents:   []Document{{Name: "license.jpg", Type: "id"}},
+	Program:     "Section 8 Voucher",
+	Status:      "draft",
+	GeneratedAt: time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC),
+}
+
+func pageCount(pdf []byte) int {
+	return strings.Count(string(pdf), "/Type /Page\n")
+}
+
+func TestRender(t *testing.T) {
+	pdf, err := Render(mock_input)
+	assert.NoError(t, err, "Failed to render application")
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF")), "Output is not a pdf")
+	assert.GreaterOrEqual(t, pageCount(pdf), 1, "Application should have pages")
+	assert.Equal(t, 2, strings.Count(string(pdf), "/FontFile2"), "Both fonts should be embedded")
+}
+
+func TestRenderPaginates(t *testing.T) {
+	input := mock_input
+	input.Data.Income.IncomeAssetEntires = nil
+	for i := 0; i < 120; i++ {
+		input.Data.Income.IncomeAssetEntires = append(input.Data.Income.IncomeAssetEntires, application.IncomeAndAssetData{
+			Type: "Income", Source: fmt.Sprintf("Job %d with a name long enough to wrap onto a second line", i), Amount: "100", FrequencyOrLocation: "Weekly",
+		})
+	}
+
+	short, err := Render(mock_input)
+	assert.NoError(t, err, "Failed to render application")
+	long, err := Render(input)
+	assert.NoError(t, err, "Failed to render long application")
+	assert.Greater(t, pageCount(long), pageCount(short)+2, "Long tables should continue onto new pages")
+}
+
+func TestSigners(t *testing.T) {
+	assert.Equal(t, []string{"José Núñez", "Ana Núñez"}, signers(mock_input), "Applicant and other adults should sign")
+
+	noMembers := mock_input
+	noMembers.Members = nil
+	assert.Equal(t, []string{"José Núñez"}, signers(noMembers), "Applicant should always sign")
+}
+
+func TestExplained(t *testing.T) {
+	assert.Equal(t, "No", explained(false, "ignored"), "No should not be explained")
+	assert.Equal(t, "Yes", explained(true, " "), "Yes without an explanation should stay yes")
+	assert.Equal(t, "Yes - A cat", explained(true, "A cat"), "Yes should be explained")
+}
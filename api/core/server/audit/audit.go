@@ -0,0 +1,128 @@
+package audit
+
+// A record of sensitive actions, like revealing an SSN, so there is a trail
+// of who saw what.
+
+import (
+	"api/core/server/account"
+	"api/db"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// Audited actions
+const (
+	ActionRevealSSN          = "reveal_ssn"
+	ActionEditApplication    = "edit_application"
+	ActionAddFamilyMember    = "add_family_member"
+	ActionUpdateFamilyMember = "update_family_member"
+	ActionDeleteFamilyMember = "delete_family_member"
+	ActionUploadFile         = "upload_file"
+	ActionDeleteFile         = "delete_file"
+	ActionExportHMIS         = "export_hmis"
+	ActionImportHMIS         = "import_hmis"
+)
+
+type AuditEventInfo struct {
+	ID           int       `json:"id"`
+	ActorEmail   string    `json:"actor_email"`
+	SubjectEmail string    `json:"subject_email"`
+	Action       string    `json:"action"`
+	Target       string    `json:"target"`
+	IPAddress    string    `json:"ip_address"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type GetAuditEventsResponse struct {
+	Success bool             `json:"success"`
+	Events  []AuditEventInfo `json:"events"`
+	Error   string           `json:"error"`
+}
+
+// Record saves an audit event for an action the actor took on the subject's
+// data. Every call is its own event, even if it repeats an earlier one.
+func Record(c echo.Context, client *db.PrismaClient, actor *db.UserModel, subjectId int, action string, target string) error {
+	_, err := client.AuditEvent.CreateOne(
+		db.AuditEvent.Action.Set(action),
+		db.AuditEvent.Actor.Link(
+			db.User.ID.Equals(actor.ID),
+		),
+		db.AuditEvent.Subject.Link(
+			db.User.ID.Equals(subjectId),
+		),
+		db.AuditEvent.Target.Set(target),
+		db.AuditEvent.IPAddress.Set(c.RealIP()),
+	).Exec(context.Background())
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to record %s by user %d: %v\n", action, actor.ID, err)
+	}
+	return err
+}
+
+// RecordAssistedEdit attributes a change a caseworker made to a client's data
+// to the caseworker, and tells the client what changed. Does nothing when
+// users change their own data.
+func RecordAssistedEdit(c echo.Context, client *db.PrismaClient, user *db.UserModel, owner *db.UserModel, action string, target string, changes []string) {
+	if user.ID == owner.ID {
+		return
+	}
+	// The change is already saved, so a missing event is only logged
+	Record(c, client, user, owner.ID, action, target)
+	account.NotifyClientOfEdits(client, user, owner, changes)
+}
+
+// GetAuditEventsHandler lists audit events, newest first. Admins see every
+// event, everyone else sees what they did and what was done to their data.
+// The action param filters by action.
+func GetAuditEventsHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, GetAuditEventsResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	filters := []db.AuditEventWhereParam{}
+	if user.Type != db.UserTypeAdmin {
+		filters = append(filters, db.AuditEvent.Or(
+			db.AuditEvent.ActorID.Equals(user.ID),
+			db.AuditEvent.SubjectID.Equals(user.ID),
+		))
+	}
+	if action := c.QueryParam("action"); action != "" {
+		filters = append(filters, db.AuditEvent.Action.Equals(action))
+	}
+
+	events, eventsErr := client.AuditEvent.FindMany(
+		filters...,
+	).With(
+		db.AuditEvent.Actor.Fetch(),
+		db.AuditEvent.Subject.Fetch(),
+	).OrderBy(
+		db.AuditEvent.CreatedAt.Order(db.SortOrderDesc),
+	).Exec(context.Background())
+	if eventsErr != nil {
+		fmt.Printf("[ERROR] Failed to get audit events for user %d: %v\n", user.ID, eventsErr)
+		return c.JSON(500, GetAuditEventsResponse{Success: false, Error: "Failed to get audit events"})
+	}
+
+	infos := make([]AuditEventInfo, len(events))
+	for i, event := range events {
+		infos[i] = AuditEventInfo{
+			ID:        event.ID,
+			Action:    event.Action,
+			Target:    event.Target,
+			IPAddress: event.IPAddress,
+			CreatedAt: event.CreatedAt,
+		}
+		if actor, ok := event.Actor(); ok {
+			infos[i].ActorEmail = actor.Email
+		}
+		if subject, ok := event.Subject(); ok {
+			infos[i].SubjectEmail = subject.Email
+		}
+	}
+
+	return c.JSON(200, GetAuditEventsResponse{Success: true, Events: infos, Error: ""})
+}
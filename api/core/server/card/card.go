@@ -0,0 +1,353 @@
+package card
+
+// Printable wallet cards that carry a client's link QR code, for clients
+// who don't have a phone to show the code from.
+
+import (
+	"api/core/server/account"
+	db "api/db"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/labstack/echo"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// Standard CR80 card size, same as a credit card.
+const cardWidth = 85.6
+const cardHeight = 54.0
+
+// Sheet layout for printing cards in batches on US Letter paper.
+const sheetColumns = 2
+const sheetRows = 4
+const sheetGap = 10.0
+const cardsPerSheet = sheetColumns * sheetRows
+
+const layoutSingle = "single"
+const layoutSheet = "sheet"
+
+// Default link page, matching what the app uses on mobile.
+const defaultLinkBaseUrl = "https://sdp.boisestate.edu/s25-stack-overflow-survivors/"
+
+// AgencyContact is the contact info printed on the back half of every card.
+type AgencyContact struct {
+	Name    string
+	Phone   string
+	Email   string
+	Website string
+}
+
+// CardInfo holds everything needed to print a single wallet card.
+type CardInfo struct {
+	FirstName string
+	Reference string
+	LinkUrl   string
+	Agency    AgencyContact
+}
+
+type GetWalletCardResponse struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+}
+
+// getAgencyContact reads the agency contact info from the environment.
+func getAgencyContact() AgencyContact {
+	contact := AgencyContact{
+		Name:    os.Getenv("AGENCY_NAME"),
+		Phone:   os.Getenv("AGENCY_PHONE"),
+		Email:   os.Getenv("AGENCY_EMAIL"),
+		Website: os.Getenv("AGENCY_WEBSITE"),
+	}
+	if contact.Name == "" {
+		contact.Name = "QRHome"
+	}
+	return contact
+}
+
+// LinkUrl builds the url encoded in the QR code for a link code. This
+// mirrors the url the app builds in getLinkUrl.
+func LinkUrl(code string) string {
+	base := os.Getenv("LINK_BASE_URL")
+	if base == "" {
+		base = defaultLinkBaseUrl
+	}
+	return fmt.Sprintf("%s?link=%s", base, code)
+}
+
+// ReferenceNumber builds a short, human readable reference number for a
+// user. The last digit is a Luhn check digit so typos read over the phone
+// can be caught.
+func ReferenceNumber(userId int) string {
+	digits := fmt.Sprintf("%06d", userId)
+	return fmt.Sprintf("QRH-%s%d", digits, luhnCheckDigit(digits))
+}
+
+// luhnCheckDigit computes the Luhn check digit for a string of digits.
+func luhnCheckDigit(digits string) int {
+	sum := 0
+	double := true
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return (10 - sum%10) % 10
+}
+
+// drawCard draws a single card with its top left corner at (x, y).
+func drawCard(pdf *gofpdf.Fpdf, tr func(string) string, x float64, y float64, card CardInfo) error {
+	qr, qrErr := qrcode.New(card.LinkUrl, qrcode.High)
+	if qrErr != nil {
+		return qrErr
+	}
+
+	// Card outline, doubles as a cut guide on sheets.
+	pdf.SetDrawColor(160, 160, 160)
+	pdf.SetLineWidth(0.2)
+	pdf.SetDashPattern([]float64{1, 1}, 0)
+	pdf.RoundedRect(x, y, cardWidth, cardHeight, 3, "1234", "D")
+	pdf.SetDashPattern([]float64{}, 0)
+
+	// QR code, drawn as vector modules so it stays sharp when printed.
+	bitmap := qr.Bitmap()
+	qrSize := cardHeight - 10
+	moduleSize := qrSize / float64(len(bitmap))
+	qrX := x + 3
+	qrY := y + 5
+	pdf.SetFillColor(0, 0, 0)
+	for row, modules := range bitmap {
+		for col, dark := range modules {
+			if dark {
+				pdf.Rect(qrX+float64(col)*moduleSize, qrY+float64(row)*moduleSize, moduleSize, moduleSize, "F")
+			}
+		}
+	}
+
+	textX := qrX + qrSize + 2
+	textWidth := x + cardWidth - textX - 3
+
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetXY(textX, y+5)
+	pdf.CellFormat(textWidth, 4, "QRHome", "", 2, "L", false, 0, "")
+
+	pdf.SetFont("Helvetica", "B", 13)
+	pdf.SetXY(textX, y+11)
+	pdf.CellFormat(textWidth, 6, tr(card.FirstName), "", 2, "L", false, 0, "")
+
+	pdf.SetFont("Helvetica", "", 7)
+	pdf.SetXY(textX, y+18)
+	pdf.CellFormat(textWidth, 3.5, fmt.Sprintf("Ref: %s", card.Reference), "", 2, "L", false, 0, "")
+
+	pdf.SetFont("Helvetica", "B", 6.5)
+	pdf.SetXY(textX, y+25)
+	pdf.CellFormat(textWidth, 3, tr(card.Agency.Name), "", 2, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 6.5)
+	for _, line := range []string{card.Agency.Phone, card.Agency.Email, card.Agency.Website} {
+		if line != "" {
+			pdf.CellFormat(textWidth, 3, tr(line), "", 2, "L", false, 0, "")
+		}
+	}
+
+	pdf.SetFont("Helvetica", "I", 5.5)
+	pdf.SetTextColor(90, 90, 90)
+	pdf.SetXY(textX, y+cardHeight-10)
+	pdf.MultiCell(textWidth, 2.5, "Show this card to your caseworker to share your housing profile.", "", "L", false)
+
+	return pdf.Error()
+}
+
+// newDocument creates an empty pdf with the settings shared by all layouts.
+func newDocument(init *gofpdf.InitType) (*gofpdf.Fpdf, func(string) string) {
+	pdf := gofpdf.NewCustom(init)
+	pdf.SetTitle("QRHome Wallet Card", true)
+	pdf.SetCreator("QRHome", true)
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+	return pdf, pdf.UnicodeTranslatorFromDescriptor("")
+}
+
+// RenderSingle renders a pdf containing a single card sized page.
+func RenderSingle(card CardInfo) ([]byte, error) {
+	pdf, tr := newDocument(&gofpdf.InitType{
+		OrientationStr: "P",
+		UnitStr:        "mm",
+		Size:           gofpdf.SizeType{Wd: cardWidth, Ht: cardHeight},
+	})
+	pdf.AddPage()
+	if err := drawCard(pdf, tr, 0, 0, card); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := pdf.Output(&out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// RenderSheet renders cards onto US Letter pages, eight to a page, with
+// dashed outlines to cut along.
+func RenderSheet(cards []CardInfo) ([]byte, error) {
+	pdf, tr := newDocument(&gofpdf.InitType{
+		OrientationStr: "P",
+		UnitStr:        "mm",
+		SizeStr:        "Letter",
+	})
+	pageWidth, pageHeight := pdf.GetPageSize()
+	marginX := (pageWidth - sheetColumns*cardWidth - (sheetColumns-1)*sheetGap) / 2
+	marginY := (pageHeight - sheetRows*cardHeight - (sheetRows-1)*sheetGap) / 2
+
+	for i, card := range cards {
+		slot := i % cardsPerSheet
+		if slot == 0 {
+			pdf.AddPage()
+		}
+		x := marginX + float64(slot%sheetColumns)*(cardWidth+sheetGap)
+		y := marginY + float64(slot/sheetColumns)*(cardHeight+sheetGap)
+		if err := drawCard(pdf, tr, x, y, card); err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	if err := pdf.Output(&out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// cardForUser builds the card info for a client, assigning a link code if
+// they don't have one yet.
+func cardForUser(client *db.PrismaClient, user *db.UserModel, info *db.PersonalInfoModel, agency AgencyContact) (CardInfo, error) {
+	code, codeErr := account.GetOrCreateLinkCode(client, user)
+	if codeErr != nil {
+		return CardInfo{}, codeErr
+	}
+	return CardInfo{
+		FirstName: info.FirstName,
+		Reference: ReferenceNumber(user.ID),
+		LinkUrl:   LinkUrl(code),
+		Agency:    agency,
+	}, nil
+}
+
+// GetWalletCardHandler renders wallet cards as a pdf. Clients get their own
+// card, either on its own or as a sheet of copies. Caseworkers get a sheet
+// of cards for their linked clients, optionally narrowed down with a comma
+// separated list of client emails.
+func GetWalletCardHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, GetWalletCardResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	layout := c.QueryParam("layout")
+	agency := getAgencyContact()
+	var cards []CardInfo
+
+	if user.Type == db.UserTypeClient {
+		if layout == "" {
+			layout = layoutSingle
+		}
+
+		info, infoErr := client.PersonalInfo.FindUnique(
+			db.PersonalInfo.ID.Equals(user.PersonalInfoID),
+		).Exec(context.Background())
+		if infoErr != nil {
+			fmt.Printf("[ERROR] Failed to get personal info: %v\n", infoErr)
+			return c.JSON(500, GetWalletCardResponse{Success: false, Error: "Failed to get personal info"})
+		}
+
+		card, cardErr := cardForUser(client, user, info, agency)
+		if cardErr != nil {
+			fmt.Printf("[ERROR] Failed to build wallet card: %v\n", cardErr)
+			return c.JSON(500, GetWalletCardResponse{Success: false, Error: "Failed to generate link code"})
+		}
+
+		cards = []CardInfo{card}
+		if layout == layoutSheet {
+			for len(cards) < cardsPerSheet {
+				cards = append(cards, card)
+			}
+		}
+	} else if user.Type == db.UserTypeCaseWorker {
+		if layout == "" {
+			layout = layoutSheet
+		}
+
+		links, linksErr := client.UserLink.FindMany(
+			db.UserLink.CaseworkerID.Equals(user.ID),
+		).With(
+			db.UserLink.Client.Fetch().With(
+				db.User.PersonalInfo.Fetch(),
+			),
+		).Exec(context.Background())
+		if linksErr != nil {
+			fmt.Printf("[ERROR] Failed to get linked clients: %v\n", linksErr)
+			return c.JSON(500, GetWalletCardResponse{Success: false, Error: "Failed to get linked clients"})
+		}
+
+		requested := map[string]bool{}
+		for _, email := range strings.Split(c.QueryParam("emails"), ",") {
+			if email = strings.TrimSpace(email); email != "" {
+				requested[email] = true
+			}
+		}
+
+		for _, link := range links {
+			linkedClient := link.Client()
+			if len(requested) != 0 && !requested[linkedClient.Email] {
+				continue
+			}
+			delete(requested, linkedClient.Email)
+
+			card, cardErr := cardForUser(client, linkedClient, linkedClient.PersonalInfo(), agency)
+			if cardErr != nil {
+				fmt.Printf("[ERROR] Failed to build wallet card for %s: %v\n", linkedClient.Email, cardErr)
+				return c.JSON(500, GetWalletCardResponse{Success: false, Error: "Failed to generate link code"})
+			}
+			cards = append(cards, card)
+		}
+
+		if len(requested) != 0 {
+			return c.JSON(400, GetWalletCardResponse{Success: false, Error: "Not a caseworker for account"})
+		}
+		if len(cards) == 0 {
+			return c.JSON(400, GetWalletCardResponse{Success: false, Error: "No linked clients"})
+		}
+	} else {
+		return c.JSON(400, GetWalletCardResponse{Success: false, Error: "Invalid user type"})
+	}
+
+	var pdfData []byte
+	var renderErr error
+	if layout == layoutSingle {
+		if len(cards) != 1 {
+			return c.JSON(400, GetWalletCardResponse{Success: false, Error: "Single layout needs exactly one card"})
+		}
+		pdfData, renderErr = RenderSingle(cards[0])
+	} else if layout == layoutSheet {
+		pdfData, renderErr = RenderSheet(cards)
+	} else {
+		return c.JSON(400, GetWalletCardResponse{Success: false, Error: "Invalid layout"})
+	}
+	if renderErr != nil {
+		fmt.Printf("[ERROR] Failed to render wallet card: %v\n", renderErr)
+		return c.JSON(500, GetWalletCardResponse{Success: false, Error: "Failed to render wallet card"})
+	}
+
+	c.Response().Header().Set("Content-Disposition", "attachment; filename=\"qrhome-card.pdf\"")
+	return c.Blob(http.StatusOK, "application/pdf", pdfData)
+}
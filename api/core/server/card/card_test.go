@@ -0,0 +1,54 @@
+package card
+
+// Wallet card unit tests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var mock_card = CardInfo{
+	FirstName: "Jane",
+	Reference: ReferenceNumber(1),
+	LinkUrl:   LinkUrl("abcdefghijkl"),
+	Agency: AgencyContact{
+		Name:  "Interfaith Sanctuary",
+		Phone: "(208) 555-0100",
+	},
+}
+
+func TestReferenceNumber(t *testing.T) {
+	assert.Equal(t, "QRH-0000018", ReferenceNumber(1), "Bad reference number")
+	assert.Equal(t, "QRH-1234566", ReferenceNumber(123456), "Bad reference number")
+	assert.NotEqual(t, ReferenceNumber(12), ReferenceNumber(21), "Transposed digits share a reference number")
+}
+
+func TestLinkUrl(t *testing.T) {
+	t.Setenv("LINK_BASE_URL", "")
+	assert.Equal(t, defaultLinkBaseUrl+"?link=abc", LinkUrl("abc"), "Bad default link url")
+
+	t.Setenv("LINK_BASE_URL", "https://qrhome.example/")
+	assert.Equal(t, "https://qrhome.example/?link=abc", LinkUrl("abc"), "Bad configured link url")
+}
+
+func TestRenderSingle(t *testing.T) {
+	pdf, err := RenderSingle(mock_card)
+	assert.NoError(t, err, "Failed to render card")
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF")), "Output is not a pdf")
+	assert.Equal(t, 1, strings.Count(string(pdf), "/Type /Page\n"), "Bad page count")
+}
+
+func TestRenderSheet(t *testing.T) {
+	cards := make([]CardInfo, cardsPerSheet+1)
+	for i := range cards {
+		cards[i] = mock_card
+	}
+
+	pdf, err := RenderSheet(cards)
+	assert.NoError(t, err, "Failed to render sheet")
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF")), "Output is not a pdf")
+	assert.Equal(t, 2, strings.Count(string(pdf), "/Type /Page\n"), "Overflow cards should start a new page")
+}
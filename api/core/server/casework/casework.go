@@ -0,0 +1,37 @@
+package casework
+
+// Tools for caseworkers to keep track of their clients.
+
+import (
+	"api/db"
+	"context"
+)
+
+// findLinkedClient finds one of the caseworker's clients by email.
+func findLinkedClient(client *db.PrismaClient, caseworker *db.UserModel, clientEmail string) (*db.UserModel, error) {
+	userLink, userLinkErr := client.UserLink.FindFirst(
+		db.UserLink.Caseworker.Where(
+			db.User.Email.Equals(caseworker.Email),
+		),
+		db.UserLink.Client.Where(
+			db.User.Email.Equals(clientEmail),
+		),
+	).With(
+		db.UserLink.Client.Fetch(),
+	).Exec(context.Background())
+	if userLinkErr != nil {
+		return nil, userLinkErr
+	}
+	return userLink.Client(), nil
+}
+
+// isLinked checks if a caseworker is currently linked with a client.
+func isLinked(client *db.PrismaClient, clientId int, caseworkerId int) bool {
+	userLink, userLinkErr := client.UserLink.FindUnique(
+		db.UserLink.UserlinkID(
+			db.UserLink.ClientID.Equals(clientId),
+			db.UserLink.CaseworkerID.Equals(caseworkerId),
+		),
+	).Exec(context.Background())
+	return userLink != nil && userLinkErr == nil
+}
@@ -0,0 +1,301 @@
+package casework
+
+// Casework unit tests
+
+import (
+	"api/core/server/account"
+	"api/core/server/data/application"
+	testutil "api/core/test_util"
+	"api/db"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var mock_lastAuth = time.Now()
+var mock_authCode = account.CreateHash(fmt.Sprintf("%v:%v", "caseworker@ihfa.org", mock_lastAuth.UnixNano()))
+var mock_caseworker = db.UserModel{
+	InnerUser: db.InnerUser{
+		ID:             1,
+		Email:          "caseworker@ihfa.org",
+		LastAuth:       &mock_lastAuth,
+		AuthCode:       &mock_authCode,
+		Type:           db.UserTypeCaseWorker,
+		PersonalInfoID: 1,
+	},
+}
+var mock_client = db.UserModel{
+	InnerUser: db.InnerUser{
+		ID:             2,
+		Email:          "test@gmail.com",
+		Type:           db.UserTypeClient,
+		PersonalInfoID: 2,
+	},
+}
+var mock_now = time.Date(2025, time.April, 1, 12, 0, 0, 0, time.UTC)
+var mock_linkCode = "abcdefghijkl"
+var mock_2faCode = "123456"
+var mock_2faExpiry = time.Now().Add(time.Hour * 50)
+
+// setup initializes state for running unit tests,
+// then returns a function pointer for teardown
+func setup() func() {
+	accountMockTeardown := account.Mock(mock_lastAuth, mock_authCode, mock_linkCode, mock_2faCode, mock_2faExpiry)
+
+	return func() {
+		accountMockTeardown()
+	}
+}
+
+// expectAuth mocks authenticating as a user.
+func expectAuth(client *db.PrismaClient, mock *db.Mock, user db.UserModel) {
+	mock.User.Expect(
+		client.User.FindFirst(
+			db.User.Email.Equals(user.Email),
+			db.User.AuthCode.Equals(*user.InnerUser.AuthCode),
+		),
+	).Returns(user)
+}
+
+// expectLinkedClient mocks finding one of the caseworker's clients.
+func expectLinkedClient(client *db.PrismaClient, mock *db.Mock, caseworker db.UserModel, linkClient db.UserModel) {
+	mock.UserLink.Expect(
+		client.UserLink.FindFirst(
+			db.UserLink.Caseworker.Where(
+				db.User.Email.Equals(caseworker.Email),
+			),
+			db.UserLink.Client.Where(
+				db.User.Email.Equals(linkClient.Email),
+			),
+		).With(
+			db.UserLink.Client.Fetch(),
+		),
+	).Returns(db.UserLinkModel{
+		InnerUserLink: db.InnerUserLink{
+			ClientID:     linkClient.ID,
+			CaseworkerID: caseworker.ID,
+		},
+		RelationsUserLink: db.RelationsUserLink{
+			Client: &linkClient,
+		},
+	})
+}
+
+func TestParseVisibility(t *testing.T) {
+	visibility, err := parseVisibility("")
+	assert.NoError(t, err, "Empty visibility should be allowed")
+	assert.Equal(t, db.NoteVisibilityInternal, visibility, "Notes should be internal by default")
+
+	visibility, err = parseVisibility("shared")
+	assert.NoError(t, err, "Lowercase visibility should be allowed")
+	assert.Equal(t, db.NoteVisibilityShared, visibility, "Bad visibility")
+
+	_, err = parseVisibility("public")
+	assert.Error(t, err, "Unknown visibility should fail")
+}
+
+func TestCreateNote(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	client, mock, ensure := db.NewMock()
+	defer ensure(t)
+
+	note := db.CaseNoteModel{
+		InnerCaseNote: db.InnerCaseNote{
+			ID:         1,
+			ClientID:   mock_client.ID,
+			Body:       "Called about missing pay stubs",
+			Visibility: db.NoteVisibilityShared,
+			CreatedAt:  mock_now,
+			UpdatedAt:  mock_now,
+		},
+	}
+
+	expectAuth(client, mock, mock_caseworker)
+	expectLinkedClient(client, mock, mock_caseworker, mock_client)
+
+	// Mock create note
+	mock.CaseNote.Expect(
+		client.CaseNote.CreateOne(
+			db.CaseNote.Client.Link(
+				db.User.ID.Equals(mock_client.ID),
+			),
+			db.CaseNote.Body.Set(note.Body),
+			db.CaseNote.Author.Link(
+				db.User.ID.Equals(mock_caseworker.ID),
+			),
+			db.CaseNote.Visibility.Set(db.NoteVisibilityShared),
+		),
+	).Returns(note)
+
+	request := CreateNoteRequest{
+		Email:      mock_client.Email,
+		Body:       "  Called about missing pay stubs\n",
+		Visibility: "shared",
+	}
+
+	rec, c, reqErr := testutil.PrepareRequestJSON(http.MethodPost, "/api/casework/notes/create", request)
+	c.Request().Header.Set(account.EmailHeaderKey, mock_caseworker.Email)
+	c.Request().Header.Set(account.AuthHeaderKey, mock_authCode)
+	assert.NoError(t, reqErr, "Failed to prepare request")
+	assert.NoError(t, CreateNoteHandler(c, client), "Error while creating note")
+	assert.Equal(t, 200, rec.Code, "Bad status code")
+
+	var response NoteResponse
+	unmarshalErr := json.Unmarshal(rec.Body.Bytes(), &response)
+	assert.Nil(t, unmarshalErr, "Error while parsing response body")
+
+	assert.Empty(t, response.Error, "Error message found")
+	assert.True(t, response.Success, "Unsuccessful")
+	assert.Equal(t, note.Body, response.Note.Body, "Bad note body")
+	assert.Equal(t, mock_client.Email, response.Note.ClientEmail, "Bad client email")
+	assert.Equal(t, mock_caseworker.Email, response.Note.AuthorEmail, "Bad author email")
+}
+
+func TestCreateNoteNotLinked(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	client, mock, ensure := db.NewMock()
+	defer ensure(t)
+
+	expectAuth(client, mock, mock_caseworker)
+
+	// Mock find client, the caseworker isn't linked
+	mock.UserLink.Expect(
+		client.UserLink.FindFirst(
+			db.UserLink.Caseworker.Where(
+				db.User.Email.Equals(mock_caseworker.Email),
+			),
+			db.UserLink.Client.Where(
+				db.User.Email.Equals(mock_client.Email),
+			),
+		).With(
+			db.UserLink.Client.Fetch(),
+		),
+	).Errors(db.ErrNotFound)
+
+	request := CreateNoteRequest{
+		Email: mock_client.Email,
+		Body:  "Should not be saved",
+	}
+
+	rec, c, reqErr := testutil.PrepareRequestJSON(http.MethodPost, "/api/casework/notes/create", request)
+	c.Request().Header.Set(account.EmailHeaderKey, mock_caseworker.Email)
+	c.Request().Header.Set(account.AuthHeaderKey, mock_authCode)
+	assert.NoError(t, reqErr, "Failed to prepare request")
+	assert.NoError(t, CreateNoteHandler(c, client), "Error while creating note")
+	assert.Equal(t, 400, rec.Code, "Bad status code")
+
+	var response NoteResponse
+	unmarshalErr := json.Unmarshal(rec.Body.Bytes(), &response)
+	assert.Nil(t, unmarshalErr, "Error while parsing response body")
+
+	assert.Equal(t, "Not a caseworker for account", response.Error, "Unlinked caseworker should be refused")
+	assert.False(t, response.Success, "Unlinked caseworker should be unsuccessful")
+}
+
+func TestParseTaskFields(t *testing.T) {
+	status, err := parseTaskStatus("")
+	assert.NoError(t, err, "Empty status should be allowed")
+	assert.Equal(t, db.TaskStatusOpen, status, "Tasks should be open by default")
+
+	status, err = parseTaskStatus("in_progress")
+	assert.NoError(t, err, "Lowercase status should be allowed")
+	assert.Equal(t, db.TaskStatusInProgress, status, "Bad status")
+
+	_, err = parseTaskStatus("finished")
+	assert.Error(t, err, "Unknown status should fail")
+
+	priority, err := parseTaskPriority("")
+	assert.NoError(t, err, "Empty priority should be allowed")
+	assert.Equal(t, db.TaskPriorityNormal, priority, "Tasks should be normal priority by default")
+
+	_, err = parseTaskPriority("whenever")
+	assert.Error(t, err, "Unknown priority should fail")
+}
+
+func TestTaskOverdue(t *testing.T) {
+	ogToday := today
+	today = func() time.Time { return mock_now.Truncate(time.Hour * 24) }
+	defer func() { today = ogToday }()
+
+	yesterday := mock_now.AddDate(0, 0, -1).Truncate(time.Hour * 24)
+	task := db.CaseTaskModel{
+		InnerCaseTask: db.InnerCaseTask{
+			ID:       1,
+			ClientID: mock_client.ID,
+			Title:    "Request birth certificate",
+			DueDate:  &yesterday,
+			Status:   db.TaskStatusOpen,
+			Priority: db.TaskPriorityHigh,
+		},
+	}
+
+	info := toTaskInfo(&task, mock_client.Email, mock_caseworker.Email)
+	assert.Equal(t, "2025-03-31", info.DueDate, "Bad due date")
+	assert.True(t, info.Overdue, "Open task past its due date should be overdue")
+
+	task.InnerCaseTask.Status = db.TaskStatusDone
+	info = toTaskInfo(&task, mock_client.Email, mock_caseworker.Email)
+	assert.False(t, info.Overdue, "Finished task should not be overdue")
+}
+
+func TestRemindersOnlyForLinkedAssignees(t *testing.T) {
+	caseworker := mock_caseworker
+	linkedClient := mock_client
+	linkedClient.RelationsUser.ClientLinks = []db.UserLinkModel{{
+		InnerUserLink: db.InnerUserLink{
+			ClientID:     mock_client.ID,
+			CaseworkerID: mock_caseworker.ID,
+		},
+	}}
+	task := db.CaseTaskModel{
+		InnerCaseTask: db.InnerCaseTask{
+			ID:       1,
+			ClientID: mock_client.ID,
+			Title:    "Request birth certificate",
+			Status:   db.TaskStatusOpen,
+		},
+		RelationsCaseTask: db.RelationsCaseTask{
+			Client:   &linkedClient,
+			Assignee: &caseworker,
+		},
+	}
+
+	assignee, ok := stillAssigned(&task)
+	assert.True(t, ok, "Linked caseworkers should be reminded")
+	assert.Equal(t, mock_caseworker.Email, assignee.Email, "Bad assignee")
+
+	// The caseworker unlinked from the client
+	unlinkedClient := mock_client
+	unlinkedClient.RelationsUser.ClientLinks = []db.UserLinkModel{}
+	task.RelationsCaseTask.Client = &unlinkedClient
+	_, ok = stillAssigned(&task)
+	assert.False(t, ok, "Unlinked caseworkers should not be reminded")
+}
+
+func TestDashboardFlags(t *testing.T) {
+	entry := DashboardClient{Documents: map[string]int{}}
+	assert.Equal(t, []string{FlagNoApplication, FlagNoDocuments}, dashboardFlags(&entry, nil), "Client without an application should be flagged")
+
+	entry = DashboardClient{Documents: map[string]int{"id": 1}, OverdueTasks: 2}
+	data := application.ApplicationData{
+		PersonalInfo: application.PersonalInfo{SSN: "123-45-6789"},
+	}
+	assert.Equal(t, []string{FlagMissingCurrentResidence, FlagOverdueTasks}, dashboardFlags(&entry, &data), "Missing residence and overdue tasks should be flagged")
+}
+
+func TestLatestHousingApplication(t *testing.T) {
+	assert.Nil(t, latestHousingApplication(nil), "Client without applications has no latest one")
+
+	older := db.HousingApplicationModel{InnerHousingApplication: db.InnerHousingApplication{ID: 1, UpdatedAt: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)}}
+	newer := db.HousingApplicationModel{InnerHousingApplication: db.InnerHousingApplication{ID: 2, UpdatedAt: time.Date(2025, 3, 5, 0, 0, 0, 0, time.UTC)}}
+	latest := latestHousingApplication([]db.HousingApplicationModel{newer, older})
+	assert.Equal(t, 2, latest.ID, "Should pick the application changed last")
+}
@@ -0,0 +1,262 @@
+package casework
+
+// An overview of a caseworker's whole caseload, so they can see which
+// clients need attention without opening every application.
+
+import (
+	"api/core/server/account"
+	"api/core/server/data/application"
+	"api/db"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// Flags for things a caseworker should follow up on.
+const (
+	FlagNoApplication           = "no_application"
+	FlagMissingSSN              = "missing_ssn"
+	FlagMissingCurrentResidence = "missing_current_residence"
+	FlagNoDocuments             = "no_documents"
+	FlagOverdueTasks            = "overdue_tasks"
+)
+
+type DashboardClient struct {
+	Email               string                   `json:"email"`
+	FirstName           string                   `json:"first_name"`
+	LastName            string                   `json:"last_name"`
+	HasApplication      bool                     `json:"has_application"`
+	Applications        []DashboardApplication   `json:"applications"`
+	Completeness        application.Completeness `json:"completeness"`
+	OverallCompleteness int                      `json:"overall_completeness"`
+	Documents           map[string]int           `json:"documents"`
+	LastUpdated         *time.Time               `json:"last_updated"`
+	PendingTasks        int                      `json:"pending_tasks"`
+	OverdueTasks        int                      `json:"overdue_tasks"`
+	NoteCount           int                      `json:"note_count"`
+	LastNoteAt          *time.Time               `json:"last_note_at"`
+	Flags               []string                 `json:"flags"`
+}
+
+// A client's application to one program.
+type DashboardApplication struct {
+	ID      int                  `json:"id"`
+	Program string               `json:"program"`
+	Status  db.ApplicationStatus `json:"status"`
+}
+
+type GetDashboardResponse struct {
+	Success bool              `json:"success"`
+	Clients []DashboardClient `json:"clients"`
+	Error   string            `json:"error"`
+}
+
+// Rows from the raw queries below
+type documentCountRow struct {
+	ClientID int    `json:"client_id"`
+	FileType string `json:"file_type"`
+	Count    int    `json:"count"`
+}
+
+type noteCountRow struct {
+	ClientID   int       `json:"client_id"`
+	Count      int       `json:"count"`
+	LastNoteAt time.Time `json:"last_note_at"`
+}
+
+// Uploaded files are counted in the database so file data isn't loaded for
+// the whole caseload.
+const documentCountQuery = `
+SELECT u."id" AS "client_id", f."file_type" AS "file_type", COUNT(*)::int AS "count"
+FROM "UploadedFile" f
+JOIN "User" u ON u."personal_info_id" = f."personalInfoId"
+JOIN "UserLink" l ON l."clientId" = u."id"
+WHERE l."caseworkerId" = $1
+GROUP BY u."id", f."file_type"`
+
+const noteCountQuery = `
+SELECT n."clientId" AS "client_id", COUNT(*)::int AS "count", MAX(n."createdAt") AS "last_note_at"
+FROM "CaseNote" n
+JOIN "UserLink" l ON l."clientId" = n."clientId"
+WHERE l."caseworkerId" = $1
+GROUP BY n."clientId"`
+
+// latestHousingApplication picks the application the client changed last,
+// which is the one the completeness is worked out from. Returns nil if they
+// have none.
+func latestHousingApplication(housingApplications []db.HousingApplicationModel) *db.HousingApplicationModel {
+	var latest *db.HousingApplicationModel = nil
+	for i := range housingApplications {
+		if latest == nil || housingApplications[i].UpdatedAt.After(latest.UpdatedAt) {
+			latest = &housingApplications[i]
+		}
+	}
+	return latest
+}
+
+// dashboardFlags works out what a caseworker should follow up on for a client.
+func dashboardFlags(entry *DashboardClient, data *application.ApplicationData) []string {
+	flags := []string{}
+	if data == nil {
+		flags = append(flags, FlagNoApplication)
+	} else {
+		if data.PersonalInfo.SSN == "" {
+			flags = append(flags, FlagMissingSSN)
+		}
+		if data.History.CurrentResidence == nil {
+			flags = append(flags, FlagMissingCurrentResidence)
+		}
+	}
+	if len(entry.Documents) == 0 {
+		flags = append(flags, FlagNoDocuments)
+	}
+	if entry.OverdueTasks > 0 {
+		flags = append(flags, FlagOverdueTasks)
+	}
+	return flags
+}
+
+// GetDashboardHandler summarizes every client linked to the caseworker:
+// how complete each section of their application is, what documents they
+// have uploaded, when their application last changed, their pending tasks
+// and notes, and anything that needs following up on.
+func GetDashboardHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, GetDashboardResponse{Success: false, Error: "Failed to authenticate"})
+	}
+	if user.Type != db.UserTypeCaseWorker {
+		return c.JSON(400, GetDashboardResponse{Success: false, Error: "Only caseworkers have a caseload"})
+	}
+
+	// Completeness needs every section, but only the preferences of the
+	// application it's worked out from
+	links, linksErr := client.UserLink.FindMany(
+		db.UserLink.CaseworkerID.Equals(user.ID),
+	).With(
+		db.UserLink.Client.Fetch().With(
+			db.User.PersonalInfo.Fetch(),
+			db.User.ApplicationData.Fetch().With(
+				application.ApplicationWith...,
+			),
+			db.User.HousingApplications.Fetch(),
+		),
+	).Exec(context.Background())
+	if linksErr != nil {
+		fmt.Printf("[ERROR] Failed to get caseload for caseworker %d: %v\n", user.ID, linksErr)
+		return c.JSON(500, GetDashboardResponse{Success: false, Error: "Failed to get caseload"})
+	}
+
+	latestIds := []int{}
+	for _, link := range links {
+		if latest := latestHousingApplication(link.Client().HousingApplications()); latest != nil {
+			latestIds = append(latestIds, latest.ID)
+		}
+	}
+	latestApplications, latestErr := client.HousingApplication.FindMany(
+		db.HousingApplication.ID.In(latestIds),
+	).With(
+		application.HousingApplicationWith...,
+	).Exec(context.Background())
+	if latestErr != nil {
+		fmt.Printf("[ERROR] Failed to get applications for caseworker %d: %v\n", user.ID, latestErr)
+		return c.JSON(500, GetDashboardResponse{Success: false, Error: "Failed to get applications"})
+	}
+	latest := map[int]*db.HousingApplicationModel{}
+	for i := range latestApplications {
+		latest[latestApplications[i].UserID] = &latestApplications[i]
+	}
+
+	var documentCounts []documentCountRow
+	if err := client.Prisma.QueryRaw(documentCountQuery, user.ID).Exec(context.Background(), &documentCounts); err != nil {
+		fmt.Printf("[ERROR] Failed to count documents for caseworker %d: %v\n", user.ID, err)
+		return c.JSON(500, GetDashboardResponse{Success: false, Error: "Failed to count documents"})
+	}
+	documents := map[int]map[string]int{}
+	for _, row := range documentCounts {
+		if documents[row.ClientID] == nil {
+			documents[row.ClientID] = map[string]int{}
+		}
+		documents[row.ClientID][row.FileType] = row.Count
+	}
+
+	var noteCounts []noteCountRow
+	if err := client.Prisma.QueryRaw(noteCountQuery, user.ID).Exec(context.Background(), &noteCounts); err != nil {
+		fmt.Printf("[ERROR] Failed to count notes for caseworker %d: %v\n", user.ID, err)
+		return c.JSON(500, GetDashboardResponse{Success: false, Error: "Failed to count notes"})
+	}
+	notes := map[int]noteCountRow{}
+	for _, row := range noteCounts {
+		notes[row.ClientID] = row
+	}
+
+	tasks, tasksErr := client.CaseTask.FindMany(
+		caseloadTaskFilter(user),
+		db.CaseTask.Status.In(openTaskStatuses),
+	).Exec(context.Background())
+	if tasksErr != nil {
+		fmt.Printf("[ERROR] Failed to get tasks for caseworker %d: %v\n", user.ID, tasksErr)
+		return c.JSON(500, GetDashboardResponse{Success: false, Error: "Failed to get tasks"})
+	}
+	pendingTasks := map[int]int{}
+	overdueTasks := map[int]int{}
+	for _, task := range tasks {
+		pendingTasks[task.ClientID]++
+		if dueDate, ok := task.DueDate(); ok && dueDate.Before(today()) {
+			overdueTasks[task.ClientID]++
+		}
+	}
+
+	clients := make([]DashboardClient, len(links))
+	for i, link := range links {
+		linkClient := link.Client()
+		personalInfo := linkClient.PersonalInfo()
+
+		entry := DashboardClient{
+			Email:        linkClient.Email,
+			FirstName:    personalInfo.FirstName,
+			LastName:     personalInfo.LastName,
+			Completeness: application.Completeness{},
+			Documents:    documents[linkClient.ID],
+			PendingTasks: pendingTasks[linkClient.ID],
+			OverdueTasks: overdueTasks[linkClient.ID],
+		}
+		if entry.Documents == nil {
+			entry.Documents = map[string]int{}
+		}
+		if note, ok := notes[linkClient.ID]; ok {
+			entry.NoteCount = note.Count
+			entry.LastNoteAt = &note.LastNoteAt
+		}
+
+		housingApplications := linkClient.HousingApplications()
+		entry.Applications = make([]DashboardApplication, len(housingApplications))
+		for j, housingApplication := range housingApplications {
+			entry.Applications[j] = DashboardApplication{
+				ID:      housingApplication.ID,
+				Program: housingApplication.Program,
+				Status:  housingApplication.Status,
+			}
+		}
+
+		// Nothing's decrypted, so one client's data can't keep the rest of
+		// the caseload from showing
+		var data *application.ApplicationData = nil
+		if applicationData, ok := linkClient.ApplicationData(); ok {
+			outline := application.ToApplicationOutline(applicationData, latest[linkClient.ID], personalInfo, linkClient.Email)
+			data = &outline
+
+			entry.HasApplication = true
+			entry.Completeness = application.GetCompleteness(data)
+			entry.OverallCompleteness = entry.Completeness.Overall()
+			entry.LastUpdated = &applicationData.UpdatedAt
+		}
+		entry.Flags = dashboardFlags(&entry, data)
+
+		clients[i] = entry
+	}
+
+	return c.JSON(200, GetDashboardResponse{Success: true, Clients: clients, Error: ""})
+}
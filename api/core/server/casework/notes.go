@@ -0,0 +1,389 @@
+package casework
+
+// Case notes caseworkers keep about their clients. Internal notes are only
+// seen by caseworkers, shared notes are also shown to the client.
+
+import (
+	"api/core/server/account"
+	"api/db"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+type NoteInfo struct {
+	ID          int       `json:"id"`
+	ClientEmail string    `json:"client_email"`
+	AuthorEmail string    `json:"author_email"`
+	Body        string    `json:"body"`
+	Visibility  string    `json:"visibility"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type NoteRevisionInfo struct {
+	Body       string    `json:"body"`
+	Visibility string    `json:"visibility"`
+	EditedAt   time.Time `json:"edited_at"`
+}
+
+type GetNotesResponse struct {
+	Success bool       `json:"success"`
+	Notes   []NoteInfo `json:"notes"`
+	Error   string     `json:"error"`
+}
+
+type CreateNoteRequest struct {
+	Email      string `json:"email"`
+	Body       string `json:"body"`
+	Visibility string `json:"visibility"`
+}
+
+type UpdateNoteRequest struct {
+	ID         int    `json:"id"`
+	Body       string `json:"body"`
+	Visibility string `json:"visibility"`
+}
+
+type NoteResponse struct {
+	Success bool     `json:"success"`
+	Note    NoteInfo `json:"note"`
+	Error   string   `json:"error"`
+}
+
+type DeleteNoteRequest struct {
+	ID int `json:"id"`
+}
+
+type DeleteNoteResponse struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+}
+
+type GetNoteHistoryResponse struct {
+	Success   bool               `json:"success"`
+	Note      NoteInfo           `json:"note"`
+	Revisions []NoteRevisionInfo `json:"revisions"`
+	Error     string             `json:"error"`
+}
+
+// parseVisibility reads a note visibility, notes are internal unless they're
+// explicitly shared.
+func parseVisibility(visibility string) (db.NoteVisibility, error) {
+	switch strings.ToUpper(strings.TrimSpace(visibility)) {
+	case "", string(db.NoteVisibilityInternal):
+		return db.NoteVisibilityInternal, nil
+	case string(db.NoteVisibilityShared):
+		return db.NoteVisibilityShared, nil
+	}
+	return "", fmt.Errorf("invalid visibility %s", visibility)
+}
+
+// toNoteInfo converts a note for a response.
+func toNoteInfo(note *db.CaseNoteModel, clientEmail string, authorEmail string) NoteInfo {
+	return NoteInfo{
+		ID:          note.ID,
+		ClientEmail: clientEmail,
+		AuthorEmail: authorEmail,
+		Body:        note.Body,
+		Visibility:  string(note.Visibility),
+		CreatedAt:   note.CreatedAt,
+		UpdatedAt:   note.UpdatedAt,
+	}
+}
+
+// fetchedNoteInfo converts a note that was fetched with its client and author.
+func fetchedNoteInfo(note *db.CaseNoteModel) NoteInfo {
+	authorEmail := ""
+	if author, ok := note.Author(); ok {
+		authorEmail = author.Email
+	}
+	return toNoteInfo(note, note.Client().Email, authorEmail)
+}
+
+// canReadNote checks if a user can see a note. Clients see the shared notes
+// about them, and caseworkers see every note about their clients.
+func canReadNote(client *db.PrismaClient, user *db.UserModel, note *db.CaseNoteModel) bool {
+	if user.Type == db.UserTypeClient {
+		return note.ClientID == user.ID && note.Visibility == db.NoteVisibilityShared
+	}
+	return user.Type == db.UserTypeCaseWorker && isLinked(client, note.ClientID, user.ID)
+}
+
+// findAuthoredNote finds a note the caseworker wrote about one of their
+// current clients, so it can be changed.
+func findAuthoredNote(client *db.PrismaClient, user *db.UserModel, id int) (*db.CaseNoteModel, string) {
+	if user.Type != db.UserTypeCaseWorker {
+		return nil, "Only caseworkers can change notes"
+	}
+
+	note, noteErr := client.CaseNote.FindUnique(
+		db.CaseNote.ID.Equals(id),
+	).With(
+		db.CaseNote.Client.Fetch(),
+	).Exec(context.Background())
+	if noteErr != nil {
+		return nil, "Failed to find note"
+	}
+
+	if authorId, ok := note.AuthorID(); !ok || authorId != user.ID {
+		return nil, "Only the author can change a note"
+	}
+	if !isLinked(client, note.ClientID, user.ID) {
+		return nil, "Not a caseworker for account"
+	}
+	return note, ""
+}
+
+// GetNotesHandler lists notes, newest first. Clients get the notes shared
+// with them. Caseworkers get the notes for one client with the email param,
+// or for their whole caseload without it. The q param searches note text.
+func GetNotesHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, GetNotesResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	var filters []db.CaseNoteWhereParam
+	if user.Type == db.UserTypeClient {
+		filters = append(filters,
+			db.CaseNote.ClientID.Equals(user.ID),
+			db.CaseNote.Visibility.Equals(db.NoteVisibilityShared),
+		)
+	} else if user.Type == db.UserTypeCaseWorker {
+		ownerEmail := c.QueryParam("email")
+		if ownerEmail != "" {
+			owner, ownerErr := findLinkedClient(client, user, ownerEmail)
+			if ownerErr != nil {
+				fmt.Printf("[ERROR] Not a caseworker for owner email: %s\n", ownerEmail)
+				return c.JSON(400, GetNotesResponse{Success: false, Error: "Not a caseworker for account"})
+			}
+			filters = append(filters, db.CaseNote.ClientID.Equals(owner.ID))
+		} else {
+			filters = append(filters, db.CaseNote.Client.Where(
+				db.User.ClientLinks.Some(
+					db.UserLink.CaseworkerID.Equals(user.ID),
+				),
+			))
+		}
+
+		if c.QueryParams().Has("visibility") {
+			visibility, visibilityErr := parseVisibility(c.QueryParam("visibility"))
+			if visibilityErr != nil {
+				return c.JSON(400, GetNotesResponse{Success: false, Error: "Invalid visibility"})
+			}
+			filters = append(filters, db.CaseNote.Visibility.Equals(visibility))
+		}
+	} else {
+		return c.JSON(400, GetNotesResponse{Success: false, Error: "Invalid user type"})
+	}
+
+	if search := strings.TrimSpace(c.QueryParam("q")); search != "" {
+		filters = append(filters,
+			db.CaseNote.Body.Contains(search),
+			db.CaseNote.Body.Mode(db.QueryModeInsensitive),
+		)
+	}
+
+	notes, notesErr := client.CaseNote.FindMany(
+		filters...,
+	).With(
+		db.CaseNote.Client.Fetch(),
+		db.CaseNote.Author.Fetch(),
+	).OrderBy(
+		db.CaseNote.CreatedAt.Order(db.SortOrderDesc),
+	).Exec(context.Background())
+	if notesErr != nil {
+		fmt.Printf("[ERROR] Failed to get notes for user %d: %v\n", user.ID, notesErr)
+		return c.JSON(500, GetNotesResponse{Success: false, Error: "Failed to get notes"})
+	}
+
+	noteInfos := make([]NoteInfo, len(notes))
+	for i := range notes {
+		noteInfos[i] = fetchedNoteInfo(&notes[i])
+	}
+
+	return c.JSON(200, GetNotesResponse{Success: true, Notes: noteInfos, Error: ""})
+}
+
+// CreateNoteHandler adds a note about one of the caseworker's clients.
+func CreateNoteHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, NoteResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	var request CreateNoteRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&request); err != nil {
+		return c.JSON(500, NoteResponse{Success: false, Error: "Failed to parse request body"})
+	}
+
+	if user.Type != db.UserTypeCaseWorker {
+		return c.JSON(400, NoteResponse{Success: false, Error: "Only caseworkers can write notes"})
+	}
+
+	owner, ownerErr := findLinkedClient(client, user, request.Email)
+	if ownerErr != nil {
+		fmt.Printf("[ERROR] Not a caseworker for owner email: %s\n", request.Email)
+		return c.JSON(400, NoteResponse{Success: false, Error: "Not a caseworker for account"})
+	}
+
+	body := strings.TrimSpace(request.Body)
+	if body == "" {
+		return c.JSON(400, NoteResponse{Success: false, Error: "Note is empty"})
+	}
+
+	visibility, visibilityErr := parseVisibility(request.Visibility)
+	if visibilityErr != nil {
+		return c.JSON(400, NoteResponse{Success: false, Error: "Invalid visibility"})
+	}
+
+	note, noteErr := client.CaseNote.CreateOne(
+		db.CaseNote.Client.Link(
+			db.User.ID.Equals(owner.ID),
+		),
+		db.CaseNote.Body.Set(body),
+		db.CaseNote.Author.Link(
+			db.User.ID.Equals(user.ID),
+		),
+		db.CaseNote.Visibility.Set(visibility),
+	).Exec(context.Background())
+	if noteErr != nil {
+		fmt.Printf("[ERROR] Failed to create note for user %d: %v\n", owner.ID, noteErr)
+		return c.JSON(500, NoteResponse{Success: false, Error: "Failed to create note"})
+	}
+
+	return c.JSON(200, NoteResponse{Success: true, Note: toNoteInfo(note, owner.Email, user.Email), Error: ""})
+}
+
+// UpdateNoteHandler edits a note, keeping the previous version in its
+// history. Only the note's author can edit it.
+func UpdateNoteHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, NoteResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	var request UpdateNoteRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&request); err != nil {
+		return c.JSON(500, NoteResponse{Success: false, Error: "Failed to parse request body"})
+	}
+
+	note, noteErr := findAuthoredNote(client, user, request.ID)
+	if note == nil {
+		return c.JSON(400, NoteResponse{Success: false, Error: noteErr})
+	}
+
+	body := strings.TrimSpace(request.Body)
+	if body == "" {
+		return c.JSON(400, NoteResponse{Success: false, Error: "Note is empty"})
+	}
+
+	visibility := note.Visibility
+	if request.Visibility != "" {
+		var visibilityErr error
+		visibility, visibilityErr = parseVisibility(request.Visibility)
+		if visibilityErr != nil {
+			return c.JSON(400, NoteResponse{Success: false, Error: "Invalid visibility"})
+		}
+	}
+
+	if body == note.Body && visibility == note.Visibility {
+		return c.JSON(200, NoteResponse{Success: true, Note: toNoteInfo(note, note.Client().Email, user.Email), Error: ""})
+	}
+
+	revision := client.CaseNoteRevision.CreateOne(
+		db.CaseNoteRevision.Note.Link(
+			db.CaseNote.ID.Equals(note.ID),
+		),
+		db.CaseNoteRevision.Body.Set(note.Body),
+		db.CaseNoteRevision.Visibility.Set(note.Visibility),
+	).Tx()
+	update := client.CaseNote.FindUnique(
+		db.CaseNote.ID.Equals(note.ID),
+	).Update(
+		db.CaseNote.Body.Set(body),
+		db.CaseNote.Visibility.Set(visibility),
+	).Tx()
+	if txErr := client.Prisma.Transaction(revision, update).Exec(context.Background()); txErr != nil {
+		fmt.Printf("[ERROR] Failed to update note %d: %v\n", note.ID, txErr)
+		return c.JSON(500, NoteResponse{Success: false, Error: "Failed to update note"})
+	}
+
+	return c.JSON(200, NoteResponse{Success: true, Note: toNoteInfo(update.Result(), note.Client().Email, user.Email), Error: ""})
+}
+
+// DeleteNoteHandler deletes a note along with its history. Only the note's
+// author can delete it.
+func DeleteNoteHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, DeleteNoteResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	var request DeleteNoteRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&request); err != nil {
+		return c.JSON(500, DeleteNoteResponse{Success: false, Error: "Failed to parse request body"})
+	}
+
+	note, noteErr := findAuthoredNote(client, user, request.ID)
+	if note == nil {
+		return c.JSON(400, DeleteNoteResponse{Success: false, Error: noteErr})
+	}
+
+	_, deleteErr := client.CaseNote.FindUnique(
+		db.CaseNote.ID.Equals(note.ID),
+	).Delete().Exec(context.Background())
+	if deleteErr != nil {
+		fmt.Printf("[ERROR] Failed to delete note %d: %v\n", note.ID, deleteErr)
+		return c.JSON(500, DeleteNoteResponse{Success: false, Error: "Failed to delete note"})
+	}
+
+	return c.JSON(200, DeleteNoteResponse{Success: true, Error: ""})
+}
+
+// GetNoteHistoryHandler returns a note with its previous versions, newest
+// first. Clients only see the versions that were shared with them.
+func GetNoteHistoryHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, GetNoteHistoryResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	id, idErr := strconv.Atoi(c.QueryParam("id"))
+	if idErr != nil {
+		return c.JSON(400, GetNoteHistoryResponse{Success: false, Error: "Invalid note ID"})
+	}
+
+	note, noteErr := client.CaseNote.FindUnique(
+		db.CaseNote.ID.Equals(id),
+	).With(
+		db.CaseNote.Client.Fetch(),
+		db.CaseNote.Author.Fetch(),
+		db.CaseNote.Revisions.Fetch().OrderBy(
+			db.CaseNoteRevision.EditedAt.Order(db.SortOrderDesc),
+		),
+	).Exec(context.Background())
+	if noteErr != nil || !canReadNote(client, user, note) {
+		return c.JSON(400, GetNoteHistoryResponse{Success: false, Error: "Failed to find note"})
+	}
+
+	revisions := []NoteRevisionInfo{}
+	for _, revision := range note.Revisions() {
+		if user.Type == db.UserTypeClient && revision.Visibility != db.NoteVisibilityShared {
+			continue
+		}
+		revisions = append(revisions, NoteRevisionInfo{
+			Body:       revision.Body,
+			Visibility: string(revision.Visibility),
+			EditedAt:   revision.EditedAt,
+		})
+	}
+
+	return c.JSON(200, GetNoteHistoryResponse{Success: true, Note: fetchedNoteInfo(note), Revisions: revisions, Error: ""})
+}
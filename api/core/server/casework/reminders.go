@@ -0,0 +1,126 @@
+package casework
+
+// Emails caseworkers when their tasks come due.
+
+import (
+	"api/core/server/email"
+	"api/db"
+	"context"
+	"fmt"
+	"time"
+)
+
+// How often the scheduler checks for tasks that have come due.
+const reminderInterval = time.Hour
+
+// sendReminderEmail sends a caseworker their reminders. Mockable for testing.
+var sendReminderEmail func(to string, tasks []string) error = email.SendTaskReminderEmail
+
+// describeTask builds the line for a task in a reminder email.
+func describeTask(task *db.CaseTaskModel) string {
+	clientName := task.Client().Email
+	if info := task.Client().PersonalInfo(); info != nil {
+		clientName = fmt.Sprintf("%s %s", info.FirstName, info.LastName)
+	}
+	dueDate, _ := task.DueDate()
+	return fmt.Sprintf("%s for %s, due %s (%s priority)", task.Title, clientName, formatDate(dueDate), task.Priority)
+}
+
+// stillAssigned reports whether a task's assignee is still linked to its
+// client, for tasks fetched with their client's links. Caseworkers who lost
+// the client, like when they unlinked, aren't reminded about their tasks.
+func stillAssigned(task *db.CaseTaskModel) (*db.UserModel, bool) {
+	assignee, ok := task.Assignee()
+	if !ok {
+		return nil, false
+	}
+	for _, link := range task.Client().ClientLinks() {
+		if link.CaseworkerID == assignee.ID {
+			return assignee, true
+		}
+	}
+	return nil, false
+}
+
+// SendDueReminders emails each caseworker a list of their open tasks that
+// are due today or overdue, for clients they're still linked to. Every task
+// is only reminded about once per due date and assignee.
+func SendDueReminders(client *db.PrismaClient) error {
+	tasks, tasksErr := client.CaseTask.FindMany(
+		db.CaseTask.Status.In(openTaskStatuses),
+		db.CaseTask.DueDate.Before(today().AddDate(0, 0, 1)),
+		db.CaseTask.ReminderSentAt.EqualsOptional(nil),
+	).With(
+		db.CaseTask.Client.Fetch().With(
+			db.User.PersonalInfo.Fetch(),
+			db.User.ClientLinks.Fetch(),
+		),
+		db.CaseTask.Assignee.Fetch(),
+	).OrderBy(
+		db.CaseTask.DueDate.Order(db.SortOrderAsc),
+	).Exec(context.Background())
+	if tasksErr != nil {
+		return tasksErr
+	}
+
+	// Group the tasks so caseworkers get one email each
+	assignees := []string{}
+	reminders := map[string][]string{}
+	reminderTaskIds := map[string][]int{}
+	for i := range tasks {
+		assignee, ok := stillAssigned(&tasks[i])
+		if !ok {
+			continue
+		}
+		if _, seen := reminders[assignee.Email]; !seen {
+			assignees = append(assignees, assignee.Email)
+		}
+		reminders[assignee.Email] = append(reminders[assignee.Email], describeTask(&tasks[i]))
+		reminderTaskIds[assignee.Email] = append(reminderTaskIds[assignee.Email], tasks[i].ID)
+	}
+
+	for _, assignee := range assignees {
+		if sendErr := sendReminderEmail(assignee, reminders[assignee]); sendErr != nil {
+			// Try again next time
+			fmt.Printf("[ERROR] Failed to send task reminders to %s: %v\n", assignee, sendErr)
+			continue
+		}
+
+		_, updateErr := client.CaseTask.FindMany(
+			db.CaseTask.ID.In(reminderTaskIds[assignee]),
+		).Update(
+			db.CaseTask.ReminderSentAt.Set(time.Now()),
+		).Exec(context.Background())
+		if updateErr != nil {
+			fmt.Printf("[ERROR] Failed to mark task reminders sent for %s: %v\n", assignee, updateErr)
+		}
+	}
+
+	return nil
+}
+
+// StartReminders runs SendDueReminders in the background until the returned
+// function is called.
+func StartReminders(client *db.PrismaClient) func() {
+	ticker := time.NewTicker(reminderInterval)
+	done := make(chan bool)
+
+	go func() {
+		for {
+			if err := SendDueReminders(client); err != nil {
+				fmt.Printf("[ERROR] Failed to send task reminders: %v\n", err)
+			}
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
@@ -0,0 +1,496 @@
+package casework
+
+// Follow-up tasks caseworkers keep for their clients, like requesting a
+// birth certificate or calling a landlord reference.
+
+import (
+	"api/core/server/account"
+	"api/core/util"
+	"api/db"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// How far ahead upcoming tasks look by default.
+const defaultUpcomingDays = 7
+
+// Tasks that still need to be done.
+var openTaskStatuses = []db.TaskStatus{
+	db.TaskStatusOpen,
+	db.TaskStatusInProgress,
+}
+
+type TaskInfo struct {
+	ID            int    `json:"id"`
+	ClientEmail   string `json:"client_email"`
+	AssigneeEmail string `json:"assignee_email"`
+	Title         string `json:"title"`
+	Description   string `json:"description"`
+	DueDate       string `json:"due_date"`
+	Status        string `json:"status"`
+	Priority      string `json:"priority"`
+	Overdue       bool   `json:"overdue"`
+}
+
+type GetTasksResponse struct {
+	Success bool       `json:"success"`
+	Tasks   []TaskInfo `json:"tasks"`
+	Error   string     `json:"error"`
+}
+
+type CreateTaskRequest struct {
+	Email         string `json:"email"`
+	AssigneeEmail string `json:"assignee_email"`
+	Title         string `json:"title"`
+	Description   string `json:"description"`
+	DueDate       string `json:"due_date"`
+	Priority      string `json:"priority"`
+}
+
+type UpdateTaskRequest struct {
+	ID            int    `json:"id"`
+	AssigneeEmail string `json:"assignee_email"`
+	Title         string `json:"title"`
+	Description   string `json:"description"`
+	DueDate       string `json:"due_date"`
+	Status        string `json:"status"`
+	Priority      string `json:"priority"`
+}
+
+type TaskResponse struct {
+	Success bool     `json:"success"`
+	Task    TaskInfo `json:"task"`
+	Error   string   `json:"error"`
+}
+
+type DeleteTaskRequest struct {
+	ID int `json:"id"`
+}
+
+type DeleteTaskResponse struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+}
+
+// today returns the start of the current day. Mockable for testing.
+var today func() time.Time = func() time.Time {
+	return time.Now().UTC().Truncate(time.Hour * 24)
+}
+
+// parseTaskStatus reads a task status, new tasks are open.
+func parseTaskStatus(status string) (db.TaskStatus, error) {
+	status = strings.ToUpper(strings.TrimSpace(status))
+	for _, taskStatus := range []db.TaskStatus{db.TaskStatusOpen, db.TaskStatusInProgress, db.TaskStatusDone, db.TaskStatusCancelled} {
+		if status == string(taskStatus) {
+			return taskStatus, nil
+		}
+	}
+	if status == "" {
+		return db.TaskStatusOpen, nil
+	}
+	return "", fmt.Errorf("invalid status %s", status)
+}
+
+// parseTaskPriority reads a task priority, tasks are normal priority unless
+// set otherwise.
+func parseTaskPriority(priority string) (db.TaskPriority, error) {
+	priority = strings.ToUpper(strings.TrimSpace(priority))
+	for _, taskPriority := range []db.TaskPriority{db.TaskPriorityLow, db.TaskPriorityNormal, db.TaskPriorityHigh, db.TaskPriorityUrgent} {
+		if priority == string(taskPriority) {
+			return taskPriority, nil
+		}
+	}
+	if priority == "" {
+		return db.TaskPriorityNormal, nil
+	}
+	return "", fmt.Errorf("invalid priority %s", priority)
+}
+
+// formatDate formats a due date the same way dates are sent to the app.
+func formatDate(date time.Time) string {
+	return fmt.Sprintf("%d-%02d-%02d", date.Year(), date.Month(), date.Day())
+}
+
+// isOpenTask checks if a task still needs to be done.
+func isOpenTask(status db.TaskStatus) bool {
+	return status == db.TaskStatusOpen || status == db.TaskStatusInProgress
+}
+
+// toTaskInfo converts a task for a response.
+func toTaskInfo(task *db.CaseTaskModel, clientEmail string, assigneeEmail string) TaskInfo {
+	info := TaskInfo{
+		ID:            task.ID,
+		ClientEmail:   clientEmail,
+		AssigneeEmail: assigneeEmail,
+		Title:         task.Title,
+		Description:   task.Description,
+		Status:        string(task.Status),
+		Priority:      string(task.Priority),
+	}
+	if dueDate, ok := task.DueDate(); ok {
+		info.DueDate = formatDate(dueDate)
+		info.Overdue = isOpenTask(task.Status) && dueDate.Before(today())
+	}
+	return info
+}
+
+// fetchedTaskInfo converts a task that was fetched with its client and assignee.
+func fetchedTaskInfo(task *db.CaseTaskModel) TaskInfo {
+	assigneeEmail := ""
+	if assignee, ok := task.Assignee(); ok {
+		assigneeEmail = assignee.Email
+	}
+	return toTaskInfo(task, task.Client().Email, assigneeEmail)
+}
+
+// findAssignee finds the caseworker a task is assigned to, who has to be
+// linked with the task's client. Tasks are assigned to their creator by
+// default.
+func findAssignee(client *db.PrismaClient, user *db.UserModel, assigneeEmail string, clientId int) (*db.UserModel, error) {
+	if assigneeEmail == "" || assigneeEmail == user.Email {
+		return user, nil
+	}
+
+	assignee, assigneeErr := client.User.FindUnique(
+		db.User.Email.Equals(assigneeEmail),
+	).Exec(context.Background())
+	if assigneeErr != nil {
+		return nil, assigneeErr
+	}
+	if assignee.Type != db.UserTypeCaseWorker || !isLinked(client, clientId, assignee.ID) {
+		return nil, fmt.Errorf("%s is not a caseworker for the client", assigneeEmail)
+	}
+	return assignee, nil
+}
+
+// findLinkedTask finds a task for one of the caseworker's clients.
+func findLinkedTask(client *db.PrismaClient, user *db.UserModel, id int) (*db.CaseTaskModel, string) {
+	if user.Type != db.UserTypeCaseWorker {
+		return nil, "Only caseworkers can change tasks"
+	}
+
+	task, taskErr := client.CaseTask.FindUnique(
+		db.CaseTask.ID.Equals(id),
+	).With(
+		db.CaseTask.Client.Fetch(),
+		db.CaseTask.Assignee.Fetch(),
+	).Exec(context.Background())
+	if taskErr != nil {
+		return nil, "Failed to find task"
+	}
+
+	if !isLinked(client, task.ClientID, user.ID) {
+		return nil, "Not a caseworker for account"
+	}
+	return task, ""
+}
+
+// caseloadTaskFilter limits tasks to the clients linked with a caseworker.
+func caseloadTaskFilter(caseworker *db.UserModel) db.CaseTaskWhereParam {
+	return db.CaseTask.Client.Where(
+		db.User.ClientLinks.Some(
+			db.UserLink.CaseworkerID.Equals(caseworker.ID),
+		),
+	)
+}
+
+// listTasks responds with the tasks matching the filters, soonest due first.
+func listTasks(c echo.Context, client *db.PrismaClient, user *db.UserModel, filters ...db.CaseTaskWhereParam) error {
+	tasks, tasksErr := client.CaseTask.FindMany(
+		filters...,
+	).With(
+		db.CaseTask.Client.Fetch(),
+		db.CaseTask.Assignee.Fetch(),
+	).OrderBy(
+		db.CaseTask.DueDate.Order(db.SortOrderAsc),
+	).Exec(context.Background())
+	if tasksErr != nil {
+		fmt.Printf("[ERROR] Failed to get tasks for user %d: %v\n", user.ID, tasksErr)
+		return c.JSON(500, GetTasksResponse{Success: false, Error: "Failed to get tasks"})
+	}
+
+	taskInfos := make([]TaskInfo, len(tasks))
+	for i := range tasks {
+		taskInfos[i] = fetchedTaskInfo(&tasks[i])
+	}
+
+	return c.JSON(200, GetTasksResponse{Success: true, Tasks: taskInfos, Error: ""})
+}
+
+// authCaseworker authenticates a request that only caseworkers can make.
+func authCaseworker(c echo.Context, client *db.PrismaClient) (*db.UserModel, string) {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return nil, "Failed to authenticate"
+	}
+	if user.Type != db.UserTypeCaseWorker {
+		return nil, "Only caseworkers can manage tasks"
+	}
+	return user, ""
+}
+
+// GetTasksHandler lists tasks, soonest due first. The email param narrows it
+// down to one client, otherwise it covers the whole caseload. The status
+// param filters by status, and assigned=me only includes the caseworker's
+// own tasks.
+func GetTasksHandler(c echo.Context, client *db.PrismaClient) error {
+	user, authErr := authCaseworker(c, client)
+	if user == nil {
+		return c.JSON(400, GetTasksResponse{Success: false, Error: authErr})
+	}
+
+	filters := []db.CaseTaskWhereParam{}
+	ownerEmail := c.QueryParam("email")
+	if ownerEmail != "" {
+		owner, ownerErr := findLinkedClient(client, user, ownerEmail)
+		if ownerErr != nil {
+			fmt.Printf("[ERROR] Not a caseworker for owner email: %s\n", ownerEmail)
+			return c.JSON(400, GetTasksResponse{Success: false, Error: "Not a caseworker for account"})
+		}
+		filters = append(filters, db.CaseTask.ClientID.Equals(owner.ID))
+	} else {
+		filters = append(filters, caseloadTaskFilter(user))
+	}
+
+	if c.QueryParams().Has("status") {
+		status, statusErr := parseTaskStatus(c.QueryParam("status"))
+		if statusErr != nil {
+			return c.JSON(400, GetTasksResponse{Success: false, Error: "Invalid status"})
+		}
+		filters = append(filters, db.CaseTask.Status.Equals(status))
+	}
+
+	if c.QueryParam("assigned") == "me" {
+		filters = append(filters, db.CaseTask.AssigneeID.Equals(user.ID))
+	}
+
+	return listTasks(c, client, user, filters...)
+}
+
+// GetOverdueTasksHandler lists open tasks across the caseload whose due
+// date has passed.
+func GetOverdueTasksHandler(c echo.Context, client *db.PrismaClient) error {
+	user, authErr := authCaseworker(c, client)
+	if user == nil {
+		return c.JSON(400, GetTasksResponse{Success: false, Error: authErr})
+	}
+
+	return listTasks(c, client, user,
+		caseloadTaskFilter(user),
+		db.CaseTask.Status.In(openTaskStatuses),
+		db.CaseTask.DueDate.Before(today()),
+	)
+}
+
+// GetUpcomingTasksHandler lists open tasks across the caseload due in the
+// next week, or in the number of days given by the days param.
+func GetUpcomingTasksHandler(c echo.Context, client *db.PrismaClient) error {
+	user, authErr := authCaseworker(c, client)
+	if user == nil {
+		return c.JSON(400, GetTasksResponse{Success: false, Error: authErr})
+	}
+
+	days := defaultUpcomingDays
+	if c.QueryParams().Has("days") {
+		var daysErr error
+		days, daysErr = strconv.Atoi(c.QueryParam("days"))
+		if daysErr != nil || days < 1 {
+			return c.JSON(400, GetTasksResponse{Success: false, Error: "Invalid number of days"})
+		}
+	}
+
+	start := today()
+	return listTasks(c, client, user,
+		caseloadTaskFilter(user),
+		db.CaseTask.Status.In(openTaskStatuses),
+		db.CaseTask.DueDate.AfterEquals(start),
+		db.CaseTask.DueDate.Before(start.AddDate(0, 0, days)),
+	)
+}
+
+// CreateTaskHandler adds a task for one of the caseworker's clients.
+func CreateTaskHandler(c echo.Context, client *db.PrismaClient) error {
+	user, authErr := authCaseworker(c, client)
+	if user == nil {
+		return c.JSON(400, TaskResponse{Success: false, Error: authErr})
+	}
+
+	var request CreateTaskRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&request); err != nil {
+		return c.JSON(500, TaskResponse{Success: false, Error: "Failed to parse request body"})
+	}
+
+	owner, ownerErr := findLinkedClient(client, user, request.Email)
+	if ownerErr != nil {
+		fmt.Printf("[ERROR] Not a caseworker for owner email: %s\n", request.Email)
+		return c.JSON(400, TaskResponse{Success: false, Error: "Not a caseworker for account"})
+	}
+
+	title := strings.TrimSpace(request.Title)
+	if title == "" {
+		return c.JSON(400, TaskResponse{Success: false, Error: "Task title is required"})
+	}
+
+	priority, priorityErr := parseTaskPriority(request.Priority)
+	if priorityErr != nil {
+		return c.JSON(400, TaskResponse{Success: false, Error: "Invalid priority"})
+	}
+
+	assignee, assigneeErr := findAssignee(client, user, request.AssigneeEmail, owner.ID)
+	if assigneeErr != nil {
+		return c.JSON(400, TaskResponse{Success: false, Error: "Assignee is not a caseworker for account"})
+	}
+
+	params := []db.CaseTaskSetParam{
+		db.CaseTask.Assignee.Link(
+			db.User.ID.Equals(assignee.ID),
+		),
+		db.CaseTask.CreatedBy.Link(
+			db.User.ID.Equals(user.ID),
+		),
+		db.CaseTask.Description.Set(strings.TrimSpace(request.Description)),
+		db.CaseTask.Priority.Set(priority),
+	}
+	if request.DueDate != "" {
+		dueDate, dueDateErr := util.ParseTime(request.DueDate)
+		if dueDateErr != nil {
+			return c.JSON(400, TaskResponse{Success: false, Error: "Invalid due date"})
+		}
+		params = append(params, db.CaseTask.DueDate.Set(dueDate))
+	}
+
+	task, taskErr := client.CaseTask.CreateOne(
+		db.CaseTask.Client.Link(
+			db.User.ID.Equals(owner.ID),
+		),
+		db.CaseTask.Title.Set(title),
+		params...,
+	).Exec(context.Background())
+	if taskErr != nil {
+		fmt.Printf("[ERROR] Failed to create task for user %d: %v\n", owner.ID, taskErr)
+		return c.JSON(500, TaskResponse{Success: false, Error: "Failed to create task"})
+	}
+
+	return c.JSON(200, TaskResponse{Success: true, Task: toTaskInfo(task, owner.Email, assignee.Email), Error: ""})
+}
+
+// UpdateTaskHandler changes a task. Any caseworker linked with the client
+// can update it, e.g. to mark it done. Empty fields are left unchanged, and
+// changing the due date means a new reminder will be sent.
+func UpdateTaskHandler(c echo.Context, client *db.PrismaClient) error {
+	user, authErr := authCaseworker(c, client)
+	if user == nil {
+		return c.JSON(400, TaskResponse{Success: false, Error: authErr})
+	}
+
+	var request UpdateTaskRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&request); err != nil {
+		return c.JSON(500, TaskResponse{Success: false, Error: "Failed to parse request body"})
+	}
+
+	task, taskErr := findLinkedTask(client, user, request.ID)
+	if task == nil {
+		return c.JSON(400, TaskResponse{Success: false, Error: taskErr})
+	}
+
+	assigneeEmail := ""
+	if assignee, ok := task.Assignee(); ok {
+		assigneeEmail = assignee.Email
+	}
+
+	params := []db.CaseTaskSetParam{}
+	if title := strings.TrimSpace(request.Title); title != "" {
+		params = append(params, db.CaseTask.Title.Set(title))
+	}
+	if request.Description != "" {
+		params = append(params, db.CaseTask.Description.Set(strings.TrimSpace(request.Description)))
+	}
+	if request.Priority != "" {
+		priority, priorityErr := parseTaskPriority(request.Priority)
+		if priorityErr != nil {
+			return c.JSON(400, TaskResponse{Success: false, Error: "Invalid priority"})
+		}
+		params = append(params, db.CaseTask.Priority.Set(priority))
+	}
+	if request.Status != "" {
+		status, statusErr := parseTaskStatus(request.Status)
+		if statusErr != nil {
+			return c.JSON(400, TaskResponse{Success: false, Error: "Invalid status"})
+		}
+		params = append(params, db.CaseTask.Status.Set(status))
+		if status == db.TaskStatusDone {
+			params = append(params, db.CaseTask.CompletedAt.Set(time.Now()))
+		} else {
+			params = append(params, db.CaseTask.CompletedAt.SetOptional(nil))
+		}
+	}
+	if request.DueDate != "" {
+		dueDate, dueDateErr := util.ParseTime(request.DueDate)
+		if dueDateErr != nil {
+			return c.JSON(400, TaskResponse{Success: false, Error: "Invalid due date"})
+		}
+		params = append(params,
+			db.CaseTask.DueDate.Set(dueDate),
+			db.CaseTask.ReminderSentAt.SetOptional(nil),
+		)
+	}
+	if request.AssigneeEmail != "" && request.AssigneeEmail != assigneeEmail {
+		assignee, assigneeErr := findAssignee(client, user, request.AssigneeEmail, task.ClientID)
+		if assigneeErr != nil {
+			return c.JSON(400, TaskResponse{Success: false, Error: "Assignee is not a caseworker for account"})
+		}
+		assigneeEmail = assignee.Email
+		params = append(params,
+			db.CaseTask.Assignee.Link(
+				db.User.ID.Equals(assignee.ID),
+			),
+			db.CaseTask.ReminderSentAt.SetOptional(nil),
+		)
+	}
+
+	updated, updateErr := client.CaseTask.FindUnique(
+		db.CaseTask.ID.Equals(task.ID),
+	).Update(
+		params...,
+	).Exec(context.Background())
+	if updateErr != nil {
+		fmt.Printf("[ERROR] Failed to update task %d: %v\n", task.ID, updateErr)
+		return c.JSON(500, TaskResponse{Success: false, Error: "Failed to update task"})
+	}
+
+	return c.JSON(200, TaskResponse{Success: true, Task: toTaskInfo(updated, task.Client().Email, assigneeEmail), Error: ""})
+}
+
+// DeleteTaskHandler deletes a task.
+func DeleteTaskHandler(c echo.Context, client *db.PrismaClient) error {
+	user, authErr := authCaseworker(c, client)
+	if user == nil {
+		return c.JSON(400, DeleteTaskResponse{Success: false, Error: authErr})
+	}
+
+	var request DeleteTaskRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&request); err != nil {
+		return c.JSON(500, DeleteTaskResponse{Success: false, Error: "Failed to parse request body"})
+	}
+
+	task, taskErr := findLinkedTask(client, user, request.ID)
+	if task == nil {
+		return c.JSON(400, DeleteTaskResponse{Success: false, Error: taskErr})
+	}
+
+	_, deleteErr := client.CaseTask.FindUnique(
+		db.CaseTask.ID.Equals(task.ID),
+	).Delete().Exec(context.Background())
+	if deleteErr != nil {
+		fmt.Printf("[ERROR] Failed to delete task %d: %v\n", task.ID, deleteErr)
+		return c.JSON(500, DeleteTaskResponse{Success: false, Error: "Failed to delete task"})
+	}
+
+	return c.JSON(200, DeleteTaskResponse{Success: true, Error: ""})
+}
@@ -0,0 +1,190 @@
+package data
+
+// Comparing a client's household income to HUD's income limits for their
+// county, loaded by core/importincomelimits.
+
+import (
+	"api/core/server/account"
+	"api/core/server/ami"
+	"api/core/server/data/application"
+	"api/db"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+	"github.com/shopspring/decimal"
+)
+
+type AMILimits struct {
+	ExtremelyLow int `json:"extremely_low"`
+	VeryLow      int `json:"very_low"`
+	Low          int `json:"low"`
+}
+
+type GetAMIEligibilityResponse struct {
+	Success         bool            `json:"success"`
+	HouseholdSize   int             `json:"household_size"`
+	AnnualIncome    decimal.Decimal `json:"annual_income"`
+	Year            int             `json:"year"`
+	State           string          `json:"state"`
+	County          string          `json:"county"`
+	AreaName        string          `json:"area_name"`
+	MedianIncome    int             `json:"median_income"`
+	PercentOfMedian int             `json:"percent_of_median"`
+	Limits          AMILimits       `json:"limits"`
+	EligibleBands   []string        `json:"eligible_bands"`
+	// Income entries that couldn't be counted, so the bands may be off
+	IncomeIssues []application.FieldIssue `json:"income_issues"`
+	Error        string                   `json:"error"`
+}
+
+func toLimits(limit *db.IncomeLimitModel) ami.Limits {
+	return ami.Limits{
+		Year:          limit.Year,
+		State:         limit.State,
+		County:        limit.County,
+		AreaName:      limit.AreaName,
+		MedianIncome:  limit.MedianIncome,
+		HouseholdSize: limit.HouseholdSize,
+		ExtremelyLow:  limit.ExtremelyLow,
+		VeryLow:       limit.VeryLow,
+		Low:           limit.Low,
+	}
+}
+
+// householdSize counts the people in the owner's household from their family
+// links, which include the owner themselves.
+func householdSize(client *db.PrismaClient, owner *db.UserModel) (int, error) {
+	links, err := client.FamilyLink.FindMany(
+		db.FamilyLink.PersonalInfoID.Equals(owner.PersonalInfoID),
+	).Exec(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	// Accounts made before the owner got a family link of their own
+	return max(len(links), 1), nil
+}
+
+// findIncomeLimits finds the county's limits for the household size. Without
+// a year, the latest loaded is used. On failure, returns the status code and
+// error message to respond with.
+func findIncomeLimits(client *db.PrismaClient, state string, county string, year int, size int) (*ami.Limits, int, string) {
+	filters := []db.IncomeLimitWhereParam{
+		db.IncomeLimit.State.Equals(state),
+		db.IncomeLimit.CountyKey.Equals(ami.CountyKey(county)),
+	}
+	if year != 0 {
+		filters = append(filters, db.IncomeLimit.Year.Equals(year))
+	}
+	lookupSize := size
+	if size > ami.MaxHouseholdSize {
+		lookupSize = 4
+	}
+
+	limit, err := client.IncomeLimit.FindFirst(
+		append(filters, db.IncomeLimit.HouseholdSize.Equals(lookupSize))...,
+	).OrderBy(
+		db.IncomeLimit.Year.Order(db.SortOrderDesc),
+	).Exec(context.Background())
+	if err == db.ErrNotFound {
+		return nil, 404, "No income limits loaded for county"
+	}
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to get income limits for %s, %s: %v\n", county, state, err)
+		return nil, 500, "Failed to get income limits"
+	}
+
+	limits := toLimits(limit)
+	if size > ami.MaxHouseholdSize {
+		limits = ami.ForHouseholdSize(limits, size)
+	}
+	return &limits, 200, ""
+}
+
+// GetAMIEligibilityHandler compares the household income of the user, or with
+// the email param, a client, to the income limits for the county and state
+// params, and lists the AMI bands they're eligible for. The state defaults to
+// the one they live in, and the year param to the latest limits loaded.
+func GetAMIEligibilityHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, GetAMIEligibilityResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	ownerEmail := c.QueryParam("email")
+	owner, ownerErr := findOwner(client, user, ownerEmail)
+	if ownerErr != nil {
+		fmt.Printf("[ERROR] Not a caseworker for owner email: %s\n", ownerEmail)
+		return c.JSON(400, GetAMIEligibilityResponse{Success: false, Error: "Not a caseworker for account"})
+	}
+
+	year := 0
+	if param := c.QueryParam("year"); param != "" {
+		parsed, parseErr := strconv.Atoi(param)
+		if parseErr != nil {
+			return c.JSON(400, GetAMIEligibilityResponse{Success: false, Error: "Invalid year"})
+		}
+		year = parsed
+	}
+	county := strings.TrimSpace(c.QueryParam("county"))
+	if county == "" {
+		return c.JSON(400, GetAMIEligibilityResponse{Success: false, Error: "Missing county"})
+	}
+
+	applicationData, personalInfo, status, findErr := findApplicationData(client, owner, owner.Email)
+	if findErr != "" {
+		return c.JSON(status, GetAMIEligibilityResponse{Success: false, Error: findErr})
+	}
+	data := application.ApplicationData{}
+	if applicationData != nil {
+		var convertErr error
+		data, convertErr = application.ToApplicationData(applicationData, nil, personalInfo, owner.Email)
+		if convertErr != nil {
+			fmt.Printf("[ERROR] Failed to read application data of user %d: %v\n", owner.ID, convertErr)
+			return c.JSON(500, GetAMIEligibilityResponse{Success: false, Error: "Failed to read application data"})
+		}
+	}
+
+	state := strings.ToUpper(strings.TrimSpace(c.QueryParam("state")))
+	if state == "" && data.History.CurrentResidence != nil {
+		state = strings.ToUpper(strings.TrimSpace(data.History.CurrentResidence.State))
+	}
+	if state == "" {
+		return c.JSON(400, GetAMIEligibilityResponse{Success: false, Error: "Missing state"})
+	}
+
+	size, sizeErr := householdSize(client, owner)
+	if sizeErr != nil {
+		fmt.Printf("[ERROR] Failed to get family links for user %d: %v\n", owner.ID, sizeErr)
+		return c.JSON(500, GetAMIEligibilityResponse{Success: false, Error: "Failed to get household"})
+	}
+
+	limits, status, limitsErr := findIncomeLimits(client, state, county, year, size)
+	if limitsErr != "" {
+		return c.JSON(status, GetAMIEligibilityResponse{Success: false, Error: limitsErr})
+	}
+
+	income := application.SummarizeIncome(data.Income)
+	annualIncome := income.Household.TotalAnnual
+	return c.JSON(200, GetAMIEligibilityResponse{
+		Success:         true,
+		HouseholdSize:   size,
+		AnnualIncome:    annualIncome,
+		Year:            limits.Year,
+		State:           limits.State,
+		County:          limits.County,
+		AreaName:        limits.AreaName,
+		MedianIncome:    limits.MedianIncome,
+		PercentOfMedian: ami.PercentOfMedian(*limits, annualIncome),
+		Limits: AMILimits{
+			ExtremelyLow: limits.ExtremelyLow,
+			VeryLow:      limits.VeryLow,
+			Low:          limits.Low,
+		},
+		EligibleBands: ami.EligibleBands(*limits, annualIncome),
+		IncomeIssues:  income.Issues,
+		Error:         "",
+	})
+}
@@ -1,6 +1,8 @@
 package application
 
 import (
+	"api/core/encryption"
+	"api/core/util"
 	"api/db"
 	"fmt"
 )
@@ -136,26 +138,60 @@ type FileUploadData struct {
 	Path string `json:"path"`
 }
 
-func ToFamilyMember(member *db.FamilyMemberModel) FamilyMember {
+// Stands in for encrypted values in an outline.
+const outlined = "***"
+
+// converter turns stored rows into the json compat structs, decrypting as it
+// goes. It keeps the first value that couldn't be decrypted, since a blank
+// in its place would be saved back over what's stored. An outline doesn't
+// decrypt anything, encrypted values only stay told apart from empty ones.
+type converter struct {
+	err     error
+	outline bool
+}
+
+func (conv *converter) decrypt(stored string) string {
+	if conv.outline {
+		if stored == "" {
+			return ""
+		}
+		return outlined
+	}
+	plaintext, err := encryption.Decrypt(stored)
+	if err != nil && conv.err == nil {
+		conv.err = err
+	}
+	return plaintext
+}
+
+func (conv *converter) familyMember(member *db.FamilyMemberModel) FamilyMember {
 	return FamilyMember{
 		ID:           member.ID,
 		FirstName:    member.FirstName,
 		LastName:     member.LastName,
 		Birthday:     member.Birthday.Format("2006-01-02"),
-		SSN:          member.Ssn,
+		SSN:          conv.decrypt(member.Ssn),
 		Gender:       member.Gender,
 		Relationship: member.Relationship,
 	}
 }
 
-func ToFamilyMembers(members []db.FamilyMemberModel) []FamilyMember {
+func (conv *converter) familyMembers(members []db.FamilyMemberModel) []FamilyMember {
 	familyMembers := make([]FamilyMember, len(members))
 	for i, member := range members {
-		familyMembers[i] = ToFamilyMember(&member)
+		familyMembers[i] = conv.familyMember(&member)
 	}
 	return familyMembers
 }
 
+// ToFamilyMember converts a stored family member, failing if their SSN
+// can't be decrypted.
+func ToFamilyMember(member *db.FamilyMemberModel) (FamilyMember, error) {
+	var conv converter
+	familyMember := conv.familyMember(member)
+	return familyMember, conv.err
+}
+
 func (member *FamilyMember) Hash() string {
 	return fmt.Sprintf("%s-%s-%s-%s-%s-%s", member.FirstName, member.LastName, member.Birthday, member.SSN, member.Gender, member.Relationship)
 }
@@ -164,7 +200,7 @@ func Equal(a *FamilyMember, b *FamilyMember) bool {
 	return a.FirstName == b.FirstName && a.LastName == b.LastName && a.Birthday == b.Birthday && a.SSN == b.SSN && a.Gender == b.Gender && a.Relationship == b.Relationship
 }
 
-func ToResidenceData(residence *db.ResidenceInfoModel) ResidenceData {
+func (conv *converter) residenceData(residence *db.ResidenceInfoModel) ResidenceData {
 	return ResidenceData{
 		Address:            residence.Address,
 		City:               residence.City,
@@ -180,51 +216,197 @@ func ToResidenceData(residence *db.ResidenceInfoModel) ResidenceData {
 		ResidenceType:      residence.ResidenceType,
 		OtherResidenceType: residence.OtherResidenceType,
 		AllReside:          residence.AllReside,
-		NonResidingMembers: ToFamilyMembers(residence.NonResidingMembers()),
+		NonResidingMembers: conv.familyMembers(residence.NonResidingMembers()),
 	}
 }
 
-func ToResidenceDataList(residences []db.ResidenceInfoModel) []ResidenceData {
+func (conv *converter) residenceDataList(residences []db.ResidenceInfoModel) []ResidenceData {
 	residenceDataList := make([]ResidenceData, len(residences))
 	for i, residence := range residences {
-		residenceDataList[i] = ToResidenceData(&residence)
+		residenceDataList[i] = conv.residenceData(&residence)
 	}
 	return residenceDataList
 }
 
-func ToCrimeData(crime *db.CrimeEntryModel) CrimeData {
+func (conv *converter) crimeData(crime *db.CrimeEntryModel) CrimeData {
 	return CrimeData{
-		FamilyMember: ToFamilyMember(crime.FamilyMember()),
-		Year:         crime.Year,
-		Crime:        crime.Crime,
-		City:         crime.City,
-		State:        crime.State,
+		FamilyMember: conv.familyMember(crime.FamilyMember()),
+		Year:         conv.decrypt(crime.Year),
+		Crime:        conv.decrypt(crime.Crime),
+		City:         conv.decrypt(crime.City),
+		State:        conv.decrypt(crime.State),
 	}
 }
 
-func ToCrimeDataList(crimes []db.CrimeEntryModel) []CrimeData {
+func (conv *converter) crimeDataList(crimes []db.CrimeEntryModel) []CrimeData {
 	crimeDataList := make([]CrimeData, len(crimes))
 	for i, crime := range crimes {
-		crimeDataList[i] = ToCrimeData(&crime)
+		crimeDataList[i] = conv.crimeData(&crime)
 	}
 	return crimeDataList
 }
 
-func ToIncomeAndAssetData(entry *db.IncomeAssetEntryModel) IncomeAndAssetData {
+func (conv *converter) incomeAndAssetData(entry *db.IncomeAssetEntryModel) IncomeAndAssetData {
 	return IncomeAndAssetData{
-		FamilyMember:        ToFamilyMember(entry.FamilyMember()),
+		FamilyMember:        conv.familyMember(entry.FamilyMember()),
 		Type:                entry.Type,
 		Source:              entry.Source,
-		Amount:              entry.Amount,
+		Amount:              conv.decrypt(entry.Amount),
 		FrequencyOrLocation: entry.FrequencyOrLocation,
-		MonthlyOrValue:      entry.MonthlyOrValue,
+		MonthlyOrValue:      conv.decrypt(entry.MonthlyOrValue),
 	}
 }
 
-func ToIncomeAndAssetDataList(entries []db.IncomeAssetEntryModel) []IncomeAndAssetData {
+func (conv *converter) incomeAndAssetDataList(entries []db.IncomeAssetEntryModel) []IncomeAndAssetData {
 	incomeAndAssetDataList := make([]IncomeAndAssetData, len(entries))
 	for i, entry := range entries {
-		incomeAndAssetDataList[i] = ToIncomeAndAssetData(&entry)
+		incomeAndAssetDataList[i] = conv.incomeAndAssetData(&entry)
 	}
 	return incomeAndAssetDataList
 }
+
+// ApplicationWith fetches every relation needed to build ApplicationData.
+var ApplicationWith = []db.ApplicationDataRelationWith{
+	db.ApplicationData.AbsentFamilyMembers.Fetch(),
+	db.ApplicationData.HudRecipients.Fetch(),
+	db.ApplicationData.AccessibilityMembers.Fetch(),
+	db.ApplicationData.MembersNeedingHelp.Fetch(),
+	db.ApplicationData.CurrentResidence.Fetch().With(
+		db.ResidenceInfo.NonResidingMembers.Fetch(),
+	),
+	db.ApplicationData.PreviousResidences.Fetch().With(
+		db.ResidenceInfo.NonResidingMembers.Fetch(),
+	),
+	db.ApplicationData.LifetimeOffenders.Fetch(),
+	db.ApplicationData.ViolentOffenders.Fetch(),
+	db.ApplicationData.MethOffenders.Fetch(),
+	db.ApplicationData.DrugOffenders.Fetch(),
+	db.ApplicationData.OtherCrimes.Fetch().With(
+		db.CrimeEntry.FamilyMember.Fetch(),
+	),
+	db.ApplicationData.IncomeAssetEntries.Fetch().With(
+		db.IncomeAssetEntry.FamilyMember.Fetch(),
+	),
+}
+
+// HousingApplicationWith fetches the relations of a housing application
+// needed to build ApplicationData.
+var HousingApplicationWith = []db.HousingApplicationRelationWith{
+	db.HousingApplication.HousingPreferenceRankings.Fetch(),
+}
+
+// ToHousingPreferences reads the preferences of a housing application
+// fetched with HousingApplicationWith. They're empty if it's nil.
+func ToHousingPreferences(housingApplication *db.HousingApplicationModel) HousingPreferences {
+	housingPreferences := HousingPreferences{Rankings: map[string]string{}}
+	if housingApplication != nil {
+		for _, ranking := range housingApplication.HousingPreferenceRankings() {
+			housingPreferences.Rankings[ranking.Preference] = ranking.Rank
+		}
+		housingPreferences.DesiredMoveInDate = housingApplication.DesiredMoveInDate
+	}
+	return housingPreferences
+}
+
+// ToApplicationData converts application data fetched with ApplicationWith,
+// along with the owner's personal info, into the json compat structs. The
+// housing preferences come from the housing application, fetched with
+// HousingApplicationWith, and are left empty if it's nil. Fails if any
+// sensitive field can't be decrypted.
+func ToApplicationData(applicationData *db.ApplicationDataModel, housingApplication *db.HousingApplicationModel, personalInfo *db.PersonalInfoModel, email string) (ApplicationData, error) {
+	var conv converter
+	data := conv.applicationData(applicationData, housingApplication, personalInfo, email)
+	return data, conv.err
+}
+
+// ToApplicationOutline converts application data like ToApplicationData,
+// without decrypting anything, for working out how complete it is. Encrypted
+// values are only told apart from empty ones, so it can't fail. Outlines
+// must never be saved.
+func ToApplicationOutline(applicationData *db.ApplicationDataModel, housingApplication *db.HousingApplicationModel, personalInfo *db.PersonalInfoModel, email string) ApplicationData {
+	conv := converter{outline: true}
+	return conv.applicationData(applicationData, housingApplication, personalInfo, email)
+}
+
+func (conv *converter) applicationData(applicationData *db.ApplicationDataModel, housingApplication *db.HousingApplicationModel, personalInfo *db.PersonalInfoModel, email string) ApplicationData {
+	housingPreferences := ToHousingPreferences(housingApplication)
+
+	currentResidence, hasCurrentResidence := applicationData.CurrentResidence()
+	var currentResidenceData *ResidenceData = nil
+	if hasCurrentResidence {
+		data := conv.residenceData(currentResidence)
+		currentResidenceData = &data
+	}
+
+	// Parse it into horrific json compat structs
+	data := ApplicationData{
+		PersonalInfo: PersonalInfo{
+			FirstName:     personalInfo.FirstName,
+			LastName:      personalInfo.LastName,
+			Email:         email,
+			Dob:           personalInfo.Dob.Format("2006-01-02"),
+			Phone:         util.WrapDefault(personalInfo.PhoneNumber, ""),
+			SSN:           conv.decrypt(applicationData.Ssn),
+			Address:       applicationData.Address,
+			Gender:        applicationData.Gender,
+			IsStudent:     applicationData.IsStudent,
+			IsVeteran:     applicationData.IsVeteran,
+			HasDisability: applicationData.HasDisability,
+		},
+		HousingPreferences: housingPreferences,
+		Household: HouseholdData{
+			HasPet:                     applicationData.HasPets,
+			PetDescription:             applicationData.PetDescription,
+			IsSmoker:                   applicationData.IsSmoker,
+			MoreThanOneResidence:       applicationData.MoreThanOneResidence,
+			AbsentMembersExplanation:   applicationData.AbsentMembersExplanation,
+			AbsentMembers:              conv.familyMembers(applicationData.AbsentFamilyMembers()),
+			CompositionChanges:         applicationData.CompositionChanges,
+			CompositionExplanation:     applicationData.CompositionChangeExplanation,
+			Custody:                    applicationData.Custody,
+			CustodyExplanation:         applicationData.CustodyExplanation,
+			ElderlyEligibility:         applicationData.ElderlyEligibility,
+			DisabledEligibility:        applicationData.DisabledEligibility,
+			ReceivedHudJan2010:         applicationData.ReceivedHudJan2010,
+			HudRecipients:              conv.familyMembers(applicationData.HudRecipients()),
+			HudPropertyName:            applicationData.HudPropertyName,
+			NeedsAccessibility:         applicationData.NeedsAccessibility,
+			AccessibilityMembers:       conv.familyMembers(applicationData.AccessibilityMembers()),
+			MobilityAccessibility:      applicationData.MobilityAccessible,
+			VisionAccessibility:        applicationData.VisionAccessible,
+			HearingAccessibility:       applicationData.HearingAccessible,
+			NeedsSpecialAccommodations: applicationData.NeedsSpecialAccommodations,
+			MembersNeedingHelp:         conv.familyMembers(applicationData.MembersNeedingHelp()),
+			AccommodationDescription:   applicationData.AccommodationDescription,
+		},
+		History: HistoryData{
+			CurrentResidence:      currentResidenceData,
+			PreviousResidences:    conv.residenceDataList(applicationData.PreviousResidences()),
+			AssistanceTerminated:  applicationData.AssistanceTerminated,
+			AssistanceExplanation: applicationData.AssistanceExplanation,
+			Evicted:               applicationData.Evicted,
+			EvicitionExplanation:  applicationData.EvictionExplanation,
+			OwesMoney:             applicationData.OwesMoney,
+			DebtExplanation:       applicationData.DebtExplanation,
+			MakingPayments:        applicationData.MakingPayments,
+			BedBugs:               applicationData.BedBugs,
+			IsLifetimeSexOffender: applicationData.IsLifetimeSexOffender,
+			LifetimeOffenders:     conv.familyMembers(applicationData.LifetimeOffenders()),
+			IsViolentOffender:     applicationData.IsViolentOffender,
+			ViolentOffenders:      conv.familyMembers(applicationData.ViolentOffenders()),
+			IsMethConviction:      applicationData.IsMethConviction,
+			MethOffenders:         conv.familyMembers(applicationData.MethOffenders()),
+			HasDrugCharges:        applicationData.HasDrugCharges,
+			DrugOffenders:         conv.familyMembers(applicationData.DrugOffenders()),
+			OtherCrimes:           conv.crimeDataList(applicationData.OtherCrimes()),
+		},
+		Income: IncomeAndAssetsData{
+			IncomeAssetEntires:          conv.incomeAndAssetDataList(applicationData.IncomeAssetEntries()),
+			ReceivesGovAssistance:       applicationData.ReceivesGovAssistance,
+			AssistanceProgramName:       applicationData.AssistanceProgramName,
+			ReceivesFromCurrentProperty: applicationData.ReceivesFromCurrentProperty,
+		},
+		UploadedFiles: []FileUploadData{},
+	}
+	return data
+}
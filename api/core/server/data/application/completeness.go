@@ -0,0 +1,53 @@
+package application
+
+// How much of each section of an application has been filled out.
+
+// Sections of the application, in the order they appear in the app.
+const (
+	SectionPersonalInfo       = "personal_info"
+	SectionHousingPreferences = "housing_preferences"
+	SectionHousehold          = "household"
+	SectionHistory            = "history"
+	SectionIncome             = "income"
+)
+
+var Sections = []string{
+	SectionPersonalInfo,
+	SectionHousingPreferences,
+	SectionHousehold,
+	SectionHistory,
+	SectionIncome,
+}
+
+// Completeness is the percent of the required fields filled in for each
+// section.
+type Completeness map[string]int
+
+// tally counts the required fields of a section that are filled in.
+type tally struct {
+	filled   int
+	required int
+}
+
+// percent is how much of the section is done. Sections with nothing
+// required are done.
+func (t *tally) percent() int {
+	if t.required == 0 {
+		return 100
+	}
+	return t.filled * 100 / t.required
+}
+
+// GetCompleteness works out how complete each section of an application is.
+func GetCompleteness(data *ApplicationData) Completeness {
+	return Validate(data).Completeness
+}
+
+// Overall is the average completeness of all the sections.
+func (completeness Completeness) Overall() int {
+	total := 0
+	for _, section := range Sections {
+		total += completeness[section]
+	}
+	return total / len(Sections)
+}
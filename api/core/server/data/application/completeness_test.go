@@ -0,0 +1,82 @@
+package application
+
+import (
+	"api/db"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompletenessEmpty(t *testing.T) {
+	completeness := GetCompleteness(&ApplicationData{})
+
+	assert.Equal(t, 0, completeness[SectionPersonalInfo], "Empty personal info should be 0%")
+	assert.Equal(t, 0, completeness[SectionHousingPreferences], "Empty preferences should be 0%")
+	assert.Equal(t, 100, completeness[SectionHousehold], "Household has nothing required until a question is answered yes")
+	assert.Equal(t, 0, completeness[SectionHistory], "History needs a current residence")
+	assert.Equal(t, 100, completeness[SectionIncome], "Income has nothing required until a question is answered yes")
+}
+
+func TestCompletenessConditional(t *testing.T) {
+	data := ApplicationData{
+		Household: HouseholdData{
+			HasPet:   true,
+			IsSmoker: true,
+			Custody:  true,
+
+			CustodyExplanation: "Joint custody",
+		},
+		History: HistoryData{
+			CurrentResidence: &ResidenceData{
+				Address: "123 Main St",
+				City:    "Boise",
+				State:   "ID",
+				ZipCode: "83702",
+				DateIn:  "2020-01-01",
+			},
+			Evicted: true,
+		},
+	}
+	completeness := GetCompleteness(&data)
+
+	assert.Equal(t, 50, completeness[SectionHousehold], "Pet description should be missing")
+	assert.Equal(t, 85, completeness[SectionHistory], "Eviction explanation should be missing")
+}
+
+func TestCompletenessOverall(t *testing.T) {
+	completeness := Completeness{
+		SectionPersonalInfo:       100,
+		SectionHousingPreferences: 50,
+		SectionHousehold:          100,
+		SectionHistory:            0,
+		SectionIncome:             100,
+	}
+	assert.Equal(t, 70, completeness.Overall(), "Overall should be the average of the sections")
+}
+
+func TestCompletenessOutline(t *testing.T) {
+	// Values that can't be decrypted still count as filled in
+	applicationData := db.ApplicationDataModel{
+		InnerApplicationData: db.InnerApplicationData{
+			Ssn:     "enc:v1:unreadable",
+			Address: "123 Main St",
+		},
+	}
+	personalInfo := db.PersonalInfoModel{
+		InnerPersonalInfo: db.InnerPersonalInfo{
+			FirstName: "Jane",
+			LastName:  "Doe",
+		},
+	}
+
+	_, convertErr := ToApplicationData(&applicationData, nil, &personalInfo, "jane@example.com")
+	assert.Error(t, convertErr, "Unreadable SSN should fail to convert")
+
+	outline := ToApplicationOutline(&applicationData, nil, &personalInfo, "jane@example.com")
+	assert.NotEmpty(t, outline.PersonalInfo.SSN, "Encrypted SSN should be filled in")
+	assert.NotEqual(t, applicationData.Ssn, outline.PersonalInfo.SSN, "Outlines should not hold stored values")
+
+	applicationData.InnerApplicationData.Ssn = ""
+	outline = ToApplicationOutline(&applicationData, nil, &personalInfo, "jane@example.com")
+	assert.Empty(t, outline.PersonalInfo.SSN, "Missing SSN should stay missing")
+}
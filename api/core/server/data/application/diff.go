@@ -0,0 +1,92 @@
+package application
+
+// Field-level diffs between two versions of an application, so it's easy to
+// see what changed between saves.
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// FieldChange is one field that differs between two versions. Field is the
+// json path, like "household.absent_members[0].first_name". Before or after
+// is nil if the field doesn't exist in that version.
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// flatten adds every leaf value in a decoded json value to fields, keyed by
+// its path.
+func flatten(path string, value interface{}, fields map[string]interface{}) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			flatten(childPath, child, fields)
+		}
+	case []interface{}:
+		for i, child := range value {
+			flatten(fmt.Sprintf("%s[%d]", path, i), child, fields)
+		}
+	default:
+		fields[path] = value
+	}
+}
+
+func flattenApplication(data *ApplicationData) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return nil, err
+	}
+
+	fields := map[string]interface{}{}
+	flatten("", decoded, fields)
+	return fields, nil
+}
+
+// Diff lists the fields that changed from before to after, sorted by field.
+func Diff(before *ApplicationData, after *ApplicationData) ([]FieldChange, error) {
+	beforeFields, err := flattenApplication(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := flattenApplication(after)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []string{}
+	for field := range beforeFields {
+		fields = append(fields, field)
+	}
+	for field := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	// Only scalars are left after flattening, so == is enough. A missing
+	// field and a null one are the same
+	changes := []FieldChange{}
+	for _, field := range fields {
+		if beforeFields[field] != afterFields[field] {
+			changes = append(changes, FieldChange{
+				Field:  field,
+				Before: beforeFields[field],
+				After:  afterFields[field],
+			})
+		}
+	}
+	return changes, nil
+}
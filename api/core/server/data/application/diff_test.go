@@ -0,0 +1,46 @@
+package application
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffSameApplication(t *testing.T) {
+	data := ApplicationData{PersonalInfo: PersonalInfo{FirstName: "Jane"}}
+	changes, err := Diff(&data, &data)
+	assert.NoError(t, err, "Diff should not fail")
+	assert.Empty(t, changes, "Nothing should have changed")
+}
+
+func TestDiffChangedFields(t *testing.T) {
+	before := ApplicationData{
+		PersonalInfo: PersonalInfo{FirstName: "Jane", IsStudent: true},
+		Income: IncomeAndAssetsData{IncomeAssetEntires: []IncomeAndAssetData{
+			{Type: "Wages", Amount: "1000"},
+		}},
+	}
+	after := ApplicationData{
+		PersonalInfo: PersonalInfo{FirstName: "Jane", IsStudent: false},
+		Income: IncomeAndAssetsData{IncomeAssetEntires: []IncomeAndAssetData{
+			{Type: "Wages", Amount: "1200"},
+		}},
+	}
+
+	changes, err := Diff(&before, &after)
+	assert.NoError(t, err, "Diff should not fail")
+	assert.Equal(t, []FieldChange{
+		{Field: "income.income_asset_entries[0].amount", Before: "1000", After: "1200"},
+		{Field: "personal_info.is_student", Before: true, After: false},
+	}, changes, "Only the changed fields should be listed, sorted")
+}
+
+func TestDiffAddedEntry(t *testing.T) {
+	before := ApplicationData{}
+	after := ApplicationData{Household: HouseholdData{AbsentMembers: []FamilyMember{{ID: 4, FirstName: "Sam"}}}}
+
+	changes, err := Diff(&before, &after)
+	assert.NoError(t, err, "Diff should not fail")
+	assert.Contains(t, changes, FieldChange{Field: "household.absent_members[0].first_name", Before: nil, After: "Sam"}, "New entries should show up as added fields")
+	assert.Contains(t, changes, FieldChange{Field: "household.absent_members[0].id", Before: nil, After: float64(4)}, "Numbers come back as json numbers")
+}
@@ -0,0 +1,294 @@
+package application
+
+// Validation and normalization for the free-form fields of an application,
+// so SSNs, phone numbers, zip codes, states and dates are always stored the
+// same way.
+
+import (
+	"api/db"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Dates are stored and sent to the app in this layout.
+const DateLayout = "2006-01-02"
+
+// Other layouts dates are accepted in
+var dateLayouts = []string{
+	DateLayout,
+	"01/02/2006",
+	"1/2/2006",
+	time.RFC3339,
+}
+
+var (
+	ErrInvalidSSN     = errors.New("Invalid social security number")
+	ErrInvalidPhone   = errors.New("Invalid phone number")
+	ErrInvalidZipCode = errors.New("Invalid zip code")
+	ErrInvalidState   = errors.New("Invalid state or territory")
+	ErrInvalidDate    = errors.New("Invalid date")
+	ErrFutureDate     = errors.New("Date can't be in the future")
+)
+
+var nonDigits = regexp.MustCompile(`\D`)
+var e164Regex = regexp.MustCompile(`^\+[1-9]\d{7,14}$`)
+var zipCodeRegex = regexp.MustCompile(`^(\d{5})-?(\d{4})?$`)
+
+// SSNs that have been published and were never valid
+var knownInvalidSSNs = map[string]bool{
+	"078051120": true,
+	"219099999": true,
+}
+
+// Postal abbreviations for every state and territory in the USStateTerritory
+// enum.
+var stateAbbreviations = map[db.USStateTerritory]string{
+	db.USStateTerritory("ALABAMA"):                  "AL",
+	db.USStateTerritory("ALASKA"):                   "AK",
+	db.USStateTerritory("AMERICAN_SAMOA"):           "AS",
+	db.USStateTerritory("ARIZONA"):                  "AZ",
+	db.USStateTerritory("ARKANSAS"):                 "AR",
+	db.USStateTerritory("CALIFORNIA"):               "CA",
+	db.USStateTerritory("COLORADO"):                 "CO",
+	db.USStateTerritory("CONNECTICUT"):              "CT",
+	db.USStateTerritory("DELAWARE"):                 "DE",
+	db.USStateTerritory("DISTRICT_OF_COLUMBIA"):     "DC",
+	db.USStateTerritory("FLORIDA"):                  "FL",
+	db.USStateTerritory("GEORGIA"):                  "GA",
+	db.USStateTerritory("GUAM"):                     "GU",
+	db.USStateTerritory("HAWAII"):                   "HI",
+	db.USStateTerritory("IDAHO"):                    "ID",
+	db.USStateTerritory("ILLINOIS"):                 "IL",
+	db.USStateTerritory("INDIANA"):                  "IN",
+	db.USStateTerritory("IOWA"):                     "IA",
+	db.USStateTerritory("KANSAS"):                   "KS",
+	db.USStateTerritory("KENTUCKY"):                 "KY",
+	db.USStateTerritory("LOUISIANA"):                "LA",
+	db.USStateTerritory("MAINE"):                    "ME",
+	db.USStateTerritory("MARYLAND"):                 "MD",
+	db.USStateTerritory("MASSACHUSETTS"):            "MA",
+	db.USStateTerritory("MICHIGAN"):                 "MI",
+	db.USStateTerritory("MINNESOTA"):                "MN",
+	db.USStateTerritory("MINOR_OUTLYING_ISLANDS"):   "UM",
+	db.USStateTerritory("MISSISSIPPI"):              "MS",
+	db.USStateTerritory("MISSOURI"):                 "MO",
+	db.USStateTerritory("MONTANA"):                  "MT",
+	db.USStateTerritory("NEBRASKA"):                 "NE",
+	db.USStateTerritory("NEVADA"):                   "NV",
+	db.USStateTerritory("NEW_HAMPSHIRE"):            "NH",
+	db.USStateTerritory("NEW_JERSEY"):               "NJ",
+	db.USStateTerritory("NEW_MEXICO"):               "NM",
+	db.USStateTerritory("NEW_YORK"):                 "NY",
+	db.USStateTerritory("NORTH_CAROLINA"):           "NC",
+	db.USStateTerritory("NORTH_DAKOTA"):             "ND",
+	db.USStateTerritory("NORTHERN_MARIANA_ISLANDS"): "MP",
+	db.USStateTerritory("OHIO"):                     "OH",
+	db.USStateTerritory("OKLAHOMA"):                 "OK",
+	db.USStateTerritory("OREGON"):                   "OR",
+	db.USStateTerritory("PENNSYLVANIA"):             "PA",
+	db.USStateTerritory("PUERTO_RICO"):              "PR",
+	db.USStateTerritory("RHODE_ISLAND"):             "RI",
+	db.USStateTerritory("SOUTH_CAROLINA"):           "SC",
+	db.USStateTerritory("SOUTH_DAKOTA"):             "SD",
+	db.USStateTerritory("TENNESSEE"):                "TN",
+	db.USStateTerritory("TEXAS"):                    "TX",
+	db.USStateTerritory("US_VIRGIN_ISLANDS"):        "VI",
+	db.USStateTerritory("UTAH"):                     "UT",
+	db.USStateTerritory("VERMONT"):                  "VT",
+	db.USStateTerritory("VIRGINIA"):                 "VA",
+	db.USStateTerritory("WASHINGTON"):               "WA",
+	db.USStateTerritory("WEST_VIRGINIA"):            "WV",
+	db.USStateTerritory("WISCONSIN"):                "WI",
+	db.USStateTerritory("WYOMING"):                  "WY",
+}
+
+// NormalizeSSN checks a social security number and returns its 9 digits.
+// Numbers the SSA never issues, like area 666 or a group of 00, are invalid.
+func NormalizeSSN(ssn string) (string, error) {
+	digits := nonDigits.ReplaceAllString(ssn, "")
+	if len(digits) != 9 || knownInvalidSSNs[digits] {
+		return "", ErrInvalidSSN
+	}
+
+	area, group, serial := digits[0:3], digits[3:5], digits[5:9]
+	if area == "000" || area == "666" || area[0] == '9' || group == "00" || serial == "0000" {
+		return "", ErrInvalidSSN
+	}
+	if strings.Count(digits, digits[0:1]) == len(digits) {
+		return "", ErrInvalidSSN
+	}
+	return digits, nil
+}
+
+// NormalizePhone returns a phone number in E.164 format. Numbers without a
+// country code are assumed to be in the US.
+func NormalizePhone(phone string) (string, error) {
+	phone = strings.TrimSpace(phone)
+	digits := nonDigits.ReplaceAllString(phone, "")
+
+	var normalized string
+	switch {
+	case strings.HasPrefix(phone, "+"):
+		normalized = "+" + digits
+	case len(digits) == 10:
+		normalized = "+1" + digits
+	case len(digits) == 11 && digits[0] == '1':
+		normalized = "+" + digits
+	default:
+		return "", ErrInvalidPhone
+	}
+
+	if !e164Regex.MatchString(normalized) {
+		return "", ErrInvalidPhone
+	}
+	return normalized, nil
+}
+
+// NormalizeZipCode checks a ZIP or ZIP+4 code, formatting ZIP+4 with a dash.
+func NormalizeZipCode(zipCode string) (string, error) {
+	matches := zipCodeRegex.FindStringSubmatch(strings.TrimSpace(zipCode))
+	if matches == nil {
+		return "", ErrInvalidZipCode
+	}
+	if matches[2] == "" {
+		return matches[1], nil
+	}
+	return fmt.Sprintf("%s-%s", matches[1], matches[2]), nil
+}
+
+// ParseState finds the state or territory by its name or postal
+// abbreviation.
+func ParseState(state string) (db.USStateTerritory, error) {
+	state = strings.ToUpper(strings.TrimSpace(state))
+	name := db.USStateTerritory(strings.ReplaceAll(state, " ", "_"))
+	if _, ok := stateAbbreviations[name]; ok {
+		return name, nil
+	}
+	for territory, abbreviation := range stateAbbreviations {
+		if state == abbreviation {
+			return territory, nil
+		}
+	}
+	return "", ErrInvalidState
+}
+
+// NormalizeState returns the postal abbreviation for a state or territory.
+func NormalizeState(state string) (string, error) {
+	territory, err := ParseState(state)
+	if err != nil {
+		return "", err
+	}
+	return stateAbbreviations[territory], nil
+}
+
+// ParseDate reads a date in any of the accepted layouts.
+func ParseDate(date string) (time.Time, error) {
+	date = strings.TrimSpace(date)
+	for _, layout := range dateLayouts {
+		if parsed, err := time.Parse(layout, date); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, ErrInvalidDate
+}
+
+// NormalizeDate checks a date is real and returns it in DateLayout.
+func NormalizeDate(date string) (string, error) {
+	parsed, err := ParseDate(date)
+	if err != nil {
+		return "", err
+	}
+	return parsed.Format(DateLayout), nil
+}
+
+// NormalizePastDate is NormalizeDate for dates that already happened, like
+// birthdays and move in dates.
+func NormalizePastDate(date string) (string, error) {
+	parsed, err := ParseDate(date)
+	if err != nil {
+		return "", err
+	}
+	if parsed.After(time.Now()) {
+		return "", ErrFutureDate
+	}
+	return parsed.Format(DateLayout), nil
+}
+
+// normalizer checks each filled in field, collecting an issue for every
+// field that is invalid. Valid fields are replaced with their normalized
+// value when apply is set.
+type normalizer struct {
+	issues []FieldIssue
+	apply  bool
+}
+
+func (n *normalizer) field(name string, value *string, normalize func(string) (string, error)) {
+	if strings.TrimSpace(*value) == "" {
+		return
+	}
+	normalized, err := normalize(*value)
+	if err != nil {
+		n.issues = append(n.issues, FieldIssue{Field: name, Severity: SeverityError, Message: err.Error()})
+		return
+	}
+	if n.apply {
+		*value = normalized
+	}
+}
+
+func (n *normalizer) residence(name string, residence *ResidenceData) {
+	n.field(name+".state", &residence.State, NormalizeState)
+	n.field(name+".zip_code", &residence.ZipCode, NormalizeZipCode)
+	n.field(name+".date_in", &residence.DateIn, NormalizePastDate)
+	n.field(name+".date_out", &residence.DateOut, NormalizeDate)
+	n.field(name+".landlord_phone", &residence.LandlordPhone, NormalizePhone)
+}
+
+func (n *normalizer) application(data *ApplicationData) {
+	n.field("personal_info.ssn", &data.PersonalInfo.SSN, NormalizeSSN)
+	n.field("personal_info.phone", &data.PersonalInfo.Phone, NormalizePhone)
+	n.field("personal_info.dob", &data.PersonalInfo.Dob, NormalizePastDate)
+	n.field("housing_preferences.desired_move_in_date", &data.HousingPreferences.DesiredMoveInDate, NormalizeDate)
+
+	if data.History.CurrentResidence != nil {
+		n.residence("history.current_residence", data.History.CurrentResidence)
+	}
+	for i := range data.History.PreviousResidences {
+		n.residence(fmt.Sprintf("history.previous_residences[%d]", i), &data.History.PreviousResidences[i])
+	}
+	for i := range data.History.OtherCrimes {
+		n.field(fmt.Sprintf("history.other_crimes[%d].state", i), &data.History.OtherCrimes[i].State, NormalizeState)
+	}
+}
+
+// Normalize rewrites the fields of an application into the format they're
+// stored in, and returns an issue for every field that is invalid.
+func Normalize(data *ApplicationData) []FieldIssue {
+	n := normalizer{issues: []FieldIssue{}, apply: true}
+	n.application(data)
+	return n.issues
+}
+
+// checkFormats returns an issue for every invalid field without changing
+// anything.
+func checkFormats(data *ApplicationData) []FieldIssue {
+	n := normalizer{issues: []FieldIssue{}, apply: false}
+	n.application(data)
+	return n.issues
+}
+
+// NormalizeFamilyMember rewrites the fields of a family member into the
+// format they're stored in, and returns an issue for every field that is
+// invalid.
+func NormalizeFamilyMember(member *FamilyMember) []FieldIssue {
+	n := normalizer{issues: []FieldIssue{}, apply: true}
+	n.field("ssn", &member.SSN, NormalizeSSN)
+	n.field("birthday", &member.Birthday, NormalizePastDate)
+	if strings.TrimSpace(member.Birthday) == "" {
+		n.issues = append(n.issues, FieldIssue{Field: "birthday", Severity: SeverityError, Message: "Birthday is required"})
+	}
+	return n.issues
+}
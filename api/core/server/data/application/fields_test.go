@@ -0,0 +1,83 @@
+package application
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeSSN(t *testing.T) {
+	ssn, err := NormalizeSSN("123-45-6789")
+	assert.NoError(t, err, "Valid SSN should be accepted")
+	assert.Equal(t, "123456789", ssn, "SSN should be stored as digits")
+
+	for _, invalid := range []string{"12345678", "000-12-3456", "666-12-3456", "912-34-5678", "123-00-4567", "123-45-0000", "078-05-1120", "111-11-1111"} {
+		_, err := NormalizeSSN(invalid)
+		assert.ErrorIs(t, err, ErrInvalidSSN, "SSN %s should be invalid", invalid)
+	}
+}
+
+func TestNormalizePhone(t *testing.T) {
+	for input, expected := range map[string]string{
+		"(208) 555-1234":   "+12085551234",
+		"1-208-555-1234":   "+12085551234",
+		"+44 20 7946 0958": "+442079460958",
+	} {
+		phone, err := NormalizePhone(input)
+		assert.NoError(t, err, "Phone %s should be valid", input)
+		assert.Equal(t, expected, phone, "Bad phone format")
+	}
+
+	_, err := NormalizePhone("555-1234")
+	assert.ErrorIs(t, err, ErrInvalidPhone, "Phone without an area code should be invalid")
+}
+
+func TestNormalizeZipCode(t *testing.T) {
+	zipCode, err := NormalizeZipCode("837021234")
+	assert.NoError(t, err, "ZIP+4 should be valid")
+	assert.Equal(t, "83702-1234", zipCode, "ZIP+4 should have a dash")
+
+	_, err = NormalizeZipCode("8370")
+	assert.ErrorIs(t, err, ErrInvalidZipCode, "Short zip code should be invalid")
+}
+
+func TestNormalizeState(t *testing.T) {
+	for _, input := range []string{"ID", "id", "Idaho", "IDAHO"} {
+		state, err := NormalizeState(input)
+		assert.NoError(t, err, "State %s should be valid", input)
+		assert.Equal(t, "ID", state, "State should be stored as its abbreviation")
+	}
+
+	state, err := NormalizeState("Puerto Rico")
+	assert.NoError(t, err, "Territories should be valid")
+	assert.Equal(t, "PR", state, "Bad territory abbreviation")
+
+	_, err = NormalizeState("Ontario")
+	assert.ErrorIs(t, err, ErrInvalidState, "Unknown state should be invalid")
+}
+
+func TestNormalizeDate(t *testing.T) {
+	date, err := NormalizeDate("04/01/2025")
+	assert.NoError(t, err, "Slash dates should be valid")
+	assert.Equal(t, "2025-04-01", date, "Bad date format")
+
+	_, err = NormalizeDate("2025-02-30")
+	assert.ErrorIs(t, err, ErrInvalidDate, "Dates that don't exist should be invalid")
+
+	_, err = NormalizePastDate("2999-01-01")
+	assert.ErrorIs(t, err, ErrFutureDate, "Future birthdays should be invalid")
+}
+
+func TestNormalizeApplication(t *testing.T) {
+	data := ApplicationData{
+		PersonalInfo: PersonalInfo{SSN: "123 45 6789"},
+		History: HistoryData{
+			CurrentResidence: &ResidenceData{State: "idaho", ZipCode: "83702", DateIn: "13/01/2020"},
+		},
+	}
+	issues := Normalize(&data)
+
+	assert.Equal(t, "123456789", data.PersonalInfo.SSN, "SSN should be normalized")
+	assert.Equal(t, "ID", data.History.CurrentResidence.State, "State should be normalized")
+	assert.Equal(t, []string{"history.current_residence.date_in"}, fields(issues), "Only the bad date should have an issue")
+}
@@ -0,0 +1,286 @@
+package application
+
+// Works out a household's yearly and monthly income from the income and
+// asset entries on the application. Amounts are strings typed in by clients,
+// so they're parsed leniently and entries that can't be worked out are
+// reported instead of guessed at.
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Categories an income or asset entry is counted under.
+const (
+	IncomeEarned  = "earned"
+	IncomeBenefit = "benefit"
+	IncomeAsset   = "asset"
+)
+
+// Hours in a year of full time work, for hourly wages.
+var hoursPerYear = decimal.NewFromInt(2080)
+
+var twelve = decimal.NewFromInt(12)
+
+// Times a year each frequency is paid, by normalizeFrequency.
+var paymentsPerYear = map[string]decimal.Decimal{
+	"hourly":      hoursPerYear,
+	"weekly":      decimal.NewFromInt(52),
+	"biweekly":    decimal.NewFromInt(26),
+	"semimonthly": decimal.NewFromInt(24),
+	"monthly":     twelve,
+	"annual":      decimal.NewFromInt(1),
+	"annually":    decimal.NewFromInt(1),
+	"yearly":      decimal.NewFromInt(1),
+}
+
+// Words in the source of an income entry that mean it's a benefit rather
+// than pay for work. They're matched as whole words.
+var benefitKeywords = []string{
+	"social security",
+	"ssi",
+	"ssdi",
+	"disability",
+	"unemployment",
+	"pension",
+	"retirement",
+	"annuity",
+	"tanf",
+	"welfare",
+	"public assistance",
+	"snap",
+	"food stamps",
+	"wic",
+	"child support",
+	"alimony",
+	"veteran",
+	"veterans",
+	"va",
+	"workers comp",
+	"workers compensation",
+	"benefit",
+	"benefits",
+}
+
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// ParseAmount reads a dollar amount like "$1,250.50". Blank is zero.
+func ParseAmount(amount string) (decimal.Decimal, error) {
+	amount = strings.TrimSpace(amount)
+	amount = strings.TrimPrefix(amount, "$")
+	amount = strings.ReplaceAll(amount, ",", "")
+	amount = strings.TrimSpace(amount)
+	if amount == "" {
+		return decimal.Zero, nil
+	}
+	parsed, err := decimal.NewFromString(amount)
+	if err != nil || parsed.IsNegative() {
+		return decimal.Zero, ErrInvalidAmount
+	}
+	return parsed, nil
+}
+
+// normalizeFrequency lowercases a frequency and drops anything that isn't a
+// letter, so "Bi-weekly" and "biweekly" are the same.
+func normalizeFrequency(frequency string) string {
+	var normalized strings.Builder
+	for _, r := range strings.ToLower(frequency) {
+		if r >= 'a' && r <= 'z' {
+			normalized.WriteRune(r)
+		}
+	}
+	return normalized.String()
+}
+
+// sourceWords lowercases a source and puts single spaces around its words,
+// so keywords can be matched as whole words.
+func sourceWords(source string) string {
+	source = strings.ReplaceAll(strings.ToLower(source), "'", "")
+	words := strings.FieldsFunc(source, func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < '0' || r > '9')
+	})
+	return " " + strings.Join(words, " ") + " "
+}
+
+// IncomeCategory is whether an entry is an asset, a benefit, or earned
+// income. Anything not recognized as a benefit counts as earned.
+func IncomeCategory(entry IncomeAndAssetData) string {
+	if strings.EqualFold(strings.TrimSpace(entry.Type), "Asset") {
+		return IncomeAsset
+	}
+	source := sourceWords(entry.Source)
+	for _, keyword := range benefitKeywords {
+		if strings.Contains(source, " "+keyword+" ") {
+			return IncomeBenefit
+		}
+	}
+	return IncomeEarned
+}
+
+// IncomeEntry is one entry worked out. Assets have their value in Value and
+// no income.
+type IncomeEntry struct {
+	Index    int             `json:"index"`
+	MemberID int             `json:"member_id"`
+	Category string          `json:"category"`
+	Source   string          `json:"source"`
+	Annual   decimal.Decimal `json:"annual"`
+	Monthly  decimal.Decimal `json:"monthly"`
+	Value    decimal.Decimal `json:"value"`
+}
+
+type IncomeTotals struct {
+	EarnedAnnual    decimal.Decimal `json:"earned_annual"`
+	EarnedMonthly   decimal.Decimal `json:"earned_monthly"`
+	BenefitsAnnual  decimal.Decimal `json:"benefits_annual"`
+	BenefitsMonthly decimal.Decimal `json:"benefits_monthly"`
+	TotalAnnual     decimal.Decimal `json:"total_annual"`
+	TotalMonthly    decimal.Decimal `json:"total_monthly"`
+	AssetValue      decimal.Decimal `json:"asset_value"`
+}
+
+type MemberIncome struct {
+	// 0 is the client themselves
+	MemberID int          `json:"member_id"`
+	Name     string       `json:"name"`
+	Totals   IncomeTotals `json:"totals"`
+}
+
+type IncomeSummary struct {
+	Entries   []IncomeEntry  `json:"entries"`
+	Members   []MemberIncome `json:"members"`
+	Household IncomeTotals   `json:"household"`
+	// Entries left out of the totals because they couldn't be worked out
+	Issues []FieldIssue `json:"issues"`
+}
+
+// annualIncome works out the yearly income of an income entry from its
+// amount and frequency. Without a frequency it knows, the total monthly
+// income the client gave is used instead.
+func annualIncome(entry IncomeAndAssetData, field string) (decimal.Decimal, *FieldIssue) {
+	amount, amountErr := ParseAmount(entry.Amount)
+	if amountErr != nil {
+		return decimal.Zero, &FieldIssue{Field: field + ".amount", Severity: SeverityWarning, Message: "Amount isn't a dollar amount"}
+	}
+	perYear, known := paymentsPerYear[normalizeFrequency(entry.FrequencyOrLocation)]
+	if known && !amount.IsZero() {
+		return amount.Mul(perYear), nil
+	}
+
+	monthly, monthlyErr := ParseAmount(entry.MonthlyOrValue)
+	if monthlyErr != nil {
+		return decimal.Zero, &FieldIssue{Field: field + ".monthly_or_value", Severity: SeverityWarning, Message: "Total monthly income isn't a dollar amount"}
+	}
+	if !monthly.IsZero() {
+		return monthly.Mul(twelve), nil
+	}
+	if known || amount.IsZero() {
+		return decimal.Zero, nil
+	}
+	return decimal.Zero, &FieldIssue{Field: field + ".frequency_or_location", Severity: SeverityWarning, Message: "Give how often it's received or the total monthly income"}
+}
+
+// totalsBuilder adds up yearly income and asset values, leaving monthly
+// figures and rounding until the end so cents aren't lost along the way.
+type totalsBuilder struct {
+	earned   decimal.Decimal
+	benefits decimal.Decimal
+	assets   decimal.Decimal
+}
+
+func (t *totalsBuilder) add(category string, annual decimal.Decimal, value decimal.Decimal) {
+	switch category {
+	case IncomeEarned:
+		t.earned = t.earned.Add(annual)
+	case IncomeBenefit:
+		t.benefits = t.benefits.Add(annual)
+	case IncomeAsset:
+		t.assets = t.assets.Add(value)
+	}
+}
+
+func (t *totalsBuilder) totals() IncomeTotals {
+	total := t.earned.Add(t.benefits)
+	return IncomeTotals{
+		EarnedAnnual:    t.earned.Round(2),
+		EarnedMonthly:   t.earned.Div(twelve).Round(2),
+		BenefitsAnnual:  t.benefits.Round(2),
+		BenefitsMonthly: t.benefits.Div(twelve).Round(2),
+		TotalAnnual:     total.Round(2),
+		TotalMonthly:    total.Div(twelve).Round(2),
+		AssetValue:      t.assets.Round(2),
+	}
+}
+
+// SummarizeIncome works out every income and asset entry, and totals them
+// for each household member and the whole household. Members are listed in
+// the order they first appear.
+func SummarizeIncome(income IncomeAndAssetsData) IncomeSummary {
+	summary := IncomeSummary{
+		Entries: []IncomeEntry{},
+		Members: []MemberIncome{},
+		Issues:  []FieldIssue{},
+	}
+
+	household := totalsBuilder{}
+	members := map[int]*totalsBuilder{}
+	names := map[int]string{}
+	order := []int{}
+
+	for i, data := range income.IncomeAssetEntires {
+		field := fmt.Sprintf("%s.income_asset_entries[%d]", SectionIncome, i)
+		category := IncomeCategory(data)
+		annual := decimal.Zero
+		value := decimal.Zero
+
+		if category == IncomeAsset {
+			parsed, err := ParseAmount(data.Amount)
+			if err != nil {
+				summary.Issues = append(summary.Issues, FieldIssue{Field: field + ".amount", Severity: SeverityWarning, Message: "Value isn't a dollar amount"})
+				continue
+			}
+			value = parsed
+		} else {
+			worked, issue := annualIncome(data, field)
+			if issue != nil {
+				summary.Issues = append(summary.Issues, *issue)
+				continue
+			}
+			annual = worked
+		}
+
+		memberId := data.FamilyMember.ID
+		member, seen := members[memberId]
+		if !seen {
+			member = &totalsBuilder{}
+			members[memberId] = member
+			names[memberId] = strings.TrimSpace(data.FamilyMember.FirstName + " " + data.FamilyMember.LastName)
+			order = append(order, memberId)
+		}
+		member.add(category, annual, value)
+		household.add(category, annual, value)
+
+		summary.Entries = append(summary.Entries, IncomeEntry{
+			Index:    i,
+			MemberID: memberId,
+			Category: category,
+			Source:   data.Source,
+			Annual:   annual.Round(2),
+			Monthly:  annual.Div(twelve).Round(2),
+			Value:    value.Round(2),
+		})
+	}
+
+	for _, id := range order {
+		summary.Members = append(summary.Members, MemberIncome{
+			MemberID: id,
+			Name:     names[id],
+			Totals:   members[id].totals(),
+		})
+	}
+	summary.Household = household.totals()
+	return summary
+}
@@ -0,0 +1,99 @@
+package application
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func dollars(amount string) decimal.Decimal {
+	return decimal.RequireFromString(amount)
+}
+
+func TestParseAmount(t *testing.T) {
+	amount, err := ParseAmount("$1,250.50")
+	assert.NoError(t, err, "Dollar signs and commas should be allowed")
+	assert.True(t, dollars("1250.50").Equal(amount), "Amount should be parsed")
+
+	amount, err = ParseAmount("  ")
+	assert.NoError(t, err, "Blank amounts should be allowed")
+	assert.True(t, amount.IsZero(), "Blank amounts should be zero")
+
+	_, err = ParseAmount("about 300")
+	assert.Equal(t, ErrInvalidAmount, err, "Words should not be an amount")
+	_, err = ParseAmount("-5")
+	assert.Equal(t, ErrInvalidAmount, err, "Negative amounts should not be allowed")
+}
+
+func TestIncomeCategory(t *testing.T) {
+	assert.Equal(t, IncomeAsset, IncomeCategory(IncomeAndAssetData{Type: "Asset", Source: "Savings"}), "Assets should be assets")
+	assert.Equal(t, IncomeBenefit, IncomeCategory(IncomeAndAssetData{Type: "Income", Source: "Social Security"}), "Social security should be a benefit")
+	assert.Equal(t, IncomeBenefit, IncomeCategory(IncomeAndAssetData{Type: "Income", Source: "SSI"}), "SSI should be a benefit")
+	assert.Equal(t, IncomeBenefit, IncomeCategory(IncomeAndAssetData{Type: "Income", Source: "Worker's Comp"}), "Workers comp should be a benefit")
+	assert.Equal(t, IncomeEarned, IncomeCategory(IncomeAndAssetData{Type: "Income", Source: "Professional services"}), "Benefit words inside other words should not count")
+	assert.Equal(t, IncomeEarned, IncomeCategory(IncomeAndAssetData{Type: "Income", Source: "Vacation pay"}), "Benefit words inside other words should not count")
+	assert.Equal(t, IncomeEarned, IncomeCategory(IncomeAndAssetData{Type: "Income", Source: "Grocery store"}), "Wages should be earned")
+}
+
+func TestSummarizeIncomeFrequencies(t *testing.T) {
+	cases := []struct {
+		frequency string
+		amount    string
+		annual    string
+		monthly   string
+	}{
+		{"Hourly", "15", "31200.00", "2600.00"},
+		{"Weekly", "500", "26000.00", "2166.67"},
+		{"Bi-weekly", "1000", "26000.00", "2166.67"},
+		{"Semi-monthly", "1000", "24000.00", "2000.00"},
+		{"Monthly", "$1,500", "18000.00", "1500.00"},
+		{"annual", "12000", "12000.00", "1000.00"},
+	}
+	for _, c := range cases {
+		summary := SummarizeIncome(IncomeAndAssetsData{IncomeAssetEntires: []IncomeAndAssetData{
+			{Type: "Income", Source: "Job", Amount: c.amount, FrequencyOrLocation: c.frequency},
+		}})
+		assert.Empty(t, summary.Issues, "%s income should be worked out", c.frequency)
+		assert.Equal(t, c.annual, summary.Entries[0].Annual.StringFixed(2), "%s annual income should be right", c.frequency)
+		assert.Equal(t, c.monthly, summary.Entries[0].Monthly.StringFixed(2), "%s monthly income should be right", c.frequency)
+	}
+}
+
+func TestSummarizeIncomeFallsBackToMonthly(t *testing.T) {
+	summary := SummarizeIncome(IncomeAndAssetsData{IncomeAssetEntires: []IncomeAndAssetData{
+		{Type: "Income", Source: "Odd jobs", Amount: "200", FrequencyOrLocation: "Other", MonthlyOrValue: "450"},
+		{Type: "Income", Source: "Side gig", Amount: "200", FrequencyOrLocation: "Other"},
+	}})
+
+	assert.Len(t, summary.Entries, 1, "Entries that can't be worked out should be left out")
+	assert.Equal(t, "5400.00", summary.Entries[0].Annual.StringFixed(2), "Total monthly income should be used for other frequencies")
+	assert.Len(t, summary.Issues, 1, "Entries that can't be worked out should be reported")
+	assert.Equal(t, "income.income_asset_entries[1].frequency_or_location", summary.Issues[0].Field, "Issue should name the entry")
+}
+
+func TestSummarizeIncomeTotals(t *testing.T) {
+	client := FamilyMember{ID: 0, FirstName: "Ada", LastName: "Lee"}
+	child := FamilyMember{ID: 4, FirstName: "Sam", LastName: "Lee"}
+	summary := SummarizeIncome(IncomeAndAssetsData{IncomeAssetEntires: []IncomeAndAssetData{
+		{FamilyMember: client, Type: "Income", Source: "Warehouse", Amount: "500", FrequencyOrLocation: "Weekly"},
+		{FamilyMember: child, Type: "Income", Source: "SSI", Amount: "943", FrequencyOrLocation: "Monthly"},
+		{FamilyMember: client, Type: "Asset", Source: "Checking", Amount: "2,000", FrequencyOrLocation: "Credit union"},
+		{FamilyMember: client, Type: "Income", Source: "Bakery", Amount: "100", FrequencyOrLocation: "Weekly"},
+		{FamilyMember: child, Type: "Income", Source: "Tips", Amount: "lots", FrequencyOrLocation: "Weekly"},
+	}})
+
+	household := summary.Household
+	assert.Equal(t, "31200.00", household.EarnedAnnual.StringFixed(2), "Earned income should be totalled")
+	assert.Equal(t, "2600.00", household.EarnedMonthly.StringFixed(2), "Monthly earned income should be totalled from the yearly figure")
+	assert.Equal(t, "11316.00", household.BenefitsAnnual.StringFixed(2), "Benefits should be totalled separately")
+	assert.Equal(t, "42516.00", household.TotalAnnual.StringFixed(2), "Total should be earned income and benefits")
+	assert.Equal(t, "2000.00", household.AssetValue.StringFixed(2), "Assets should not count as income")
+
+	assert.Len(t, summary.Members, 2, "Each member with income should be totalled")
+	assert.Equal(t, 0, summary.Members[0].MemberID, "Members should be in the order they appear")
+	assert.Equal(t, "Ada Lee", summary.Members[0].Name, "Member should be named")
+	assert.Equal(t, "31200.00", summary.Members[0].Totals.TotalAnnual.StringFixed(2), "Client's income should be totalled")
+	assert.Equal(t, "11316.00", summary.Members[1].Totals.BenefitsAnnual.StringFixed(2), "Child's benefits should be totalled")
+	assert.Len(t, summary.Issues, 1, "Invalid amounts should be reported")
+}
@@ -0,0 +1,68 @@
+package application
+
+// Masking SSNs so only the last four digits show, which is enough to tell
+// people apart without exposing the whole number.
+
+import "regexp"
+
+var maskedSSNRegex = regexp.MustCompile(`^\*\*\*(-\*\*-\d{4})?$`)
+
+// MaskSSN hides all but the last four digits of an SSN, like ***-**-1234.
+// Anything too short to have a last four is hidden completely as ***, which
+// still tells it apart from having no SSN.
+func MaskSSN(ssn string) string {
+	if ssn == "" {
+		return ""
+	}
+	digits := nonDigits.ReplaceAllString(ssn, "")
+	if len(digits) < 4 {
+		return "***"
+	}
+	return "***-**-" + digits[len(digits)-4:]
+}
+
+// IsMaskedSSN checks if an SSN was masked by MaskSSN, so saving data that
+// came back masked doesn't overwrite the real number.
+func IsMaskedSSN(ssn string) bool {
+	return maskedSSNRegex.MatchString(ssn)
+}
+
+// MaskSSN masks the family member's SSN.
+func (member *FamilyMember) MaskSSN() {
+	member.SSN = MaskSSN(member.SSN)
+}
+
+func maskMembers(members []FamilyMember) {
+	for i := range members {
+		members[i].MaskSSN()
+	}
+}
+
+// MaskSSNs masks every SSN in the application, including the ones of
+// household members.
+func (data *ApplicationData) MaskSSNs() {
+	data.PersonalInfo.SSN = MaskSSN(data.PersonalInfo.SSN)
+
+	maskMembers(data.Household.AbsentMembers)
+	maskMembers(data.Household.HudRecipients)
+	maskMembers(data.Household.AccessibilityMembers)
+	maskMembers(data.Household.MembersNeedingHelp)
+
+	if data.History.CurrentResidence != nil {
+		maskMembers(data.History.CurrentResidence.NonResidingMembers)
+	}
+	for i := range data.History.PreviousResidences {
+		maskMembers(data.History.PreviousResidences[i].NonResidingMembers)
+	}
+	maskMembers(data.History.LifetimeOffenders)
+	maskMembers(data.History.ViolentOffenders)
+	maskMembers(data.History.MethOffenders)
+	maskMembers(data.History.DrugOffenders)
+	for i := range data.History.OtherCrimes {
+		data.History.OtherCrimes[i].FamilyMember.MaskSSN()
+	}
+
+	for i := range data.Income.IncomeAssetEntires {
+		data.Income.IncomeAssetEntires[i].FamilyMember.MaskSSN()
+	}
+}
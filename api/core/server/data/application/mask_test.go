@@ -0,0 +1,37 @@
+package application
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskSSN(t *testing.T) {
+	assert.Equal(t, "***-**-6789", MaskSSN("123456789"), "Only the last four digits should show")
+	assert.Equal(t, "***-**-6789", MaskSSN("123-45-6789"), "Dashes should not matter")
+	assert.Equal(t, "", MaskSSN(""), "Empty SSN should stay empty")
+	assert.Equal(t, "***", MaskSSN("12"), "Short SSNs should be hidden completely")
+	assert.True(t, IsMaskedSSN(MaskSSN("12")), "Fully hidden SSN should be recognized")
+	assert.True(t, IsMaskedSSN(MaskSSN("123456789")), "Masked SSN should be recognized")
+	assert.False(t, IsMaskedSSN("123456789"), "Full SSN should not look masked")
+}
+
+func TestMaskApplicationSSNs(t *testing.T) {
+	member := FamilyMember{ID: 1, SSN: "987654321"}
+	data := ApplicationData{
+		PersonalInfo: PersonalInfo{SSN: "123456789"},
+		Household:    HouseholdData{AbsentMembers: []FamilyMember{member}},
+		History: HistoryData{
+			CurrentResidence: &ResidenceData{NonResidingMembers: []FamilyMember{member}},
+			OtherCrimes:      []CrimeData{{FamilyMember: member}},
+		},
+		Income: IncomeAndAssetsData{IncomeAssetEntires: []IncomeAndAssetData{{FamilyMember: member}}},
+	}
+	data.MaskSSNs()
+
+	assert.Equal(t, "***-**-6789", data.PersonalInfo.SSN, "Applicant SSN should be masked")
+	assert.Equal(t, "***-**-4321", data.Household.AbsentMembers[0].SSN, "Household SSNs should be masked")
+	assert.Equal(t, "***-**-4321", data.History.CurrentResidence.NonResidingMembers[0].SSN, "Residence member SSNs should be masked")
+	assert.Equal(t, "***-**-4321", data.History.OtherCrimes[0].FamilyMember.SSN, "Crime member SSNs should be masked")
+	assert.Equal(t, "***-**-4321", data.Income.IncomeAssetEntires[0].FamilyMember.SSN, "Income member SSNs should be masked")
+}
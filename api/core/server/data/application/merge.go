@@ -0,0 +1,59 @@
+package application
+
+// JSON Merge Patch (RFC 7396), for changing part of an application without
+// sending the whole thing.
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalidPatch = errors.New("patch must be a json object")
+
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+		} else {
+			targetObject[key] = mergePatch(targetObject[key], value)
+		}
+	}
+	return targetObject
+}
+
+// MergePatch applies a merge patch to one section of the application, like
+// "household". Fields in the patch replace the ones in the section, nulls
+// clear them, and lists are replaced as a whole.
+func (data *ApplicationData) MergePatch(section string, patch []byte) error {
+	var patchObject map[string]interface{}
+	if err := json.Unmarshal(patch, &patchObject); err != nil || patchObject == nil {
+		return ErrInvalidPatch
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	var target map[string]interface{}
+	if err := json.Unmarshal(encoded, &target); err != nil {
+		return err
+	}
+
+	target[section] = mergePatch(target[section], patchObject)
+
+	patched, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+	*data = ApplicationData{}
+	return json.Unmarshal(patched, data)
+}
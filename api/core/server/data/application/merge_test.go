@@ -0,0 +1,61 @@
+package application
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergePatchSection(t *testing.T) {
+	data := ApplicationData{
+		PersonalInfo: PersonalInfo{FirstName: "Jane"},
+		Household: HouseholdData{
+			HasPet:         true,
+			PetDescription: "Cat",
+			AbsentMembers:  []FamilyMember{{ID: 1}, {ID: 2}},
+		},
+	}
+
+	err := data.MergePatch("household", []byte(`{"pet_description": "Dog", "absent_members": [{"id": 3}], "is_smoker": true}`))
+	assert.NoError(t, err, "Patch should apply")
+
+	assert.True(t, data.Household.HasPet, "Fields not in the patch should stay")
+	assert.Equal(t, "Dog", data.Household.PetDescription, "Fields in the patch should change")
+	assert.True(t, data.Household.IsSmoker, "Fields in the patch should change")
+	assert.Equal(t, []FamilyMember{{ID: 3}}, data.Household.AbsentMembers, "Lists should be replaced")
+	assert.Equal(t, "Jane", data.PersonalInfo.FirstName, "Other sections should stay")
+}
+
+func TestMergePatchNullClears(t *testing.T) {
+	data := ApplicationData{
+		History: HistoryData{
+			Evicted:          true,
+			CurrentResidence: &ResidenceData{Address: "1 Main St"},
+		},
+	}
+
+	err := data.MergePatch("history", []byte(`{"current_residence": null, "evicted": null}`))
+	assert.NoError(t, err, "Patch should apply")
+	assert.Nil(t, data.History.CurrentResidence, "Null should clear the residence")
+	assert.False(t, data.History.Evicted, "Null should clear the field")
+}
+
+func TestMergePatchNested(t *testing.T) {
+	data := ApplicationData{
+		History: HistoryData{
+			CurrentResidence: &ResidenceData{Address: "1 Main St", City: "Springfield"},
+		},
+	}
+
+	err := data.MergePatch("history", []byte(`{"current_residence": {"city": "Shelbyville"}}`))
+	assert.NoError(t, err, "Patch should apply")
+	assert.Equal(t, "1 Main St", data.History.CurrentResidence.Address, "Nested fields not in the patch should stay")
+	assert.Equal(t, "Shelbyville", data.History.CurrentResidence.City, "Nested fields in the patch should change")
+}
+
+func TestMergePatchInvalid(t *testing.T) {
+	data := ApplicationData{}
+	assert.ErrorIs(t, data.MergePatch("income", []byte(`[1, 2]`)), ErrInvalidPatch, "Lists aren't patches")
+	assert.ErrorIs(t, data.MergePatch("income", []byte(`null`)), ErrInvalidPatch, "Null isn't a patch")
+	assert.ErrorIs(t, data.MergePatch("income", []byte(`{`)), ErrInvalidPatch, "Broken json isn't a patch")
+}
@@ -0,0 +1,118 @@
+package application
+
+// The lifecycle of an application, from draft to a decision, and who can move
+// it along each step.
+
+import (
+	"api/db"
+	"errors"
+	"slices"
+)
+
+var (
+	ErrInvalidTransition   = errors.New("application can't move to that status")
+	ErrTransitionForbidden = errors.New("not allowed to move the application to that status")
+	ErrReasonRequired      = errors.New("a reason is required")
+)
+
+type transition struct {
+	from db.ApplicationStatus
+	to   db.ApplicationStatus
+}
+
+var (
+	clientOnly         = []db.UserType{db.UserTypeClient}
+	caseworkerOnly     = []db.UserType{db.UserTypeCaseWorker}
+	clientOrCaseworker = []db.UserType{db.UserTypeClient, db.UserTypeCaseWorker}
+	reviewers          = []db.UserType{db.UserTypeCaseWorker, db.UserTypeAdmin}
+	// Caseworkers only review their own clients' applications
+	anyClientReviewers = []db.UserType{db.UserTypeAdmin}
+)
+
+// Who can make each transition. Anything not listed isn't allowed.
+var transitions = map[transition][]db.UserType{
+	{db.ApplicationStatusDraft, db.ApplicationStatusReadyForReview}:     clientOrCaseworker,
+	{db.ApplicationStatusReadyForReview, db.ApplicationStatusDraft}:     clientOrCaseworker,
+	{db.ApplicationStatusReadyForReview, db.ApplicationStatusSubmitted}: caseworkerOnly,
+	{db.ApplicationStatusSubmitted, db.ApplicationStatusUnderReview}:    reviewers,
+	{db.ApplicationStatusUnderReview, db.ApplicationStatusApproved}:     reviewers,
+	{db.ApplicationStatusUnderReview, db.ApplicationStatusDenied}:       reviewers,
+	{db.ApplicationStatusDraft, db.ApplicationStatusWithdrawn}:          clientOrCaseworker,
+	{db.ApplicationStatusReadyForReview, db.ApplicationStatusWithdrawn}: clientOrCaseworker,
+	{db.ApplicationStatusSubmitted, db.ApplicationStatusWithdrawn}:      clientOrCaseworker,
+	{db.ApplicationStatusUnderReview, db.ApplicationStatusWithdrawn}:    clientOrCaseworker,
+	{db.ApplicationStatusWithdrawn, db.ApplicationStatusDraft}:          clientOnly,
+}
+
+// Statuses an application can still be changed in. Once it's submitted, it
+// has to be withdrawn and reopened first.
+var EditableStatuses = []db.ApplicationStatus{
+	db.ApplicationStatusDraft,
+	db.ApplicationStatusReadyForReview,
+}
+
+// IsEditable checks if an application in the status can be changed.
+func IsEditable(status db.ApplicationStatus) bool {
+	return slices.Contains(EditableStatuses, status)
+}
+
+// Statuses an application is waiting on a decision in. Every application of
+// a client shares the same application data, so none of it can change while
+// any of them is in one.
+var PendingStatuses = []db.ApplicationStatus{
+	db.ApplicationStatusSubmitted,
+	db.ApplicationStatusUnderReview,
+}
+
+// IsReview checks if moving to the status is part of reviewing a submitted
+// application.
+func IsReview(to db.ApplicationStatus) bool {
+	return to == db.ApplicationStatusUnderReview || IsDecision(to)
+}
+
+// IsDecision checks if moving to the status decides on the application,
+// which whoever submitted it can't do.
+func IsDecision(to db.ApplicationStatus) bool {
+	return to == db.ApplicationStatusApproved || to == db.ApplicationStatusDenied
+}
+
+// IsReviewer checks if a user of the given type can review any client's
+// application, not just the ones of clients linked to them.
+func IsReviewer(role db.UserType) bool {
+	return slices.Contains(anyClientReviewers, role)
+}
+
+// RequiresReason checks if moving to the status needs a reason, so the client
+// knows why.
+func RequiresReason(status db.ApplicationStatus) bool {
+	return status == db.ApplicationStatusDenied || status == db.ApplicationStatusWithdrawn
+}
+
+// CheckTransition checks if a user of the given type can move an application
+// from one status to another.
+func CheckTransition(from db.ApplicationStatus, to db.ApplicationStatus, role db.UserType, reason string) error {
+	roles, ok := transitions[transition{from, to}]
+	if !ok {
+		return ErrInvalidTransition
+	}
+	if !slices.Contains(roles, role) {
+		return ErrTransitionForbidden
+	}
+	if RequiresReason(to) && reason == "" {
+		return ErrReasonRequired
+	}
+	return nil
+}
+
+// NextStatuses lists the statuses a user of the given type can move an
+// application to from its current status.
+func NextStatuses(from db.ApplicationStatus, role db.UserType) []db.ApplicationStatus {
+	next := []db.ApplicationStatus{}
+	for t, roles := range transitions {
+		if t.from == from && slices.Contains(roles, role) {
+			next = append(next, t.to)
+		}
+	}
+	slices.Sort(next)
+	return next
+}
@@ -0,0 +1,58 @@
+package application
+
+import (
+	"api/db"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckTransition(t *testing.T) {
+	assert.NoError(t, CheckTransition(db.ApplicationStatusDraft, db.ApplicationStatusReadyForReview, db.UserTypeClient, ""), "Client should be able to mark their draft ready for review")
+	assert.NoError(t, CheckTransition(db.ApplicationStatusReadyForReview, db.ApplicationStatusSubmitted, db.UserTypeCaseWorker, ""), "Caseworker should be able to submit a reviewed application")
+	assert.NoError(t, CheckTransition(db.ApplicationStatusUnderReview, db.ApplicationStatusApproved, db.UserTypeAdmin, ""), "Admin should be able to approve")
+
+	assert.Equal(t, ErrTransitionForbidden, CheckTransition(db.ApplicationStatusReadyForReview, db.ApplicationStatusSubmitted, db.UserTypeClient, ""), "Client shouldn't be able to submit their own application")
+	assert.Equal(t, ErrTransitionForbidden, CheckTransition(db.ApplicationStatusUnderReview, db.ApplicationStatusApproved, db.UserTypeClient, ""), "Client shouldn't be able to approve")
+	assert.Equal(t, ErrTransitionForbidden, CheckTransition(db.ApplicationStatusWithdrawn, db.ApplicationStatusDraft, db.UserTypeCaseWorker, ""), "Only the client should reopen a withdrawn application")
+
+	assert.Equal(t, ErrInvalidTransition, CheckTransition(db.ApplicationStatusDraft, db.ApplicationStatusApproved, db.UserTypeAdmin, ""), "Draft shouldn't skip straight to approved")
+	assert.Equal(t, ErrInvalidTransition, CheckTransition(db.ApplicationStatusApproved, db.ApplicationStatusWithdrawn, db.UserTypeClient, ""), "Decisions should be final")
+
+	assert.Equal(t, ErrReasonRequired, CheckTransition(db.ApplicationStatusUnderReview, db.ApplicationStatusDenied, db.UserTypeCaseWorker, ""), "Denying should need a reason")
+	assert.NoError(t, CheckTransition(db.ApplicationStatusUnderReview, db.ApplicationStatusDenied, db.UserTypeCaseWorker, "Over income"), "Denying with a reason should be allowed")
+}
+
+func TestNextStatuses(t *testing.T) {
+	assert.Equal(t,
+		[]db.ApplicationStatus{db.ApplicationStatusReadyForReview, db.ApplicationStatusWithdrawn},
+		NextStatuses(db.ApplicationStatusDraft, db.UserTypeClient),
+		"Client should be able to mark a draft ready or withdraw it")
+	assert.Equal(t,
+		[]db.ApplicationStatus{db.ApplicationStatusDraft, db.ApplicationStatusSubmitted, db.ApplicationStatusWithdrawn},
+		NextStatuses(db.ApplicationStatusReadyForReview, db.UserTypeCaseWorker),
+		"Caseworker should be able to send back, submit or withdraw")
+	assert.Empty(t, NextStatuses(db.ApplicationStatusApproved, db.UserTypeAdmin), "Approved should be final")
+	assert.Empty(t, NextStatuses(db.ApplicationStatusDraft, db.UserTypeAdmin), "Admins shouldn't touch drafts")
+}
+
+func TestIsReview(t *testing.T) {
+	assert.True(t, IsReview(db.ApplicationStatusUnderReview), "Starting a review should be a review")
+	assert.True(t, IsReview(db.ApplicationStatusApproved), "Approving should be a review")
+	assert.False(t, IsReview(db.ApplicationStatusSubmitted), "Submitting shouldn't be a review")
+	assert.False(t, IsReview(db.ApplicationStatusWithdrawn), "Withdrawing shouldn't be a review")
+
+	assert.True(t, IsDecision(db.ApplicationStatusDenied), "Denying should be a decision")
+	assert.False(t, IsDecision(db.ApplicationStatusUnderReview), "Starting a review shouldn't be a decision")
+
+	assert.True(t, IsReviewer(db.UserTypeAdmin), "Admins should review")
+	assert.False(t, IsReviewer(db.UserTypeCaseWorker), "Caseworkers should only review their own clients")
+	assert.False(t, IsReviewer(db.UserTypeClient), "Clients shouldn't review")
+}
+
+func TestIsEditable(t *testing.T) {
+	assert.True(t, IsEditable(db.ApplicationStatusDraft), "Drafts should be editable")
+	assert.True(t, IsEditable(db.ApplicationStatusReadyForReview), "Applications waiting for review should be editable")
+	assert.False(t, IsEditable(db.ApplicationStatusSubmitted), "Submitted applications should be locked")
+	assert.False(t, IsEditable(db.ApplicationStatusWithdrawn), "Withdrawn applications should be reopened first")
+}
@@ -0,0 +1,175 @@
+package application
+
+// Checks an application for missing answers and anything that looks off.
+// Missing answers are errors that keep the application from being complete,
+// while warnings are only worth a second look.
+
+import "fmt"
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// FieldIssue is a problem with one field, named by its json path like
+// "history.previous_residences[0].date_in".
+type FieldIssue struct {
+	Field    string   `json:"field"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+type Validation struct {
+	Valid               bool         `json:"valid"`
+	Errors              []FieldIssue `json:"errors"`
+	Warnings            []FieldIssue `json:"warnings"`
+	Completeness        Completeness `json:"completeness"`
+	OverallCompleteness int          `json:"overall_completeness"`
+}
+
+// validator collects issues and tallies required fields for each section.
+type validator struct {
+	errors   []FieldIssue
+	warnings []FieldIssue
+	tallies  map[string]*tally
+}
+
+// require checks a field that must be filled in.
+func (v *validator) require(section string, field string, filled bool, message string) {
+	t := v.tallies[section]
+	t.required++
+	if filled {
+		t.filled++
+		return
+	}
+	v.errors = append(v.errors, FieldIssue{Field: section + "." + field, Severity: SeverityError, Message: message})
+}
+
+// requireIf checks a field that must be filled in when the question it
+// explains was answered yes.
+func (v *validator) requireIf(section string, field string, condition bool, filled bool, message string) {
+	if condition {
+		v.require(section, field, filled, message)
+	}
+}
+
+// warn notes something that doesn't stop the application from being
+// complete, but should be looked at.
+func (v *validator) warn(section string, field string, message string) {
+	v.warnings = append(v.warnings, FieldIssue{Field: section + "." + field, Severity: SeverityWarning, Message: message})
+}
+
+// requireResidence checks a residence has an address and the dates it was
+// lived at. Only past residences need a move out date.
+func (v *validator) requireResidence(field string, residence *ResidenceData, current bool) {
+	v.require(SectionHistory, field+".address", residence.Address != "", "Address is required")
+	v.require(SectionHistory, field+".city", residence.City != "", "City is required")
+	v.require(SectionHistory, field+".state", residence.State != "", "State is required")
+	v.require(SectionHistory, field+".zip_code", residence.ZipCode != "", "Zip code is required")
+	v.require(SectionHistory, field+".date_in", residence.DateIn != "", "Move in date is required")
+	v.requireIf(SectionHistory, field+".date_out", !current, residence.DateOut != "", "Move out date is required")
+	v.requireIf(SectionHistory, field+".other_residence_type", residence.ResidenceType == "Other", residence.OtherResidenceType != "", "Describe the type of residence")
+
+	if current && residence.DateOut != "" {
+		v.warn(SectionHistory, field+".date_out", "Current residence has a move out date")
+	}
+	dateIn, dateInErr := ParseDate(residence.DateIn)
+	dateOut, dateOutErr := ParseDate(residence.DateOut)
+	if dateInErr == nil && dateOutErr == nil && dateOut.Before(dateIn) {
+		v.warn(SectionHistory, field+".date_out", "Move out date is before move in date")
+	}
+}
+
+// Validate checks an application for missing answers and works out how
+// complete each section is.
+func Validate(data *ApplicationData) Validation {
+	v := validator{
+		errors:   []FieldIssue{},
+		warnings: []FieldIssue{},
+		tallies:  map[string]*tally{},
+	}
+	for _, section := range Sections {
+		v.tallies[section] = &tally{}
+	}
+
+	personal := data.PersonalInfo
+	v.require(SectionPersonalInfo, "first_name", personal.FirstName != "", "First name is required")
+	v.require(SectionPersonalInfo, "last_name", personal.LastName != "", "Last name is required")
+	v.require(SectionPersonalInfo, "dob", personal.Dob != "", "Date of birth is required")
+	v.require(SectionPersonalInfo, "phone", personal.Phone != "", "Phone number is required")
+	v.require(SectionPersonalInfo, "ssn", personal.SSN != "", "Social security number is required")
+	v.require(SectionPersonalInfo, "address", personal.Address != "", "Address is required")
+	v.require(SectionPersonalInfo, "gender", personal.Gender != "", "Gender is required")
+
+	preferences := data.HousingPreferences
+	v.require(SectionHousingPreferences, "rankings", len(preferences.Rankings) > 0, "Rank at least one housing preference")
+	v.require(SectionHousingPreferences, "desired_move_in_date", preferences.DesiredMoveInDate != "", "Desired move in date is required")
+
+	household := data.Household
+	v.requireIf(SectionHousehold, "pet_description", household.HasPet, household.PetDescription != "", "Describe your pets")
+	v.requireIf(SectionHousehold, "absent_members_explanation", household.MoreThanOneResidence, household.AbsentMembersExplanation != "", "Explain why members live somewhere else")
+	v.requireIf(SectionHousehold, "composition_explanation", household.CompositionChanges, household.CompositionExplanation != "", "Explain the household changes")
+	v.requireIf(SectionHousehold, "custody_explanation", household.Custody, household.CustodyExplanation != "", "Explain the custody arrangement")
+	v.requireIf(SectionHousehold, "hud_property_name", household.ReceivedHudJan2010, household.HudPropertyName != "", "Name the HUD property")
+	v.requireIf(SectionHousehold, "accessibility_members", household.NeedsAccessibility, len(household.AccessibilityMembers) > 0, "List the members who need an accessible unit")
+	v.requireIf(SectionHousehold, "accommodation_description", household.NeedsSpecialAccommodations, household.AccommodationDescription != "", "Describe the accommodations needed")
+	if household.NeedsAccessibility && !household.MobilityAccessibility && !household.VisionAccessibility && !household.HearingAccessibility {
+		v.warn(SectionHousehold, "needs_accessibility", "No type of accessibility was selected")
+	}
+	if household.ReceivedHudJan2010 && len(household.HudRecipients) == 0 {
+		v.warn(SectionHousehold, "hud_recipients", "No members were listed as receiving HUD assistance")
+	}
+
+	history := data.History
+	v.require(SectionHistory, "current_residence", history.CurrentResidence != nil, "Current residence is required")
+	if history.CurrentResidence != nil {
+		v.requireResidence("current_residence", history.CurrentResidence, true)
+	}
+	for i := range history.PreviousResidences {
+		v.requireResidence(fmt.Sprintf("previous_residences[%d]", i), &history.PreviousResidences[i], false)
+	}
+	v.requireIf(SectionHistory, "assistance_explanation", history.AssistanceTerminated, history.AssistanceExplanation != "", "Explain why assistance was terminated")
+	v.requireIf(SectionHistory, "eviction_explanation", history.Evicted, history.EvicitionExplanation != "", "Explain the eviction")
+	v.requireIf(SectionHistory, "debt_explanation", history.OwesMoney, history.DebtExplanation != "", "Explain the debt")
+	v.requireIf(SectionHistory, "lifetime_offenders", history.IsLifetimeSexOffender, len(history.LifetimeOffenders) > 0, "List the members who are lifetime sex offenders")
+	v.requireIf(SectionHistory, "violent_offenders", history.IsViolentOffender, len(history.ViolentOffenders) > 0, "List the members who are violent offenders")
+	v.requireIf(SectionHistory, "meth_offenders", history.IsMethConviction, len(history.MethOffenders) > 0, "List the members with meth convictions")
+	v.requireIf(SectionHistory, "drug_offenders", history.HasDrugCharges, len(history.DrugOffenders) > 0, "List the members with drug charges")
+	if history.OwesMoney && !history.MakingPayments {
+		v.warn(SectionHistory, "making_payments", "Money is owed without a payment plan")
+	}
+	for i, crime := range history.OtherCrimes {
+		field := fmt.Sprintf("other_crimes[%d]", i)
+		v.require(SectionHistory, field+".crime", crime.Crime != "", "Crime is required")
+		v.require(SectionHistory, field+".year", crime.Year != "", "Year is required")
+	}
+
+	income := data.Income
+	v.requireIf(SectionIncome, "assistance_program_name", income.ReceivesGovAssistance, income.AssistanceProgramName != "", "Name the assistance program")
+	for i, entry := range income.IncomeAssetEntires {
+		field := fmt.Sprintf("income_asset_entries[%d]", i)
+		v.require(SectionIncome, field+".type", entry.Type != "", "Type is required")
+		v.require(SectionIncome, field+".source", entry.Source != "", "Source is required")
+		v.require(SectionIncome, field+".amount", entry.Amount != "", "Amount is required")
+	}
+	if len(income.IncomeAssetEntires) == 0 {
+		v.warn(SectionIncome, "income_asset_entries", "No income or assets were listed")
+	}
+
+	v.errors = append(v.errors, checkFormats(data)...)
+
+	completeness := Completeness{}
+	for _, section := range Sections {
+		completeness[section] = v.tallies[section].percent()
+	}
+
+	return Validation{
+		Valid:               len(v.errors) == 0,
+		Errors:              v.errors,
+		Warnings:            v.warnings,
+		Completeness:        completeness,
+		OverallCompleteness: completeness.Overall(),
+	}
+}
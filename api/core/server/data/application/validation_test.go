@@ -0,0 +1,102 @@
+package application
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fields lists the fields issues were found for.
+func fields(issues []FieldIssue) []string {
+	names := make([]string, len(issues))
+	for i, issue := range issues {
+		names[i] = issue.Field
+	}
+	return names
+}
+
+func TestValidateConditionalAnswers(t *testing.T) {
+	data := ApplicationData{
+		Household: HouseholdData{
+			HasPet: true,
+		},
+		History: HistoryData{
+			Evicted:           true,
+			IsViolentOffender: true,
+		},
+	}
+	validation := Validate(&data)
+
+	assert.False(t, validation.Valid, "Incomplete application should not be valid")
+	errors := fields(validation.Errors)
+	assert.Contains(t, errors, "household.pet_description", "Pet description should be required")
+	assert.Contains(t, errors, "history.eviction_explanation", "Eviction explanation should be required")
+	assert.Contains(t, errors, "history.violent_offenders", "Violent offenders should be required")
+	assert.NotContains(t, errors, "history.debt_explanation", "Debt explanation should only be required when owing money")
+}
+
+func TestValidateResidenceDates(t *testing.T) {
+	data := ApplicationData{
+		History: HistoryData{
+			CurrentResidence: &ResidenceData{
+				Address: "123 Main St",
+				City:    "Boise",
+				State:   "ID",
+				ZipCode: "83702",
+			},
+			PreviousResidences: []ResidenceData{
+				{
+					Address: "456 Elm St",
+					City:    "Nampa",
+					State:   "ID",
+					ZipCode: "83651",
+					DateIn:  "2019-06-01",
+					DateOut: "2018-01-01",
+				},
+			},
+		},
+	}
+	validation := Validate(&data)
+
+	errors := fields(validation.Errors)
+	assert.Contains(t, errors, "history.current_residence.date_in", "Current residence should need a move in date")
+	assert.NotContains(t, errors, "history.current_residence.date_out", "Current residence should not need a move out date")
+	assert.Contains(t, fields(validation.Warnings), "history.previous_residences[0].date_out", "Moving out before moving in should be a warning")
+}
+
+func TestValidateComplete(t *testing.T) {
+	data := ApplicationData{
+		PersonalInfo: PersonalInfo{
+			FirstName: "Jane",
+			LastName:  "Doe",
+			Dob:       "2000-04-01",
+			Phone:     "2085551234",
+			SSN:       "123-45-6789",
+			Address:   "123 Main St",
+			Gender:    "Female",
+		},
+		HousingPreferences: HousingPreferences{
+			Rankings:          map[string]string{"Boise": "1"},
+			DesiredMoveInDate: "2025-06-01",
+		},
+		History: HistoryData{
+			CurrentResidence: &ResidenceData{
+				Address: "123 Main St",
+				City:    "Boise",
+				State:   "ID",
+				ZipCode: "83702",
+				DateIn:  "2020-01-01",
+			},
+		},
+		Income: IncomeAndAssetsData{
+			IncomeAssetEntires: []IncomeAndAssetData{
+				{Type: "Income", Source: "Employment", Amount: "2000"},
+			},
+		},
+	}
+	validation := Validate(&data)
+
+	assert.True(t, validation.Valid, "Complete application should be valid")
+	assert.Empty(t, validation.Errors, "Complete application should have no errors")
+	assert.Equal(t, 100, validation.OverallCompleteness, "Complete application should be 100%")
+}
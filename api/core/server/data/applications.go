@@ -0,0 +1,284 @@
+package data
+
+// Clients can apply to several housing programs at once. Each program gets
+// its own housing application with a move in date, preferences and status,
+// everything else comes from the client's shared application data.
+
+import (
+	"api/core/server/account"
+	"api/core/server/data/application"
+	"api/db"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+type HousingApplicationInfo struct {
+	ID                int                  `json:"id"`
+	ProgramID         *int                 `json:"program_id"`
+	Program           string               `json:"program"`
+	Status            db.ApplicationStatus `json:"status"`
+	StatusChangedAt   time.Time            `json:"status_changed_at"`
+	DesiredMoveInDate string               `json:"desired_move_in_date"`
+	CreatedAt         time.Time            `json:"created_at"`
+	UpdatedAt         time.Time            `json:"updated_at"`
+}
+
+type GetHousingApplicationsResponse struct {
+	Success      bool                     `json:"success"`
+	Applications []HousingApplicationInfo `json:"applications"`
+	Error        string                   `json:"error"`
+}
+
+type CreateHousingApplicationRequest struct {
+	// Set by caseworkers starting an application for a client
+	Email     string `json:"email"`
+	ProgramID int    `json:"program_id"`
+	// A program that isn't in the catalog, by name
+	Program string `json:"program"`
+}
+
+type CreateHousingApplicationResponse struct {
+	Success bool   `json:"success"`
+	ID      int    `json:"id"`
+	Error   string `json:"error"`
+}
+
+// Statuses an application is finished in, so the client can apply to the
+// same program again.
+var closedStatuses = []db.ApplicationStatus{
+	db.ApplicationStatusDenied,
+	db.ApplicationStatusWithdrawn,
+}
+
+func toHousingApplicationInfo(housingApplication *db.HousingApplicationModel) HousingApplicationInfo {
+	info := HousingApplicationInfo{
+		ID:                housingApplication.ID,
+		Program:           housingApplication.Program,
+		Status:            housingApplication.Status,
+		StatusChangedAt:   housingApplication.StatusChangedAt,
+		DesiredMoveInDate: housingApplication.DesiredMoveInDate,
+		CreatedAt:         housingApplication.CreatedAt,
+		UpdatedAt:         housingApplication.UpdatedAt,
+	}
+	if programId, ok := housingApplication.HousingProgramID(); ok {
+		info.ProgramID = &programId
+	}
+	return info
+}
+
+// parseApplicationID reads an application id param. An empty param is 0,
+// which picks the owner's only application.
+func parseApplicationID(param string) (int, bool) {
+	if param == "" {
+		return 0, true
+	}
+	id, err := strconv.Atoi(param)
+	return id, err == nil && id > 0
+}
+
+// findHousingApplication finds one of the owner's housing applications, with
+// its preferences. Without an id, it's their only application, which lets
+// clients that don't know about programs yet keep working. If they have none,
+// one is started when create is set, otherwise it's nil. On failure, returns
+// the status code and error message to respond with.
+func findHousingApplication(client *db.PrismaClient, owner *db.UserModel, id int, create bool) (*db.HousingApplicationModel, int, string) {
+	if id != 0 {
+		housingApplication, err := client.HousingApplication.FindFirst(
+			db.HousingApplication.ID.Equals(id),
+			db.HousingApplication.UserID.Equals(owner.ID),
+		).With(
+			application.HousingApplicationWith...,
+		).Exec(context.Background())
+		if err == db.ErrNotFound {
+			return nil, 404, "Application not found"
+		}
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to get housing application %d: %v\n", id, err)
+			return nil, 500, "Failed to retrieve application"
+		}
+		return housingApplication, 200, ""
+	}
+
+	housingApplications, err := client.HousingApplication.FindMany(
+		db.HousingApplication.UserID.Equals(owner.ID),
+	).With(
+		application.HousingApplicationWith...,
+	).Exec(context.Background())
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to get housing applications for user %d: %v\n", owner.ID, err)
+		return nil, 500, "Failed to retrieve application"
+	}
+
+	switch len(housingApplications) {
+	case 0:
+		if !create {
+			return nil, 200, ""
+		}
+		housingApplication, createErr := client.HousingApplication.CreateOne(
+			db.HousingApplication.User.Link(
+				db.User.ID.Equals(owner.ID),
+			),
+		).With(
+			application.HousingApplicationWith...,
+		).Exec(context.Background())
+		if createErr != nil {
+			fmt.Printf("[ERROR] Failed to create housing application for user %d: %v\n", owner.ID, createErr)
+			return nil, 500, "Failed to create application"
+		}
+		return housingApplication, 200, ""
+	case 1:
+		return &housingApplications[0], 200, ""
+	default:
+		return nil, 400, "Applying to more than one program, pick an application"
+	}
+}
+
+// checkSharedDataEditable refuses changes to the owner's shared application
+// data while any of their applications is waiting on a decision, since it's
+// what's being reviewed. On failure, returns the status code and error
+// message to respond with.
+func checkSharedDataEditable(client *db.PrismaClient, owner *db.UserModel) (int, string) {
+	pending, err := client.HousingApplication.FindFirst(
+		db.HousingApplication.UserID.Equals(owner.ID),
+		db.HousingApplication.Status.In(application.PendingStatuses),
+	).Exec(context.Background())
+	if err != nil && err != db.ErrNotFound {
+		fmt.Printf("[ERROR] Failed to get housing applications for user %d: %v\n", owner.ID, err)
+		return 500, "Failed to retrieve application"
+	}
+	if pending != nil {
+		return 400, pendingError
+	}
+	return 200, ""
+}
+
+// reloadHousingApplication gets a housing application again after it's been
+// saved. Returns nil for id 0.
+func reloadHousingApplication(client *db.PrismaClient, id int) (*db.HousingApplicationModel, error) {
+	if id == 0 {
+		return nil, nil
+	}
+	return client.HousingApplication.FindUnique(
+		db.HousingApplication.ID.Equals(id),
+	).With(
+		application.HousingApplicationWith...,
+	).Exec(context.Background())
+}
+
+// GetHousingApplicationsHandler lists the user's applications, or with the
+// email param, a client's, oldest first.
+func GetHousingApplicationsHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, GetHousingApplicationsResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	ownerEmail := c.QueryParam("email")
+	owner, ownerErr := findOwner(client, user, ownerEmail)
+	if ownerErr != nil {
+		fmt.Printf("[ERROR] Not a caseworker for owner email: %s\n", ownerEmail)
+		return c.JSON(400, GetHousingApplicationsResponse{Success: false, Error: "Not a caseworker for account"})
+	}
+
+	housingApplications, err := client.HousingApplication.FindMany(
+		db.HousingApplication.UserID.Equals(owner.ID),
+	).OrderBy(
+		db.HousingApplication.CreatedAt.Order(db.SortOrderAsc),
+	).Exec(context.Background())
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to get housing applications for user %d: %v\n", owner.ID, err)
+		return c.JSON(500, GetHousingApplicationsResponse{Success: false, Error: "Failed to get applications"})
+	}
+
+	infos := make([]HousingApplicationInfo, len(housingApplications))
+	for i := range housingApplications {
+		infos[i] = toHousingApplicationInfo(&housingApplications[i])
+	}
+	return c.JSON(200, GetHousingApplicationsResponse{Success: true, Applications: infos, Error: ""})
+}
+
+// CreateHousingApplicationHandler starts an application to a program from
+// the catalog, as long as its intake is open. A client can only have one open
+// application per program.
+func CreateHousingApplicationHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, CreateHousingApplicationResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	var request CreateHousingApplicationRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&request); err != nil {
+		return c.JSON(500, CreateHousingApplicationResponse{Success: false, Error: "Failed to parse request body"})
+	}
+	filters := []db.HousingApplicationWhereParam{}
+	params := []db.HousingApplicationSetParam{}
+	if request.ProgramID != 0 {
+		program, programErr := client.HousingProgram.FindUnique(
+			db.HousingProgram.ID.Equals(request.ProgramID),
+		).Exec(context.Background())
+		if programErr == db.ErrNotFound {
+			return c.JSON(404, CreateHousingApplicationResponse{Success: false, Error: "Program not found"})
+		}
+		if programErr != nil {
+			fmt.Printf("[ERROR] Failed to get program %d: %v\n", request.ProgramID, programErr)
+			return c.JSON(500, CreateHousingApplicationResponse{Success: false, Error: "Failed to get program"})
+		}
+		if !program.IntakeOpen {
+			return c.JSON(400, CreateHousingApplicationResponse{Success: false, Error: "Program isn't taking applications"})
+		}
+		filters = append(filters, db.HousingApplication.HousingProgramID.Equals(program.ID))
+		params = append(params,
+			db.HousingApplication.Program.Set(program.Name),
+			db.HousingApplication.HousingProgram.Link(
+				db.HousingProgram.ID.Equals(program.ID),
+			),
+		)
+	} else {
+		name := strings.TrimSpace(request.Program)
+		if name == "" {
+			return c.JSON(400, CreateHousingApplicationResponse{Success: false, Error: "Missing program"})
+		}
+		filters = append(filters, db.HousingApplication.Program.Equals(name))
+		params = append(params, db.HousingApplication.Program.Set(name))
+	}
+
+	// Caseworkers can start an application for a client if the client lets them
+	owner, ownerErr := account.FindEditableOwner(client, user, request.Email)
+	if ownerErr != nil {
+		status, message := account.EditAccessError(ownerErr, request.Email)
+		return c.JSON(status, CreateHousingApplicationResponse{Success: false, Error: message})
+	}
+
+	existing, existingErr := client.HousingApplication.FindFirst(
+		append(filters,
+			db.HousingApplication.UserID.Equals(owner.ID),
+			db.HousingApplication.Status.NotIn(closedStatuses),
+		)...,
+	).Exec(context.Background())
+	if existingErr != nil && existingErr != db.ErrNotFound {
+		fmt.Printf("[ERROR] Failed to check housing applications for user %d: %v\n", owner.ID, existingErr)
+		return c.JSON(500, CreateHousingApplicationResponse{Success: false, Error: "Failed to create application"})
+	}
+	if existing != nil {
+		return c.JSON(409, CreateHousingApplicationResponse{Success: false, ID: existing.ID, Error: "Already applying to this program"})
+	}
+
+	housingApplication, createErr := client.HousingApplication.CreateOne(
+		db.HousingApplication.User.Link(
+			db.User.ID.Equals(owner.ID),
+		),
+		params...,
+	).Exec(context.Background())
+	if createErr != nil {
+		fmt.Printf("[ERROR] Failed to create housing application for user %d: %v\n", owner.ID, createErr)
+		return c.JSON(500, CreateHousingApplicationResponse{Success: false, Error: "Failed to create application"})
+	}
+
+	return c.JSON(200, CreateHousingApplicationResponse{Success: true, ID: housingApplication.ID, Error: ""})
+}
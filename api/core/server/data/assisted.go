@@ -0,0 +1,37 @@
+package data
+
+// Caseworkers editing a client's application for them, with the client's
+// consent. See account.FindEditableOwner and audit.RecordAssistedEdit.
+
+import (
+	"api/core/server/data/application"
+	"strings"
+)
+
+// keepMaskedSSN puts the stored SSN back when a caseworker sends back the
+// masked one they were shown, so it isn't overwritten.
+func keepMaskedSSN(ssn *string, stored string) {
+	if application.IsMaskedSSN(*ssn) {
+		*ssn = stored
+	}
+}
+
+// summarizeChanges lists the changed fields for the client, without values.
+// Changes inside a list, like one income entry, are listed as the list.
+func summarizeChanges(changes []application.FieldChange) []string {
+	summary := []string{}
+	seen := map[string]bool{}
+	for _, change := range changes {
+		field := change.Field
+		if i := strings.Index(field, "["); i >= 0 {
+			field = field[:i]
+		}
+		field = strings.ReplaceAll(field, "_", " ")
+		field = strings.ReplaceAll(field, ".", ": ")
+		if !seen[field] {
+			seen[field] = true
+			summary = append(summary, field)
+		}
+	}
+	return summary
+}
@@ -0,0 +1,91 @@
+package data
+
+// Applications carry a version number so a caseworker and a client editing
+// at the same time don't silently overwrite each other. Saves have to say
+// which version they started from, and are refused if it's changed since.
+
+import (
+	"api/core/server/data/application"
+	"api/db"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+const conflictError = "Application was changed by someone else, review their changes and try again"
+
+// versionETag formats an application version as an ETag.
+func versionETag(version int) string {
+	return fmt.Sprintf("\"%d\"", version)
+}
+
+// parseIfMatch reads the version out of an If-Match header set from
+// versionETag. Returns false if the header is missing or isn't a version.
+func parseIfMatch(header string) (int, bool) {
+	tag := strings.TrimPrefix(strings.TrimSpace(header), "W/")
+	tag = strings.Trim(tag, "\"")
+	version, err := strconv.Atoi(tag)
+	if err != nil || version < 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+// claimVersion bumps the application's version past the expected one. It
+// goes in the same transaction as the snapshot of the new version, and
+// version numbers are unique, so of two saves racing from the same version
+// only one gets through.
+func claimVersion(client *db.PrismaClient, applicationId int, expectedVersion int) db.PrismaTransaction {
+	return client.ApplicationData.FindUnique(
+		db.ApplicationData.ID.Equals(applicationId),
+	).Update(
+		db.ApplicationData.Version.Set(expectedVersion + 1),
+	).Tx()
+}
+
+// hasVersion checks if the application is still at the expected version,
+// which tells a save that lost a race apart from one that failed.
+func hasVersion(client *db.PrismaClient, applicationId int, expectedVersion int) (bool, error) {
+	applicationData, err := client.ApplicationData.FindUnique(
+		db.ApplicationData.ID.Equals(applicationId),
+	).Exec(context.Background())
+	if err != nil {
+		return false, err
+	}
+	return applicationData.Version == expectedVersion, nil
+}
+
+// respondConflict responds with the owner's application as it is now, with
+// the preferences of the housing application being saved, so the user can
+// see what changed before trying again.
+func respondConflict(c echo.Context, client *db.PrismaClient, user *db.UserModel, owner *db.UserModel, housingApplicationId int) error {
+	applicationData, personalInfo, _, findErr := findApplicationData(client, owner, owner.Email)
+	if findErr != "" || applicationData == nil {
+		return c.JSON(409, UpdateApplicationDataResponse{Success: false, Error: conflictError})
+	}
+	housingApplication, housingApplicationErr := reloadHousingApplication(client, housingApplicationId)
+	if housingApplicationErr != nil {
+		fmt.Printf("[ERROR] Failed to get housing application %d: %v\n", housingApplicationId, housingApplicationErr)
+		return c.JSON(409, UpdateApplicationDataResponse{Success: false, Error: conflictError})
+	}
+
+	current, convertErr := application.ToApplicationData(applicationData, housingApplication, personalInfo, owner.Email)
+	if convertErr != nil {
+		fmt.Printf("[ERROR] Failed to read application data of user %d: %v\n", owner.ID, convertErr)
+		return c.JSON(409, UpdateApplicationDataResponse{Success: false, Error: conflictError})
+	}
+	if owner.ID != user.ID {
+		current.MaskSSNs()
+	}
+	c.Response().Header().Set("ETag", versionETag(applicationData.Version))
+	return c.JSON(409, UpdateApplicationDataResponse{
+		Success:       false,
+		Error:         conflictError,
+		Version:       applicationData.Version,
+		Current:       &current,
+		ApplicationID: housingApplicationId,
+	})
+}
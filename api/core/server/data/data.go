@@ -1,14 +1,15 @@
 package data
 
 import (
+	"api/core/encryption"
 	"api/core/server/account"
+	"api/core/server/audit"
 	"api/core/server/data/application"
 	"api/core/util"
 	"api/db"
 	"context"
 	"encoding/json"
 	"fmt"
-	"regexp"
 	"time"
 
 	"github.com/labstack/echo"
@@ -23,6 +24,7 @@ var validGenders = map[string]bool{
 }
 
 var validRelationships = map[string]bool{
+	"Self":   true,
 	"Spouse": true,
 	"Child":  true,
 	"Parent": true,
@@ -45,11 +47,8 @@ type UpdatePersInfoRequest struct {
 type UpdatePersInfoResponse struct {
 	Success bool   `json:"success"`
 	Error   string `json:"error"`
-}
-
-func validatePhoneNumber(pn string) bool {
-	match, _ := regexp.MatchString("^\\d{10}$", pn)
-	return match
+	// The application's version after the change, or 0 if it has none
+	Version int `json:"version"`
 }
 
 func GetPersInfoHandler(c echo.Context, client *db.PrismaClient) error {
@@ -91,21 +90,26 @@ func UpdatePersInfoHandler(c echo.Context, client *db.PrismaClient) error {
 		return c.JSON(500, UpdatePersInfoResponse{Success: false, Error: "Failed to decode body"})
 	}
 
-	if !validatePhoneNumber(request.PhoneNumber) {
-		return c.JSON(500, UpdatePersInfoResponse{Success: false, Error: "Invalid phone number"})
+	phoneNumber, phoneErr := application.NormalizePhone(request.PhoneNumber)
+	if phoneErr != nil {
+		return c.JSON(400, UpdatePersInfoResponse{Success: false, Error: phoneErr.Error()})
 	}
 
-	_, updateErr := client.PersonalInfo.FindUnique(
-		db.PersonalInfo.ID.Equals(user.PersonalInfoID),
-	).Update(
-		db.PersonalInfo.PhoneNumber.SetOptional(&request.PhoneNumber),
-	).Exec(context.Background())
-	if updateErr != nil {
-		fmt.Printf("Failed to update personal info: %v\n", updateErr)
-		return c.JSON(500, UpdatePersInfoResponse{Success: false, Error: "Failed to update personal info"})
+	writes := []db.PrismaTransaction{
+		client.PersonalInfo.FindUnique(
+			db.PersonalInfo.ID.Equals(user.PersonalInfoID),
+		).Update(
+			db.PersonalInfo.PhoneNumber.SetOptional(&phoneNumber),
+		).Tx(),
+	}
+	version, status, saveErr := saveHouseholdChange(client, user, user, writes, func(personalInfo *db.PersonalInfoModel, _ map[int]application.FamilyMember, _ *application.ApplicationData) {
+		personalInfo.InnerPersonalInfo.PhoneNumber = &phoneNumber
+	})
+	if saveErr != "" {
+		return c.JSON(status, UpdatePersInfoResponse{Success: false, Error: saveErr})
 	}
 
-	return c.JSON(200, UpdatePersInfoResponse{Success: true})
+	return c.JSON(200, UpdatePersInfoResponse{Success: true, Version: version})
 }
 
 /**
@@ -117,228 +121,264 @@ type GetApplicationDataResponse struct {
 	Error   string                      `json:"error"`
 	HasData bool                        `json:"has_data"`
 	Data    application.ApplicationData `json:"application_data"`
+	Version int                         `json:"version"`
+	// The housing application the preferences and status are from
+	ApplicationID int                  `json:"application_id"`
+	Status        db.ApplicationStatus `json:"status"`
 }
 
 type UpdateApplicationDataRequest struct {
-	Data application.ApplicationData `json:"application_data"`
+	Data    application.ApplicationData `json:"application_data"`
+	Version *int                        `json:"version"`
+	// Can be left out by clients with only one application
+	ApplicationID int `json:"application_id"`
+	// Set by caseworkers saving a client's application
+	Email string `json:"email"`
 }
 
 type UpdateApplicationDataResponse struct {
-	Success bool   `json:"success"`
-	Error   string `json:"error"`
+	Success     bool                         `json:"success"`
+	Error       string                       `json:"error"`
+	FieldErrors []application.FieldIssue     `json:"field_errors"`
+	Validation  application.Validation       `json:"validation"`
+	Version     int                          `json:"version"`
+	Current     *application.ApplicationData `json:"current,omitempty"`
+	// The housing application that was saved
+	ApplicationID int `json:"application_id"`
 }
 
-var applicationWith = []db.ApplicationDataRelationWith{
-	db.ApplicationData.HousingPreferenceRankings.Fetch(),
-	db.ApplicationData.AbsentFamilyMembers.Fetch(),
-	db.ApplicationData.HudRecipients.Fetch(),
-	db.ApplicationData.AccessibilityMembers.Fetch(),
-	db.ApplicationData.MembersNeedingHelp.Fetch(),
-	db.ApplicationData.CurrentResidence.Fetch().With(
-		db.ResidenceInfo.NonResidingMembers.Fetch(),
-	),
-	db.ApplicationData.PreviousResidences.Fetch().With(
-		db.ResidenceInfo.NonResidingMembers.Fetch(),
-	),
-	db.ApplicationData.LifetimeOffenders.Fetch(),
-	db.ApplicationData.ViolentOffenders.Fetch(),
-	db.ApplicationData.MethOffenders.Fetch(),
-	db.ApplicationData.DrugOffenders.Fetch(),
-	db.ApplicationData.OtherCrimes.Fetch().With(
-		db.CrimeEntry.FamilyMember.Fetch(),
-	),
-	db.ApplicationData.IncomeAssetEntries.Fetch().With(
-		db.IncomeAssetEntry.FamilyMember.Fetch(),
-	),
+type ValidateApplicationDataResponse struct {
+	Success    bool                   `json:"success"`
+	Error      string                 `json:"error"`
+	Validation application.Validation `json:"validation"`
 }
 
-func GetApplicationDataHandler(c echo.Context, client *db.PrismaClient) error {
-	user := account.ValidateAuth(c, client)
-	if user == nil {
-		return c.JSON(400, GetApplicationDataResponse{Success: false, Error: "Failed to authenticate"})
+// findOwner finds the user whose data is being looked at, which is either
+// the user themselves or one of their clients.
+func findOwner(client *db.PrismaClient, user *db.UserModel, ownerEmail string) (*db.UserModel, error) {
+	if ownerEmail == "" || ownerEmail == user.Email {
+		return user, nil
 	}
 
-	// Get the data
-	ownerEmail := c.QueryParam("email")
-	var applicationData *db.ApplicationDataModel
-	var personalInfo *db.PersonalInfoModel
+	userLink, userLinkErr := client.UserLink.FindFirst(
+		db.UserLink.Caseworker.Where(
+			db.User.Email.Equals(user.Email),
+		),
+		db.UserLink.Client.Where(
+			db.User.Email.Equals(ownerEmail),
+		),
+	).With(
+		db.UserLink.Client.Fetch(),
+	).Exec(context.Background())
+	if userLinkErr != nil {
+		return nil, userLinkErr
+	}
+	return userLink.Client(), nil
+}
+
+// findApplicationData gets the application data for the owner email, which
+// is either the user or one of their clients. The application data is nil if
+// the owner hasn't started one yet. On failure, returns the status code and
+// error message to respond with.
+func findApplicationData(client *db.PrismaClient, user *db.UserModel, ownerEmail string) (*db.ApplicationDataModel, *db.PersonalInfoModel, int, string) {
 	if ownerEmail == user.Email {
-		var applicationDataErr error
-		applicationData, applicationDataErr = client.ApplicationData.FindFirst(
+		applicationData, applicationDataErr := client.ApplicationData.FindFirst(
 			db.ApplicationData.UserID.Equals(user.ID),
 		).With(
-			applicationWith...,
+			application.ApplicationWith...,
 		).Exec(context.Background())
 
 		if applicationDataErr != nil && applicationDataErr != db.ErrNotFound {
 			fmt.Printf("[ERROR] Failed to get application data: %v\n", applicationDataErr)
-			return c.JSON(500, GetApplicationDataResponse{Success: false, Error: "Failed to retrieve application data"})
+			return nil, nil, 500, "Failed to retrieve application data"
 		}
 
 		if applicationData == nil {
 			fmt.Printf("[INFO] No application data found for user %d\n", user.ID)
-			return c.JSON(200, GetApplicationDataResponse{Success: true, HasData: false})
+			return nil, nil, 200, ""
 		}
 
-		var personalInfoErr error
-		personalInfo, personalInfoErr = client.PersonalInfo.FindUnique(
+		personalInfo, personalInfoErr := client.PersonalInfo.FindUnique(
 			db.PersonalInfo.ID.Equals(user.PersonalInfoID),
 		).Exec(context.Background())
 
 		if personalInfoErr != nil {
 			fmt.Printf("[ERROR] Failed to get personal info: %v\n", personalInfoErr)
-			return c.JSON(500, GetApplicationDataResponse{Success: false, Error: "Failed to retrieve personal info"})
-		}
-	} else {
-		userLink, userLinkErr := client.UserLink.FindFirst(
-			db.UserLink.Caseworker.Where(
-				db.User.Email.Equals(user.Email),
-			),
-			db.UserLink.Client.Where(
-				db.User.Email.Equals(ownerEmail),
-			),
-		).Exec(context.Background())
-		if userLinkErr != nil || userLink == nil {
-			fmt.Printf("[ERROR] Not a caseworker for owner email: %s, %v\n", ownerEmail, userLinkErr)
-			return c.JSON(400, GetApplicationDataResponse{
-				Success: false,
-				Error:   "Not a caseworker for account",
-			})
+			return nil, nil, 500, "Failed to retrieve personal info"
 		}
+		return applicationData, personalInfo, 200, ""
+	}
 
-		ownerUser, ownerUserErr := client.User.FindUnique(
+	userLink, userLinkErr := client.UserLink.FindFirst(
+		db.UserLink.Caseworker.Where(
+			db.User.Email.Equals(user.Email),
+		),
+		db.UserLink.Client.Where(
 			db.User.Email.Equals(ownerEmail),
-		).With(
-			db.User.CaseworkerLinks.Fetch(),
-			db.User.ApplicationData.Fetch().With(
-				applicationWith...,
-			),
-			db.User.PersonalInfo.Fetch(),
-		).Exec(context.Background())
+		),
+	).Exec(context.Background())
+	if userLinkErr != nil || userLink == nil {
+		fmt.Printf("[ERROR] Not a caseworker for owner email: %s, %v\n", ownerEmail, userLinkErr)
+		return nil, nil, 400, "Not a caseworker for account"
+	}
 
-		if ownerUserErr != nil || ownerUser == nil {
-			fmt.Printf("[ERROR] Invalid Owner Email (Owner Email: %s), %v\n", ownerEmail, ownerUserErr)
-			return c.JSON(400, GetApplicationDataResponse{Success: false, Error: "Invalid owner email"})
-		}
+	ownerUser, ownerUserErr := client.User.FindUnique(
+		db.User.Email.Equals(ownerEmail),
+	).With(
+		db.User.CaseworkerLinks.Fetch(),
+		db.User.ApplicationData.Fetch().With(
+			application.ApplicationWith...,
+		),
+		db.User.PersonalInfo.Fetch(),
+	).Exec(context.Background())
 
-		applicationData, _ = ownerUser.ApplicationData()
-		if applicationData == nil {
-			fmt.Printf("[INFO] No application data found for user %d\n", ownerUser.ID)
-			return c.JSON(200, GetApplicationDataResponse{Success: true, HasData: false})
-		}
+	if ownerUserErr != nil || ownerUser == nil {
+		fmt.Printf("[ERROR] Invalid Owner Email (Owner Email: %s), %v\n", ownerEmail, ownerUserErr)
+		return nil, nil, 400, "Invalid owner email"
+	}
 
-		personalInfo = ownerUser.PersonalInfo()
+	applicationData, _ := ownerUser.ApplicationData()
+	if applicationData == nil {
+		fmt.Printf("[INFO] No application data found for user %d\n", ownerUser.ID)
+		return nil, nil, 200, ""
 	}
 
-	housingPreferenceRankings := make(map[string]string)
-	dbRankings := applicationData.HousingPreferenceRankings()
-	fmt.Printf("[DEBUG] Housing Preference Rankings Legnth: %d\n", len(dbRankings))
-	for _, ranking := range applicationData.HousingPreferenceRankings() {
-		housingPreferenceRankings[ranking.Preference] = ranking.Rank
+	return applicationData, ownerUser.PersonalInfo(), 200, ""
+}
+
+func GetApplicationDataHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, GetApplicationDataResponse{Success: false, Error: "Failed to authenticate"})
 	}
 
-	currentResidence, hasCurrentResidence := applicationData.CurrentResidence()
-	var currentResidenceData *application.ResidenceData = nil
-	if hasCurrentResidence {
-		data := application.ToResidenceData(currentResidence)
-		currentResidenceData = &data
+	ownerEmail := c.QueryParam("email")
+	owner, ownerErr := findOwner(client, user, ownerEmail)
+	if ownerErr != nil {
+		fmt.Printf("[ERROR] Not a caseworker for owner email: %s, %v\n", ownerEmail, ownerErr)
+		return c.JSON(400, GetApplicationDataResponse{Success: false, Error: "Not a caseworker for account"})
+	}
+
+	applicationId, ok := parseApplicationID(c.QueryParam("id"))
+	if !ok {
+		return c.JSON(400, GetApplicationDataResponse{Success: false, Error: "Invalid application id"})
+	}
+	housingApplication, status, findErr := findHousingApplication(client, owner, applicationId, false)
+	if findErr != "" {
+		return c.JSON(status, GetApplicationDataResponse{Success: false, Error: findErr})
+	}
+
+	// Get the data
+	applicationData, personalInfo, status, findErr := findApplicationData(client, owner, owner.Email)
+	if findErr != "" {
+		return c.JSON(status, GetApplicationDataResponse{Success: false, Error: findErr})
+	}
+
+	response := GetApplicationDataResponse{Success: true, HasData: false, Status: db.ApplicationStatusDraft}
+	if housingApplication != nil {
+		response.ApplicationID = housingApplication.ID
+		response.Status = housingApplication.Status
+	}
+	if applicationData == nil {
+		c.Response().Header().Set("ETag", versionETag(0))
+		return c.JSON(200, response)
 	}
 
 	// Parse it into horrific json compat structs
-	return c.JSON(200, GetApplicationDataResponse{
-		Success: true,
-		HasData: true,
-		Data: application.ApplicationData{
-			PersonalInfo: application.PersonalInfo{
-				FirstName:     personalInfo.FirstName,
-				LastName:      personalInfo.LastName,
-				Email:         ownerEmail,
-				Dob:           personalInfo.Dob.Format("2006-01-02"),
-				Phone:         util.WrapDefault(personalInfo.PhoneNumber, ""),
-				SSN:           applicationData.Ssn,
-				Address:       applicationData.Address,
-				Gender:        applicationData.Gender,
-				IsStudent:     applicationData.IsStudent,
-				IsVeteran:     applicationData.IsVeteran,
-				HasDisability: applicationData.HasDisability,
-			},
-			HousingPreferences: application.HousingPreferences{
-				Rankings:          housingPreferenceRankings,
-				DesiredMoveInDate: applicationData.DesiredMoveInDate,
-			},
-			Household: application.HouseholdData{
-				HasPet:                     applicationData.HasPets,
-				PetDescription:             applicationData.PetDescription,
-				IsSmoker:                   applicationData.IsSmoker,
-				MoreThanOneResidence:       applicationData.MoreThanOneResidence,
-				AbsentMembersExplanation:   applicationData.AbsentMembersExplanation,
-				AbsentMembers:              application.ToFamilyMembers(applicationData.AbsentFamilyMembers()),
-				CompositionChanges:         applicationData.CompositionChanges,
-				CompositionExplanation:     applicationData.CompositionChangeExplanation,
-				Custody:                    applicationData.Custody,
-				CustodyExplanation:         applicationData.CustodyExplanation,
-				ElderlyEligibility:         applicationData.ElderlyEligibility,
-				DisabledEligibility:        applicationData.DisabledEligibility,
-				ReceivedHudJan2010:         applicationData.ReceivedHudJan2010,
-				HudRecipients:              application.ToFamilyMembers(applicationData.HudRecipients()),
-				HudPropertyName:            applicationData.HudPropertyName,
-				NeedsAccessibility:         applicationData.NeedsAccessibility,
-				AccessibilityMembers:       application.ToFamilyMembers(applicationData.AccessibilityMembers()),
-				MobilityAccessibility:      applicationData.MobilityAccessible,
-				VisionAccessibility:        applicationData.VisionAccessible,
-				HearingAccessibility:       applicationData.HearingAccessible,
-				NeedsSpecialAccommodations: applicationData.NeedsSpecialAccommodations,
-				MembersNeedingHelp:         application.ToFamilyMembers(applicationData.MembersNeedingHelp()),
-				AccommodationDescription:   applicationData.AccommodationDescription,
-			},
-			History: application.HistoryData{
-				CurrentResidence:      currentResidenceData,
-				PreviousResidences:    application.ToResidenceDataList(applicationData.PreviousResidences()),
-				AssistanceTerminated:  applicationData.AssistanceTerminated,
-				AssistanceExplanation: applicationData.AssistanceExplanation,
-				Evicted:               applicationData.Evicted,
-				EvicitionExplanation:  applicationData.EvictionExplanation,
-				OwesMoney:             applicationData.OwesMoney,
-				DebtExplanation:       applicationData.DebtExplanation,
-				MakingPayments:        applicationData.MakingPayments,
-				BedBugs:               applicationData.BedBugs,
-				IsLifetimeSexOffender: applicationData.IsLifetimeSexOffender,
-				LifetimeOffenders:     application.ToFamilyMembers(applicationData.LifetimeOffenders()),
-				IsViolentOffender:     applicationData.IsViolentOffender,
-				ViolentOffenders:      application.ToFamilyMembers(applicationData.ViolentOffenders()),
-				IsMethConviction:      applicationData.IsMethConviction,
-				MethOffenders:         application.ToFamilyMembers(applicationData.MethOffenders()),
-				HasDrugCharges:        applicationData.HasDrugCharges,
-				DrugOffenders:         application.ToFamilyMembers(applicationData.DrugOffenders()),
-				OtherCrimes:           application.ToCrimeDataList(applicationData.OtherCrimes()),
-			},
-			Income: application.IncomeAndAssetsData{
-				IncomeAssetEntires:          application.ToIncomeAndAssetDataList(applicationData.IncomeAssetEntries()),
-				ReceivesGovAssistance:       applicationData.ReceivesGovAssistance,
-				AssistanceProgramName:       applicationData.AssistanceProgramName,
-				ReceivesFromCurrentProperty: applicationData.ReceivesFromCurrentProperty,
-			},
-			UploadedFiles: []application.FileUploadData{},
-		},
+	data, convertErr := application.ToApplicationData(applicationData, housingApplication, personalInfo, owner.Email)
+	if convertErr != nil {
+		fmt.Printf("[ERROR] Failed to read application data of user %d: %v\n", owner.ID, convertErr)
+		return c.JSON(500, GetApplicationDataResponse{Success: false, Error: "Failed to read application data"})
+	}
+
+	// Caseworkers have to reveal SSNs one at a time
+	if owner.ID != user.ID {
+		data.MaskSSNs()
+	}
+
+	c.Response().Header().Set("ETag", versionETag(applicationData.Version))
+	response.HasData = true
+	response.Data = data
+	response.Version = applicationData.Version
+	return c.JSON(200, response)
+}
+
+// ValidateApplicationDataHandler lists what is missing from an application
+// and how complete each section is.
+func ValidateApplicationDataHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, ValidateApplicationDataResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	ownerEmail := c.QueryParam("email")
+	owner, ownerErr := findOwner(client, user, ownerEmail)
+	if ownerErr != nil {
+		fmt.Printf("[ERROR] Not a caseworker for owner email: %s, %v\n", ownerEmail, ownerErr)
+		return c.JSON(400, ValidateApplicationDataResponse{Success: false, Error: "Not a caseworker for account"})
+	}
+
+	applicationId, ok := parseApplicationID(c.QueryParam("id"))
+	if !ok {
+		return c.JSON(400, ValidateApplicationDataResponse{Success: false, Error: "Invalid application id"})
+	}
+	housingApplication, status, findErr := findHousingApplication(client, owner, applicationId, false)
+	if findErr != "" {
+		return c.JSON(status, ValidateApplicationDataResponse{Success: false, Error: findErr})
+	}
+
+	applicationData, personalInfo, status, findErr := findApplicationData(client, owner, owner.Email)
+	if findErr != "" {
+		return c.JSON(status, ValidateApplicationDataResponse{Success: false, Error: findErr})
+	}
+
+	// An application that hasn't been started is missing everything
+	data := application.ApplicationData{}
+	if applicationData != nil {
+		var convertErr error
+		data, convertErr = application.ToApplicationData(applicationData, housingApplication, personalInfo, owner.Email)
+		if convertErr != nil {
+			fmt.Printf("[ERROR] Failed to read application data of user %d: %v\n", owner.ID, convertErr)
+			return c.JSON(500, ValidateApplicationDataResponse{Success: false, Error: "Failed to read application data"})
+		}
+	}
+
+	return c.JSON(200, ValidateApplicationDataResponse{
+		Success:    true,
+		Validation: application.Validate(&data),
 	})
 }
 
-func resetCrimeEntries(client *db.PrismaClient, applicationRequest *application.ApplicationData, applicationDb *db.ApplicationDataModel) error {
+// encryptFields encrypts values in place before they're saved.
+func encryptFields(values ...*string) error {
+	for _, value := range values {
+		encrypted, err := encryption.Encrypt(*value)
+		if err != nil {
+			return err
+		}
+		*value = encrypted
+	}
+	return nil
+}
+
+func resetCrimeEntries(client *db.PrismaClient, applicationRequest *application.ApplicationData, applicationDb *db.ApplicationDataModel) ([]db.PrismaTransaction, error) {
 	// Delete any existing entries
 	ids := make([]int, len(applicationDb.OtherCrimes()))
 	for i, entry := range applicationDb.OtherCrimes() {
 		ids[i] = entry.ID
 	}
-	_, err := client.CrimeEntry.FindMany(
-		db.CrimeEntry.ID.In(ids),
-	).Delete().Exec(context.Background())
-	if err != nil {
-		return err
+	txs := []db.PrismaTransaction{
+		client.CrimeEntry.FindMany(
+			db.CrimeEntry.ID.In(ids),
+		).Delete().Tx(),
 	}
 
 	// Create new entries
 	for _, crime := range applicationRequest.History.OtherCrimes {
-		_, err := client.CrimeEntry.CreateOne(
+		if err := encryptFields(&crime.Year, &crime.Crime, &crime.City, &crime.State); err != nil {
+			return nil, err
+		}
+		txs = append(txs, client.CrimeEntry.CreateOne(
 			db.CrimeEntry.FamilyMember.Link(
 				db.FamilyMember.ID.Equals(crime.FamilyMember.ID),
 			),
@@ -350,30 +390,29 @@ func resetCrimeEntries(client *db.PrismaClient, applicationRequest *application.
 			db.CrimeEntry.Crime.Set(crime.Crime),
 			db.CrimeEntry.City.Set(crime.City),
 			db.CrimeEntry.State.Set(crime.State),
-		).Exec(context.Background())
-		if err != nil {
-			return err
-		}
+		).Tx())
 	}
-	return nil
+	return txs, nil
 }
 
-func resetIncomeAssetEntries(client *db.PrismaClient, applicationRequest *application.ApplicationData, applicationDb *db.ApplicationDataModel) error {
+func resetIncomeAssetEntries(client *db.PrismaClient, applicationRequest *application.ApplicationData, applicationDb *db.ApplicationDataModel) ([]db.PrismaTransaction, error) {
 	// Delete any existing entries
 	ids := make([]int, len(applicationDb.IncomeAssetEntries()))
 	for i, entry := range applicationDb.IncomeAssetEntries() {
 		ids[i] = entry.ID
 	}
-	_, err := client.IncomeAssetEntry.FindMany(
-		db.IncomeAssetEntry.ID.In(ids),
-	).Delete().Exec(context.Background())
-	if err != nil {
-		return err
+	txs := []db.PrismaTransaction{
+		client.IncomeAssetEntry.FindMany(
+			db.IncomeAssetEntry.ID.In(ids),
+		).Delete().Tx(),
 	}
 
 	// Create new entries
 	for _, income := range applicationRequest.Income.IncomeAssetEntires {
-		_, err := client.IncomeAssetEntry.CreateOne(
+		if err := encryptFields(&income.Amount, &income.MonthlyOrValue); err != nil {
+			return nil, err
+		}
+		txs = append(txs, client.IncomeAssetEntry.CreateOne(
 			db.IncomeAssetEntry.FamilyMember.Link(
 				db.FamilyMember.ID.Equals(income.FamilyMember.ID),
 			),
@@ -386,44 +425,66 @@ func resetIncomeAssetEntries(client *db.PrismaClient, applicationRequest *applic
 			db.IncomeAssetEntry.Amount.Set(income.Amount),
 			db.IncomeAssetEntry.FrequencyOrLocation.Set(income.FrequencyOrLocation),
 			db.IncomeAssetEntry.MonthlyOrValue.Set(income.MonthlyOrValue),
-		).Exec(context.Background())
-		if err != nil {
-			return err
-		}
+		).Tx())
 	}
-	return nil
+	return txs, nil
 }
 
-func resetPreferenceRankings(client *db.PrismaClient, applicationRequest *application.ApplicationData, applicationDb *db.ApplicationDataModel) error {
+func resetPreferenceRankings(client *db.PrismaClient, applicationRequest *application.ApplicationData, housingApplication *db.HousingApplicationModel) []db.PrismaTransaction {
 	// Delete any existing entries
-	ids := make([]int, len(applicationDb.HousingPreferenceRankings()))
-	for i, entry := range applicationDb.HousingPreferenceRankings() {
+	ids := make([]int, len(housingApplication.HousingPreferenceRankings()))
+	for i, entry := range housingApplication.HousingPreferenceRankings() {
 		ids[i] = entry.ID
 	}
-	_, err := client.HousingPreferenceRanking.FindMany(
-		db.HousingPreferenceRanking.ID.In(ids),
-	).Delete().Exec(context.Background())
-	if err != nil {
-		return err
+	txs := []db.PrismaTransaction{
+		client.HousingPreferenceRanking.FindMany(
+			db.HousingPreferenceRanking.ID.In(ids),
+		).Delete().Tx(),
 	}
 
 	// Create new entries
 	for preference_, rank := range applicationRequest.HousingPreferences.Rankings {
-		_, err := client.HousingPreferenceRanking.CreateOne(
-			db.HousingPreferenceRanking.Application.Link(
-				db.ApplicationData.ID.Equals(applicationDb.ID),
-			),
+		txs = append(txs, client.HousingPreferenceRanking.CreateOne(
 			db.HousingPreferenceRanking.Preference.Set(preference_),
 			db.HousingPreferenceRanking.Rank.Set(rank),
-		).Exec(context.Background())
-		if err != nil {
-			return err
+			db.HousingPreferenceRanking.HousingApplication.Link(
+				db.HousingApplication.ID.Equals(housingApplication.ID),
+			),
+		).Tx())
+	}
+	return txs
+}
+
+// createResidence creates a residence with its non residing members linked
+// to it. link links it to the application as either the current or a
+// previous residence.
+func createResidence(client *db.PrismaClient, residence *application.ResidenceData, link db.ResidenceInfoSetParam) db.PrismaTransaction {
+	params := []db.ResidenceInfoSetParam{
+		db.ResidenceInfo.Address.Set(residence.Address),
+		db.ResidenceInfo.City.Set(residence.City),
+		db.ResidenceInfo.State.Set(residence.State),
+		db.ResidenceInfo.ZipCode.Set(residence.ZipCode),
+		db.ResidenceInfo.DateIn.Set(residence.DateIn),
+		db.ResidenceInfo.DateOut.Set(residence.DateOut),
+		db.ResidenceInfo.LandlordName.Set(residence.LandlordName),
+		db.ResidenceInfo.LandlordPhone.Set(residence.LandlordPhone),
+		db.ResidenceInfo.MonthlyPayment.Set(residence.MonthlyPayment),
+		db.ResidenceInfo.ResidenceType.Set(residence.ResidenceType),
+		db.ResidenceInfo.OtherResidenceType.Set(residence.OtherResidenceType),
+		db.ResidenceInfo.AllReside.Set(residence.AllReside),
+		link,
+	}
+	if len(residence.NonResidingMembers) > 0 {
+		members := make([]db.FamilyMemberWhereParam, len(residence.NonResidingMembers))
+		for i, member := range residence.NonResidingMembers {
+			members[i] = db.FamilyMember.ID.Equals(member.ID)
 		}
+		params = append(params, db.ResidenceInfo.NonResidingMembers.Link(members...))
 	}
-	return nil
+	return client.ResidenceInfo.CreateOne(params...).Tx()
 }
 
-func resetResidences(client *db.PrismaClient, applicationRequest *application.ApplicationData, applicationDb *db.ApplicationDataModel) error {
+func resetResidences(client *db.PrismaClient, applicationRequest *application.ApplicationData, applicationDb *db.ApplicationDataModel) []db.PrismaTransaction {
 	// Remove any existing entries
 	ids := make([]int, len(applicationDb.PreviousResidences()))
 	for i, entry := range applicationDb.PreviousResidences() {
@@ -433,504 +494,450 @@ func resetResidences(client *db.PrismaClient, applicationRequest *application.Ap
 	if hasCurrentResidence {
 		ids = append(ids, currentResidence.ID)
 	}
-	_, err := client.ResidenceInfo.FindMany(
-		db.ResidenceInfo.ID.In(ids),
-	).Delete().Exec(context.Background())
-	if err != nil {
-		return err
+	txs := []db.PrismaTransaction{
+		client.ResidenceInfo.FindMany(
+			db.ResidenceInfo.ID.In(ids),
+		).Delete().Tx(),
 	}
 
 	// Create new entries
 	for _, residence := range applicationRequest.History.PreviousResidences {
-		// parsedTimeIn, parseInErr := util.ParseTime(residence.DateIn)
-		// parsedTimeOut, parseOutErr := util.ParseTime(residence.DateOut)
-		// if parseInErr != nil {
-		// 	parsedTimeIn = time.Now()
-		// }
-		// if parseOutErr != nil {
-		// 	parsedTimeOut = time.Now()
-		// }
-		dbResidence, err := client.ResidenceInfo.CreateOne(
-			db.ResidenceInfo.Address.Set(residence.Address),
-			db.ResidenceInfo.City.Set(residence.City),
-			db.ResidenceInfo.State.Set(residence.State),
-			db.ResidenceInfo.ZipCode.Set(residence.ZipCode),
-			db.ResidenceInfo.DateIn.Set(residence.DateIn),
-			db.ResidenceInfo.DateOut.Set(residence.DateOut),
-			db.ResidenceInfo.LandlordName.Set(residence.LandlordName),
-			db.ResidenceInfo.LandlordPhone.Set(residence.LandlordPhone),
-			db.ResidenceInfo.MonthlyPayment.Set(residence.MonthlyPayment),
-			db.ResidenceInfo.ResidenceType.Set(residence.ResidenceType),
-			db.ResidenceInfo.OtherResidenceType.Set(residence.OtherResidenceType),
-			db.ResidenceInfo.AllReside.Set(residence.AllReside),
-
-			// db.ResidenceInfo.NonResidingMembers.Link(
-			// 	db.FamilyMember.ID.In(
-			// 		nonResiding,
-			// 	),
-			// ),
-			db.ResidenceInfo.PreviousResidences.Link(
-				db.ApplicationData.ID.Equals(applicationDb.ID),
-			),
-		).Exec(context.Background())
-		if err != nil {
-			return err
-		}
-
-		for _, member := range residence.NonResidingMembers {
-			_, err := client.FamilyMember.FindUnique(
-				db.FamilyMember.ID.Equals(member.ID),
-			).Update(
-				db.FamilyMember.NonResidingMember.Link(
-					db.ResidenceInfo.ID.Equals(dbResidence.ID),
-				),
-			).Exec(context.Background())
-			if err != nil {
-				return err
-			}
-		}
+		txs = append(txs, createResidence(client, &residence, db.ResidenceInfo.PreviousResidences.Link(
+			db.ApplicationData.ID.Equals(applicationDb.ID),
+		)))
 	}
 	if applicationRequest.History.CurrentResidence != nil {
-		residence := applicationRequest.History.CurrentResidence
-		// parsedTimeIn, parseInErr := util.ParseTime(residence.DateIn)
-		// parsedTimeOut, parseOutErr := util.ParseTime(residence.DateOut)
-		// if parseInErr != nil {
-		// 	parsedTimeIn = time.Now()
-		// }
-		// if parseOutErr != nil {
-		// 	parsedTimeOut = time.Now()
-		// }
-		dbResidence, err := client.ResidenceInfo.CreateOne(
-			db.ResidenceInfo.Address.Set(residence.Address),
-			db.ResidenceInfo.City.Set(residence.City),
-			db.ResidenceInfo.State.Set(residence.State),
-			db.ResidenceInfo.ZipCode.Set(residence.ZipCode),
-			db.ResidenceInfo.DateIn.Set(residence.DateIn),
-			db.ResidenceInfo.DateOut.Set(residence.DateOut),
-			db.ResidenceInfo.LandlordName.Set(residence.LandlordName),
-			db.ResidenceInfo.LandlordPhone.Set(residence.LandlordPhone),
-			db.ResidenceInfo.MonthlyPayment.Set(residence.MonthlyPayment),
-			db.ResidenceInfo.ResidenceType.Set(residence.ResidenceType),
-			db.ResidenceInfo.OtherResidenceType.Set(residence.OtherResidenceType),
-			db.ResidenceInfo.AllReside.Set(residence.AllReside),
-
-			db.ResidenceInfo.CurrentResidence.Link(
-				db.ApplicationData.ID.Equals(applicationDb.ID),
-			),
-		).Exec(context.Background())
-		if err != nil {
-			return err
-		}
+		txs = append(txs, createResidence(client, applicationRequest.History.CurrentResidence, db.ResidenceInfo.CurrentResidence.Link(
+			db.ApplicationData.ID.Equals(applicationDb.ID),
+		)))
+	}
+	return txs
+}
 
-		for _, member := range residence.NonResidingMembers {
-			_, err := client.FamilyMember.FindUnique(
-				db.FamilyMember.ID.Equals(member.ID),
-			).Update(
-				db.FamilyMember.NonResidingMember.Link(
-					db.ResidenceInfo.ID.Equals(dbResidence.ID),
-				),
-			).Exec(context.Background())
-			if err != nil {
-				return err
-			}
+// referencedMembers lists the ids of every family member the application data
+// refers to, by the ids sent with it.
+func referencedMembers(data *application.ApplicationData) []int {
+	ids := []int{}
+	add := func(members []application.FamilyMember) {
+		for _, member := range members {
+			ids = append(ids, member.ID)
 		}
 	}
-	return nil
+	add(data.Household.AbsentMembers)
+	add(data.Household.HudRecipients)
+	add(data.Household.AccessibilityMembers)
+	add(data.Household.MembersNeedingHelp)
+	if data.History.CurrentResidence != nil {
+		add(data.History.CurrentResidence.NonResidingMembers)
+	}
+	for _, residence := range data.History.PreviousResidences {
+		add(residence.NonResidingMembers)
+	}
+	add(data.History.LifetimeOffenders)
+	add(data.History.ViolentOffenders)
+	add(data.History.MethOffenders)
+	add(data.History.DrugOffenders)
+	for _, crime := range data.History.OtherCrimes {
+		ids = append(ids, crime.FamilyMember.ID)
+	}
+	for _, entry := range data.Income.IncomeAssetEntires {
+		ids = append(ids, entry.FamilyMember.ID)
+	}
+	return ids
 }
 
-func UpdateApplicationDataHandler(c echo.Context, client *db.PrismaClient) error {
-	user := account.ValidateAuth(c, client)
-	if user == nil {
-		return c.JSON(400, UpdateApplicationDataResponse{Success: false, Error: "Failed to authenticate"})
+// checkOwnedMembers makes sure every family member the application data
+// refers to is in the owner's household, since members are linked to the
+// application by the ids sent. On failure, returns the status code and error
+// message to respond with.
+func checkOwnedMembers(client *db.PrismaClient, owner *db.UserModel, data *application.ApplicationData) (int, string) {
+	links, linksErr := client.FamilyLink.FindMany(
+		db.FamilyLink.PersonalInfoID.Equals(owner.PersonalInfoID),
+	).Exec(context.Background())
+	if linksErr != nil {
+		fmt.Printf("[ERROR] Failed to get family links for user %d: %v\n", owner.ID, linksErr)
+		return 500, "Failed to get household"
 	}
-
-	var request UpdateApplicationDataRequest
-	err := json.NewDecoder(c.Request().Body).Decode(&request)
-	if err != nil {
-		return c.JSON(500, UpdateApplicationDataResponse{Success: false, Error: "Failed to decode body"})
+	owned := map[int]bool{}
+	for _, link := range links {
+		owned[link.FamilyMemberID] = true
 	}
+	for _, id := range referencedMembers(data) {
+		if !owned[id] {
+			return 400, fmt.Sprintf("Family member %d isn't in the household", id)
+		}
+	}
+	return 200, ""
+}
 
-	pretty, prettyErr := json.MarshalIndent(request, "", "  ")
-	if prettyErr != nil {
-		fmt.Printf("[ERROR] Failed to pretty print request: %v\n", prettyErr)
-	} else {
-		fmt.Printf("[INFO] Update Application Data:\n%s\n", pretty)
+// linkMembers links each family member to the application through one of the
+// member lists, like absent members or HUD recipients. Members have to be
+// unlinked first, and checked with checkOwnedMembers.
+func linkMembers(client *db.PrismaClient, members []application.FamilyMember, link func(applicationId int) db.FamilyMemberSetParam, applicationId int) []db.PrismaTransaction {
+	txs := make([]db.PrismaTransaction, len(members))
+	for i, member := range members {
+		txs[i] = client.FamilyMember.FindUnique(
+			db.FamilyMember.ID.Equals(member.ID),
+		).Update(
+			link(applicationId),
+		).Tx()
 	}
+	return txs
+}
 
-	// Update/Create the Application Data
-	applicationData, appDataErr := client.ApplicationData.FindUnique(
-		db.ApplicationData.UserID.Equals(user.ID),
-	).With(
-		applicationWith...,
-	).Exec(context.Background())
-	if appDataErr != nil && appDataErr != db.ErrNotFound {
-		fmt.Printf("[ERROR] Failed to get application data: %v\n", appDataErr)
-		return c.JSON(500, UpdateApplicationDataResponse{Success: false, Error: "Error while attempting to retrieve application data"})
+// savePersonalInfo saves the application's part of the personal info. Name,
+// contact info and date of birth are saved with UpdatePersInfoHandler.
+func savePersonalInfo(client *db.PrismaClient, data *application.ApplicationData, applicationData *db.ApplicationDataModel) ([]db.PrismaTransaction, error) {
+	ssn, encryptErr := encryption.Encrypt(data.PersonalInfo.SSN)
+	if encryptErr != nil {
+		return nil, encryptErr
 	}
+	return []db.PrismaTransaction{
+		client.ApplicationData.FindUnique(
+			db.ApplicationData.ID.Equals(applicationData.ID),
+		).Update(
+			db.ApplicationData.Ssn.Set(ssn),
+			db.ApplicationData.Address.Set(data.PersonalInfo.Address),
+			db.ApplicationData.Gender.Set(data.PersonalInfo.Gender),
+			db.ApplicationData.IsStudent.Set(data.PersonalInfo.IsStudent),
+			db.ApplicationData.IsVeteran.Set(data.PersonalInfo.IsVeteran),
+			db.ApplicationData.HasDisability.Set(data.PersonalInfo.HasDisability),
+		).Tx(),
+	}, nil
+}
 
-	absentMembers := make([]int, len(request.Data.Household.AbsentMembers))
-	for i, member := range request.Data.Household.AbsentMembers {
-		absentMembers[i] = member.ID
+// savePreferences saves the housing preferences, which belong to the housing
+// application rather than the shared application data.
+func savePreferences(client *db.PrismaClient, data *application.ApplicationData, housingApplication *db.HousingApplicationModel) []db.PrismaTransaction {
+	txs := []db.PrismaTransaction{
+		client.HousingApplication.FindUnique(
+			db.HousingApplication.ID.Equals(housingApplication.ID),
+		).Update(
+			db.HousingApplication.DesiredMoveInDate.Set(data.HousingPreferences.DesiredMoveInDate),
+		).Tx(),
 	}
-	hudRecipients := make([]int, len(request.Data.Household.HudRecipients))
-	for i, member := range request.Data.Household.HudRecipients {
-		hudRecipients[i] = member.ID
+	return append(txs, resetPreferenceRankings(client, data, housingApplication)...)
+}
+
+func saveHousehold(client *db.PrismaClient, data *application.ApplicationData, applicationData *db.ApplicationDataModel) ([]db.PrismaTransaction, error) {
+	txs := []db.PrismaTransaction{
+		client.ApplicationData.FindUnique(
+			db.ApplicationData.ID.Equals(applicationData.ID),
+		).Update(
+			db.ApplicationData.HasPets.Set(data.Household.HasPet),
+			db.ApplicationData.PetDescription.Set(data.Household.PetDescription),
+			db.ApplicationData.IsSmoker.Set(data.Household.IsSmoker),
+			db.ApplicationData.MoreThanOneResidence.Set(data.Household.MoreThanOneResidence),
+			db.ApplicationData.AbsentMembersExplanation.Set(data.Household.AbsentMembersExplanation),
+			db.ApplicationData.AbsentFamilyMembers.Unlink(),
+			db.ApplicationData.CompositionChanges.Set(data.Household.CompositionChanges),
+			db.ApplicationData.CompositionChangeExplanation.Set(data.Household.CompositionExplanation),
+			db.ApplicationData.Custody.Set(data.Household.Custody),
+			db.ApplicationData.CustodyExplanation.Set(data.Household.CustodyExplanation),
+			db.ApplicationData.ElderlyEligibility.Set(data.Household.ElderlyEligibility),
+			db.ApplicationData.DisabledEligibility.Set(data.Household.DisabledEligibility),
+			db.ApplicationData.ReceivedHudJan2010.Set(data.Household.ReceivedHudJan2010),
+			db.ApplicationData.HudRecipients.Unlink(),
+			db.ApplicationData.HudPropertyName.Set(data.Household.HudPropertyName),
+			db.ApplicationData.NeedsAccessibility.Set(data.Household.NeedsAccessibility),
+			db.ApplicationData.AccessibilityMembers.Unlink(),
+			db.ApplicationData.MobilityAccessible.Set(data.Household.MobilityAccessibility),
+			db.ApplicationData.VisionAccessible.Set(data.Household.VisionAccessibility),
+			db.ApplicationData.HearingAccessible.Set(data.Household.HearingAccessibility),
+			db.ApplicationData.NeedsSpecialAccommodations.Set(data.Household.NeedsSpecialAccommodations),
+			db.ApplicationData.MembersNeedingHelp.Unlink(),
+			db.ApplicationData.AccommodationDescription.Set(data.Household.AccommodationDescription),
+		).Tx(),
+	}
+
+	txs = append(txs, linkMembers(client, data.Household.AbsentMembers, func(id int) db.FamilyMemberSetParam {
+		return db.FamilyMember.AbsentFamilyMember.Link(db.ApplicationData.ID.Equals(id))
+	}, applicationData.ID)...)
+	txs = append(txs, linkMembers(client, data.Household.HudRecipients, func(id int) db.FamilyMemberSetParam {
+		return db.FamilyMember.HudRecipient.Link(db.ApplicationData.ID.Equals(id))
+	}, applicationData.ID)...)
+	txs = append(txs, linkMembers(client, data.Household.AccessibilityMembers, func(id int) db.FamilyMemberSetParam {
+		return db.FamilyMember.Accessibility.Link(db.ApplicationData.ID.Equals(id))
+	}, applicationData.ID)...)
+	txs = append(txs, linkMembers(client, data.Household.MembersNeedingHelp, func(id int) db.FamilyMemberSetParam {
+		return db.FamilyMember.MembersNeedingHelp.Link(db.ApplicationData.ID.Equals(id))
+	}, applicationData.ID)...)
+	return txs, nil
+}
+
+func saveHistory(client *db.PrismaClient, data *application.ApplicationData, applicationData *db.ApplicationDataModel) ([]db.PrismaTransaction, error) {
+	txs := []db.PrismaTransaction{
+		client.ApplicationData.FindUnique(
+			db.ApplicationData.ID.Equals(applicationData.ID),
+		).Update(
+			db.ApplicationData.AssistanceTerminated.Set(data.History.AssistanceTerminated),
+			db.ApplicationData.AssistanceExplanation.Set(data.History.AssistanceExplanation),
+			db.ApplicationData.Evicted.Set(data.History.Evicted),
+			db.ApplicationData.EvictionExplanation.Set(data.History.EvicitionExplanation),
+			db.ApplicationData.OwesMoney.Set(data.History.OwesMoney),
+			db.ApplicationData.DebtExplanation.Set(data.History.DebtExplanation),
+			db.ApplicationData.MakingPayments.Set(data.History.MakingPayments),
+			db.ApplicationData.BedBugs.Set(data.History.BedBugs),
+			db.ApplicationData.IsLifetimeSexOffender.Set(data.History.IsLifetimeSexOffender),
+			db.ApplicationData.LifetimeOffenders.Unlink(),
+			db.ApplicationData.IsViolentOffender.Set(data.History.IsViolentOffender),
+			db.ApplicationData.ViolentOffenders.Unlink(),
+			db.ApplicationData.IsMethConviction.Set(data.History.IsMethConviction),
+			db.ApplicationData.MethOffenders.Unlink(),
+			db.ApplicationData.HasDrugCharges.Set(data.History.HasDrugCharges),
+			db.ApplicationData.DrugOffenders.Unlink(),
+		).Tx(),
+	}
+
+	txs = append(txs, linkMembers(client, data.History.LifetimeOffenders, func(id int) db.FamilyMemberSetParam {
+		return db.FamilyMember.LifetimeOffender.Link(db.ApplicationData.ID.Equals(id))
+	}, applicationData.ID)...)
+	txs = append(txs, linkMembers(client, data.History.ViolentOffenders, func(id int) db.FamilyMemberSetParam {
+		return db.FamilyMember.ViolentOffender.Link(db.ApplicationData.ID.Equals(id))
+	}, applicationData.ID)...)
+	txs = append(txs, linkMembers(client, data.History.MethOffenders, func(id int) db.FamilyMemberSetParam {
+		return db.FamilyMember.MethConviction.Link(db.ApplicationData.ID.Equals(id))
+	}, applicationData.ID)...)
+	txs = append(txs, linkMembers(client, data.History.DrugOffenders, func(id int) db.FamilyMemberSetParam {
+		return db.FamilyMember.DrugConviction.Link(db.ApplicationData.ID.Equals(id))
+	}, applicationData.ID)...)
+
+	crimeTxs, crimeErr := resetCrimeEntries(client, data, applicationData)
+	if crimeErr != nil {
+		return nil, crimeErr
 	}
-	accessibilityMembers := make([]int, len(request.Data.Household.AccessibilityMembers))
-	for i, member := range request.Data.Household.AccessibilityMembers {
-		accessibilityMembers[i] = member.ID
+	txs = append(txs, crimeTxs...)
+	return append(txs, resetResidences(client, data, applicationData)...), nil
+}
+
+func saveIncome(client *db.PrismaClient, data *application.ApplicationData, applicationData *db.ApplicationDataModel) ([]db.PrismaTransaction, error) {
+	txs := []db.PrismaTransaction{
+		client.ApplicationData.FindUnique(
+			db.ApplicationData.ID.Equals(applicationData.ID),
+		).Update(
+			db.ApplicationData.ReceivesGovAssistance.Set(data.Income.ReceivesGovAssistance),
+			db.ApplicationData.AssistanceProgramName.Set(data.Income.AssistanceProgramName),
+			db.ApplicationData.ReceivesFromCurrentProperty.Set(data.Income.ReceivesFromCurrentProperty),
+		).Tx(),
 	}
-	membersNeedingHelp := make([]int, len(request.Data.Household.MembersNeedingHelp))
-	for i, member := range request.Data.Household.MembersNeedingHelp {
-		membersNeedingHelp[i] = member.ID
+
+	incomeTxs, incomeErr := resetIncomeAssetEntries(client, data, applicationData)
+	if incomeErr != nil {
+		return nil, incomeErr
 	}
-	lifetimeOffenders := make([]int, len(request.Data.History.LifetimeOffenders))
-	for i, member := range request.Data.History.LifetimeOffenders {
-		lifetimeOffenders[i] = member.ID
+	return append(txs, incomeTxs...), nil
+}
+
+// sectionSaver builds the writes that save one section of the shared
+// application data, without touching the rest of it. Nothing is written
+// until they're run in writeApplication.
+type sectionSaver func(*db.PrismaClient, *application.ApplicationData, *db.ApplicationDataModel) ([]db.PrismaTransaction, error)
+
+// sectionSavers save the whole shared application data. Preferences are saved
+// with savePreferences.
+var sectionSavers = []sectionSaver{
+	savePersonalInfo,
+	saveHousehold,
+	saveHistory,
+	saveIncome,
+}
+
+// writeApplication runs a save's writes in one transaction with the claim of
+// the next version and its snapshot, so a save either goes through with its
+// version recorded or doesn't happen at all. The application has to have
+// been at the expected version when it was read. The author is whoever made
+// the change, restoredFrom is the version it was restored from, or 0.
+// Returns the new version, or on failure, the status code and error message
+// to respond with.
+func writeApplication(client *db.PrismaClient, owner *db.UserModel, author *db.UserModel, applicationData *db.ApplicationDataModel, housingApplication *db.HousingApplicationModel, data *application.ApplicationData, expectedVersion int, restoredFrom int, writes []db.PrismaTransaction) (int, int, string) {
+	snapshot, snapshotErr := snapshotApplication(client, owner, housingApplication, data)
+	if snapshotErr != nil {
+		fmt.Printf("[ERROR] Failed to snapshot application %d: %v\n", applicationData.ID, snapshotErr)
+		return 0, 500, "Failed to save application version"
+	}
+	return commitVersion(client, author, applicationData, housingApplication, expectedVersion, restoredFrom, snapshot, writes)
+}
+
+// commitVersion is writeApplication with the snapshot already taken.
+func commitVersion(client *db.PrismaClient, author *db.UserModel, applicationData *db.ApplicationDataModel, housingApplication *db.HousingApplicationModel, expectedVersion int, restoredFrom int, snapshot string, writes []db.PrismaTransaction) (int, int, string) {
+	number := expectedVersion + 1
+	txs := []db.PrismaTransaction{claimVersion(client, applicationData.ID, expectedVersion)}
+	txs = append(txs, writes...)
+	txs = append(txs, recordVersion(client, applicationData.ID, number, housingApplication, author, restoredFrom, snapshot))
+	if txErr := client.Prisma.Transaction(txs...).Exec(context.Background()); txErr != nil {
+		// Another save recording the same version makes this one fail
+		if current, checkErr := hasVersion(client, applicationData.ID, expectedVersion); checkErr == nil && !current {
+			return 0, 409, conflictError
+		}
+		fmt.Printf("[ERROR] Failed to save application data %d: %v\n", applicationData.ID, txErr)
+		return 0, 500, "Failed to update application data"
 	}
-	violentOffenders := make([]int, len(request.Data.History.ViolentOffenders))
-	for i, member := range request.Data.History.ViolentOffenders {
-		violentOffenders[i] = member.ID
+	return number, 200, ""
+}
+
+// prepareApplicationData gets the owner's application data to save over,
+// created empty if they don't have any yet, as long as it's still at the
+// expected version, the housing application isn't locked and none of their
+// applications is waiting on a decision. On failure, returns the status code
+// and error message to respond with.
+func prepareApplicationData(client *db.PrismaClient, owner *db.UserModel, housingApplication *db.HousingApplicationModel, expectedVersion int) (*db.ApplicationDataModel, int, string) {
+	if housingApplication != nil && !application.IsEditable(housingApplication.Status) {
+		return nil, 400, lockedError
 	}
-	methOffenders := make([]int, len(request.Data.History.MethOffenders))
-	for i, member := range request.Data.History.MethOffenders {
-		methOffenders[i] = member.ID
+	if status, editErr := checkSharedDataEditable(client, owner); editErr != "" {
+		return nil, status, editErr
 	}
-	drugOffenders := make([]int, len(request.Data.History.DrugOffenders))
-	for i, member := range request.Data.History.DrugOffenders {
-		drugOffenders[i] = member.ID
+
+	applicationData, appDataErr := client.ApplicationData.FindUnique(
+		db.ApplicationData.UserID.Equals(owner.ID),
+	).With(
+		application.ApplicationWith...,
+	).Exec(context.Background())
+	if appDataErr != nil && appDataErr != db.ErrNotFound {
+		fmt.Printf("[ERROR] Failed to get application data: %v\n", appDataErr)
+		return nil, 500, "Error while attempting to retrieve application data"
 	}
 
 	if applicationData == nil {
+		// Created empty at version 0, so the first save claims version 1
+		// like any other
 		applicationData, appDataErr = client.ApplicationData.CreateOne(
 			db.ApplicationData.User.Link(
-				db.User.ID.Equals(user.ID),
+				db.User.ID.Equals(owner.ID),
 			),
-
-			db.ApplicationData.Ssn.Set(request.Data.PersonalInfo.SSN),
-			db.ApplicationData.Address.Set(request.Data.PersonalInfo.Address),
-			db.ApplicationData.Gender.Set(request.Data.PersonalInfo.Gender),
-			db.ApplicationData.IsStudent.Set(request.Data.PersonalInfo.IsStudent),
-			db.ApplicationData.IsVeteran.Set(request.Data.PersonalInfo.IsVeteran),
-			db.ApplicationData.HasDisability.Set(request.Data.PersonalInfo.HasDisability),
-
-			db.ApplicationData.DesiredMoveInDate.Set(request.Data.HousingPreferences.DesiredMoveInDate),
-
-			db.ApplicationData.HasPets.Set(request.Data.Household.HasPet),
-			db.ApplicationData.PetDescription.Set(request.Data.Household.PetDescription),
-			db.ApplicationData.IsSmoker.Set(request.Data.Household.IsSmoker),
-			db.ApplicationData.MoreThanOneResidence.Set(request.Data.Household.MoreThanOneResidence),
-			db.ApplicationData.AbsentMembersExplanation.Set(request.Data.Household.AbsentMembersExplanation),
-			// db.ApplicationData.AbsentFamilyMembers.Link(
-			// 	db.FamilyMember.ID.In(
-			// 		absentMembers,
-			// 	),
-			// ),
-			db.ApplicationData.CompositionChanges.Set(request.Data.Household.CompositionChanges),
-			db.ApplicationData.CompositionChangeExplanation.Set(request.Data.Household.CompositionExplanation),
-			db.ApplicationData.Custody.Set(request.Data.Household.Custody),
-			db.ApplicationData.CustodyExplanation.Set(request.Data.Household.CustodyExplanation),
-			db.ApplicationData.ElderlyEligibility.Set(request.Data.Household.ElderlyEligibility),
-			db.ApplicationData.DisabledEligibility.Set(request.Data.Household.DisabledEligibility),
-			db.ApplicationData.ReceivedHudJan2010.Set(request.Data.Household.ReceivedHudJan2010),
-			// db.ApplicationData.HudRecipients.Link(
-			// 	db.FamilyMember.ID.In(
-			// 		hudRecipients,
-			// 	),
-			// ),
-			db.ApplicationData.HudPropertyName.Set(request.Data.Household.HudPropertyName),
-			db.ApplicationData.NeedsAccessibility.Set(request.Data.Household.NeedsAccessibility),
-			// db.ApplicationData.AccessibilityMembers.Link(
-			// 	db.FamilyMember.ID.In(
-			// 		accessibilityMembers,
-			// 	),
-			// ),
-			db.ApplicationData.MobilityAccessible.Set(request.Data.Household.MobilityAccessibility),
-			db.ApplicationData.VisionAccessible.Set(request.Data.Household.VisionAccessibility),
-			db.ApplicationData.HearingAccessible.Set(request.Data.Household.HearingAccessibility),
-			db.ApplicationData.NeedsSpecialAccommodations.Set(request.Data.Household.NeedsSpecialAccommodations),
-			// db.ApplicationData.MembersNeedingHelp.Link(
-			// 	db.FamilyMember.ID.In(
-			// 		membersNeedingHelp,
-			// 	),
-			// ),
-			db.ApplicationData.AccommodationDescription.Set(request.Data.Household.AccommodationDescription),
-
-			db.ApplicationData.AssistanceTerminated.Set(request.Data.History.AssistanceTerminated),
-			db.ApplicationData.AssistanceExplanation.Set(request.Data.History.AssistanceExplanation),
-			db.ApplicationData.Evicted.Set(request.Data.History.Evicted),
-			db.ApplicationData.EvictionExplanation.Set(request.Data.History.EvicitionExplanation),
-			db.ApplicationData.OwesMoney.Set(request.Data.History.OwesMoney),
-			db.ApplicationData.DebtExplanation.Set(request.Data.History.DebtExplanation),
-			db.ApplicationData.MakingPayments.Set(request.Data.History.MakingPayments),
-			db.ApplicationData.BedBugs.Set(request.Data.History.BedBugs),
-			db.ApplicationData.IsLifetimeSexOffender.Set(request.Data.History.IsLifetimeSexOffender),
-			// db.ApplicationData.LifetimeOffenders.Link(
-			// 	db.FamilyMember.ID.In(
-			// 		lifetimeOffenders,
-			// 	),
-			// ),
-			db.ApplicationData.IsViolentOffender.Set(request.Data.History.IsViolentOffender),
-			// db.ApplicationData.ViolentOffenders.Link(
-			// 	db.FamilyMember.ID.In(
-			// 		violentOffenders,
-			// 	),
-			// ),
-			db.ApplicationData.IsMethConviction.Set(request.Data.History.IsMethConviction),
-			// db.ApplicationData.MethOffenders.Link(
-			// 	db.FamilyMember.ID.In(
-			// 		methOffenders,
-			// 	),
-			// ),
-			db.ApplicationData.HasDrugCharges.Set(request.Data.History.HasDrugCharges),
-			// db.ApplicationData.DrugOffenders.Link(
-			// 	db.FamilyMember.ID.In(
-			// 		drugOffenders,
-			// 	),
-			// ),
-
-			db.ApplicationData.ReceivesGovAssistance.Set(request.Data.Income.ReceivesGovAssistance),
-			db.ApplicationData.AssistanceProgramName.Set(request.Data.Income.AssistanceProgramName),
-			db.ApplicationData.ReceivesFromCurrentProperty.Set(request.Data.Income.ReceivesFromCurrentProperty),
+			db.ApplicationData.Version.Set(0),
 		).With(
-			applicationWith...,
+			application.ApplicationWith...,
 		).Exec(context.Background())
 		if appDataErr != nil {
 			fmt.Printf("[ERROR] Failed to create application data: %v\n", appDataErr)
-			return c.JSON(500, UpdateApplicationDataResponse{Success: false, Error: "Failed to create application data"})
+			return nil, 500, "Failed to create application data"
 		}
-	} else {
-		_, err := client.ApplicationData.FindUnique(
-			db.ApplicationData.ID.Equals(applicationData.ID),
-		).Update(
-			db.ApplicationData.Ssn.Set(request.Data.PersonalInfo.SSN),
-			db.ApplicationData.Address.Set(request.Data.PersonalInfo.Address),
-			db.ApplicationData.Gender.Set(request.Data.PersonalInfo.Gender),
-			db.ApplicationData.IsStudent.Set(request.Data.PersonalInfo.IsStudent),
-			db.ApplicationData.IsVeteran.Set(request.Data.PersonalInfo.IsVeteran),
-			db.ApplicationData.HasDisability.Set(request.Data.PersonalInfo.HasDisability),
-
-			db.ApplicationData.DesiredMoveInDate.Set(request.Data.HousingPreferences.DesiredMoveInDate),
-
-			db.ApplicationData.HasPets.Set(request.Data.Household.HasPet),
-			db.ApplicationData.PetDescription.Set(request.Data.Household.PetDescription),
-			db.ApplicationData.IsSmoker.Set(request.Data.Household.IsSmoker),
-			db.ApplicationData.MoreThanOneResidence.Set(request.Data.Household.MoreThanOneResidence),
-			db.ApplicationData.AbsentMembersExplanation.Set(request.Data.Household.AbsentMembersExplanation),
-			db.ApplicationData.AbsentFamilyMembers.Unlink(),
-			// db.ApplicationData.AbsentFamilyMembers.Link(
-			// 	db.FamilyMember.ID.In(
-			// 		absentMembers,
-			// 	),
-			// ),
-			db.ApplicationData.CompositionChanges.Set(request.Data.Household.CompositionChanges),
-			db.ApplicationData.CompositionChangeExplanation.Set(request.Data.Household.CompositionExplanation),
-			db.ApplicationData.Custody.Set(request.Data.Household.Custody),
-			db.ApplicationData.CustodyExplanation.Set(request.Data.Household.CustodyExplanation),
-			db.ApplicationData.ElderlyEligibility.Set(request.Data.Household.ElderlyEligibility),
-			db.ApplicationData.DisabledEligibility.Set(request.Data.Household.DisabledEligibility),
-			db.ApplicationData.ReceivedHudJan2010.Set(request.Data.Household.ReceivedHudJan2010),
-			db.ApplicationData.HudRecipients.Unlink(),
-			// db.ApplicationData.HudRecipients.Link(
-			// 	db.FamilyMember.ID.In(
-			// 		hudRecipients,
-			// 	),
-			// ),
-			db.ApplicationData.HudPropertyName.Set(request.Data.Household.HudPropertyName),
-			db.ApplicationData.NeedsAccessibility.Set(request.Data.Household.NeedsAccessibility),
-			db.ApplicationData.AccessibilityMembers.Unlink(),
-			// db.ApplicationData.AccessibilityMembers.Link(
-			// 	db.FamilyMember.ID.In(
-			// 		accessibilityMembers,
-			// 	),
-			// ),
-			db.ApplicationData.MobilityAccessible.Set(request.Data.Household.MobilityAccessibility),
-			db.ApplicationData.VisionAccessible.Set(request.Data.Household.VisionAccessibility),
-			db.ApplicationData.HearingAccessible.Set(request.Data.Household.HearingAccessibility),
-			db.ApplicationData.NeedsSpecialAccommodations.Set(request.Data.Household.NeedsSpecialAccommodations),
-			db.ApplicationData.MembersNeedingHelp.Unlink(),
-			// db.ApplicationData.MembersNeedingHelp.Link(
-			// 	db.FamilyMember.ID.In(
-			// 		membersNeedingHelp,
-			// 	),
-			// ),
-			db.ApplicationData.AccommodationDescription.Set(request.Data.Household.AccommodationDescription),
-
-			db.ApplicationData.AssistanceTerminated.Set(request.Data.History.AssistanceTerminated),
-			db.ApplicationData.AssistanceExplanation.Set(request.Data.History.AssistanceExplanation),
-			db.ApplicationData.Evicted.Set(request.Data.History.Evicted),
-			db.ApplicationData.EvictionExplanation.Set(request.Data.History.EvicitionExplanation),
-			db.ApplicationData.OwesMoney.Set(request.Data.History.OwesMoney),
-			db.ApplicationData.DebtExplanation.Set(request.Data.History.DebtExplanation),
-			db.ApplicationData.MakingPayments.Set(request.Data.History.MakingPayments),
-			db.ApplicationData.BedBugs.Set(request.Data.History.BedBugs),
-			db.ApplicationData.IsLifetimeSexOffender.Set(request.Data.History.IsLifetimeSexOffender),
-			db.ApplicationData.LifetimeOffenders.Unlink(),
-			// db.ApplicationData.LifetimeOffenders.Link(
-			// 	db.FamilyMember.ID.In(
-			// 		lifetimeOffenders,
-			// 	),
-			// ),
-			db.ApplicationData.IsViolentOffender.Set(request.Data.History.IsViolentOffender),
-			db.ApplicationData.ViolentOffenders.Unlink(),
-			// db.ApplicationData.ViolentOffenders.Link(
-			// 	db.FamilyMember.ID.In(
-			// 		violentOffenders,
-			// 	),
-			// ),
-			db.ApplicationData.IsMethConviction.Set(request.Data.History.IsMethConviction),
-			db.ApplicationData.MethOffenders.Unlink(),
-			// db.ApplicationData.MethOffenders.Link(
-			// 	db.FamilyMember.ID.In(
-			// 		methOffenders,
-			// 	),
-			// ),
-			db.ApplicationData.HasDrugCharges.Set(request.Data.History.HasDrugCharges),
-			db.ApplicationData.DrugOffenders.Unlink(),
-			// db.ApplicationData.DrugOffenders.Link(
-			// 	db.FamilyMember.ID.In(
-			// 		drugOffenders,
-			// 	),
-			// ),
-
-			db.ApplicationData.ReceivesGovAssistance.Set(request.Data.Income.ReceivesGovAssistance),
-			db.ApplicationData.AssistanceProgramName.Set(request.Data.Income.AssistanceProgramName),
-			db.ApplicationData.ReceivesFromCurrentProperty.Set(request.Data.Income.ReceivesFromCurrentProperty),
-		).Exec(context.Background())
-		if err != nil {
-			fmt.Printf("[ERROR] Failed to update application data: %v\n", err)
-			return c.JSON(500, UpdateApplicationDataResponse{Success: false, Error: "Failed to update application data"})
+	}
+	if applicationData.Version != expectedVersion {
+		return nil, 409, conflictError
+	}
+	return applicationData, 200, ""
+}
+
+// sharedDataWrites builds the writes that save the whole shared application
+// data.
+func sharedDataWrites(client *db.PrismaClient, data *application.ApplicationData, applicationData *db.ApplicationDataModel) ([]db.PrismaTransaction, error) {
+	var writes []db.PrismaTransaction
+	for _, save := range sectionSavers {
+		txs, saveErr := save(client, data, applicationData)
+		if saveErr != nil {
+			return nil, saveErr
 		}
+		writes = append(writes, txs...)
+	}
+	return writes, nil
+}
 
+// saveApplicationData creates or replaces the owner's application data, and
+// the housing application's preferences, as long as it's still at the
+// expected version and none of their applications is waiting on a decision.
+// Without a housing application, only the shared data is saved. The author
+// and restoredFrom are recorded with the new version. Returns the new
+// version, or on failure, the status code and error message to respond with.
+func saveApplicationData(client *db.PrismaClient, owner *db.UserModel, author *db.UserModel, housingApplication *db.HousingApplicationModel, data *application.ApplicationData, expectedVersion int, restoredFrom int) (int, int, string) {
+	applicationData, status, prepareErr := prepareApplicationData(client, owner, housingApplication, expectedVersion)
+	if prepareErr != "" {
+		return 0, status, prepareErr
+	}
+	if status, ownedErr := checkOwnedMembers(client, owner, data); ownedErr != "" {
+		return 0, status, ownedErr
 	}
 
-	for _, member := range request.Data.Household.AbsentMembers {
-		_, err := client.FamilyMember.FindUnique(
-			db.FamilyMember.ID.Equals(member.ID),
-		).Update(
-			db.FamilyMember.AbsentFamilyMember.Link(
-				db.ApplicationData.ID.Equals(applicationData.ID),
-			),
-		).Exec(context.Background())
-		if err != nil {
-			fmt.Printf("[ERROR] Failed to update absent family member %d: %v\n", member.ID, err)
-			return c.JSON(500, UpdateApplicationDataResponse{Success: false, Error: "Failed to update absent family member"})
-		}
+	writes, saveErr := sharedDataWrites(client, data, applicationData)
+	if saveErr != nil {
+		fmt.Printf("[ERROR] Failed to prepare application data %d: %v\n", applicationData.ID, saveErr)
+		return 0, 500, "Failed to update application data"
 	}
-	for _, member := range request.Data.Household.HudRecipients {
-		_, err := client.FamilyMember.FindUnique(
-			db.FamilyMember.ID.Equals(member.ID),
-		).Update(
-			db.FamilyMember.HudRecipient.Link(
-				db.ApplicationData.ID.Equals(applicationData.ID),
-			),
-		).Exec(context.Background())
-		if err != nil {
-			fmt.Printf("[ERROR] Failed to update hud recipient family member %d: %v\n", member.ID, err)
-			return c.JSON(500, UpdateApplicationDataResponse{Success: false, Error: "Failed to update hud recipient family member"})
-		}
+	if housingApplication != nil {
+		writes = append(writes, savePreferences(client, data, housingApplication)...)
 	}
-	for _, member := range request.Data.Household.AccessibilityMembers {
-		_, err := client.FamilyMember.FindUnique(
-			db.FamilyMember.ID.Equals(member.ID),
-		).Update(
-			db.FamilyMember.Accessibility.Link(
-				db.ApplicationData.ID.Equals(applicationData.ID),
-			),
-		).Exec(context.Background())
-		if err != nil {
-			fmt.Printf("[ERROR] Failed to update accessibility family member %d: %v\n", member.ID, err)
-			return c.JSON(500, UpdateApplicationDataResponse{Success: false, Error: "Failed to update accessibility family member"})
-		}
+	return writeApplication(client, owner, author, applicationData, housingApplication, data, expectedVersion, restoredFrom, writes)
+}
+
+func UpdateApplicationDataHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, UpdateApplicationDataResponse{Success: false, Error: "Failed to authenticate"})
 	}
-	for _, member := range request.Data.Household.MembersNeedingHelp {
-		_, err := client.FamilyMember.FindUnique(
-			db.FamilyMember.ID.Equals(member.ID),
-		).Update(
-			db.FamilyMember.MembersNeedingHelp.Link(
-				db.ApplicationData.ID.Equals(applicationData.ID),
-			),
-		).Exec(context.Background())
-		if err != nil {
-			fmt.Printf("[ERROR] Failed to update needing help family member %d: %v\n", member.ID, err)
-			return c.JSON(500, UpdateApplicationDataResponse{Success: false, Error: "Failed to update needing help family member"})
-		}
+
+	var request UpdateApplicationDataRequest
+	err := json.NewDecoder(c.Request().Body).Decode(&request)
+	if err != nil {
+		return c.JSON(500, UpdateApplicationDataResponse{Success: false, Error: "Failed to decode body"})
 	}
-	for _, member := range request.Data.History.LifetimeOffenders {
-		_, err := client.FamilyMember.FindUnique(
-			db.FamilyMember.ID.Equals(member.ID),
-		).Update(
-			db.FamilyMember.LifetimeOffender.Link(
-				db.ApplicationData.ID.Equals(applicationData.ID),
-			),
-		).Exec(context.Background())
-		if err != nil {
-			fmt.Printf("[ERROR] Failed to update lifetime offender family member %d: %v\n", member.ID, err)
-			return c.JSON(500, UpdateApplicationDataResponse{Success: false, Error: "Failed to update lifetime offender family member"})
-		}
+
+	// Both If-Match and a version in the body work, the header wins
+	expectedVersion, hasVersion := parseIfMatch(c.Request().Header.Get("If-Match"))
+	if !hasVersion && request.Version != nil {
+		expectedVersion, hasVersion = *request.Version, true
 	}
-	for _, member := range request.Data.History.ViolentOffenders {
-		_, err := client.FamilyMember.FindUnique(
-			db.FamilyMember.ID.Equals(member.ID),
-		).Update(
-			db.FamilyMember.ViolentOffender.Link(
-				db.ApplicationData.ID.Equals(applicationData.ID),
-			),
-		).Exec(context.Background())
-		if err != nil {
-			fmt.Printf("[ERROR] Failed to update violent offender family member %d: %v\n", member.ID, err)
-			return c.JSON(500, UpdateApplicationDataResponse{Success: false, Error: "Failed to update violent offender family member"})
-		}
+	if !hasVersion {
+		return c.JSON(428, UpdateApplicationDataResponse{Success: false, Error: "Missing application version, reload and try again"})
 	}
-	for _, member := range request.Data.History.MethOffenders {
-		_, err := client.FamilyMember.FindUnique(
-			db.FamilyMember.ID.Equals(member.ID),
-		).Update(
-			db.FamilyMember.MethConviction.Link(
-				db.ApplicationData.ID.Equals(applicationData.ID),
-			),
-		).Exec(context.Background())
-		if err != nil {
-			fmt.Printf("[ERROR] Failed to update meth offender family member %d: %v\n", member.ID, err)
-			return c.JSON(500, UpdateApplicationDataResponse{Success: false, Error: "Failed to update meth offender family member"})
-		}
+
+	// Caseworkers can save a client's application if the client lets them
+	owner, ownerErr := account.FindEditableOwner(client, user, request.Email)
+	if ownerErr != nil {
+		status, message := account.EditAccessError(ownerErr, request.Email)
+		return c.JSON(status, UpdateApplicationDataResponse{Success: false, Error: message})
 	}
-	for _, member := range request.Data.History.DrugOffenders {
-		_, err := client.FamilyMember.FindUnique(
-			db.FamilyMember.ID.Equals(member.ID),
-		).Update(
-			db.FamilyMember.DrugConviction.Link(
-				db.ApplicationData.ID.Equals(applicationData.ID),
-			),
-		).Exec(context.Background())
-		if err != nil {
-			fmt.Printf("[ERROR] Failed to update drug offender family member %d: %v\n", member.ID, err)
-			return c.JSON(500, UpdateApplicationDataResponse{Success: false, Error: "Failed to update drug offender family member"})
+
+	housingApplication, status, findErr := findHousingApplication(client, owner, request.ApplicationID, true)
+	if findErr != "" {
+		return c.JSON(status, UpdateApplicationDataResponse{Success: false, Error: findErr})
+	}
+
+	// What the application was, to tell the client what their caseworker changed
+	before := application.ApplicationData{}
+	if owner.ID != user.ID {
+		applicationData, personalInfo, status, findErr := findApplicationData(client, owner, owner.Email)
+		if findErr != "" {
+			return c.JSON(status, UpdateApplicationDataResponse{Success: false, Error: findErr})
 		}
+		if applicationData != nil {
+			var convertErr error
+			before, convertErr = application.ToApplicationData(applicationData, housingApplication, personalInfo, owner.Email)
+			if convertErr != nil {
+				fmt.Printf("[ERROR] Failed to read application data of user %d: %v\n", owner.ID, convertErr)
+				return c.JSON(500, UpdateApplicationDataResponse{Success: false, Error: "Failed to read application data"})
+			}
+		}
+		keepMaskedSSN(&request.Data.PersonalInfo.SSN, before.PersonalInfo.SSN)
 	}
 
-	crimeErr := resetCrimeEntries(client, &request.Data, applicationData)
-	resErr := resetResidences(client, &request.Data, applicationData)
-	prefErr := resetPreferenceRankings(client, &request.Data, applicationData)
-	incomeErr := resetIncomeAssetEntries(client, &request.Data, applicationData)
-	if crimeErr != nil {
-		fmt.Printf("[ERROR] Failed to update crime entries: %v\n", err)
-		return c.JSON(500, UpdateApplicationDataResponse{Success: false, Error: "Failed to update crime entries"})
+	// Invalid fields aren't saved, missing ones are fine until it's submitted
+	if fieldErrors := application.Normalize(&request.Data); len(fieldErrors) > 0 {
+		return c.JSON(400, UpdateApplicationDataResponse{Success: false, Error: "Invalid application data", FieldErrors: fieldErrors})
 	}
-	if resErr != nil {
-		fmt.Printf("[ERROR] Failed to update residence entries: %v\n", err)
-		return c.JSON(500, UpdateApplicationDataResponse{Success: false, Error: "Failed to update residence entries"})
+
+	number, status, saveErr := saveApplicationData(client, owner, user, housingApplication, &request.Data, expectedVersion, 0)
+	if status == 409 {
+		return respondConflict(c, client, user, owner, housingApplication.ID)
 	}
-	if prefErr != nil {
-		fmt.Printf("[ERROR] Failed to update preference rankings: %v\n", err)
-		return c.JSON(500, UpdateApplicationDataResponse{Success: false, Error: "Failed to update preference rankings"})
+	if saveErr != "" {
+		return c.JSON(status, UpdateApplicationDataResponse{Success: false, Error: saveErr})
 	}
-	if incomeErr != nil {
-		fmt.Printf("[ERROR] Failed to update income entries: %v\n", err)
-		return c.JSON(500, UpdateApplicationDataResponse{Success: false, Error: "Failed to update income entries"})
+
+	if owner.ID != user.ID {
+		changes, _ := application.Diff(&before, &request.Data)
+		audit.RecordAssistedEdit(c, client, user, owner, audit.ActionEditApplication, fmt.Sprintf("application_version:%d", number), summarizeChanges(changes))
 	}
 
-	return c.JSON(200, UpdateApplicationDataResponse{Success: true, Error: ""})
+	// Saving a partial application is fine, the validation says what's left
+	c.Response().Header().Set("ETag", versionETag(number))
+	return c.JSON(200, UpdateApplicationDataResponse{
+		Success:       true,
+		Error:         "",
+		Version:       number,
+		Validation:    application.Validate(&request.Data),
+		ApplicationID: housingApplication.ID,
+	})
 }
 
 /**
@@ -945,43 +952,172 @@ type GetFamilyMembersResponse struct {
 
 type AddFamilyMemberRequest struct {
 	Data application.FamilyMember `json:"data"`
+	// Set by caseworkers changing a client's family
+	Email string `json:"email"`
 }
 
 type AddFamilyMemberResponse struct {
-	ID      int    `json:"id"`
-	Success bool   `json:"success"`
-	Error   string `json:"error"`
+	ID      int                      `json:"id"`
+	Success bool                     `json:"success"`
+	Error   string                   `json:"error"`
+	Errors  []application.FieldIssue `json:"errors"`
+	// The application's version after the change, or 0 if it has none
+	Version int `json:"version"`
 }
 
 type UpdateFamilyMemberRequest struct {
-	Data application.FamilyMember `json:"data"`
+	Data  application.FamilyMember `json:"data"`
+	Email string                   `json:"email"`
 }
 
 type UpdateFamilyMemberResponse struct {
-	Success bool   `json:"success"`
-	Error   string `json:"error"`
+	Success bool                     `json:"success"`
+	Error   string                   `json:"error"`
+	Errors  []application.FieldIssue `json:"errors"`
+	// The application's version after the change, or 0 if it has none
+	Version int `json:"version"`
 }
 
 type DeleteFamilyMemberRequest struct {
-	ID int `json:"id"`
+	ID    int    `json:"id"`
+	Email string `json:"email"`
 }
 
 type DeleteFamilyMemberResponse struct {
 	Success bool   `json:"success"`
 	Error   string `json:"error"`
+	// The application's version after the change, or 0 if it has none
+	Version int `json:"version"`
+}
+
+// saveHouseholdChange runs the writes that change the owner's personal info
+// or household as a new version of their application, since both are part
+// of its snapshots, as long as none of their applications is waiting on a
+// decision. The snapshot is the current version's, with the change made to
+// it. Without a saved version there's nothing to snapshot, so the writes are
+// run on their own. Returns the new version, or 0 without one, or on
+// failure, the status code and error message to respond with.
+func saveHouseholdChange(client *db.PrismaClient, owner *db.UserModel, author *db.UserModel, writes []db.PrismaTransaction, change func(*db.PersonalInfoModel, map[int]application.FamilyMember, *application.ApplicationData)) (int, int, string) {
+	if status, editErr := checkSharedDataEditable(client, owner); editErr != "" {
+		return 0, status, editErr
+	}
+
+	applicationData, applicationDataErr := client.ApplicationData.FindUnique(
+		db.ApplicationData.UserID.Equals(owner.ID),
+	).Exec(context.Background())
+	if applicationDataErr != nil && applicationDataErr != db.ErrNotFound {
+		fmt.Printf("[ERROR] Failed to get application data for user %d: %v\n", owner.ID, applicationDataErr)
+		return 0, 500, "Failed to retrieve application data"
+	}
+	if applicationData == nil || applicationData.Version == 0 {
+		if txErr := client.Prisma.Transaction(writes...).Exec(context.Background()); txErr != nil {
+			fmt.Printf("[ERROR] Failed to save household of user %d: %v\n", owner.ID, txErr)
+			return 0, 500, "Failed to save household"
+		}
+		return 0, 200, ""
+	}
+
+	current, versionErr := client.ApplicationVersion.FindUnique(
+		db.ApplicationVersion.ApplicationDataIDVersion(
+			db.ApplicationVersion.ApplicationDataID.Equals(applicationData.ID),
+			db.ApplicationVersion.Version.Equals(applicationData.Version),
+		),
+	).With(
+		db.ApplicationVersion.HousingApplication.Fetch(),
+	).Exec(context.Background())
+	if versionErr != nil {
+		fmt.Printf("[ERROR] Failed to get version %d of application %d: %v\n", applicationData.Version, applicationData.ID, versionErr)
+		return 0, 500, "Failed to save application version"
+	}
+	data, decodeErr := decodeVersion(current)
+	if decodeErr != nil {
+		fmt.Printf("[ERROR] Failed to read version %d of application %d: %v\n", current.Version, applicationData.ID, decodeErr)
+		return 0, 500, "Failed to save application version"
+	}
+	housingApplication, _ := current.HousingApplication()
+
+	personalInfo, personalInfoErr := client.PersonalInfo.FindUnique(
+		db.PersonalInfo.ID.Equals(owner.PersonalInfoID),
+	).With(
+		db.PersonalInfo.FamilyLinks.Fetch().With(
+			db.FamilyLink.FamilyMember.Fetch(),
+		),
+	).Exec(context.Background())
+	if personalInfoErr != nil {
+		fmt.Printf("[ERROR] Failed to get personal info of user %d: %v\n", owner.ID, personalInfoErr)
+		return 0, 500, "Failed to save application version"
+	}
+	members, membersErr := linkedMembers(personalInfo.FamilyLinks())
+	if membersErr != nil {
+		fmt.Printf("[ERROR] Failed to read family members of user %d: %v\n", owner.ID, membersErr)
+		return 0, 500, "Failed to save application version"
+	}
+
+	change(personalInfo, members, &data)
+	snapshot, snapshotErr := encodeSnapshot(owner, personalInfo, members, housingApplication, &data)
+	if snapshotErr != nil {
+		fmt.Printf("[ERROR] Failed to snapshot application %d: %v\n", applicationData.ID, snapshotErr)
+		return 0, 500, "Failed to save application version"
+	}
+	return commitVersion(client, author, applicationData, housingApplication, applicationData.Version, 0, snapshot, writes)
 }
 
-func validateFamilyMember(member *application.FamilyMember) (birthday time.Time, err error) {
+// dropMember takes a deleted family member out of the member lists, which
+// lose them when they're deleted.
+func dropMember(data *application.ApplicationData, id int) {
+	without := func(members []application.FamilyMember) []application.FamilyMember {
+		kept := []application.FamilyMember{}
+		for _, member := range members {
+			if member.ID != id {
+				kept = append(kept, member)
+			}
+		}
+		return kept
+	}
+	data.Household.AbsentMembers = without(data.Household.AbsentMembers)
+	data.Household.HudRecipients = without(data.Household.HudRecipients)
+	data.Household.AccessibilityMembers = without(data.Household.AccessibilityMembers)
+	data.Household.MembersNeedingHelp = without(data.Household.MembersNeedingHelp)
+	if data.History.CurrentResidence != nil {
+		data.History.CurrentResidence.NonResidingMembers = without(data.History.CurrentResidence.NonResidingMembers)
+	}
+	for i := range data.History.PreviousResidences {
+		data.History.PreviousResidences[i].NonResidingMembers = without(data.History.PreviousResidences[i].NonResidingMembers)
+	}
+	data.History.LifetimeOffenders = without(data.History.LifetimeOffenders)
+	data.History.ViolentOffenders = without(data.History.ViolentOffenders)
+	data.History.MethOffenders = without(data.History.MethOffenders)
+	data.History.DrugOffenders = without(data.History.DrugOffenders)
+}
+
+// validateFamilyMember normalizes a family member's fields, returning their
+// birthday and an issue for every invalid field.
+func validateFamilyMember(member *application.FamilyMember) (time.Time, []application.FieldIssue) {
+	issues := application.NormalizeFamilyMember(member)
+
 	if !validGenders[member.Gender] {
-		err = fmt.Errorf("Invalid gender value: %v", member.Gender)
+		issues = append(issues, application.FieldIssue{Field: "gender", Severity: application.SeverityError, Message: fmt.Sprintf("Invalid gender value: %v", member.Gender)})
 	}
 
 	if !validRelationships[member.Relationship] {
-		err = fmt.Errorf("Invalid relationship value: %v", member.Relationship)
+		issues = append(issues, application.FieldIssue{Field: "relationship", Severity: application.SeverityError, Message: fmt.Sprintf("Invalid relationship value: %v", member.Relationship)})
 	}
 
-	birthday, err = util.ParseTime(member.Birthday)
-	return
+	birthday, _ := util.ParseTime(member.Birthday)
+	return birthday, issues
+}
+
+// findOwnedFamilyMember finds one of the owner's family members, with their
+// family link.
+func findOwnedFamilyMember(client *db.PrismaClient, owner *db.UserModel, id int) (*db.FamilyMemberModel, error) {
+	return client.FamilyMember.FindFirst(
+		db.FamilyMember.ID.Equals(id),
+		db.FamilyMember.FamilyLink.Where(
+			db.FamilyLink.PersonalInfoID.Equals(owner.PersonalInfoID),
+		),
+	).With(
+		db.FamilyMember.FamilyLink.Fetch(),
+	).Exec(context.Background())
 }
 
 func GetFamilyMembersHandler(c echo.Context, client *db.PrismaClient) error {
@@ -1034,7 +1170,15 @@ func GetFamilyMembersHandler(c echo.Context, client *db.PrismaClient) error {
 
 	members := make([]application.FamilyMember, len(personalData.FamilyLinks()))
 	for i, link := range personalData.FamilyLinks() {
-		members[i] = application.ToFamilyMember(link.FamilyMember())
+		member, convertErr := application.ToFamilyMember(link.FamilyMember())
+		if convertErr != nil {
+			fmt.Printf("[ERROR] Failed to read family member %d: %v\n", link.FamilyMemberID, convertErr)
+			return c.JSON(500, GetFamilyMembersResponse{Success: false, Error: "Failed to read family members"})
+		}
+		members[i] = member
+		if ownerEmail != user.Email {
+			members[i].MaskSSN()
+		}
 	}
 
 	return c.JSON(200, GetFamilyMembersResponse{
@@ -1056,17 +1200,34 @@ func AddFamilyMemberHandler(c echo.Context, client *db.PrismaClient) error {
 		return c.JSON(500, AddFamilyMemberResponse{Success: false, Error: "Invalid request"})
 	}
 
-	birthday, err := validateFamilyMember(&req.Data)
-	if err != nil {
-		fmt.Printf("[ERROR] Failed to validate family member: %v\n", err)
-		return c.JSON(500, AddFamilyMemberResponse{Success: false, Error: "Failed to validate family member"})
+	owner, ownerErr := account.FindEditableOwner(client, user, req.Email)
+	if ownerErr != nil {
+		status, message := account.EditAccessError(ownerErr, req.Email)
+		return c.JSON(status, AddFamilyMemberResponse{Success: false, Error: message})
 	}
 
+	birthday, issues := validateFamilyMember(&req.Data)
+	if len(issues) > 0 {
+		fmt.Printf("[ERROR] Failed to validate family member: %v\n", issues)
+		return c.JSON(400, AddFamilyMemberResponse{Success: false, Error: "Failed to validate family member", Errors: issues})
+	}
+	ssn, encryptErr := encryption.Encrypt(req.Data.SSN)
+	if encryptErr != nil {
+		fmt.Printf("[ERROR] Failed to encrypt family member SSN: %v\n", encryptErr)
+		return c.JSON(500, AddFamilyMemberResponse{Success: false, Error: "Failed to create family member"})
+	}
+
+	if status, editErr := checkSharedDataEditable(client, owner); editErr != "" {
+		return c.JSON(status, AddFamilyMemberResponse{Success: false, Error: editErr})
+	}
+
+	// Created on its own since linking it needs its id. It's deleted again if
+	// linking it fails.
 	member, err := client.FamilyMember.CreateOne(
 		db.FamilyMember.FirstName.Set(req.Data.FirstName),
 		db.FamilyMember.LastName.Set(req.Data.LastName),
 		db.FamilyMember.Birthday.Set(birthday),
-		db.FamilyMember.Ssn.Set(req.Data.SSN),
+		db.FamilyMember.Ssn.Set(ssn),
 		db.FamilyMember.Gender.Set(req.Data.Gender),
 		db.FamilyMember.Relationship.Set(req.Data.Relationship),
 	).Exec(context.Background())
@@ -1075,20 +1236,35 @@ func AddFamilyMemberHandler(c echo.Context, client *db.PrismaClient) error {
 		return c.JSON(500, AddFamilyMemberResponse{Success: false, Error: "Failed to create family member"})
 	}
 
-	_, err = client.FamilyLink.CreateOne(
-		db.FamilyLink.Relationship.Set(req.Data.Relationship),
-		db.FamilyLink.PersonalInfo.Link(db.PersonalInfo.ID.Equals(user.PersonalInfoID)),
-		db.FamilyLink.FamilyMember.Link(db.FamilyMember.ID.Equals(member.ID)),
-	).Exec(context.Background())
-
-	if err != nil {
-		fmt.Printf("[ERROR] Failed to create family link: %v\n", err)
-		return c.JSON(500, AddFamilyMemberResponse{Success: false, Error: "Failed to create family link"})
+	writes := []db.PrismaTransaction{
+		client.FamilyLink.CreateOne(
+			db.FamilyLink.Relationship.Set(req.Data.Relationship),
+			db.FamilyLink.PersonalInfo.Link(db.PersonalInfo.ID.Equals(owner.PersonalInfoID)),
+			db.FamilyLink.FamilyMember.Link(db.FamilyMember.ID.Equals(member.ID)),
+		).Tx(),
+	}
+	added := req.Data
+	added.ID = member.ID
+	version, status, saveErr := saveHouseholdChange(client, owner, user, writes, func(_ *db.PersonalInfoModel, members map[int]application.FamilyMember, _ *application.ApplicationData) {
+		members[added.ID] = added
+	})
+	if saveErr != "" {
+		if _, deleteErr := client.FamilyMember.FindUnique(
+			db.FamilyMember.ID.Equals(member.ID),
+		).Delete().Exec(context.Background()); deleteErr != nil {
+			fmt.Printf("[ERROR] Failed to delete unlinked family member %d: %v\n", member.ID, deleteErr)
+		}
+		return c.JSON(status, AddFamilyMemberResponse{Success: false, Error: saveErr})
 	}
 
+	audit.RecordAssistedEdit(c, client, user, owner, audit.ActionAddFamilyMember, fmt.Sprintf("family_member:%d", member.ID), []string{
+		fmt.Sprintf("Added family member %s %s", member.FirstName, member.LastName),
+	})
+
 	return c.JSON(200, AddFamilyMemberResponse{
 		ID:      member.ID,
 		Success: true,
+		Version: version,
 	})
 }
 
@@ -1105,46 +1281,71 @@ func UpdateFamilyMemberHandler(c echo.Context, client *db.PrismaClient) error {
 		return c.JSON(500, UpdateFamilyMemberResponse{Success: false, Error: "Invalid request"})
 	}
 
-	birthday, err := validateFamilyMember(&req.Data)
-	if err != nil {
-		fmt.Printf("[ERROR] Failed to validate family member: %v\n", err)
-		return c.JSON(500, UpdateFamilyMemberResponse{Success: false, Error: "Failed to validate family member"})
+	owner, ownerErr := account.FindEditableOwner(client, user, req.Email)
+	if ownerErr != nil {
+		status, message := account.EditAccessError(ownerErr, req.Email)
+		return c.JSON(status, UpdateFamilyMemberResponse{Success: false, Error: message})
 	}
 
-	member, err := client.FamilyMember.FindUnique(
-		db.FamilyMember.ID.Equals(req.Data.ID),
-	).With(
-		db.FamilyMember.FamilyLink.Fetch(),
-	).Update(
-		db.FamilyMember.FirstName.Set(req.Data.FirstName),
-		db.FamilyMember.LastName.Set(req.Data.LastName),
-		db.FamilyMember.Birthday.Set(birthday),
-		db.FamilyMember.Ssn.Set(req.Data.SSN),
-		db.FamilyMember.Gender.Set(req.Data.Gender),
-		db.FamilyMember.Relationship.Set(req.Data.Relationship),
-	).Exec(context.Background())
-	if err != nil {
-		fmt.Printf("[ERROR] Failed to update family member: %v\n", err)
+	existing, existingErr := findOwnedFamilyMember(client, owner, req.Data.ID)
+	if existingErr != nil {
+		fmt.Printf("[ERROR] Failed to find family member %d of user %d: %v\n", req.Data.ID, owner.ID, existingErr)
+		return c.JSON(400, UpdateFamilyMemberResponse{Success: false, Error: "Failed to find family member"})
+	}
+	existingSSN, decryptErr := encryption.Decrypt(existing.Ssn)
+	if decryptErr != nil {
+		fmt.Printf("[ERROR] Failed to decrypt SSN of family member %d: %v\n", existing.ID, decryptErr)
+		return c.JSON(500, UpdateFamilyMemberResponse{Success: false, Error: "Failed to read family member"})
+	}
+	keepMaskedSSN(&req.Data.SSN, existingSSN)
+
+	birthday, issues := validateFamilyMember(&req.Data)
+	if len(issues) > 0 {
+		fmt.Printf("[ERROR] Failed to validate family member: %v\n", issues)
+		return c.JSON(400, UpdateFamilyMemberResponse{Success: false, Error: "Failed to validate family member", Errors: issues})
+	}
+	ssn, encryptErr := encryption.Encrypt(req.Data.SSN)
+	if encryptErr != nil {
+		fmt.Printf("[ERROR] Failed to encrypt SSN of family member %d: %v\n", existing.ID, encryptErr)
 		return c.JSON(500, UpdateFamilyMemberResponse{Success: false, Error: "Failed to update family member"})
 	}
 
-	familyLink, hasFamilyLink := member.FamilyLink()
+	familyLink, hasFamilyLink := existing.FamilyLink()
 	if !hasFamilyLink {
-		fmt.Printf("[ERROR] Family link not found for member: %v\n", err)
+		fmt.Printf("[ERROR] Family link not found for member %d\n", existing.ID)
 		return c.JSON(500, UpdateFamilyMemberResponse{Success: false, Error: "Family link not found"})
 	}
-	_, err = client.FamilyLink.FindUnique(
-		db.FamilyLink.ID.Equals(familyLink.ID),
-	).Update(
-		db.FamilyLink.Relationship.Set(req.Data.Relationship),
-	).Exec(context.Background())
-	if err != nil {
-		fmt.Printf("[ERROR] Failed to update family link: %v\n", err)
-		return c.JSON(500, UpdateFamilyMemberResponse{Success: false, Error: "Failed to update family link"})
+	writes := []db.PrismaTransaction{
+		client.FamilyMember.FindUnique(
+			db.FamilyMember.ID.Equals(existing.ID),
+		).Update(
+			db.FamilyMember.FirstName.Set(req.Data.FirstName),
+			db.FamilyMember.LastName.Set(req.Data.LastName),
+			db.FamilyMember.Birthday.Set(birthday),
+			db.FamilyMember.Ssn.Set(ssn),
+			db.FamilyMember.Gender.Set(req.Data.Gender),
+			db.FamilyMember.Relationship.Set(req.Data.Relationship),
+		).Tx(),
+		client.FamilyLink.FindUnique(
+			db.FamilyLink.ID.Equals(familyLink.ID),
+		).Update(
+			db.FamilyLink.Relationship.Set(req.Data.Relationship),
+		).Tx(),
 	}
+	version, status, saveErr := saveHouseholdChange(client, owner, user, writes, func(_ *db.PersonalInfoModel, members map[int]application.FamilyMember, _ *application.ApplicationData) {
+		members[existing.ID] = req.Data
+	})
+	if saveErr != "" {
+		return c.JSON(status, UpdateFamilyMemberResponse{Success: false, Error: saveErr})
+	}
+
+	audit.RecordAssistedEdit(c, client, user, owner, audit.ActionUpdateFamilyMember, fmt.Sprintf("family_member:%d", existing.ID), []string{
+		fmt.Sprintf("Updated family member %s %s", req.Data.FirstName, req.Data.LastName),
+	})
 	return c.JSON(200, UpdateFamilyMemberResponse{
 		Success: true,
 		Error:   "",
+		Version: version,
 	})
 }
 
@@ -1161,11 +1362,13 @@ func DeleteFamilyMemberHandler(c echo.Context, client *db.PrismaClient) error {
 		return c.JSON(500, UpdateFamilyMemberResponse{Success: false, Error: "Invalid request"})
 	}
 
-	member, err := client.FamilyMember.FindUnique(
-		db.FamilyMember.ID.Equals(req.ID),
-	).With(
-		db.FamilyMember.FamilyLink.Fetch(),
-	).Exec(context.Background())
+	owner, ownerErr := account.FindEditableOwner(client, user, req.Email)
+	if ownerErr != nil {
+		status, message := account.EditAccessError(ownerErr, req.Email)
+		return c.JSON(status, DeleteFamilyMemberResponse{Success: false, Error: message})
+	}
+
+	member, err := findOwnedFamilyMember(client, owner, req.ID)
 	if err != nil {
 		fmt.Printf("[ERROR] Failed to find family member: %v\n", err)
 		return c.JSON(500, DeleteFamilyMemberResponse{Success: false, Error: "Failed to find family member"})
@@ -1182,23 +1385,29 @@ func DeleteFamilyMemberHandler(c echo.Context, client *db.PrismaClient) error {
 		return c.JSON(500, DeleteFamilyMemberResponse{Success: false, Error: "Family link not found"})
 	}
 
-	_, err = client.FamilyLink.FindUnique(
-		db.FamilyLink.ID.Equals(familyLink.ID),
-	).Delete().Exec(context.Background())
-	if err != nil {
-		fmt.Printf("[ERROR] Failed to delete family link: %v\n", err)
-		return c.JSON(500, DeleteFamilyMemberResponse{Success: false, Error: "Failed to delete family link"})
+	writes := []db.PrismaTransaction{
+		client.FamilyLink.FindUnique(
+			db.FamilyLink.ID.Equals(familyLink.ID),
+		).Delete().Tx(),
+		client.FamilyMember.FindUnique(
+			db.FamilyMember.ID.Equals(member.ID),
+		).Delete().Tx(),
 	}
-	_, err = client.FamilyMember.FindUnique(
-		db.FamilyMember.ID.Equals(member.ID),
-	).Delete().Exec(context.Background())
-	if err != nil {
-		fmt.Printf("[ERROR] Failed to delete family member: %v\n", err)
-		return c.JSON(500, DeleteFamilyMemberResponse{Success: false, Error: "Failed to delete family member"})
+	version, status, saveErr := saveHouseholdChange(client, owner, user, writes, func(_ *db.PersonalInfoModel, members map[int]application.FamilyMember, data *application.ApplicationData) {
+		delete(members, member.ID)
+		dropMember(data, member.ID)
+	})
+	if saveErr != "" {
+		return c.JSON(status, DeleteFamilyMemberResponse{Success: false, Error: saveErr})
 	}
 
+	audit.RecordAssistedEdit(c, client, user, owner, audit.ActionDeleteFamilyMember, fmt.Sprintf("family_member:%d", member.ID), []string{
+		fmt.Sprintf("Removed family member %s %s", member.FirstName, member.LastName),
+	})
+
 	return c.JSON(200, DeleteFamilyMemberResponse{
 		Success: true,
 		Error:   "",
+		Version: version,
 	})
 }
@@ -1,6 +1,7 @@
 package data
 
 import (
+	"api/core/encryption"
 	"api/core/server/account"
 	"api/core/server/data/application"
 	testutil "api/core/test_util"
@@ -45,9 +46,11 @@ var mock_2faExpiry = time.Now().Add(time.Hour * 50)
 // then returns a function pointer for teardown
 func setup() func() {
 	accountMockTeardown := account.Mock(mock_lastAuth, mock_authCode, mock_linkCode, mock_2faCode, mock_2faExpiry)
+	encryptionMockTeardown := encryption.Mock()
 
 	return func() {
 		accountMockTeardown()
+		encryptionMockTeardown()
 	}
 }
 
@@ -107,12 +110,13 @@ func TestUpdatePersonalInfo(t *testing.T) {
 	userA.InnerUser.LastAuth = &mock_lastAuth
 	userA.InnerUser.AuthCode = &mock_authCode
 
-	phoneNumber := "5556789506"
+	phoneNumber := "+15556789506"
 	updatedInfo := mock_personalDataA
 	updatedInfo.InnerPersonalInfo.PhoneNumber = &phoneNumber
 
+	// Numbers are stored normalized
 	params := UpdatePersInfoRequest{
-		PhoneNumber: phoneNumber,
+		PhoneNumber: "(555) 678-9506",
 	}
 
 	// Mock auth validation
@@ -123,12 +127,25 @@ func TestUpdatePersonalInfo(t *testing.T) {
 		),
 	).Returns(userA)
 
+	// Nothing's pending and there's no application to version yet
+	mock.HousingApplication.Expect(
+		client.HousingApplication.FindFirst(
+			db.HousingApplication.UserID.Equals(userA.ID),
+			db.HousingApplication.Status.In(application.PendingStatuses),
+		),
+	).Errors(db.ErrNotFound)
+	mock.ApplicationData.Expect(
+		client.ApplicationData.FindUnique(
+			db.ApplicationData.UserID.Equals(userA.ID),
+		),
+	).Errors(db.ErrNotFound)
+
 	// Mock update personal info
 	mock.PersonalInfo.Expect(
 		client.PersonalInfo.FindUnique(
 			db.PersonalInfo.ID.Equals(userA.PersonalInfoID),
 		).Update(
-			db.PersonalInfo.PhoneNumber.SetOptional(&params.PhoneNumber),
+			db.PersonalInfo.PhoneNumber.SetOptional(&phoneNumber),
 		),
 	).Returns(updatedInfo)
 
@@ -147,6 +164,53 @@ func TestUpdatePersonalInfo(t *testing.T) {
 	assert.True(t, response.Success, "Unsuccessful")
 }
 
+func TestUpdatePersonalInfoLockedWhilePending(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	client, mock, ensure := db.NewMock()
+	defer ensure(t)
+
+	userA := mock_userA
+	userA.InnerUser.LastAuth = &mock_lastAuth
+	userA.InnerUser.AuthCode = &mock_authCode
+
+	mock.User.Expect(
+		client.User.FindFirst(
+			db.User.Email.Equals(userA.Email),
+			db.User.AuthCode.Equals(*userA.InnerUser.AuthCode),
+		),
+	).Returns(userA)
+
+	// The phone number is part of the submitted application, so it isn't
+	// updated
+	submitted := db.HousingApplicationModel{
+		InnerHousingApplication: db.InnerHousingApplication{
+			ID:     2,
+			UserID: userA.ID,
+			Status: db.ApplicationStatusSubmitted,
+		},
+	}
+	mock.HousingApplication.Expect(
+		client.HousingApplication.FindFirst(
+			db.HousingApplication.UserID.Equals(userA.ID),
+			db.HousingApplication.Status.In(application.PendingStatuses),
+		),
+	).Returns(submitted)
+
+	rec, c, reqErr := testutil.PrepareRequestJSON(http.MethodPost, "/api/data/personal", UpdatePersInfoRequest{PhoneNumber: "(555) 678-9506"})
+	assert.NoError(t, reqErr, "Failed to prepare request")
+	c.Request().Header.Set(account.EmailHeaderKey, userA.InnerUser.Email)
+	c.Request().Header.Set(account.AuthHeaderKey, *userA.InnerUser.AuthCode)
+	UpdatePersInfoHandler(c, client)
+
+	assert.Equal(t, 400, rec.Code, "Bad status code")
+	var response UpdatePersInfoResponse
+	unmarshalErr := json.Unmarshal(rec.Body.Bytes(), &response)
+	assert.Nil(t, unmarshalErr, "Error while parsing response body")
+	assert.Equal(t, pendingError, response.Error)
+}
+
 /**
  *  Families Tests
  */
@@ -306,3 +370,232 @@ func TestAddFamilyMemberHandler(t *testing.T) {
 // 	assert.Equal(t, "Jack", response.Family[0].FirstName)
 // 	assert.Equal(t, "Child", response.Family[0].Relationship)
 // }
+
+/**
+ *  SSN Tests
+ */
+
+func TestRevealSSNRequiresReauth(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	client, mock, ensure := db.NewMock()
+	defer ensure(t)
+
+	userA := mock_userA
+	userA.InnerUser.LastAuth = &mock_lastAuth
+	userA.InnerUser.AuthCode = &mock_authCode
+
+	mock.User.Expect(
+		client.User.FindFirst(
+			db.User.Email.Equals(userA.Email),
+			db.User.AuthCode.Equals(*userA.InnerUser.AuthCode),
+		),
+	).Returns(userA)
+
+	params := RevealSSNRequest{Email: userA.Email}
+	rec, c, reqErr := testutil.PrepareRequestJSON(http.MethodPost, "/api/data/ssn/reveal", params)
+	assert.NoError(t, reqErr, "Failed to prepare request")
+	c.Request().Header.Set(account.EmailHeaderKey, userA.InnerUser.Email)
+	c.Request().Header.Set(account.AuthHeaderKey, *userA.InnerUser.AuthCode)
+	RevealSSNHandler(c, client)
+
+	assert.Equal(t, 403, rec.Code, "Bad status code")
+	var response RevealSSNResponse
+	unmarshalErr := json.Unmarshal(rec.Body.Bytes(), &response)
+	assert.Nil(t, unmarshalErr, "Error while parsing response body")
+
+	assert.False(t, response.Success, "SSN should not be revealed without 2FA")
+	assert.True(t, response.ReauthRequired, "Should ask for 2FA")
+	assert.Empty(t, response.SSN, "SSN should not be sent")
+}
+
+/**
+ *  Concurrency Tests
+ */
+
+func TestParseIfMatch(t *testing.T) {
+	version, ok := parseIfMatch(versionETag(7))
+	assert.True(t, ok, "ETag should parse")
+	assert.Equal(t, 7, version, "Wrong version")
+
+	version, ok = parseIfMatch("W/\"3\"")
+	assert.True(t, ok, "Weak ETag should parse")
+	assert.Equal(t, 3, version, "Wrong version")
+
+	_, ok = parseIfMatch("")
+	assert.False(t, ok, "Missing header should not parse")
+	_, ok = parseIfMatch("*")
+	assert.False(t, ok, "Wildcard is not a version")
+}
+
+func TestParseApplicationID(t *testing.T) {
+	id, ok := parseApplicationID("")
+	assert.True(t, ok, "Missing id should pick the only application")
+	assert.Equal(t, 0, id, "Wrong id")
+
+	id, ok = parseApplicationID("12")
+	assert.True(t, ok, "Id should parse")
+	assert.Equal(t, 12, id, "Wrong id")
+
+	_, ok = parseApplicationID("0")
+	assert.False(t, ok, "Zero is not an application")
+	_, ok = parseApplicationID("abc")
+	assert.False(t, ok, "Letters are not an application")
+}
+
+func TestUpdateApplicationDataRequiresVersion(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	client, mock, ensure := db.NewMock()
+	defer ensure(t)
+
+	userA := mock_userA
+	userA.InnerUser.LastAuth = &mock_lastAuth
+	userA.InnerUser.AuthCode = &mock_authCode
+
+	mock.User.Expect(
+		client.User.FindFirst(
+			db.User.Email.Equals(userA.Email),
+			db.User.AuthCode.Equals(*userA.InnerUser.AuthCode),
+		),
+	).Returns(userA)
+
+	params := UpdateApplicationDataRequest{}
+	rec, c, reqErr := testutil.PrepareRequestJSON(http.MethodPost, "/api/data/application", params)
+	assert.NoError(t, reqErr, "Failed to prepare request")
+	c.Request().Header.Set(account.EmailHeaderKey, userA.InnerUser.Email)
+	c.Request().Header.Set(account.AuthHeaderKey, *userA.InnerUser.AuthCode)
+	UpdateApplicationDataHandler(c, client)
+
+	assert.Equal(t, 428, rec.Code, "Bad status code")
+	var response UpdateApplicationDataResponse
+	unmarshalErr := json.Unmarshal(rec.Body.Bytes(), &response)
+	assert.Nil(t, unmarshalErr, "Error while parsing response body")
+	assert.False(t, response.Success, "Update without a version should fail")
+}
+
+func TestPatchUnknownApplicationSection(t *testing.T) {
+	teardown := setup()
+	defer teardown()
+
+	client, mock, ensure := db.NewMock()
+	defer ensure(t)
+
+	userA := mock_userA
+	userA.InnerUser.LastAuth = &mock_lastAuth
+	userA.InnerUser.AuthCode = &mock_authCode
+
+	mock.User.Expect(
+		client.User.FindFirst(
+			db.User.Email.Equals(userA.Email),
+			db.User.AuthCode.Equals(*userA.InnerUser.AuthCode),
+		),
+	).Returns(userA)
+
+	rec, c, reqErr := testutil.PrepareRequestJSON(http.MethodPatch, "/api/data/application/pets", map[string]string{"name": "Rex"})
+	assert.NoError(t, reqErr, "Failed to prepare request")
+	c.SetParamNames("section")
+	c.SetParamValues("pets")
+	c.Request().Header.Set(account.EmailHeaderKey, userA.InnerUser.Email)
+	c.Request().Header.Set(account.AuthHeaderKey, *userA.InnerUser.AuthCode)
+	c.Request().Header.Set("If-Match", versionETag(1))
+	PatchApplicationSectionHandler(c, client)
+
+	assert.Equal(t, 404, rec.Code, "Bad status code")
+	var response UpdateApplicationDataResponse
+	unmarshalErr := json.Unmarshal(rec.Body.Bytes(), &response)
+	assert.Nil(t, unmarshalErr, "Error while parsing response body")
+	assert.False(t, response.Success, "Unknown sections should not save")
+}
+
+func TestSaveTwoAbsentMembers(t *testing.T) {
+	client, mock, ensure := db.NewMock()
+	defer ensure(t)
+
+	sibling := mock_familyLink
+	sibling.InnerFamilyLink.ID = 2
+	sibling.InnerFamilyLink.FamilyMemberID = 43
+	links := []db.FamilyLinkModel{mock_familyLink, sibling}
+	expectLinks := func() {
+		mock.FamilyLink.Expect(
+			client.FamilyLink.FindMany(
+				db.FamilyLink.PersonalInfoID.Equals(mock_userA.PersonalInfoID),
+			),
+		).ReturnsMany(links)
+	}
+
+	data := application.ApplicationData{}
+	data.Household.AbsentMembers = []application.FamilyMember{{ID: 42}, {ID: 43}}
+
+	expectLinks()
+	status, ownedErr := checkOwnedMembers(client, &mock_userA, &data)
+	assert.Equal(t, 200, status)
+	assert.Empty(t, ownedErr)
+
+	// Each absent member is linked on their own, after the list is unlinked
+	txs, saveErr := saveHousehold(client, &data, &db.ApplicationDataModel{InnerApplicationData: db.InnerApplicationData{ID: 1}})
+	assert.NoError(t, saveErr)
+	assert.Len(t, txs, 1+len(data.Household.AbsentMembers))
+
+	data.Household.AbsentMembers = append(data.Household.AbsentMembers, application.FamilyMember{ID: 99})
+	expectLinks()
+	status, ownedErr = checkOwnedMembers(client, &mock_userA, &data)
+	assert.Equal(t, 400, status)
+	assert.Contains(t, ownedErr, "isn't in the household")
+}
+
+func TestSharedDataLockedWhilePending(t *testing.T) {
+	client, mock, ensure := db.NewMock()
+	defer ensure(t)
+
+	submitted := db.HousingApplicationModel{
+		InnerHousingApplication: db.InnerHousingApplication{
+			ID:     2,
+			UserID: mock_userA.ID,
+			Status: db.ApplicationStatusSubmitted,
+		},
+	}
+	mock.HousingApplication.Expect(
+		client.HousingApplication.FindFirst(
+			db.HousingApplication.UserID.Equals(mock_userA.ID),
+			db.HousingApplication.Status.In(application.PendingStatuses),
+		),
+	).Returns(submitted)
+
+	status, editErr := checkSharedDataEditable(client, &mock_userA)
+	assert.Equal(t, 400, status, "Bad status code")
+	assert.Equal(t, pendingError, editErr, "Shared data should be locked while another application is submitted")
+}
+
+/**
+ *  Assisted Editing Tests
+ */
+
+func TestKeepMaskedSSN(t *testing.T) {
+	ssn := application.MaskSSN("123456789")
+	keepMaskedSSN(&ssn, "123456789")
+	assert.Equal(t, "123456789", ssn, "Masked SSN should be replaced by the stored one")
+
+	ssn = "987654321"
+	keepMaskedSSN(&ssn, "123456789")
+	assert.Equal(t, "987654321", ssn, "A new SSN should be kept")
+
+	ssn = application.MaskSSN("12")
+	keepMaskedSSN(&ssn, "12")
+	assert.Equal(t, "12", ssn, "A fully hidden SSN should be replaced by the stored one")
+
+	ssn = ""
+	keepMaskedSSN(&ssn, "12")
+	assert.Equal(t, "", ssn, "Clearing the SSN should be kept")
+}
+
+func TestSummarizeChanges(t *testing.T) {
+	summary := summarizeChanges([]application.FieldChange{
+		{Field: "household.pet_description", Before: "Cat", After: "Dog"},
+		{Field: "income.income_asset_entries[0].amount", Before: "100", After: "200"},
+		{Field: "income.income_asset_entries[1].amount", Before: "300", After: "400"},
+	})
+	assert.Equal(t, []string{"household: pet description", "income: income asset entries"}, summary, "Changes should be listed once per field, without values")
+}
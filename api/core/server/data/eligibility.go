@@ -0,0 +1,104 @@
+package data
+
+// Screening a client's application with a program's eligibility rules.
+
+import (
+	"api/core/server/account"
+	"api/core/server/data/application"
+	"api/core/server/eligibility"
+	"api/db"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+type CheckEligibilityResponse struct {
+	Success   bool   `json:"success"`
+	ProgramID int    `json:"program_id"`
+	Program   string `json:"program"`
+	// Whether the program's own rules were used, rather than HUD's defaults
+	CustomRules bool               `json:"custom_rules"`
+	Result      eligibility.Result `json:"result"`
+	Error       string             `json:"error"`
+}
+
+// CheckEligibilityHandler screens the user's application, or with the email
+// param, a client's, with the rules of the program in the program_id param.
+// It's only a pre-screen, caseworkers make the decision.
+func CheckEligibilityHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, CheckEligibilityResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	ownerEmail := c.QueryParam("email")
+	owner, ownerErr := findOwner(client, user, ownerEmail)
+	if ownerErr != nil {
+		fmt.Printf("[ERROR] Not a caseworker for owner email: %s\n", ownerEmail)
+		return c.JSON(400, CheckEligibilityResponse{Success: false, Error: "Not a caseworker for account"})
+	}
+
+	programId, parseErr := strconv.Atoi(c.QueryParam("program_id"))
+	if parseErr != nil {
+		return c.JSON(400, CheckEligibilityResponse{Success: false, Error: "Invalid program id"})
+	}
+	housingProgram, programErr := client.HousingProgram.FindUnique(
+		db.HousingProgram.ID.Equals(programId),
+	).Exec(context.Background())
+	if programErr == db.ErrNotFound {
+		return c.JSON(404, CheckEligibilityResponse{Success: false, Error: "Program not found"})
+	}
+	if programErr != nil {
+		fmt.Printf("[ERROR] Failed to get program %d: %v\n", programId, programErr)
+		return c.JSON(500, CheckEligibilityResponse{Success: false, Error: "Failed to get program"})
+	}
+
+	rules := eligibility.DefaultRules()
+	customRules := strings.TrimSpace(housingProgram.EligibilityRules) != ""
+	if customRules {
+		parsed, rulesErr := eligibility.Parse(housingProgram.EligibilityRules)
+		if rulesErr != nil {
+			fmt.Printf("[ERROR] Invalid eligibility rules for program %d: %v\n", programId, rulesErr)
+			return c.JSON(500, CheckEligibilityResponse{Success: false, Error: "Program's eligibility rules are invalid"})
+		}
+		rules = parsed
+	}
+
+	applicationData, personalInfo, status, findErr := findApplicationData(client, owner, owner.Email)
+	if findErr != "" {
+		return c.JSON(status, CheckEligibilityResponse{Success: false, Error: findErr})
+	}
+	data := application.ApplicationData{}
+	if applicationData != nil {
+		var convertErr error
+		data, convertErr = application.ToApplicationData(applicationData, nil, personalInfo, owner.Email)
+		if convertErr != nil {
+			fmt.Printf("[ERROR] Failed to read application data of user %d: %v\n", owner.ID, convertErr)
+			return c.JSON(500, CheckEligibilityResponse{Success: false, Error: "Failed to read application data"})
+		}
+	}
+
+	size, sizeErr := householdSize(client, owner)
+	if sizeErr != nil {
+		fmt.Printf("[ERROR] Failed to get family links for user %d: %v\n", owner.ID, sizeErr)
+		return c.JSON(500, CheckEligibilityResponse{Success: false, Error: "Failed to get household"})
+	}
+
+	result, evaluateErr := eligibility.Evaluate(rules, &data, size)
+	if evaluateErr != nil {
+		fmt.Printf("[ERROR] Failed to check eligibility for user %d: %v\n", owner.ID, evaluateErr)
+		return c.JSON(500, CheckEligibilityResponse{Success: false, Error: "Failed to check eligibility"})
+	}
+
+	return c.JSON(200, CheckEligibilityResponse{
+		Success:     true,
+		ProgramID:   housingProgram.ID,
+		Program:     housingProgram.Name,
+		CustomRules: customRules,
+		Result:      result,
+		Error:       "",
+	})
+}
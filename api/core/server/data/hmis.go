@@ -0,0 +1,425 @@
+package data
+
+// Importing a client's existing records from an agency's HMIS, so they
+// don't have to enter their history again. Caseworkers need the client's
+// consent to edit, the same as editing their application.
+
+import (
+	"api/core/encryption"
+	"api/core/server/account"
+	"api/core/server/audit"
+	"api/core/server/data/application"
+	"api/core/server/file"
+	"api/core/server/hmis"
+	"api/core/util"
+	"api/db"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"strconv"
+
+	"github.com/labstack/echo"
+)
+
+type ImportHMISResponse struct {
+	Success bool        `json:"success"`
+	DryRun  bool        `json:"dry_run"`
+	Import  hmis.Import `json:"import"`
+	// The application version saved, or for a dry run and conflicts, the
+	// version the import was planned from
+	Version     int                      `json:"version"`
+	FieldErrors []application.FieldIssue `json:"field_errors"`
+	Error       string                   `json:"error"`
+}
+
+// readHMISFiles reads every file uploaded as files, by name.
+func readHMISFiles(form *multipart.Form) (map[string][]byte, string) {
+	uploads := form.File["files"]
+	if len(uploads) == 0 {
+		return nil, "Upload the HMIS CSV files, or a zip of them"
+	}
+	files := map[string][]byte{}
+	for _, upload := range uploads {
+		src, err := upload.Open()
+		if err != nil {
+			return nil, "Failed to read uploaded file"
+		}
+		data, err := io.ReadAll(io.LimitReader(src, file.MaxFileSize+1))
+		src.Close()
+		if err != nil {
+			return nil, "Failed to read uploaded file"
+		}
+		if len(data) > file.MaxFileSize {
+			return nil, fmt.Sprintf("%s is too large", upload.Filename)
+		}
+		files[upload.Filename] = data
+	}
+	return files, ""
+}
+
+// checkImportedMembers validates the family members an import changes.
+// New ones that aren't valid are left out, along with their income, and
+// reported.
+func checkImportedMembers(plan *hmis.Import) {
+	dropped := map[int]bool{}
+	members := []hmis.MemberImport{}
+	for _, member := range plan.Members {
+		if !member.Changed {
+			members = append(members, member)
+			continue
+		}
+		_, issues := validateFamilyMember(&member.Member)
+		if len(issues) == 0 {
+			members = append(members, member)
+			continue
+		}
+		for _, issue := range issues {
+			plan.Issues = append(plan.Issues, hmis.Issue{
+				File:     "Client.csv",
+				RecordID: member.PersonalID,
+				Field:    issue.Field,
+				Severity: application.SeverityWarning,
+				Message:  fmt.Sprintf("%s %s isn't imported: %s", member.Member.FirstName, member.Member.LastName, issue.Message),
+			})
+		}
+		if !member.New {
+			// Their income still belongs to the member as they are
+			member.Changed = false
+			members = append(members, member)
+			continue
+		}
+		dropped[member.Member.ID] = true
+	}
+	plan.Members = members
+
+	entries := []application.IncomeAndAssetData{}
+	for _, entry := range plan.Data.Income.IncomeAssetEntires {
+		if !dropped[entry.FamilyMember.ID] {
+			entries = append(entries, entry)
+		}
+	}
+	plan.Data.Income.IncomeAssetEntires = entries
+}
+
+// createImportedMembers creates the import's new family members, so their
+// income entries can point to them, and moves them and their entries to
+// their saved ids. They aren't linked to the client until importWrites links
+// them in the import's transaction, so if it fails they're never seen, and
+// are deleted with deleteImportedMembers. Returns the ids created, or the
+// error message to respond with.
+func createImportedMembers(client *db.PrismaClient, plan *hmis.Import) ([]int, string) {
+	created := []int{}
+	savedIds := map[int]int{}
+	for i, imported := range plan.Members {
+		if !imported.Changed || !imported.New {
+			continue
+		}
+		member := imported.Member
+		birthday, _ := util.ParseTime(member.Birthday)
+		ssn, encryptErr := encryption.Encrypt(member.SSN)
+		if encryptErr != nil {
+			fmt.Printf("[ERROR] Failed to encrypt family member SSN: %v\n", encryptErr)
+			deleteImportedMembers(client, created)
+			return nil, "Failed to save family member"
+		}
+		saved, err := client.FamilyMember.CreateOne(
+			db.FamilyMember.FirstName.Set(member.FirstName),
+			db.FamilyMember.LastName.Set(member.LastName),
+			db.FamilyMember.Birthday.Set(birthday),
+			db.FamilyMember.Ssn.Set(ssn),
+			db.FamilyMember.Gender.Set(member.Gender),
+			db.FamilyMember.Relationship.Set(member.Relationship),
+		).Exec(context.Background())
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to create family member: %v\n", err)
+			deleteImportedMembers(client, created)
+			return nil, "Failed to create family member"
+		}
+		created = append(created, saved.ID)
+		savedIds[member.ID] = saved.ID
+		plan.Members[i].Member.ID = saved.ID
+	}
+
+	for i, entry := range plan.Data.Income.IncomeAssetEntires {
+		if id, ok := savedIds[entry.FamilyMember.ID]; ok {
+			plan.Data.Income.IncomeAssetEntires[i].FamilyMember.ID = id
+		}
+	}
+	return created, ""
+}
+
+// deleteImportedMembers deletes the family members created for an import
+// that wasn't saved.
+func deleteImportedMembers(client *db.PrismaClient, ids []int) {
+	if len(ids) == 0 {
+		return
+	}
+	_, err := client.FamilyMember.FindMany(
+		db.FamilyMember.ID.In(ids),
+	).Delete().Exec(context.Background())
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to delete unsaved family members %v: %v\n", ids, err)
+	}
+}
+
+// importWrites builds the writes that save what the import changes besides
+// the application data: the client's name and date of birth, and their
+// family. New members have to have been created with createImportedMembers.
+func importWrites(client *db.PrismaClient, plan *hmis.Import, personalInfo *db.PersonalInfoModel) ([]db.PrismaTransaction, error) {
+	txs := []db.PrismaTransaction{}
+	info := plan.Data.PersonalInfo
+	if info.FirstName != personalInfo.FirstName || info.LastName != personalInfo.LastName || info.Dob != personalInfo.Dob.Format(application.DateLayout) {
+		dob, _ := util.ParseTime(info.Dob)
+		txs = append(txs, client.PersonalInfo.FindUnique(
+			db.PersonalInfo.ID.Equals(personalInfo.ID),
+		).Update(
+			db.PersonalInfo.FirstName.Set(info.FirstName),
+			db.PersonalInfo.LastName.Set(info.LastName),
+			db.PersonalInfo.Dob.Set(dob),
+		).Tx())
+	}
+
+	for _, imported := range plan.Members {
+		if !imported.Changed {
+			continue
+		}
+		member := imported.Member
+		if imported.New {
+			txs = append(txs, client.FamilyLink.CreateOne(
+				db.FamilyLink.Relationship.Set(member.Relationship),
+				db.FamilyLink.PersonalInfo.Link(db.PersonalInfo.ID.Equals(personalInfo.ID)),
+				db.FamilyLink.FamilyMember.Link(db.FamilyMember.ID.Equals(member.ID)),
+			).Tx())
+			continue
+		}
+
+		birthday, _ := util.ParseTime(member.Birthday)
+		ssn, encryptErr := encryption.Encrypt(member.SSN)
+		if encryptErr != nil {
+			return nil, encryptErr
+		}
+		txs = append(txs, client.FamilyMember.FindUnique(
+			db.FamilyMember.ID.Equals(member.ID),
+		).Update(
+			db.FamilyMember.FirstName.Set(member.FirstName),
+			db.FamilyMember.LastName.Set(member.LastName),
+			db.FamilyMember.Birthday.Set(birthday),
+			db.FamilyMember.Ssn.Set(ssn),
+			db.FamilyMember.Gender.Set(member.Gender),
+			db.FamilyMember.Relationship.Set(member.Relationship),
+		).Tx())
+		for _, link := range personalInfo.FamilyLinks() {
+			if link.FamilyMemberID != member.ID {
+				continue
+			}
+			txs = append(txs, client.FamilyLink.FindUnique(
+				db.FamilyLink.ID.Equals(link.ID),
+			).Update(
+				db.FamilyLink.Relationship.Set(member.Relationship),
+			).Tx())
+		}
+	}
+	return txs, nil
+}
+
+// importSnapshot snapshots the application as the import saves it, with the
+// client's name, date of birth and family changed like importWrites.
+func importSnapshot(owner *db.UserModel, plan *hmis.Import, personalInfo *db.PersonalInfoModel, housingApplication *db.HousingApplicationModel) (string, error) {
+	members, membersErr := linkedMembers(personalInfo.FamilyLinks())
+	if membersErr != nil {
+		return "", membersErr
+	}
+	for _, imported := range plan.Members {
+		if imported.Changed {
+			members[imported.Member.ID] = imported.Member
+		}
+	}
+
+	saved := *personalInfo
+	saved.FirstName = plan.Data.PersonalInfo.FirstName
+	saved.LastName = plan.Data.PersonalInfo.LastName
+	if dob, err := util.ParseTime(plan.Data.PersonalInfo.Dob); err == nil {
+		saved.Dob = dob
+	}
+	return encodeSnapshot(owner, &saved, members, housingApplication, &plan.Data)
+}
+
+// ImportHMISHandler imports a client's records from the HMIS CSV files
+// uploaded as files, which can be zipped. The client is matched in HMIS by
+// name, date of birth and SSN, or by the personal_id field if more than one
+// person matches. A personal_id of someone who doesn't match the client
+// needs its identity conflict accepted. With dry_run=true, nothing is saved
+// and the response is a preview, with the version it was made from. Answers
+// that differ from what QRHome has are reported as conflicts and kept,
+// unless their keys are given as accept fields. The import is saved through
+// the housing application in the id field, if there is one, and only if the
+// application is still at the version field, which only dry runs can leave
+// out.
+func ImportHMISHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, ImportHMISResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return c.JSON(400, ImportHMISResponse{Success: false, Error: "Failed to parse form"})
+	}
+	formValue := func(key string) string {
+		if values := form.Value[key]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	dryRun := formValue("dry_run") == "true"
+	version := formValue("version")
+	if version == "" && !dryRun {
+		return c.JSON(428, ImportHMISResponse{Success: false, Error: "Missing application version, reload and try again"})
+	}
+	applicationId, ok := parseApplicationID(formValue("id"))
+	if !ok {
+		return c.JSON(400, ImportHMISResponse{Success: false, Error: "Invalid application id"})
+	}
+
+	// Caseworkers can import a client's records if the client lets them edit
+	ownerEmail := formValue("email")
+	owner, ownerErr := account.FindEditableOwner(client, user, ownerEmail)
+	if ownerErr != nil {
+		status, message := account.EditAccessError(ownerErr, ownerEmail)
+		return c.JSON(status, ImportHMISResponse{Success: false, Error: message})
+	}
+
+	// The import doesn't touch preferences, so without a housing application
+	// only the shared data is saved, rather than creating one for it
+	housingApplication, status, findErr := findHousingApplication(client, owner, applicationId, false)
+	if findErr != "" {
+		return c.JSON(status, ImportHMISResponse{Success: false, Error: findErr})
+	}
+
+	files, readErr := readHMISFiles(form)
+	if readErr != "" {
+		return c.JSON(400, ImportHMISResponse{Success: false, Error: readErr})
+	}
+	tables, tablesErr := hmis.ReadFiles(files)
+	if tablesErr != nil {
+		return c.JSON(400, ImportHMISResponse{Success: false, Error: tablesErr.Error()})
+	}
+
+	personalInfo, personalInfoErr := client.PersonalInfo.FindUnique(
+		db.PersonalInfo.ID.Equals(owner.PersonalInfoID),
+	).With(
+		db.PersonalInfo.FamilyLinks.Fetch().With(
+			db.FamilyLink.FamilyMember.Fetch(),
+		),
+	).Exec(context.Background())
+	if personalInfoErr != nil {
+		fmt.Printf("[ERROR] Failed to get personal info of user %d: %v\n", owner.ID, personalInfoErr)
+		return c.JSON(500, ImportHMISResponse{Success: false, Error: "Failed to retrieve personal info"})
+	}
+	applicationData, applicationDataErr := client.ApplicationData.FindUnique(
+		db.ApplicationData.UserID.Equals(owner.ID),
+	).With(
+		application.ApplicationWith...,
+	).Exec(context.Background())
+	if applicationDataErr != nil && applicationDataErr != db.ErrNotFound {
+		fmt.Printf("[ERROR] Failed to get application data: %v\n", applicationDataErr)
+		return c.JSON(500, ImportHMISResponse{Success: false, Error: "Failed to retrieve application data"})
+	}
+
+	existing := hmis.Existing{HasApplication: applicationData != nil}
+	expectedVersion := 0
+	if applicationData != nil {
+		var convertErr error
+		existing.Data, convertErr = application.ToApplicationData(applicationData, housingApplication, personalInfo, owner.Email)
+		if convertErr != nil {
+			fmt.Printf("[ERROR] Failed to read application data of user %d: %v\n", owner.ID, convertErr)
+			return c.JSON(500, ImportHMISResponse{Success: false, Error: "Failed to read application data"})
+		}
+		expectedVersion = applicationData.Version
+	} else {
+		existing.Data.PersonalInfo = application.PersonalInfo{
+			FirstName: personalInfo.FirstName,
+			LastName:  personalInfo.LastName,
+			Email:     owner.Email,
+			Dob:       personalInfo.Dob.Format(application.DateLayout),
+			Phone:     util.WrapDefault(personalInfo.PhoneNumber, ""),
+		}
+		existing.Data.HousingPreferences = application.ToHousingPreferences(housingApplication)
+	}
+	if version != "" && version != strconv.Itoa(expectedVersion) {
+		return c.JSON(409, ImportHMISResponse{Success: false, Version: expectedVersion, Error: conflictError})
+	}
+	for _, link := range personalInfo.FamilyLinks() {
+		member, convertErr := application.ToFamilyMember(link.FamilyMember())
+		if convertErr != nil {
+			fmt.Printf("[ERROR] Failed to read family member %d: %v\n", link.FamilyMemberID, convertErr)
+			return c.JSON(500, ImportHMISResponse{Success: false, Error: "Failed to read household"})
+		}
+		existing.Members = append(existing.Members, member)
+	}
+
+	plan, planErr := hmis.PlanImport(tables, existing, formValue("personal_id"), form.Value["accept"])
+	switch {
+	case errors.Is(planErr, hmis.ErrNoMatch):
+		return c.JSON(404, ImportHMISResponse{Success: false, Import: plan, Error: "Client not found in the HMIS files"})
+	case errors.Is(planErr, hmis.ErrAmbiguousMatch):
+		return c.JSON(409, ImportHMISResponse{Success: false, Import: plan, Error: "More than one person in the HMIS files matches the client, pick their personal id"})
+	case errors.Is(planErr, hmis.ErrIdentityMismatch):
+		return c.JSON(409, ImportHMISResponse{Success: false, Import: plan, Error: "The picked person in the HMIS files has another name, date of birth or SSN, accept the identity conflict to import them anyway"})
+	case planErr != nil:
+		fmt.Printf("[ERROR] Failed to plan HMIS import for user %d: %v\n", owner.ID, planErr)
+		return c.JSON(500, ImportHMISResponse{Success: false, Error: "Failed to import HMIS files"})
+	}
+
+	checkImportedMembers(&plan)
+	if fieldErrors := application.Normalize(&plan.Data); len(fieldErrors) > 0 {
+		return c.JSON(400, ImportHMISResponse{Success: false, Import: plan, FieldErrors: fieldErrors, Error: "Invalid application data"})
+	}
+
+	if dryRun {
+		if owner.ID != user.ID {
+			plan.Data.MaskSSNs()
+			for i := range plan.Members {
+				plan.Members[i].Member.MaskSSN()
+			}
+		}
+		return c.JSON(200, ImportHMISResponse{Success: true, DryRun: true, Import: plan, Version: expectedVersion, Error: ""})
+	}
+
+	// Everything's checked before anything's written, and then written in one
+	// transaction
+	applicationData, status, prepareErr := prepareApplicationData(client, owner, housingApplication, expectedVersion)
+	if prepareErr != "" {
+		return c.JSON(status, ImportHMISResponse{Success: false, Version: expectedVersion, Error: prepareErr})
+	}
+	created, createErr := createImportedMembers(client, &plan)
+	if createErr != "" {
+		return c.JSON(500, ImportHMISResponse{Success: false, Error: createErr})
+	}
+	writes, writesErr := importWrites(client, &plan, personalInfo)
+	sharedWrites, sharedErr := sharedDataWrites(client, &plan.Data, applicationData)
+	snapshot, snapshotErr := importSnapshot(owner, &plan, personalInfo, housingApplication)
+	if err := errors.Join(writesErr, sharedErr, snapshotErr); err != nil {
+		fmt.Printf("[ERROR] Failed to prepare HMIS import for user %d: %v\n", owner.ID, err)
+		deleteImportedMembers(client, created)
+		return c.JSON(500, ImportHMISResponse{Success: false, Error: "Failed to import HMIS files"})
+	}
+
+	number, status, saveErr := commitVersion(client, user, applicationData, housingApplication, expectedVersion, 0, snapshot, append(writes, sharedWrites...))
+	if saveErr != "" {
+		deleteImportedMembers(client, created)
+		return c.JSON(status, ImportHMISResponse{Success: false, Version: expectedVersion, Error: saveErr})
+	}
+
+	audit.RecordAssistedEdit(c, client, user, owner, audit.ActionImportHMIS, fmt.Sprintf("hmis:%s", plan.PersonalID), plan.Changes)
+
+	if owner.ID != user.ID {
+		plan.Data.MaskSSNs()
+		for i := range plan.Members {
+			plan.Members[i].Member.MaskSSN()
+		}
+	}
+	return c.JSON(200, ImportHMISResponse{Success: true, Import: plan, Version: number, Error: ""})
+}
@@ -0,0 +1,57 @@
+package data
+
+// Working out a client's household income from their application.
+
+import (
+	"api/core/server/account"
+	"api/core/server/data/application"
+	"api/db"
+	"fmt"
+
+	"github.com/labstack/echo"
+)
+
+type GetIncomeResponse struct {
+	Success bool                      `json:"success"`
+	Income  application.IncomeSummary `json:"income"`
+	Error   string                    `json:"error"`
+}
+
+// GetIncomeHandler works out the yearly and monthly income of the user's
+// household, or with the email param, a client's, for each member and in
+// total. Income is shared by all of their applications.
+func GetIncomeHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, GetIncomeResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	ownerEmail := c.QueryParam("email")
+	owner, ownerErr := findOwner(client, user, ownerEmail)
+	if ownerErr != nil {
+		fmt.Printf("[ERROR] Not a caseworker for owner email: %s\n", ownerEmail)
+		return c.JSON(400, GetIncomeResponse{Success: false, Error: "Not a caseworker for account"})
+	}
+
+	applicationData, personalInfo, status, findErr := findApplicationData(client, owner, owner.Email)
+	if findErr != "" {
+		return c.JSON(status, GetIncomeResponse{Success: false, Error: findErr})
+	}
+
+	// No application yet means no income listed
+	data := application.ApplicationData{}
+	if applicationData != nil {
+		var convertErr error
+		data, convertErr = application.ToApplicationData(applicationData, nil, personalInfo, owner.Email)
+		if convertErr != nil {
+			fmt.Printf("[ERROR] Failed to read application data of user %d: %v\n", owner.ID, convertErr)
+			return c.JSON(500, GetIncomeResponse{Success: false, Error: "Failed to read application data"})
+		}
+	}
+
+	return c.JSON(200, GetIncomeResponse{
+		Success: true,
+		Income:  application.SummarizeIncome(data.Income),
+		Error:   "",
+	})
+}
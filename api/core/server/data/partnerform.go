@@ -0,0 +1,135 @@
+package data
+
+// Filling partner agencies' own forms with a client's application.
+
+import (
+	"api/core/server/account"
+	"api/core/server/data/application"
+	"api/core/server/partnerform"
+	"api/db"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+type PartnerFormReportResponse struct {
+	Success bool               `json:"success"`
+	FormID  int                `json:"form_id"`
+	Form    string             `json:"form"`
+	Report  partnerform.Report `json:"report"`
+	Error   string             `json:"error"`
+}
+
+// fillPartnerForm fills the partner form in the form_id param for the user,
+// or with the email param, a client. Caseworkers get masked SSNs, the same as
+// in the app. On failure, returns the status code and error message to
+// respond with.
+func fillPartnerForm(c echo.Context, client *db.PrismaClient) (*db.PartnerFormModel, []byte, partnerform.Report, int, string) {
+	report := partnerform.Report{}
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return nil, nil, report, 400, "Failed to authenticate"
+	}
+
+	ownerEmail := c.QueryParam("email")
+	owner, ownerErr := findOwner(client, user, ownerEmail)
+	if ownerErr != nil {
+		fmt.Printf("[ERROR] Not a caseworker for owner email: %s\n", ownerEmail)
+		return nil, nil, report, 400, "Not a caseworker for account"
+	}
+
+	formId, parseErr := strconv.Atoi(c.QueryParam("form_id"))
+	if parseErr != nil {
+		return nil, nil, report, 400, "Invalid form id"
+	}
+	form, formErr := client.PartnerForm.FindUnique(
+		db.PartnerForm.ID.Equals(formId),
+	).Exec(context.Background())
+	if formErr == db.ErrNotFound {
+		return nil, nil, report, 404, "Partner form not found"
+	}
+	if formErr != nil {
+		fmt.Printf("[ERROR] Failed to get partner form %d: %v\n", formId, formErr)
+		return nil, nil, report, 500, "Failed to get partner form"
+	}
+	mapping, mappingErr := partnerform.Parse(form.Mapping)
+	if mappingErr != nil {
+		fmt.Printf("[ERROR] Partner form %d has an invalid mapping: %v\n", formId, mappingErr)
+		return nil, nil, report, 500, "Partner form has an invalid mapping"
+	}
+
+	applicationData, personalInfo, status, findErr := findApplicationData(client, owner, owner.Email)
+	if findErr != "" {
+		return nil, nil, report, status, findErr
+	}
+	if applicationData == nil {
+		return nil, nil, report, 404, "No application data"
+	}
+	data, convertErr := application.ToApplicationData(applicationData, nil, personalInfo, owner.Email)
+	if convertErr != nil {
+		fmt.Printf("[ERROR] Failed to read application data of user %d: %v\n", owner.ID, convertErr)
+		return nil, nil, report, 500, "Failed to read application data"
+	}
+
+	links, linksErr := client.FamilyLink.FindMany(
+		db.FamilyLink.PersonalInfoID.Equals(owner.PersonalInfoID),
+	).With(
+		db.FamilyLink.FamilyMember.Fetch(),
+	).OrderBy(
+		db.FamilyLink.ID.Order(db.SortOrderAsc),
+	).Exec(context.Background())
+	if linksErr != nil {
+		fmt.Printf("[ERROR] Failed to get family links for user %d: %v\n", owner.ID, linksErr)
+		return nil, nil, report, 500, "Failed to get household"
+	}
+	members := make([]application.FamilyMember, len(links))
+	for i, link := range links {
+		member, convertErr := application.ToFamilyMember(link.FamilyMember())
+		if convertErr != nil {
+			fmt.Printf("[ERROR] Failed to read family member %d: %v\n", link.FamilyMemberID, convertErr)
+			return nil, nil, report, 500, "Failed to read household"
+		}
+		members[i] = member
+	}
+
+	if owner.ID != user.ID {
+		data.MaskSSNs()
+		for i := range members {
+			members[i].MaskSSN()
+		}
+	}
+
+	filled, report, fillErr := partnerform.Fill(form.Pdf, mapping, partnerform.NewSource(data, members, time.Now()))
+	if fillErr != nil {
+		fmt.Printf("[ERROR] Failed to fill partner form %d for user %d: %v\n", formId, owner.ID, fillErr)
+		return nil, nil, report, 500, "Failed to fill partner form"
+	}
+	return form, filled, report, 200, ""
+}
+
+// GetPartnerFormHandler downloads a partner form filled with the client's
+// application. What's left to fill in by hand is in the report.
+func GetPartnerFormHandler(c echo.Context, client *db.PrismaClient) error {
+	form, filled, _, status, fillErr := fillPartnerForm(c, client)
+	if fillErr != "" {
+		return c.JSON(status, PartnerFormReportResponse{Success: false, Error: fillErr})
+	}
+
+	c.Response().Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", form.Filename))
+	return c.Blob(http.StatusOK, "application/pdf", filled)
+}
+
+// GetPartnerFormReportHandler lists what filling a partner form for the
+// client leaves for the caseworker: required fields that aren't mapped, or
+// that the client hasn't answered.
+func GetPartnerFormReportHandler(c echo.Context, client *db.PrismaClient) error {
+	form, _, report, status, fillErr := fillPartnerForm(c, client)
+	if fillErr != "" {
+		return c.JSON(status, PartnerFormReportResponse{Success: false, Error: fillErr})
+	}
+	return c.JSON(200, PartnerFormReportResponse{Success: true, FormID: form.ID, Form: form.Name, Report: report, Error: ""})
+}
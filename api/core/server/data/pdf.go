@@ -0,0 +1,132 @@
+package data
+
+// Downloading a client's application as a PDF to hand to housing providers.
+
+import (
+	"api/core/server/account"
+	"api/core/server/applicationpdf"
+	"api/core/server/data/application"
+	"api/db"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+type GetApplicationPDFResponse struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+}
+
+// Only the names and types of the uploaded files are listed, so the file data
+// isn't loaded.
+const uploadedDocumentsQuery = `
+SELECT f."filename" AS "filename", f."file_type" AS "file_type"
+FROM "UploadedFile" f
+WHERE f."personalInfoId" = $1
+ORDER BY f."id"`
+
+type uploadedDocumentRow struct {
+	Filename string `json:"filename"`
+	FileType string `json:"file_type"`
+}
+
+// GetApplicationPDFHandler renders the user's application, or with the email
+// param, a client's, as a PDF to print and sign. The id param picks which of
+// their applications, like getting the application data. Caseworkers get
+// masked SSNs, the same as in the app.
+func GetApplicationPDFHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, GetApplicationPDFResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	ownerEmail := c.QueryParam("email")
+	owner, ownerErr := findOwner(client, user, ownerEmail)
+	if ownerErr != nil {
+		fmt.Printf("[ERROR] Not a caseworker for owner email: %s\n", ownerEmail)
+		return c.JSON(400, GetApplicationPDFResponse{Success: false, Error: "Not a caseworker for account"})
+	}
+
+	applicationId, ok := parseApplicationID(c.QueryParam("id"))
+	if !ok {
+		return c.JSON(400, GetApplicationPDFResponse{Success: false, Error: "Invalid application id"})
+	}
+	housingApplication, status, findErr := findHousingApplication(client, owner, applicationId, false)
+	if findErr != "" {
+		return c.JSON(status, GetApplicationPDFResponse{Success: false, Error: findErr})
+	}
+
+	applicationData, personalInfo, status, findErr := findApplicationData(client, owner, owner.Email)
+	if findErr != "" {
+		return c.JSON(status, GetApplicationPDFResponse{Success: false, Error: findErr})
+	}
+	if applicationData == nil {
+		return c.JSON(404, GetApplicationPDFResponse{Success: false, Error: "No application data"})
+	}
+	data, convertErr := application.ToApplicationData(applicationData, housingApplication, personalInfo, owner.Email)
+	if convertErr != nil {
+		fmt.Printf("[ERROR] Failed to read application data of user %d: %v\n", owner.ID, convertErr)
+		return c.JSON(500, GetApplicationPDFResponse{Success: false, Error: "Failed to read application data"})
+	}
+
+	links, linksErr := client.FamilyLink.FindMany(
+		db.FamilyLink.PersonalInfoID.Equals(owner.PersonalInfoID),
+	).With(
+		db.FamilyLink.FamilyMember.Fetch(),
+	).Exec(context.Background())
+	if linksErr != nil {
+		fmt.Printf("[ERROR] Failed to get family links for user %d: %v\n", owner.ID, linksErr)
+		return c.JSON(500, GetApplicationPDFResponse{Success: false, Error: "Failed to get household"})
+	}
+	members := make([]application.FamilyMember, len(links))
+	for i, link := range links {
+		member, convertErr := application.ToFamilyMember(link.FamilyMember())
+		if convertErr != nil {
+			fmt.Printf("[ERROR] Failed to read family member %d: %v\n", link.FamilyMemberID, convertErr)
+			return c.JSON(500, GetApplicationPDFResponse{Success: false, Error: "Failed to read household"})
+		}
+		members[i] = member
+	}
+
+	// Caseworkers have to reveal SSNs one at a time
+	if owner.ID != user.ID {
+		data.MaskSSNs()
+		for i := range members {
+			members[i].MaskSSN()
+		}
+	}
+
+	var rows []uploadedDocumentRow
+	if err := client.Prisma.QueryRaw(uploadedDocumentsQuery, owner.PersonalInfoID).Exec(context.Background(), &rows); err != nil {
+		fmt.Printf("[ERROR] Failed to get documents for user %d: %v\n", owner.ID, err)
+		return c.JSON(500, GetApplicationPDFResponse{Success: false, Error: "Failed to get documents"})
+	}
+	documents := make([]applicationpdf.Document, len(rows))
+	for i, row := range rows {
+		documents[i] = applicationpdf.Document{Name: row.Filename, Type: row.FileType}
+	}
+
+	input := applicationpdf.Input{
+		Data:        data,
+		Members:     members,
+		Documents:   documents,
+		GeneratedAt: time.Now(),
+	}
+	if housingApplication != nil {
+		input.Program = housingApplication.Program
+		input.Status = strings.ToLower(strings.ReplaceAll(string(housingApplication.Status), "_", " "))
+	}
+
+	pdfData, renderErr := applicationpdf.Render(input)
+	if renderErr != nil {
+		fmt.Printf("[ERROR] Failed to render application for user %d: %v\n", owner.ID, renderErr)
+		return c.JSON(500, GetApplicationPDFResponse{Success: false, Error: "Failed to render application"})
+	}
+
+	c.Response().Header().Set("Content-Disposition", "attachment; filename=\"housing-application.pdf\"")
+	return c.Blob(http.StatusOK, "application/pdf", pdfData)
+}
@@ -0,0 +1,109 @@
+package data
+
+// Checking a client's application against a program from the catalog.
+
+import (
+	"api/core/server/account"
+	"api/core/server/data/application"
+	"api/core/server/program"
+	"api/db"
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/labstack/echo"
+)
+
+type CheckProgramResponse struct {
+	Success bool                 `json:"success"`
+	Check   program.ProgramCheck `json:"check"`
+	Error   string               `json:"error"`
+}
+
+// Only the types of the uploaded files are needed, so the file data isn't
+// loaded.
+const uploadedFileTypesQuery = `
+SELECT DISTINCT f."file_type" AS "file_type"
+FROM "UploadedFile" f
+WHERE f."personalInfoId" = $1`
+
+type fileTypeRow struct {
+	FileType string `json:"file_type"`
+}
+
+// CheckProgramHandler checks the user's application, or with the email
+// param, a client's, against the program in the program_id param. The
+// preferences come from their open application to the program, if they've
+// started one.
+func CheckProgramHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, CheckProgramResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	ownerEmail := c.QueryParam("email")
+	owner, ownerErr := findOwner(client, user, ownerEmail)
+	if ownerErr != nil {
+		fmt.Printf("[ERROR] Not a caseworker for owner email: %s\n", ownerEmail)
+		return c.JSON(400, CheckProgramResponse{Success: false, Error: "Not a caseworker for account"})
+	}
+
+	programId, parseErr := strconv.Atoi(c.QueryParam("program_id"))
+	if parseErr != nil {
+		return c.JSON(400, CheckProgramResponse{Success: false, Error: "Invalid program id"})
+	}
+	housingProgram, programErr := client.HousingProgram.FindUnique(
+		db.HousingProgram.ID.Equals(programId),
+	).Exec(context.Background())
+	if programErr == db.ErrNotFound {
+		return c.JSON(404, CheckProgramResponse{Success: false, Error: "Program not found"})
+	}
+	if programErr != nil {
+		fmt.Printf("[ERROR] Failed to get program %d: %v\n", programId, programErr)
+		return c.JSON(500, CheckProgramResponse{Success: false, Error: "Failed to get program"})
+	}
+
+	housingApplication, housingApplicationErr := client.HousingApplication.FindFirst(
+		db.HousingApplication.UserID.Equals(owner.ID),
+		db.HousingApplication.HousingProgramID.Equals(housingProgram.ID),
+		db.HousingApplication.Status.NotIn(closedStatuses),
+	).With(
+		application.HousingApplicationWith...,
+	).Exec(context.Background())
+	if housingApplicationErr != nil && housingApplicationErr != db.ErrNotFound {
+		fmt.Printf("[ERROR] Failed to get housing application for user %d: %v\n", owner.ID, housingApplicationErr)
+		return c.JSON(500, CheckProgramResponse{Success: false, Error: "Failed to retrieve application"})
+	}
+
+	applicationData, personalInfo, status, findErr := findApplicationData(client, owner, owner.Email)
+	if findErr != "" {
+		return c.JSON(status, CheckProgramResponse{Success: false, Error: findErr})
+	}
+
+	// An application that hasn't been started is missing everything
+	data := application.ApplicationData{}
+	if applicationData != nil {
+		var convertErr error
+		data, convertErr = application.ToApplicationData(applicationData, housingApplication, personalInfo, owner.Email)
+		if convertErr != nil {
+			fmt.Printf("[ERROR] Failed to read application data of user %d: %v\n", owner.ID, convertErr)
+			return c.JSON(500, CheckProgramResponse{Success: false, Error: "Failed to read application data"})
+		}
+	}
+
+	var rows []fileTypeRow
+	if err := client.Prisma.QueryRaw(uploadedFileTypesQuery, owner.PersonalInfoID).Exec(context.Background(), &rows); err != nil {
+		fmt.Printf("[ERROR] Failed to get file types for user %d: %v\n", owner.ID, err)
+		return c.JSON(500, CheckProgramResponse{Success: false, Error: "Failed to get documents"})
+	}
+	fileTypes := make([]string, len(rows))
+	for i, row := range rows {
+		fileTypes[i] = row.FileType
+	}
+
+	return c.JSON(200, CheckProgramResponse{
+		Success: true,
+		Check:   program.Check(housingProgram, &data, fileTypes),
+		Error:   "",
+	})
+}
@@ -0,0 +1,126 @@
+package data
+
+// Working out what a client would pay in subsidized housing.
+
+import (
+	"api/core/server/account"
+	"api/core/server/data/application"
+	"api/core/server/rent"
+	"api/db"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/shopspring/decimal"
+)
+
+type GetRentShareResponse struct {
+	Success       bool        `json:"success"`
+	HouseholdSize int         `json:"household_size"`
+	Rent          rent.Result `json:"rent"`
+	// Income entries that couldn't be counted, so the rent may be off
+	IncomeIssues []application.FieldIssue `json:"income_issues"`
+	Error        string                   `json:"error"`
+}
+
+// rentParams are the query params of dollar amounts the calculator takes.
+var rentParams = []string{"contract_rent", "utility_allowance", "childcare_costs", "medical_costs"}
+
+// householdMembers gets the owner's household from their family links, with
+// everyone's age today.
+func householdMembers(client *db.PrismaClient, owner *db.UserModel) ([]rent.Member, error) {
+	links, err := client.FamilyLink.FindMany(
+		db.FamilyLink.PersonalInfoID.Equals(owner.PersonalInfoID),
+	).With(
+		db.FamilyLink.FamilyMember.Fetch(),
+	).Exec(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	members := make([]rent.Member, len(links))
+	for i, link := range links {
+		members[i] = rent.Member{
+			Relationship: link.Relationship,
+			Age:          rent.Age(link.FamilyMember().Birthday, now),
+		}
+	}
+	return members, nil
+}
+
+// GetRentShareHandler works out the Total Tenant Payment of the user's
+// household, or with the email param, a client's, and their share of the
+// contract_rent param. The utility_allowance param is monthly, the
+// childcare_costs and medical_costs params are yearly.
+func GetRentShareHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, GetRentShareResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	ownerEmail := c.QueryParam("email")
+	owner, ownerErr := findOwner(client, user, ownerEmail)
+	if ownerErr != nil {
+		fmt.Printf("[ERROR] Not a caseworker for owner email: %s\n", ownerEmail)
+		return c.JSON(400, GetRentShareResponse{Success: false, Error: "Not a caseworker for account"})
+	}
+
+	amounts := map[string]decimal.Decimal{}
+	for _, param := range rentParams {
+		amount, parseErr := application.ParseAmount(c.QueryParam(param))
+		if parseErr != nil {
+			return c.JSON(400, GetRentShareResponse{Success: false, Error: fmt.Sprintf("Invalid %s", param)})
+		}
+		amounts[param] = amount
+	}
+
+	applicationData, personalInfo, status, findErr := findApplicationData(client, owner, owner.Email)
+	if findErr != "" {
+		return c.JSON(status, GetRentShareResponse{Success: false, Error: findErr})
+	}
+	data := application.ApplicationData{}
+	if applicationData != nil {
+		var convertErr error
+		data, convertErr = application.ToApplicationData(applicationData, nil, personalInfo, owner.Email)
+		if convertErr != nil {
+			fmt.Printf("[ERROR] Failed to read application data of user %d: %v\n", owner.ID, convertErr)
+			return c.JSON(500, GetRentShareResponse{Success: false, Error: "Failed to read application data"})
+		}
+	}
+
+	members, membersErr := householdMembers(client, owner)
+	if membersErr != nil {
+		fmt.Printf("[ERROR] Failed to get family links for user %d: %v\n", owner.ID, membersErr)
+		return c.JSON(500, GetRentShareResponse{Success: false, Error: "Failed to get household"})
+	}
+	// Accounts made before the owner got a family link of their own
+	if len(members) == 0 {
+		members = []rent.Member{{Relationship: rent.RelationshipSelf}}
+	}
+
+	income := application.SummarizeIncome(data.Income)
+	result := rent.Calculate(rent.Input{
+		Household: rent.Household{
+			Members:  members,
+			Elderly:  data.Household.ElderlyEligibility,
+			Disabled: data.Household.DisabledEligibility || data.PersonalInfo.HasDisability,
+		},
+		AnnualIncome:     income.Household.TotalAnnual,
+		EarnedIncome:     income.Household.EarnedAnnual,
+		AssetValue:       income.Household.AssetValue,
+		ChildcareCosts:   amounts["childcare_costs"],
+		MedicalCosts:     amounts["medical_costs"],
+		ContractRent:     amounts["contract_rent"],
+		UtilityAllowance: amounts["utility_allowance"],
+	}, rent.DefaultParams)
+
+	return c.JSON(200, GetRentShareResponse{
+		Success:       true,
+		HouseholdSize: len(members),
+		Rent:          result,
+		IncomeIssues:  income.Issues,
+		Error:         "",
+	})
+}
@@ -0,0 +1,153 @@
+package data
+
+// Saving one section of an application at a time, so the form doesn't have
+// to send the whole application on every step, and only that section's
+// tables change.
+
+import (
+	"api/core/server/account"
+	"api/core/server/audit"
+	"api/core/server/data/application"
+	"api/db"
+	"fmt"
+	"io"
+
+	"github.com/labstack/echo"
+)
+
+type applicationSection struct {
+	// The section's key in the application json
+	key string
+	// Saves the section to the shared application data. Nil for preferences,
+	// which belong to the housing application
+	save sectionSaver
+}
+
+// Sections by their name in the url
+var applicationSections = map[string]applicationSection{
+	"personal":    {"personal_info", savePersonalInfo},
+	"preferences": {"housing_preferences", nil},
+	"household":   {"household", saveHousehold},
+	"history":     {"history", saveHistory},
+	"income":      {"income", saveIncome},
+}
+
+// PatchApplicationSectionHandler applies a JSON Merge Patch to one section of
+// the user's application, or with the email param, a client's. The id param
+// picks the housing application, which can be left out with only one. Like
+// full updates, it needs the version the change started from in If-Match or
+// the version param.
+func PatchApplicationSectionHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, UpdateApplicationDataResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	section, ok := applicationSections[c.Param("section")]
+	if !ok {
+		return c.JSON(404, UpdateApplicationDataResponse{Success: false, Error: "Unknown application section"})
+	}
+
+	expectedVersion, hasVersion := parseIfMatch(c.Request().Header.Get("If-Match"))
+	if !hasVersion {
+		expectedVersion, hasVersion = parseIfMatch(c.QueryParam("version"))
+	}
+	if !hasVersion {
+		return c.JSON(428, UpdateApplicationDataResponse{Success: false, Error: "Missing application version, reload and try again"})
+	}
+
+	patch, readErr := io.ReadAll(c.Request().Body)
+	if readErr != nil {
+		return c.JSON(500, UpdateApplicationDataResponse{Success: false, Error: "Failed to read body"})
+	}
+
+	// Caseworkers can save a client's application if the client lets them
+	ownerEmail := c.QueryParam("email")
+	owner, ownerErr := account.FindEditableOwner(client, user, ownerEmail)
+	if ownerErr != nil {
+		status, message := account.EditAccessError(ownerErr, ownerEmail)
+		return c.JSON(status, UpdateApplicationDataResponse{Success: false, Error: message})
+	}
+
+	applicationId, ok := parseApplicationID(c.QueryParam("id"))
+	if !ok {
+		return c.JSON(400, UpdateApplicationDataResponse{Success: false, Error: "Invalid application id"})
+	}
+	housingApplication, status, findErr := findHousingApplication(client, owner, applicationId, false)
+	if findErr != "" {
+		return c.JSON(status, UpdateApplicationDataResponse{Success: false, Error: findErr})
+	}
+
+	applicationData, personalInfo, status, findErr := findApplicationData(client, owner, owner.Email)
+	if findErr != "" {
+		return c.JSON(status, UpdateApplicationDataResponse{Success: false, Error: findErr})
+	}
+	if applicationData == nil || housingApplication == nil {
+		return c.JSON(404, UpdateApplicationDataResponse{Success: false, Error: "Save the whole application once before saving sections"})
+	}
+	if !application.IsEditable(housingApplication.Status) {
+		return c.JSON(400, UpdateApplicationDataResponse{Success: false, Error: lockedError})
+	}
+	if section.save != nil {
+		if status, editErr := checkSharedDataEditable(client, owner); editErr != "" {
+			return c.JSON(status, UpdateApplicationDataResponse{Success: false, Error: editErr})
+		}
+	}
+	if applicationData.Version != expectedVersion {
+		return respondConflict(c, client, user, owner, housingApplication.ID)
+	}
+
+	before, convertErr := application.ToApplicationData(applicationData, housingApplication, personalInfo, owner.Email)
+	if convertErr != nil {
+		fmt.Printf("[ERROR] Failed to read application data of user %d: %v\n", owner.ID, convertErr)
+		return c.JSON(500, UpdateApplicationDataResponse{Success: false, Error: "Failed to read application data"})
+	}
+	data := before
+	if patchErr := data.MergePatch(section.key, patch); patchErr != nil {
+		return c.JSON(400, UpdateApplicationDataResponse{Success: false, Error: "Invalid merge patch"})
+	}
+	keepMaskedSSN(&data.PersonalInfo.SSN, before.PersonalInfo.SSN)
+	if fieldErrors := application.Normalize(&data); len(fieldErrors) > 0 {
+		return c.JSON(400, UpdateApplicationDataResponse{Success: false, Error: "Invalid application data", FieldErrors: fieldErrors})
+	}
+
+	if section.save != nil {
+		if status, ownedErr := checkOwnedMembers(client, owner, &data); ownedErr != "" {
+			return c.JSON(status, UpdateApplicationDataResponse{Success: false, Error: ownedErr})
+		}
+	}
+
+	var writes []db.PrismaTransaction
+	if section.save == nil {
+		writes = savePreferences(client, &data, housingApplication)
+	} else {
+		var saveErr error
+		writes, saveErr = section.save(client, &data, applicationData)
+		if saveErr != nil {
+			fmt.Printf("[ERROR] Failed to prepare application data %d: %v\n", applicationData.ID, saveErr)
+			return c.JSON(500, UpdateApplicationDataResponse{Success: false, Error: "Failed to update application data"})
+		}
+	}
+
+	number, status, saveErr := writeApplication(client, owner, user, applicationData, housingApplication, &data, expectedVersion, 0, writes)
+	if status == 409 {
+		return respondConflict(c, client, user, owner, housingApplication.ID)
+	}
+	if saveErr != "" {
+		return c.JSON(status, UpdateApplicationDataResponse{Success: false, Error: saveErr})
+	}
+
+	if owner.ID != user.ID {
+		changes, _ := application.Diff(&before, &data)
+		audit.RecordAssistedEdit(c, client, user, owner, audit.ActionEditApplication, fmt.Sprintf("application_version:%d", number), summarizeChanges(changes))
+	}
+
+	c.Response().Header().Set("ETag", versionETag(number))
+	return c.JSON(200, UpdateApplicationDataResponse{
+		Success:       true,
+		Error:         "",
+		Version:       number,
+		Validation:    application.Validate(&data),
+		ApplicationID: housingApplication.ID,
+	})
+}
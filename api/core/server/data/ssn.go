@@ -0,0 +1,94 @@
+package data
+
+// SSNs are masked when caseworkers look at a client's data. Seeing the full
+// number takes a recent 2FA re-prompt, and is audited every time.
+
+import (
+	"api/core/encryption"
+	"api/core/server/account"
+	"api/core/server/audit"
+	"api/db"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/labstack/echo"
+)
+
+type RevealSSNRequest struct {
+	Email    string `json:"email"`
+	MemberID int    `json:"member_id"`
+}
+
+type RevealSSNResponse struct {
+	Success        bool   `json:"success"`
+	SSN            string `json:"ssn"`
+	ReauthRequired bool   `json:"reauth_required"`
+	Error          string `json:"error"`
+}
+
+// RevealSSNHandler returns a full SSN, either the applicant's or, with
+// member_id, a household member's. The user has to have passed a 2FA
+// re-prompt recently.
+func RevealSSNHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, RevealSSNResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	var request RevealSSNRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&request); err != nil {
+		return c.JSON(500, RevealSSNResponse{Success: false, Error: "Failed to parse request body"})
+	}
+
+	if !account.HasRecentReauth(user) {
+		return c.JSON(403, RevealSSNResponse{Success: false, ReauthRequired: true, Error: "Confirm it's you with 2FA to see the full SSN"})
+	}
+
+	owner, ownerErr := findOwner(client, user, request.Email)
+	if ownerErr != nil {
+		fmt.Printf("[ERROR] Not a caseworker for owner email: %s\n", request.Email)
+		return c.JSON(400, RevealSSNResponse{Success: false, Error: "Not a caseworker for account"})
+	}
+
+	var storedSSN string
+	var target string
+	if request.MemberID == 0 {
+		applicationData, applicationDataErr := client.ApplicationData.FindUnique(
+			db.ApplicationData.UserID.Equals(owner.ID),
+		).Exec(context.Background())
+		if applicationDataErr != nil {
+			return c.JSON(400, RevealSSNResponse{Success: false, Error: "No SSN on file"})
+		}
+		storedSSN = applicationData.Ssn
+		target = "application"
+	} else {
+		member, memberErr := client.FamilyMember.FindFirst(
+			db.FamilyMember.ID.Equals(request.MemberID),
+			db.FamilyMember.FamilyLink.Where(
+				db.FamilyLink.PersonalInfoID.Equals(owner.PersonalInfoID),
+			),
+		).Exec(context.Background())
+		if memberErr != nil {
+			return c.JSON(400, RevealSSNResponse{Success: false, Error: "Failed to find family member"})
+		}
+		storedSSN = member.Ssn
+		target = fmt.Sprintf("family_member:%d", member.ID)
+	}
+
+	ssn, decryptErr := encryption.Decrypt(storedSSN)
+	if decryptErr != nil {
+		fmt.Printf("[ERROR] Failed to decrypt SSN for %s of user %d: %v\n", target, owner.ID, decryptErr)
+		return c.JSON(500, RevealSSNResponse{Success: false, Error: "Failed to read SSN"})
+	}
+	if ssn == "" {
+		return c.JSON(400, RevealSSNResponse{Success: false, Error: "No SSN on file"})
+	}
+
+	// Nothing is revealed without a record of it
+	if auditErr := audit.Record(c, client, user, owner.ID, audit.ActionRevealSSN, target); auditErr != nil {
+		return c.JSON(500, RevealSSNResponse{Success: false, Error: "Failed to record SSN reveal"})
+	}
+
+	return c.JSON(200, RevealSSNResponse{Success: true, SSN: ssn, Error: ""})
+}
@@ -0,0 +1,254 @@
+package data
+
+// Moving an application through its lifecycle, see application.CheckTransition
+// for who can make which change.
+
+import (
+	"api/core/server/account"
+	"api/core/server/data/application"
+	"api/db"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+type ApplicationTransitionInfo struct {
+	From       db.ApplicationStatus `json:"from"`
+	To         db.ApplicationStatus `json:"to"`
+	ActorEmail string               `json:"actor_email"`
+	Reason     string               `json:"reason"`
+	CreatedAt  time.Time            `json:"created_at"`
+}
+
+type GetApplicationStatusResponse struct {
+	Success         bool                        `json:"success"`
+	Status          db.ApplicationStatus        `json:"status"`
+	StatusChangedAt time.Time                   `json:"status_changed_at"`
+	Next            []db.ApplicationStatus      `json:"next"`
+	Transitions     []ApplicationTransitionInfo `json:"transitions"`
+	Error           string                      `json:"error"`
+}
+
+type TransitionApplicationRequest struct {
+	// Set by caseworkers changing a client's application
+	Email string `json:"email"`
+	// Can be left out by clients with only one application
+	ApplicationID int                  `json:"application_id"`
+	Status        db.ApplicationStatus `json:"status"`
+	Reason        string               `json:"reason"`
+}
+
+type TransitionApplicationResponse struct {
+	Success    bool                    `json:"success"`
+	Status     db.ApplicationStatus    `json:"status"`
+	Validation *application.Validation `json:"validation,omitempty"`
+	Error      string                  `json:"error"`
+}
+
+const lockedError = "Application can't be changed once it's submitted, withdraw it first"
+const pendingError = "Application can't be changed while it's submitted to a program, withdraw that application first"
+
+// GetApplicationStatusHandler gets the status of an application, what the
+// user can move it to, and how it got here, newest first.
+func GetApplicationStatusHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, GetApplicationStatusResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	ownerEmail := c.QueryParam("email")
+	owner, ownerErr := findOwner(client, user, ownerEmail)
+	if ownerErr != nil {
+		fmt.Printf("[ERROR] Not a caseworker for owner email: %s\n", ownerEmail)
+		return c.JSON(400, GetApplicationStatusResponse{Success: false, Error: "Not a caseworker for account"})
+	}
+
+	applicationId, ok := parseApplicationID(c.QueryParam("id"))
+	if !ok {
+		return c.JSON(400, GetApplicationStatusResponse{Success: false, Error: "Invalid application id"})
+	}
+	housingApplication, status, findErr := findHousingApplication(client, owner, applicationId, false)
+	if findErr != "" {
+		return c.JSON(status, GetApplicationStatusResponse{Success: false, Error: findErr})
+	}
+	if housingApplication == nil {
+		return c.JSON(404, GetApplicationStatusResponse{Success: false, Error: "No application data"})
+	}
+
+	transitions, transitionsErr := client.ApplicationTransition.FindMany(
+		db.ApplicationTransition.HousingApplicationID.Equals(housingApplication.ID),
+	).With(
+		db.ApplicationTransition.Actor.Fetch(),
+	).OrderBy(
+		db.ApplicationTransition.CreatedAt.Order(db.SortOrderDesc),
+	).Exec(context.Background())
+	if transitionsErr != nil {
+		fmt.Printf("[ERROR] Failed to get transitions of housing application %d: %v\n", housingApplication.ID, transitionsErr)
+		return c.JSON(500, GetApplicationStatusResponse{Success: false, Error: "Failed to get status history"})
+	}
+
+	infos := make([]ApplicationTransitionInfo, len(transitions))
+	for i, transition := range transitions {
+		infos[i] = ApplicationTransitionInfo{
+			From:      transition.From,
+			To:        transition.To,
+			Reason:    transition.Reason,
+			CreatedAt: transition.CreatedAt,
+		}
+		if actor, ok := transition.Actor(); ok {
+			infos[i].ActorEmail = actor.Email
+		}
+	}
+
+	return c.JSON(200, GetApplicationStatusResponse{
+		Success:         true,
+		Status:          housingApplication.Status,
+		StatusChangedAt: housingApplication.StatusChangedAt,
+		Next:            application.NextStatuses(housingApplication.Status, user.Type),
+		Transitions:     infos,
+		Error:           "",
+	})
+}
+
+// findTransitionOwner finds the client whose application is being moved to
+// the status. Admins can review any client's application, everything else
+// needs the user to be the client or their caseworker. Unknown emails get the
+// same error as clients of other caseworkers, so it doesn't tell who has an
+// account. On failure, returns the status code and error message to respond
+// with.
+func findTransitionOwner(client *db.PrismaClient, user *db.UserModel, ownerEmail string, to db.ApplicationStatus) (*db.UserModel, int, string) {
+	owner, ownerErr := findOwner(client, user, ownerEmail)
+	if ownerErr == nil {
+		return owner, 200, ""
+	}
+	if ownerErr != db.ErrNotFound {
+		fmt.Printf("[ERROR] Failed to get user link for %s: %v\n", ownerEmail, ownerErr)
+		return nil, 500, "Failed to get client"
+	}
+	if application.IsReview(to) && application.IsReviewer(user.Type) {
+		owner, ownerErr = client.User.FindUnique(
+			db.User.Email.Equals(ownerEmail),
+		).Exec(context.Background())
+		if ownerErr == nil && owner.Type == db.UserTypeClient {
+			return owner, 200, ""
+		}
+		if ownerErr != nil && ownerErr != db.ErrNotFound {
+			fmt.Printf("[ERROR] Failed to get user %s: %v\n", ownerEmail, ownerErr)
+			return nil, 500, "Failed to get client"
+		}
+	}
+	fmt.Printf("[ERROR] Not a caseworker for owner email: %s\n", ownerEmail)
+	return nil, 400, "Not a caseworker for account"
+}
+
+// TransitionApplicationHandler moves an application to a new status. Marking
+// it ready for review or submitting it needs the application to be complete,
+// and whoever submitted it can't approve or deny it.
+func TransitionApplicationHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, TransitionApplicationResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	var request TransitionApplicationRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&request); err != nil {
+		return c.JSON(500, TransitionApplicationResponse{Success: false, Error: "Failed to parse request body"})
+	}
+
+	owner, status, ownerErr := findTransitionOwner(client, user, request.Email, request.Status)
+	if ownerErr != "" {
+		return c.JSON(status, TransitionApplicationResponse{Success: false, Error: ownerErr})
+	}
+
+	housingApplication, status, findErr := findHousingApplication(client, owner, request.ApplicationID, false)
+	if findErr != "" {
+		return c.JSON(status, TransitionApplicationResponse{Success: false, Error: findErr})
+	}
+	if housingApplication == nil {
+		return c.JSON(404, TransitionApplicationResponse{Success: false, Error: "No application data"})
+	}
+	from := housingApplication.Status
+
+	switch application.CheckTransition(from, request.Status, user.Type, request.Reason) {
+	case nil:
+	case application.ErrTransitionForbidden:
+		return c.JSON(403, TransitionApplicationResponse{Success: false, Status: from, Error: fmt.Sprintf("Not allowed to move the application to %s", request.Status)})
+	case application.ErrReasonRequired:
+		return c.JSON(400, TransitionApplicationResponse{Success: false, Status: from, Error: fmt.Sprintf("A reason is required to move the application to %s", request.Status)})
+	default:
+		return c.JSON(400, TransitionApplicationResponse{Success: false, Status: from, Error: fmt.Sprintf("Application can't move from %s to %s", from, request.Status)})
+	}
+
+	// Someone else has to check the work of whoever submitted it
+	if application.IsDecision(request.Status) {
+		submission, submissionErr := client.ApplicationTransition.FindFirst(
+			db.ApplicationTransition.HousingApplicationID.Equals(housingApplication.ID),
+			db.ApplicationTransition.To.Equals(db.ApplicationStatusSubmitted),
+		).OrderBy(
+			db.ApplicationTransition.CreatedAt.Order(db.SortOrderDesc),
+		).Exec(context.Background())
+		if submissionErr != nil && submissionErr != db.ErrNotFound {
+			fmt.Printf("[ERROR] Failed to get submission of housing application %d: %v\n", housingApplication.ID, submissionErr)
+			return c.JSON(500, TransitionApplicationResponse{Success: false, Error: "Failed to get status history"})
+		}
+		if submission != nil {
+			if submitterId, ok := submission.ActorID(); ok && submitterId == user.ID {
+				return c.JSON(403, TransitionApplicationResponse{Success: false, Status: from, Error: "The application has to be decided by someone other than who submitted it"})
+			}
+		}
+	}
+
+	if request.Status == db.ApplicationStatusReadyForReview || request.Status == db.ApplicationStatusSubmitted {
+		applicationData, personalInfo, status, findErr := findApplicationData(client, owner, owner.Email)
+		if findErr != "" {
+			return c.JSON(status, TransitionApplicationResponse{Success: false, Error: findErr})
+		}
+		data := application.ApplicationData{}
+		if applicationData != nil {
+			var convertErr error
+			data, convertErr = application.ToApplicationData(applicationData, housingApplication, personalInfo, owner.Email)
+			if convertErr != nil {
+				fmt.Printf("[ERROR] Failed to read application data of user %d: %v\n", owner.ID, convertErr)
+				return c.JSON(500, TransitionApplicationResponse{Success: false, Error: "Failed to read application data"})
+			}
+		}
+		validation := application.Validate(&data)
+		if !validation.Valid {
+			return c.JSON(400, TransitionApplicationResponse{Success: false, Status: from, Validation: &validation, Error: "Finish the application first"})
+		}
+	}
+
+	// The status and its history are saved together. A transition that
+	// raced this one out of the same status makes it fail.
+	updateTx := client.HousingApplication.FindUnique(
+		db.HousingApplication.ID.Equals(housingApplication.ID),
+	).Update(
+		db.HousingApplication.Status.Set(request.Status),
+		db.HousingApplication.StatusChangedAt.Set(time.Now()),
+	).Tx()
+	transitionTx := client.ApplicationTransition.CreateOne(
+		db.ApplicationTransition.HousingApplication.Link(
+			db.HousingApplication.ID.Equals(housingApplication.ID),
+		),
+		db.ApplicationTransition.From.Set(from),
+		db.ApplicationTransition.To.Set(request.Status),
+		db.ApplicationTransition.Actor.Link(
+			db.User.ID.Equals(user.ID),
+		),
+		db.ApplicationTransition.Reason.Set(request.Reason),
+		db.ApplicationTransition.FromSince.Set(housingApplication.StatusChangedAt),
+	).Tx()
+	if txErr := client.Prisma.Transaction(updateTx, transitionTx).Exec(context.Background()); txErr != nil {
+		current, reloadErr := reloadHousingApplication(client, housingApplication.ID)
+		if reloadErr == nil && (current.Status != from || !current.StatusChangedAt.Equal(housingApplication.StatusChangedAt)) {
+			return c.JSON(409, TransitionApplicationResponse{Success: false, Error: "Application status changed, reload and try again"})
+		}
+		fmt.Printf("[ERROR] Failed to update status of housing application %d: %v\n", housingApplication.ID, txErr)
+		return c.JSON(500, TransitionApplicationResponse{Success: false, Error: "Failed to update status"})
+	}
+
+	return c.JSON(200, TransitionApplicationResponse{Success: true, Status: request.Status, Error: ""})
+}
@@ -0,0 +1,466 @@
+package data
+
+// Every save of an application is kept as a snapshot, so there's a history of
+// who changed what. Snapshots hold the whole application, SSNs and all, so
+// they're encrypted like the fields they came from.
+
+import (
+	"api/core/encryption"
+	"api/core/server/account"
+	"api/core/server/audit"
+	"api/core/server/data/application"
+	"api/core/util"
+	"api/db"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+type ApplicationVersionInfo struct {
+	Version      int    `json:"version"`
+	AuthorEmail  string `json:"author_email"`
+	RestoredFrom *int   `json:"restored_from"`
+	// The housing application it was saved through
+	ApplicationID *int      `json:"application_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type GetApplicationVersionsResponse struct {
+	Success  bool                     `json:"success"`
+	Versions []ApplicationVersionInfo `json:"versions"`
+	Error    string                   `json:"error"`
+}
+
+type GetApplicationVersionResponse struct {
+	Success bool                        `json:"success"`
+	Version ApplicationVersionInfo      `json:"version"`
+	Data    application.ApplicationData `json:"application_data"`
+	Error   string                      `json:"error"`
+}
+
+type DiffApplicationVersionsResponse struct {
+	Success bool                      `json:"success"`
+	From    int                       `json:"from"`
+	To      int                       `json:"to"`
+	Changes []application.FieldChange `json:"changes"`
+	Error   string                    `json:"error"`
+}
+
+type RestoreApplicationVersionRequest struct {
+	Email   string `json:"email"`
+	Version int    `json:"version"`
+}
+
+type RestoreApplicationVersionResponse struct {
+	Success    bool                   `json:"success"`
+	Version    int                    `json:"version"`
+	Validation application.Validation `json:"validation"`
+	Error      string                 `json:"error"`
+}
+
+func toApplicationVersionInfo(version *db.ApplicationVersionModel) ApplicationVersionInfo {
+	info := ApplicationVersionInfo{
+		Version:   version.Version,
+		CreatedAt: version.CreatedAt,
+	}
+	if author, ok := version.Author(); ok {
+		info.AuthorEmail = author.Email
+	}
+	if restoredFrom, ok := version.RestoredFrom(); ok {
+		info.RestoredFrom = &restoredFrom
+	}
+	if housingApplicationId, ok := version.HousingApplicationID(); ok {
+		info.ApplicationID = &housingApplicationId
+	}
+	return info
+}
+
+// snapshotApplication builds the encrypted snapshot of what a save is about
+// to store, so it can be recorded in the same transaction. It's what
+// reloading the application after the save would give: the personal info and
+// household members come from what's stored, the rest from the saved data.
+// Without a housing application, there are no preferences.
+func snapshotApplication(client *db.PrismaClient, owner *db.UserModel, housingApplication *db.HousingApplicationModel, data *application.ApplicationData) (string, error) {
+	personalInfo, personalInfoErr := client.PersonalInfo.FindUnique(
+		db.PersonalInfo.ID.Equals(owner.PersonalInfoID),
+	).With(
+		db.PersonalInfo.FamilyLinks.Fetch().With(
+			db.FamilyLink.FamilyMember.Fetch(),
+		),
+	).Exec(context.Background())
+	if personalInfoErr != nil {
+		return "", personalInfoErr
+	}
+	members, membersErr := linkedMembers(personalInfo.FamilyLinks())
+	if membersErr != nil {
+		return "", membersErr
+	}
+	return encodeSnapshot(owner, personalInfo, members, housingApplication, data)
+}
+
+// linkedMembers reads the family members of family links fetched with their
+// members, by id.
+func linkedMembers(links []db.FamilyLinkModel) (map[int]application.FamilyMember, error) {
+	members := map[int]application.FamilyMember{}
+	for _, link := range links {
+		member, convertErr := application.ToFamilyMember(link.FamilyMember())
+		if convertErr != nil {
+			return nil, convertErr
+		}
+		members[member.ID] = member
+	}
+	return members, nil
+}
+
+// encodeSnapshot builds a snapshot like snapshotApplication, with the
+// personal info and household members given rather than what's stored, for
+// saves that change them too.
+func encodeSnapshot(owner *db.UserModel, personalInfo *db.PersonalInfoModel, members map[int]application.FamilyMember, housingApplication *db.HousingApplicationModel, data *application.ApplicationData) (string, error) {
+	// Copied so the saved data isn't changed
+	var snapshot application.ApplicationData
+	encoded, marshalErr := json.Marshal(data)
+	if marshalErr != nil {
+		return "", marshalErr
+	}
+	if unmarshalErr := json.Unmarshal(encoded, &snapshot); unmarshalErr != nil {
+		return "", unmarshalErr
+	}
+
+	snapshot.PersonalInfo.FirstName = personalInfo.FirstName
+	snapshot.PersonalInfo.LastName = personalInfo.LastName
+	snapshot.PersonalInfo.Email = owner.Email
+	snapshot.PersonalInfo.Dob = personalInfo.Dob.Format(application.DateLayout)
+	snapshot.PersonalInfo.Phone = util.WrapDefault(personalInfo.PhoneNumber, "")
+	if housingApplication == nil {
+		snapshot.HousingPreferences = application.HousingPreferences{Rankings: map[string]string{}}
+	}
+	snapshot.UploadedFiles = []application.FileUploadData{}
+
+	resolve := func(member *application.FamilyMember) {
+		if stored, ok := members[member.ID]; ok {
+			*member = stored
+		}
+	}
+	resolveAll := func(list []application.FamilyMember) {
+		for i := range list {
+			resolve(&list[i])
+		}
+	}
+	resolveAll(snapshot.Household.AbsentMembers)
+	resolveAll(snapshot.Household.HudRecipients)
+	resolveAll(snapshot.Household.AccessibilityMembers)
+	resolveAll(snapshot.Household.MembersNeedingHelp)
+	if snapshot.History.CurrentResidence != nil {
+		resolveAll(snapshot.History.CurrentResidence.NonResidingMembers)
+	}
+	for i := range snapshot.History.PreviousResidences {
+		resolveAll(snapshot.History.PreviousResidences[i].NonResidingMembers)
+	}
+	resolveAll(snapshot.History.LifetimeOffenders)
+	resolveAll(snapshot.History.ViolentOffenders)
+	resolveAll(snapshot.History.MethOffenders)
+	resolveAll(snapshot.History.DrugOffenders)
+	for i := range snapshot.History.OtherCrimes {
+		resolve(&snapshot.History.OtherCrimes[i].FamilyMember)
+	}
+	for i := range snapshot.Income.IncomeAssetEntires {
+		resolve(&snapshot.Income.IncomeAssetEntires[i].FamilyMember)
+	}
+
+	encoded, marshalErr = json.Marshal(snapshot)
+	if marshalErr != nil {
+		return "", marshalErr
+	}
+	return encryption.EncryptValue(string(encoded))
+}
+
+// recordVersion records the snapshot of the application at a version, with
+// the housing application it was saved through, if any. The author is
+// whoever made the change, restoredFrom is the version it was restored from,
+// or 0.
+func recordVersion(client *db.PrismaClient, applicationId int, version int, housingApplication *db.HousingApplicationModel, author *db.UserModel, restoredFrom int, snapshot string) db.PrismaTransaction {
+	params := []db.ApplicationVersionSetParam{
+		db.ApplicationVersion.Author.Link(
+			db.User.ID.Equals(author.ID),
+		),
+	}
+	if restoredFrom != 0 {
+		params = append(params, db.ApplicationVersion.RestoredFrom.Set(restoredFrom))
+	}
+	if housingApplication != nil {
+		params = append(params, db.ApplicationVersion.HousingApplication.Link(
+			db.HousingApplication.ID.Equals(housingApplication.ID),
+		))
+	}
+
+	return client.ApplicationVersion.CreateOne(
+		db.ApplicationVersion.ApplicationData.Link(
+			db.ApplicationData.ID.Equals(applicationId),
+		),
+		db.ApplicationVersion.Version.Set(version),
+		db.ApplicationVersion.Data.Set(snapshot),
+		params...,
+	).Tx()
+}
+
+// decodeVersion reads the application snapshot stored in a version.
+func decodeVersion(version *db.ApplicationVersionModel) (application.ApplicationData, error) {
+	var data application.ApplicationData
+	snapshot, decryptErr := encryption.Decrypt(version.Data)
+	if decryptErr != nil {
+		return data, decryptErr
+	}
+	err := json.Unmarshal([]byte(snapshot), &data)
+	return data, err
+}
+
+// findApplication finds the owner and their application, without any of its
+// relations, for the user themselves or one of their clients. On failure,
+// returns the status code and error message to respond with.
+func findApplication(client *db.PrismaClient, user *db.UserModel, ownerEmail string) (*db.UserModel, *db.ApplicationDataModel, int, string) {
+	owner, ownerErr := findOwner(client, user, ownerEmail)
+	if ownerErr != nil {
+		fmt.Printf("[ERROR] Not a caseworker for owner email: %s\n", ownerEmail)
+		return nil, nil, 400, "Not a caseworker for account"
+	}
+
+	applicationData, applicationDataErr := client.ApplicationData.FindUnique(
+		db.ApplicationData.UserID.Equals(owner.ID),
+	).Exec(context.Background())
+	if applicationDataErr == db.ErrNotFound {
+		return nil, nil, 404, "No application data"
+	}
+	if applicationDataErr != nil {
+		fmt.Printf("[ERROR] Failed to get application data for user %d: %v\n", owner.ID, applicationDataErr)
+		return nil, nil, 500, "Failed to retrieve application data"
+	}
+	return owner, applicationData, 200, ""
+}
+
+func findVersion(client *db.PrismaClient, applicationId int, number int) (*db.ApplicationVersionModel, error) {
+	return client.ApplicationVersion.FindUnique(
+		db.ApplicationVersion.ApplicationDataIDVersion(
+			db.ApplicationVersion.ApplicationDataID.Equals(applicationId),
+			db.ApplicationVersion.Version.Equals(number),
+		),
+	).With(
+		db.ApplicationVersion.Author.Fetch(),
+	).Exec(context.Background())
+}
+
+// findDecodedVersion finds a version and reads its snapshot. SSNs are masked
+// unless the user owns the application. On failure, returns the status code
+// and error message to respond with.
+func findDecodedVersion(client *db.PrismaClient, user *db.UserModel, owner *db.UserModel, applicationId int, param string) (*db.ApplicationVersionModel, application.ApplicationData, int, string) {
+	number, parseErr := strconv.Atoi(param)
+	if parseErr != nil {
+		return nil, application.ApplicationData{}, 400, "Invalid version"
+	}
+
+	version, versionErr := findVersion(client, applicationId, number)
+	if versionErr == db.ErrNotFound {
+		return nil, application.ApplicationData{}, 404, fmt.Sprintf("Version %d not found", number)
+	}
+	if versionErr != nil {
+		fmt.Printf("[ERROR] Failed to get version %d of application %d: %v\n", number, applicationId, versionErr)
+		return nil, application.ApplicationData{}, 500, "Failed to get version"
+	}
+
+	data, decodeErr := decodeVersion(version)
+	if decodeErr != nil {
+		fmt.Printf("[ERROR] Failed to read version %d of application %d: %v\n", number, applicationId, decodeErr)
+		return nil, application.ApplicationData{}, 500, "Failed to read version"
+	}
+
+	// Caseworkers have to reveal SSNs one at a time
+	if owner.ID != user.ID {
+		data.MaskSSNs()
+	}
+	return version, data, 200, ""
+}
+
+// GetApplicationVersionsHandler lists the versions of an application, newest
+// first.
+func GetApplicationVersionsHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, GetApplicationVersionsResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	_, applicationData, status, findErr := findApplication(client, user, c.QueryParam("email"))
+	if findErr != "" {
+		return c.JSON(status, GetApplicationVersionsResponse{Success: false, Error: findErr})
+	}
+	applicationId := applicationData.ID
+
+	versions, versionsErr := client.ApplicationVersion.FindMany(
+		db.ApplicationVersion.ApplicationDataID.Equals(applicationId),
+	).With(
+		db.ApplicationVersion.Author.Fetch(),
+	).OrderBy(
+		db.ApplicationVersion.Version.Order(db.SortOrderDesc),
+	).Exec(context.Background())
+	if versionsErr != nil {
+		fmt.Printf("[ERROR] Failed to get versions of application %d: %v\n", applicationId, versionsErr)
+		return c.JSON(500, GetApplicationVersionsResponse{Success: false, Error: "Failed to get versions"})
+	}
+
+	infos := make([]ApplicationVersionInfo, len(versions))
+	for i := range versions {
+		infos[i] = toApplicationVersionInfo(&versions[i])
+	}
+
+	return c.JSON(200, GetApplicationVersionsResponse{Success: true, Versions: infos, Error: ""})
+}
+
+// GetApplicationVersionHandler gets the application as it was at one version.
+func GetApplicationVersionHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, GetApplicationVersionResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	owner, applicationData, status, findErr := findApplication(client, user, c.QueryParam("email"))
+	if findErr != "" {
+		return c.JSON(status, GetApplicationVersionResponse{Success: false, Error: findErr})
+	}
+	applicationId := applicationData.ID
+
+	version, data, status, versionErr := findDecodedVersion(client, user, owner, applicationId, c.QueryParam("version"))
+	if versionErr != "" {
+		return c.JSON(status, GetApplicationVersionResponse{Success: false, Error: versionErr})
+	}
+
+	return c.JSON(200, GetApplicationVersionResponse{
+		Success: true,
+		Version: toApplicationVersionInfo(version),
+		Data:    data,
+		Error:   "",
+	})
+}
+
+// DiffApplicationVersionsHandler lists the fields that changed between the
+// from and to versions.
+func DiffApplicationVersionsHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, DiffApplicationVersionsResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	owner, applicationData, status, findErr := findApplication(client, user, c.QueryParam("email"))
+	if findErr != "" {
+		return c.JSON(status, DiffApplicationVersionsResponse{Success: false, Error: findErr})
+	}
+	applicationId := applicationData.ID
+
+	from, fromData, status, fromErr := findDecodedVersion(client, user, owner, applicationId, c.QueryParam("from"))
+	if fromErr != "" {
+		return c.JSON(status, DiffApplicationVersionsResponse{Success: false, Error: fromErr})
+	}
+	to, toData, status, toErr := findDecodedVersion(client, user, owner, applicationId, c.QueryParam("to"))
+	if toErr != "" {
+		return c.JSON(status, DiffApplicationVersionsResponse{Success: false, Error: toErr})
+	}
+
+	changes, diffErr := application.Diff(&fromData, &toData)
+	if diffErr != nil {
+		fmt.Printf("[ERROR] Failed to diff versions %d and %d of application %d: %v\n", from.Version, to.Version, applicationId, diffErr)
+		return c.JSON(500, DiffApplicationVersionsResponse{Success: false, Error: "Failed to compare versions"})
+	}
+
+	return c.JSON(200, DiffApplicationVersionsResponse{
+		Success: true,
+		From:    from.Version,
+		To:      to.Version,
+		Changes: changes,
+		Error:   "",
+	})
+}
+
+// RestoreApplicationVersionHandler lets a caseworker put a client's
+// application back the way it was at an earlier version, if the client lets
+// them edit it. The restore is saved as a new version, so nothing is lost.
+func RestoreApplicationVersionHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, RestoreApplicationVersionResponse{Success: false, Error: "Failed to authenticate"})
+	}
+	if user.Type != db.UserTypeCaseWorker {
+		return c.JSON(400, RestoreApplicationVersionResponse{Success: false, Error: "Only caseworkers can restore versions"})
+	}
+
+	var request RestoreApplicationVersionRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&request); err != nil {
+		return c.JSON(500, RestoreApplicationVersionResponse{Success: false, Error: "Failed to parse request body"})
+	}
+	if request.Email == "" || request.Email == user.Email {
+		return c.JSON(400, RestoreApplicationVersionResponse{Success: false, Error: "Missing client email"})
+	}
+
+	// Restoring changes the application, so it needs the client's consent
+	owner, ownerErr := account.FindEditableOwner(client, user, request.Email)
+	if ownerErr != nil {
+		status, message := account.EditAccessError(ownerErr, request.Email)
+		return c.JSON(status, RestoreApplicationVersionResponse{Success: false, Error: message})
+	}
+
+	applicationData, personalInfo, status, findErr := findApplicationData(client, owner, owner.Email)
+	if findErr != "" {
+		return c.JSON(status, RestoreApplicationVersionResponse{Success: false, Error: findErr})
+	}
+	if applicationData == nil {
+		return c.JSON(404, RestoreApplicationVersionResponse{Success: false, Error: "No application data"})
+	}
+	applicationId := applicationData.ID
+
+	version, versionErr := findVersion(client, applicationId, request.Version)
+	if versionErr == db.ErrNotFound {
+		return c.JSON(404, RestoreApplicationVersionResponse{Success: false, Error: fmt.Sprintf("Version %d not found", request.Version)})
+	}
+	if versionErr != nil {
+		fmt.Printf("[ERROR] Failed to get version %d of application %d: %v\n", request.Version, applicationId, versionErr)
+		return c.JSON(500, RestoreApplicationVersionResponse{Success: false, Error: "Failed to get version"})
+	}
+
+	data, decodeErr := decodeVersion(version)
+	if decodeErr != nil {
+		fmt.Printf("[ERROR] Failed to read version %d of application %d: %v\n", request.Version, applicationId, decodeErr)
+		return c.JSON(500, RestoreApplicationVersionResponse{Success: false, Error: "Failed to read version"})
+	}
+
+	// The preferences go back to the housing application they were saved
+	// through, unless it's been deleted since
+	housingApplicationId, _ := version.HousingApplicationID()
+	housingApplication, housingApplicationErr := reloadHousingApplication(client, housingApplicationId)
+	if housingApplicationErr != nil && housingApplicationErr != db.ErrNotFound {
+		fmt.Printf("[ERROR] Failed to get housing application %d: %v\n", housingApplicationId, housingApplicationErr)
+		return c.JSON(500, RestoreApplicationVersionResponse{Success: false, Error: "Failed to retrieve application"})
+	}
+	if housingApplication == nil {
+		housingApplicationId = 0
+	}
+	before, convertErr := application.ToApplicationData(applicationData, housingApplication, personalInfo, owner.Email)
+	if convertErr != nil {
+		fmt.Printf("[ERROR] Failed to read application data of user %d: %v\n", owner.ID, convertErr)
+		return c.JSON(500, RestoreApplicationVersionResponse{Success: false, Error: "Failed to read application data"})
+	}
+
+	number, status, saveErr := saveApplicationData(client, owner, user, housingApplication, &data, applicationData.Version, version.Version)
+	if saveErr != "" {
+		return c.JSON(status, RestoreApplicationVersionResponse{Success: false, Error: saveErr})
+	}
+
+	changes, _ := application.Diff(&before, &data)
+	audit.RecordAssistedEdit(c, client, user, owner, audit.ActionEditApplication, fmt.Sprintf("application_version:%d", number), summarizeChanges(changes))
+
+	return c.JSON(200, RestoreApplicationVersionResponse{
+		Success:    true,
+		Version:    number,
+		Validation: application.Validate(&data),
+		Error:      "",
+	})
+}
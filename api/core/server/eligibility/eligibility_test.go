@@ -0,0 +1,117 @@
+package eligibility
+
+import (
+	"api/core/server/data/application"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func evaluate(t *testing.T, rules RuleSet, data application.ApplicationData) Result {
+	result, err := Evaluate(rules, &data, 1)
+	assert.NoError(t, err, "Application should be evaluated")
+	return result
+}
+
+func findResult(result Result, id string) RuleResult {
+	for _, rule := range result.Rules {
+		if rule.ID == id {
+			return rule
+		}
+	}
+	return RuleResult{}
+}
+
+func TestDefaultRulesParse(t *testing.T) {
+	_, err := Parse(defaultRules)
+	assert.NoError(t, err, "Built in rules should parse")
+	assert.NotEmpty(t, DefaultRules().Rules, "Built in rules should have rules")
+}
+
+func TestParseJSON(t *testing.T) {
+	rules, err := Parse(`{"rules": [{"id": "smoker", "description": "Doesn't smoke", "if": {"field": "household.is_smoker", "equals": true}, "then": "needs_review"}]}`)
+	assert.NoError(t, err, "Rules should be readable as JSON")
+	assert.Equal(t, "household.is_smoker", rules.Rules[0].If.Field, "Condition should be read")
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := map[string]string{
+		"no rules":         `rules: []`,
+		"unknown field":    "rules:\n  - id: a\n    if: {field: history.flying, equals: true}\n    then: fail",
+		"unknown outcome":  "rules:\n  - id: a\n    if: {field: history.evicted, equals: true}\n    then: maybe",
+		"two comparisons":  "rules:\n  - id: a\n    if: {field: history.evicted, equals: true, empty: false}\n    then: fail",
+		"no comparison":    "rules:\n  - id: a\n    if: {field: history.evicted}\n    then: fail",
+		"duplicate id":     "rules:\n  - id: a\n    if: {field: history.evicted, equals: true}\n    then: fail\n  - id: a\n    if: {field: history.evicted, equals: true}\n    then: fail",
+		"unknown property": "rules:\n  - id: a\n    if: {field: history.evicted, is: true}\n    then: fail",
+	}
+	for name, source := range cases {
+		_, err := Parse(source)
+		assert.ErrorIs(t, err, ErrInvalidRules, "Rules with %s should be rejected", name)
+	}
+}
+
+func TestEvaluateDefaultRules(t *testing.T) {
+	clean := evaluate(t, DefaultRules(), application.ApplicationData{})
+	assert.Equal(t, OutcomePass, clean.Outcome, "Application with nothing flagged should pass")
+	assert.Len(t, clean.Rules, len(DefaultRules().Rules), "Every rule should have a result")
+
+	offender := evaluate(t, DefaultRules(), application.ApplicationData{
+		History: application.HistoryData{IsLifetimeSexOffender: true, Evicted: true},
+	})
+	assert.Equal(t, OutcomeFail, offender.Outcome, "Failing any rule should fail the application")
+	assert.Equal(t, OutcomeFail, findResult(offender, "lifetime_sex_offender").Outcome, "Lifetime registration should fail")
+	assert.Equal(t, OutcomeNeedsReview, findResult(offender, "evicted").Outcome, "Evictions should need review")
+	assert.NotEmpty(t, findResult(offender, "lifetime_sex_offender").Explanation, "Failures should be explained")
+
+	owes := evaluate(t, DefaultRules(), application.ApplicationData{
+		History: application.HistoryData{OwesMoney: true},
+	})
+	assert.Equal(t, OutcomeNeedsReview, owes.Outcome, "Owing money without a plan should need review")
+
+	paying := evaluate(t, DefaultRules(), application.ApplicationData{
+		History: application.HistoryData{OwesMoney: true, MakingPayments: true},
+	})
+	assert.Equal(t, OutcomePass, paying.Outcome, "Owing money with a plan should pass")
+}
+
+func TestEvaluateComparisons(t *testing.T) {
+	rules, err := Parse(`
+rules:
+  - id: income
+    if: {field: computed.annual_income, greater_than: 50000}
+    then: fail
+    explanation: Over the income limit
+  - id: household
+    if: {field: computed.household_size, less_than: 2}
+    then: needs_review
+  - id: residence
+    if: {field: history.current_residence.state, in: [ID, OR]}
+    then: needs_review
+  - id: hud_property
+    if:
+      not: {field: household.hud_property_name, empty: true}
+    then: needs_review
+  - id: rent
+    if: {field: history.current_residence.monthly_payment, greater_than: 1000}
+    then: needs_review
+`)
+	assert.NoError(t, err, "Rules should parse")
+
+	result := evaluate(t, rules, application.ApplicationData{
+		History:   application.HistoryData{CurrentResidence: &application.ResidenceData{State: "id", MonthlyPayment: "$1,200"}},
+		Household: application.HouseholdData{HudPropertyName: "Main St Apartments"},
+		Income: application.IncomeAndAssetsData{IncomeAssetEntires: []application.IncomeAndAssetData{
+			{Type: "Income", Source: "Job", Amount: "1000", FrequencyOrLocation: "Weekly"},
+		}},
+	})
+	assert.Equal(t, OutcomeFail, findResult(result, "income").Outcome, "Income should be worked out from the entries")
+	assert.Equal(t, "Over the income limit", findResult(result, "income").Explanation, "Explanation should come from the rule")
+	assert.Equal(t, OutcomeNeedsReview, findResult(result, "household").Outcome, "Household size should be checked")
+	assert.Equal(t, OutcomeNeedsReview, findResult(result, "residence").Outcome, "Text should match without case")
+	assert.Equal(t, OutcomeNeedsReview, findResult(result, "hud_property").Outcome, "Not should flip its condition")
+	assert.Equal(t, OutcomeNeedsReview, findResult(result, "rent").Outcome, "Dollar amounts in text should compare as numbers")
+
+	missing := evaluate(t, rules, application.ApplicationData{})
+	assert.Equal(t, OutcomePass, findResult(missing, "residence").Outcome, "Missing sections should not match")
+	assert.Equal(t, OutcomePass, findResult(missing, "income").Outcome, "No income should be under the limit")
+}
@@ -0,0 +1,203 @@
+package eligibility
+
+// Evaluating a rule set against an application.
+
+import (
+	"api/core/server/data/application"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type RuleResult struct {
+	ID          string  `json:"id"`
+	Description string  `json:"description"`
+	Outcome     Outcome `json:"outcome"`
+	Explanation string  `json:"explanation"`
+}
+
+type Result struct {
+	// Fail if any rule failed, otherwise needs_review if any rule needs it
+	Outcome Outcome      `json:"outcome"`
+	Rules   []RuleResult `json:"rules"`
+}
+
+// facts are the values rules check, keyed by field path.
+type facts struct {
+	application map[string]interface{}
+	computed    map[string]interface{}
+}
+
+func newFacts(data *application.ApplicationData, householdSize int) (facts, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return facts{}, err
+	}
+	decoded := map[string]interface{}{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return facts{}, err
+	}
+
+	income := application.SummarizeIncome(data.Income)
+	annual, _ := income.Household.TotalAnnual.Float64()
+	monthly, _ := income.Household.TotalMonthly.Float64()
+	assets, _ := income.Household.AssetValue.Float64()
+	return facts{
+		application: decoded,
+		computed: map[string]interface{}{
+			ComputedAnnualIncome:  annual,
+			ComputedMonthlyIncome: monthly,
+			ComputedAssetValue:    assets,
+			ComputedHouseholdSize: float64(householdSize),
+		},
+	}, nil
+}
+
+// lookup finds the value at a field path. Sections left out of the
+// application, like a missing current residence, are nil.
+func (f facts) lookup(path string) interface{} {
+	if value, ok := f.computed[path]; ok {
+		return value
+	}
+	var current interface{} = f.application
+	for _, name := range strings.Split(path, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = object[name]
+	}
+	return current
+}
+
+// number reads a value as a number. Text is read as a dollar amount, since
+// that's how the application stores amounts.
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case string:
+		amount, err := application.ParseAmount(v)
+		if err != nil || strings.TrimSpace(v) == "" {
+			return 0, false
+		}
+		parsed, _ := amount.Float64()
+		return parsed, true
+	}
+	return 0, false
+}
+
+// equal compares a value from the application with one from a rule. Numbers
+// compare as numbers and text ignores case.
+func equal(actual interface{}, expected interface{}) bool {
+	switch e := expected.(type) {
+	case int, float64:
+		expectedNumber, _ := number(e)
+		actualNumber, ok := number(actual)
+		return ok && actualNumber == expectedNumber
+	case string:
+		actualText, ok := actual.(string)
+		return ok && strings.EqualFold(strings.TrimSpace(actualText), strings.TrimSpace(e))
+	}
+	return actual == expected
+}
+
+func empty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+func (condition Condition) matches(f facts) bool {
+	switch {
+	case len(condition.All) > 0:
+		for _, nested := range condition.All {
+			if !nested.matches(f) {
+				return false
+			}
+		}
+		return true
+	case len(condition.Any) > 0:
+		for _, nested := range condition.Any {
+			if nested.matches(f) {
+				return true
+			}
+		}
+		return false
+	case condition.Not != nil:
+		return !condition.Not.matches(f)
+	}
+
+	value := f.lookup(condition.Field)
+	switch {
+	case condition.Equals != nil:
+		return equal(value, condition.Equals)
+	case condition.NotEquals != nil:
+		return !equal(value, condition.NotEquals)
+	case condition.In != nil:
+		for _, option := range condition.In {
+			if equal(value, option) {
+				return true
+			}
+		}
+		return false
+	case condition.GreaterThan != nil:
+		actual, ok := number(value)
+		return ok && actual > *condition.GreaterThan
+	case condition.LessThan != nil:
+		actual, ok := number(value)
+		return ok && actual < *condition.LessThan
+	case condition.Empty != nil:
+		return empty(value) == *condition.Empty
+	}
+	return false
+}
+
+// Evaluate checks the application against every rule. householdSize is the
+// number of people in the household, including the client.
+func Evaluate(rules RuleSet, data *application.ApplicationData, householdSize int) (Result, error) {
+	f, err := newFacts(data, householdSize)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read application: %w", err)
+	}
+
+	result := Result{Outcome: OutcomePass, Rules: []RuleResult{}}
+	for _, rule := range rules.Rules {
+		ruleResult := RuleResult{
+			ID:          rule.ID,
+			Description: rule.Description,
+			Outcome:     OutcomePass,
+			Explanation: "Not flagged by the application",
+		}
+		if rule.If.matches(f) {
+			ruleResult.Outcome = rule.Then
+			ruleResult.Explanation = rule.Explanation
+			if ruleResult.Explanation == "" {
+				ruleResult.Explanation = rule.Description
+			}
+		}
+
+		switch {
+		case ruleResult.Outcome == OutcomeFail:
+			result.Outcome = OutcomeFail
+		case ruleResult.Outcome == OutcomeNeedsReview && result.Outcome == OutcomePass:
+			result.Outcome = OutcomeNeedsReview
+		}
+		result.Rules = append(result.Rules, ruleResult)
+	}
+	return result, nil
+}
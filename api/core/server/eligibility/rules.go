@@ -0,0 +1,223 @@
+package eligibility
+
+// Eligibility rules housing programs screen applications with, written in
+// YAML or JSON so programs can change them without a release. For example:
+//
+//	rules:
+//	  - id: owes_money
+//	    description: Doesn't owe money to a housing provider
+//	    if:
+//	      all:
+//	        - field: history.owes_money
+//	          equals: true
+//	        - field: history.making_payments
+//	          equals: false
+//	    then: needs_review
+//	    explanation: Owes money to a housing provider without a payment plan
+//
+// A rule passes unless its condition matches the application, in which case
+// it fails or needs review. Fields are json paths into the application, or
+// figures worked out from it under "computed".
+
+import (
+	"api/core/server/data/application"
+	_ "embed"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Outcome string
+
+const (
+	OutcomePass        Outcome = "pass"
+	OutcomeFail        Outcome = "fail"
+	OutcomeNeedsReview Outcome = "needs_review"
+)
+
+// Figures worked out from the application that rules can check.
+const (
+	ComputedAnnualIncome  = "computed.annual_income"
+	ComputedMonthlyIncome = "computed.monthly_income"
+	ComputedAssetValue    = "computed.asset_value"
+	ComputedHouseholdSize = "computed.household_size"
+)
+
+var computedFields = []string{
+	ComputedAnnualIncome,
+	ComputedMonthlyIncome,
+	ComputedAssetValue,
+	ComputedHouseholdSize,
+}
+
+// Condition matches an application. Either it checks one field with one of
+// the comparisons, or it combines other conditions with all, any or not.
+type Condition struct {
+	Field     string        `yaml:"field,omitempty" json:"field,omitempty"`
+	Equals    interface{}   `yaml:"equals,omitempty" json:"equals,omitempty"`
+	NotEquals interface{}   `yaml:"not_equals,omitempty" json:"not_equals,omitempty"`
+	In        []interface{} `yaml:"in,omitempty" json:"in,omitempty"`
+	// Numbers, including dollar amounts typed in as text
+	GreaterThan *float64 `yaml:"greater_than,omitempty" json:"greater_than,omitempty"`
+	LessThan    *float64 `yaml:"less_than,omitempty" json:"less_than,omitempty"`
+	// Blank text, false, zero, or an empty list
+	Empty *bool `yaml:"empty,omitempty" json:"empty,omitempty"`
+
+	All []Condition `yaml:"all,omitempty" json:"all,omitempty"`
+	Any []Condition `yaml:"any,omitempty" json:"any,omitempty"`
+	Not *Condition  `yaml:"not,omitempty" json:"not,omitempty"`
+}
+
+type Rule struct {
+	ID          string    `yaml:"id" json:"id"`
+	Description string    `yaml:"description" json:"description"`
+	If          Condition `yaml:"if" json:"if"`
+	// What happens when the condition matches, fail or needs_review
+	Then        Outcome `yaml:"then" json:"then"`
+	Explanation string  `yaml:"explanation" json:"explanation"`
+}
+
+type RuleSet struct {
+	Rules []Rule `yaml:"rules" json:"rules"`
+}
+
+var ErrInvalidRules = errors.New("invalid eligibility rules")
+
+// HUD's screening rules for federally assisted housing, used by programs that
+// don't have their own.
+//
+//go:embed default_rules.yaml
+var defaultRules string
+
+// DefaultRules parses the built in rules. They're checked by the tests, so
+// they always parse.
+func DefaultRules() RuleSet {
+	rules, err := Parse(defaultRules)
+	if err != nil {
+		panic(err)
+	}
+	return rules
+}
+
+// Parse reads a rule set in YAML or JSON, and checks every rule can be
+// evaluated.
+func Parse(source string) (RuleSet, error) {
+	var rules RuleSet
+	decoder := yaml.NewDecoder(strings.NewReader(source))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&rules); err != nil {
+		return RuleSet{}, fmt.Errorf("%w: %v", ErrInvalidRules, err)
+	}
+	if err := rules.check(); err != nil {
+		return RuleSet{}, fmt.Errorf("%w: %v", ErrInvalidRules, err)
+	}
+	return rules, nil
+}
+
+func (rules RuleSet) check() error {
+	if len(rules.Rules) == 0 {
+		return errors.New("no rules")
+	}
+	ids := map[string]bool{}
+	for i, rule := range rules.Rules {
+		if rule.ID == "" {
+			return fmt.Errorf("rule %d has no id", i+1)
+		}
+		if ids[rule.ID] {
+			return fmt.Errorf("rule %s is listed twice", rule.ID)
+		}
+		ids[rule.ID] = true
+		if rule.Then != OutcomeFail && rule.Then != OutcomeNeedsReview {
+			return fmt.Errorf("rule %s: then must be %s or %s", rule.ID, OutcomeFail, OutcomeNeedsReview)
+		}
+		if err := rule.If.check(); err != nil {
+			return fmt.Errorf("rule %s: %v", rule.ID, err)
+		}
+	}
+	return nil
+}
+
+func (condition Condition) check() error {
+	combined := 0
+	if len(condition.All) > 0 {
+		combined++
+	}
+	if len(condition.Any) > 0 {
+		combined++
+	}
+	if condition.Not != nil {
+		combined++
+	}
+	comparisons := 0
+	for _, set := range []bool{
+		condition.Equals != nil,
+		condition.NotEquals != nil,
+		condition.In != nil,
+		condition.GreaterThan != nil,
+		condition.LessThan != nil,
+		condition.Empty != nil,
+	} {
+		if set {
+			comparisons++
+		}
+	}
+
+	if condition.Field == "" {
+		if combined != 1 || comparisons != 0 {
+			return errors.New("a condition needs a field, or one of all, any or not")
+		}
+		for _, nested := range append(condition.All, condition.Any...) {
+			if err := nested.check(); err != nil {
+				return err
+			}
+		}
+		if condition.Not != nil {
+			return condition.Not.check()
+		}
+		return nil
+	}
+
+	if combined != 0 || comparisons != 1 {
+		return fmt.Errorf("%s needs exactly one comparison", condition.Field)
+	}
+	if !knownField(condition.Field) {
+		return fmt.Errorf("unknown field %s", condition.Field)
+	}
+	return nil
+}
+
+// knownField checks a path is a computed figure or a json path to a field of
+// the application. Lists are checked as a whole, so paths don't go into them.
+func knownField(path string) bool {
+	for _, computed := range computedFields {
+		if path == computed {
+			return true
+		}
+	}
+
+	current := reflect.TypeOf(application.ApplicationData{})
+	for _, name := range strings.Split(path, ".") {
+		for current.Kind() == reflect.Pointer {
+			current = current.Elem()
+		}
+		if current.Kind() != reflect.Struct {
+			return false
+		}
+		found := false
+		for i := 0; i < current.NumField(); i++ {
+			field := current.Field(i)
+			if strings.Split(field.Tag.Get("json"), ",")[0] == name {
+				current = field.Type
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
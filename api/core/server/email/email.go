@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/smtp"
 	"os"
+	"strings"
 )
 
 // EmailConfig holds SMTP server settings
@@ -55,3 +56,67 @@ func Send2FACodeEmail(email, code string) error {
 	subject, body := build2FAEmail(code)
 	return sendEmail(email, subject, body)
 }
+
+// buildLinkRequestEmail returns subject and body for a caseworker link request
+func buildLinkRequestEmail(caseworkerName, caseworkerEmail string) (string, string) {
+	subject := "A caseworker wants to link with your account"
+	body := fmt.Sprintf("%s (%s) scanned your QR Home code and has asked to link with your account.\n\nOnce linked, they will be able to view and help with your housing application. Open QR Home to approve or reject this request. If you don't recognize this caseworker, reject the request.\n\nBest regards,\nQR Home", caseworkerName, caseworkerEmail)
+	return subject, body
+}
+
+// SendLinkRequestEmail notifies a client that a caseworker has asked to link
+func SendLinkRequestEmail(email, caseworkerName, caseworkerEmail string) error {
+	subject, body := buildLinkRequestEmail(caseworkerName, caseworkerEmail)
+	return sendEmail(email, subject, body)
+}
+
+// buildCaseloadTransferEmail returns subject and body for a caseworker change
+func buildCaseloadTransferEmail(fromName, toName, toEmail string) (string, string) {
+	subject := "You have a new caseworker"
+	body := fmt.Sprintf("%s is no longer your caseworker on QR Home. Your account has been moved to %s (%s), who can now view and help with your housing application.\n\nYou don't need to share your QR code again. If you have questions about this change, contact %s.\n\nBest regards,\nQR Home", fromName, toName, toEmail, toName)
+	return subject, body
+}
+
+// SendCaseloadTransferEmail notifies a client that they have been moved to a new caseworker
+func SendCaseloadTransferEmail(email, fromName, toName, toEmail string) error {
+	subject, body := buildCaseloadTransferEmail(fromName, toName, toEmail)
+	return sendEmail(email, subject, body)
+}
+
+// buildTaskReminderEmail returns subject and body for a list of tasks coming due
+func buildTaskReminderEmail(tasks []string) (string, string) {
+	subject := fmt.Sprintf("You have %d task(s) due", len(tasks))
+	if len(tasks) == 1 {
+		subject = "You have a task due"
+	}
+	body := fmt.Sprintf("These tasks are due or overdue:\n\n- %s\n\nOpen QR Home to update them.\n\nBest regards,\nQR Home", strings.Join(tasks, "\n- "))
+	return subject, body
+}
+
+// SendTaskReminderEmail reminds a caseworker of tasks that have come due
+func SendTaskReminderEmail(email string, tasks []string) error {
+	subject, body := buildTaskReminderEmail(tasks)
+	return sendEmail(email, subject, body)
+}
+
+// How many changes an edit email lists before summarizing the rest
+const maxListedChanges = 15
+
+// buildCaseworkerEditEmail returns subject and body for changes a caseworker made to a client's application
+func buildCaseworkerEditEmail(caseworkerName, caseworkerEmail string, changes []string) (string, string) {
+	subject := "Your caseworker updated your application"
+	listed := changes
+	more := ""
+	if len(changes) > maxListedChanges {
+		listed = changes[:maxListedChanges]
+		more = fmt.Sprintf("\n- and %d more", len(changes)-maxListedChanges)
+	}
+	body := fmt.Sprintf("%s (%s) made these changes to your housing application for you:\n\n- %s%s\n\nOpen QR Home to review them. If you didn't expect these changes, contact %s or turn off their edit access.\n\nBest regards,\nQR Home", caseworkerName, caseworkerEmail, strings.Join(listed, "\n- "), more, caseworkerName)
+	return subject, body
+}
+
+// SendCaseworkerEditEmail tells a client what a caseworker changed for them
+func SendCaseworkerEditEmail(email, caseworkerName, caseworkerEmail string, changes []string) error {
+	subject, body := buildCaseworkerEditEmail(caseworkerName, caseworkerEmail, changes)
+	return sendEmail(email, subject, body)
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"api/core/server/account"
+	"api/core/server/audit"
 	db "api/db"
 
 	"github.com/labstack/echo"
@@ -48,9 +49,25 @@ func UploadFileHandler(c echo.Context, client *db.PrismaClient) error {
 		return c.JSON(400, FileUploadResponse{Success: false, Error: "Failed to authenticate"})
 	}
 
+	form, err := c.MultipartForm()
+	if err != nil {
+		return c.JSON(500, FileUploadResponse{Success: false, Error: "Failed to get multipart form"})
+	}
+
+	// Caseworkers can upload for a client if the client lets them
+	ownerEmail := ""
+	if emailValues := form.Value["email"]; len(emailValues) > 0 {
+		ownerEmail = emailValues[0]
+	}
+	owner, ownerErr := account.FindEditableOwner(client, user, ownerEmail)
+	if ownerErr != nil {
+		status, message := account.EditAccessError(ownerErr, ownerEmail)
+		return c.JSON(status, FileUploadResponse{Success: false, Error: message})
+	}
+
 	personalInfo, personalInfoErr := client.PersonalInfo.FindFirst(
 		db.PersonalInfo.User.Where(
-			db.User.ID.Equals(user.ID),
+			db.User.ID.Equals(owner.ID),
 		),
 	).Exec(context.Background())
 	if personalInfo == nil || personalInfoErr != nil {
@@ -61,11 +78,6 @@ func UploadFileHandler(c echo.Context, client *db.PrismaClient) error {
 		})
 	}
 
-	form, err := c.MultipartForm()
-	if err != nil {
-		return c.JSON(500, FileUploadResponse{Success: false, Error: "Failed to get multipart form"})
-	}
-
 	files := form.File["file"]
 	if len(files) == 0 {
 		return c.JSON(500, FileUploadResponse{
@@ -81,6 +93,8 @@ func UploadFileHandler(c echo.Context, client *db.PrismaClient) error {
 	}
 
 	var uploadedFiles []string
+	var targets []string
+	var changes []string
 
 	for _, file := range files {
 		src, err := file.Open()
@@ -116,7 +130,7 @@ func UploadFileHandler(c echo.Context, client *db.PrismaClient) error {
 			})
 		}
 
-		_, err = client.UploadedFile.CreateOne(
+		uploaded, err := client.UploadedFile.CreateOne(
 			db.UploadedFile.Filename.Set(file.Filename),
 			db.UploadedFile.FileType.Set(uploadedFileType),
 			db.UploadedFile.MimeType.Set(detectedMimeType),
@@ -134,8 +148,12 @@ func UploadFileHandler(c echo.Context, client *db.PrismaClient) error {
 		}
 
 		uploadedFiles = append(uploadedFiles, file.Filename)
+		targets = append(targets, fmt.Sprintf("file:%d", uploaded.ID))
+		changes = append(changes, fmt.Sprintf("Uploaded %s", file.Filename))
 	}
 
+	audit.RecordAssistedEdit(c, client, user, owner, audit.ActionUploadFile, strings.Join(targets, ","), changes)
+
 	// Respond with list of successfully uploaded filenames
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"success":        true,
@@ -267,6 +285,26 @@ func DeleteFileHandler(c echo.Context, client *db.PrismaClient) error {
 		return c.JSON(400, DeleteFileResponse{Success: false, Error: "Invalid file ID"})
 	}
 
+	// Caseworkers can delete a client's files if the client lets them
+	ownerEmail := c.QueryParam("email")
+	owner, ownerErr := account.FindEditableOwner(client, user, ownerEmail)
+	if ownerErr != nil {
+		status, message := account.EditAccessError(ownerErr, ownerEmail)
+		return c.JSON(status, DeleteFileResponse{Success: false, Error: message})
+	}
+
+	// Only the owner's files can be deleted
+	file, fileErr := client.UploadedFile.FindFirst(
+		db.UploadedFile.ID.Equals(id),
+		db.UploadedFile.PersonalInfoID.Equals(owner.PersonalInfoID),
+	).Exec(context.Background())
+	if fileErr != nil {
+		return c.JSON(400, DeleteFileResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to find file with ID '%d'", id),
+		})
+	}
+
 	// Try to delete the file
 	_, deleteErr := client.UploadedFile.FindUnique(
 		db.UploadedFile.ID.Equals(id),
@@ -279,6 +317,8 @@ func DeleteFileHandler(c echo.Context, client *db.PrismaClient) error {
 		})
 	}
 
+	audit.RecordAssistedEdit(c, client, user, owner, audit.ActionDeleteFile, fmt.Sprintf("file:%d", id), []string{fmt.Sprintf("Deleted %s", file.Filename)})
+
 	return c.JSON(200, DeleteFileResponse{
 		Success: true,
 		Error:   "",
@@ -0,0 +1,318 @@
+package hmis
+
+// The files of the HUD HMIS CSV format, FY2024, and how QRHome's answers map
+// to HMIS's codes. Only the files and columns QRHome has answers for are
+// filled in, everything else is left blank for the partner's HMIS.
+
+import (
+	"api/core/server/data/application"
+	"strings"
+)
+
+const CSVVersion = "2024 v1.3"
+
+// HMIS's answers for something that wasn't asked or answered.
+const (
+	YesCode          = "1"
+	NoCode           = "0"
+	DoesNotKnow      = "8"
+	PrefersNotToSay  = "9"
+	DataNotCollected = "99"
+)
+
+// Data quality of names, SSNs and dates of birth
+const (
+	QualityFull    = "1"
+	QualityPartial = "2"
+)
+
+// Relationship to head of household
+const (
+	RelationshipSelf          = "1"
+	RelationshipChild         = "2"
+	RelationshipSpouse        = "3"
+	RelationshipOtherRelative = "4"
+	RelationshipOther         = "5"
+)
+
+// Data collection stages
+const (
+	StageProjectStart = "1"
+	StageProjectExit  = "3"
+)
+
+// Destination for applications that ended without the client moving in
+// anywhere QRHome knows of.
+const DestinationNoExitInterview = "30"
+
+// Disability types, from physical disability to HIV/AIDS
+var DisabilityTypes = []string{"5", "6", "7", "8", "9", "10"}
+
+// Columns of each file, in order.
+var (
+	ExportColumns = []string{
+		"ExportID", "SourceType", "SourceID", "SourceName", "SourceContactFirst", "SourceContactLast",
+		"SourceContactPhone", "SourceContactExtension", "SourceContactEmail", "ExportDate",
+		"ExportStartDate", "ExportEndDate", "SoftwareName", "SoftwareVersion", "CSVVersion",
+		"ExportPeriodType", "ExportDirective", "HashStatus", "ImplementationID",
+	}
+	OrganizationColumns = []string{
+		"OrganizationID", "OrganizationName", "VictimServiceProvider", "OrganizationCommonName",
+		"DateCreated", "DateUpdated", "UserID", "DateDeleted", "ExportID",
+	}
+	UserColumns = []string{
+		"UserID", "UserFirstName", "UserLastName", "UserPhone", "UserExtension", "UserEmail",
+		"DateCreated", "DateUpdated", "DateDeleted", "ExportID",
+	}
+	ProjectColumns = []string{
+		"ProjectID", "OrganizationID", "ProjectName", "ProjectCommonName", "OperatingStartDate",
+		"OperatingEndDate", "ContinuumProject", "ProjectType", "HousingType", "RRHSubType",
+		"ResidentialAffiliation", "TargetPopulation", "HOPWAMedAssistedLivingFac", "PITCount",
+		"DateCreated", "DateUpdated", "UserID", "DateDeleted", "ExportID",
+	}
+	ClientColumns = []string{
+		"PersonalID", "FirstName", "MiddleName", "LastName", "NameSuffix", "NameDataQuality",
+		"SSN", "SSNDataQuality", "DOB", "DOBDataQuality",
+		"AmIndAKNative", "Asian", "BlackAfAmerican", "HispanicLatinaeo", "MidEastNAfrican",
+		"NativeHIPacific", "White", "RaceNone", "AdditionalRaceEthnicity",
+		"Woman", "Man", "NonBinary", "CulturallySpecific", "Transgender", "Questioning",
+		"DifferentIdentity", "GenderNone", "DifferentIdentityText",
+		"VeteranStatus", "YearEnteredService", "YearSeparated", "WorldWarII", "KoreanWar",
+		"VietnamWar", "DesertStorm", "AfghanistanOEF", "IraqOIF", "IraqOND", "OtherTheater",
+		"MilitaryBranch", "DischargeStatus",
+		"DateCreated", "DateUpdated", "UserID", "DateDeleted", "ExportID",
+	}
+	EnrollmentColumns = []string{
+		"EnrollmentID", "PersonalID", "ProjectID", "EntryDate", "HouseholdID", "RelationshipToHoH",
+		"EnrollmentCoC", "LivingSituation", "RentalSubsidyType", "LengthOfStay", "LOSUnderThreshold",
+		"PreviousStreetESSH", "DateToStreetESSH", "TimesHomelessPastThreeYears",
+		"MonthsHomelessPastThreeYears", "DisablingCondition", "DateOfEngagement", "MoveInDate",
+		"DateOfPATHStatus", "ClientEnrolledInPATH", "ReasonNotEnrolled", "PercentAMI",
+		"ReferralSource", "CountOutreachReferralApproaches", "DateOfBCPStatus", "EligibleForRHY",
+		"ReasonNoServices", "RunawayYouth", "SexualOrientation", "SexualOrientationOther",
+		"FormerWardChildWelfare", "ChildWelfareYears", "ChildWelfareMonths",
+		"FormerWardJuvenileJustice", "JuvenileJusticeYears", "JuvenileJusticeMonths",
+		"UnemploymentFam", "MentalHealthDisorderFam", "PhysicalDisabilityFam",
+		"AlcoholDrugUseDisorderFam", "InsufficientIncome", "IncarceratedParent",
+		"TargetScreenReqd", "TimeToHousingLoss", "AnnualPercentAMI", "LiteralHomelessHistory",
+		"ClientLeaseholder", "HOHLeaseholder", "SubsidyAtRisk", "EvictionHistory",
+		"CriminalRecord", "IncarceratedAdult", "PrisonDischarge", "SexOffender", "DisabledHoH",
+		"CurrentPregnant", "SingleParent", "DependentUnder6", "HH5Plus", "CoCPrioritized",
+		"HPScreeningScore", "ThresholdScore", "VAMCStation", "TranslationNeeded",
+		"PreferredLanguage", "PreferredLanguageDifferent",
+		"DateCreated", "DateUpdated", "UserID", "DateDeleted", "ExportID",
+	}
+	ExitColumns = []string{
+		"ExitID", "EnrollmentID", "PersonalID", "ExitDate", "Destination", "DestinationSubsidyType",
+		"OtherDestination", "HousingAssessment", "SubsidyInformation", "ProjectCompletionStatus",
+		"EarlyExitReason", "ExchangeForSex", "ExchangeForSexPastThreeMonths", "CountOfExchangeForSex",
+		"AskedOrForcedToExchangeForSex", "AskedOrForcedToExchangeForSexPastThreeMonths",
+		"WorkplaceViolenceThreats", "WorkplacePromiseDifference", "CoercedToContinueWork",
+		"LaborExploitPastThreeMonths", "CounselingReceived", "IndividualCounseling",
+		"FamilyCounseling", "GroupCounseling", "SessionCountAtExit", "PostExitCounselingPlan",
+		"SessionsInPlan", "DestinationSafeClient", "DestinationSafeWorker", "PosAdultConnections",
+		"PosPeerConnections", "PosCommunityConnections", "AftercareDate", "AftercareProvided",
+		"EmailSocialMedia", "Telephone", "InPersonIndividual", "InPersonGroup", "CMExitReason",
+		"DateCreated", "DateUpdated", "UserID", "DateDeleted", "ExportID",
+	}
+	IncomeBenefitsColumns = []string{
+		"IncomeBenefitsID", "EnrollmentID", "PersonalID", "InformationDate", "IncomeFromAnySource",
+		"TotalMonthlyIncome", "Earned", "EarnedAmount", "Unemployment", "UnemploymentAmount",
+		"SSI", "SSIAmount", "SSDI", "SSDIAmount", "VADisabilityService", "VADisabilityServiceAmount",
+		"VADisabilityNonService", "VADisabilityNonServiceAmount", "PrivateDisability",
+		"PrivateDisabilityAmount", "WorkersComp", "WorkersCompAmount", "TANF", "TANFAmount",
+		"GA", "GAAmount", "SocSecRetirement", "SocSecRetirementAmount", "Pension", "PensionAmount",
+		"ChildSupport", "ChildSupportAmount", "Alimony", "AlimonyAmount", "OtherIncomeSource",
+		"OtherIncomeAmount", "OtherIncomeSourceIdentify", "BenefitsFromAnySource", "SNAP", "WIC",
+		"TANFChildCare", "TANFTransportation", "OtherTANF", "OtherBenefitsSource",
+		"OtherBenefitsSourceIdentify", "InsuranceFromAnySource", "Medicaid", "NoMedicaidReason",
+		"Medicare", "NoMedicareReason", "SCHIP", "NoSCHIPReason", "VHAServices", "NoVHAReason",
+		"EmployerProvided", "NoEmployerProvidedReason", "COBRA", "NoCOBRAReason", "PrivatePay",
+		"NoPrivatePayReason", "StateHealthIns", "NoStateHealthInsReason", "IndianHealthServices",
+		"NoIndianHealthServicesReason", "OtherInsurance", "OtherInsuranceIdentify", "ADAP",
+		"NoADAPReason", "RyanWhiteMedDent", "NoRyanWhiteReason", "ConnectionWithSOAR",
+		"DataCollectionStage", "DateCreated", "DateUpdated", "UserID", "DateDeleted", "ExportID",
+	}
+	DisabilitiesColumns = []string{
+		"DisabilitiesID", "EnrollmentID", "PersonalID", "InformationDate", "DisabilityType",
+		"DisabilityResponse", "IndefiniteAndImpairs", "TCellCountAvailable", "TCellCount",
+		"TCellSource", "ViralLoadAvailable", "ViralLoad", "ViralLoadSource", "AntiRetroviral",
+		"DataCollectionStage", "DateCreated", "DateUpdated", "UserID", "DateDeleted", "ExportID",
+	}
+)
+
+// Columns QRHome never collects, which are exported as "data not collected"
+// and have to be asked in HMIS.
+var NotCollected = []string{
+	"Client.csv: race and ethnicity",
+	"Client.csv: veteran status of household members besides the client",
+	"Enrollment.csv: CoC code and living situation at project start",
+	"Enrollment.csv: disabling condition of household members besides the client",
+	"IncomeBenefits.csv: health insurance",
+	"Disabilities.csv: which kind of disability the client has",
+	"Project.csv: project type, which is exported as Other",
+}
+
+// genderColumns are the Client.csv columns for each of QRHome's genders.
+var genderColumns = map[string]string{
+	"Male":       "Man",
+	"Female":     "Woman",
+	"Non-Binary": "NonBinary",
+	"Other":      "DifferentIdentity",
+}
+
+var genderNames = []string{"Woman", "Man", "NonBinary", "CulturallySpecific", "Transgender", "Questioning", "DifferentIdentity"}
+
+// GenderFields sets the gender columns of a Client.csv record.
+func GenderFields(gender string, record map[string]string) {
+	column, known := genderColumns[gender]
+	for _, name := range genderNames {
+		record[name] = NoCode
+		if known && name == column {
+			record[name] = YesCode
+		}
+	}
+	switch {
+	case known:
+		record["GenderNone"] = ""
+	case gender == "Prefer not to say":
+		record["GenderNone"] = PrefersNotToSay
+	default:
+		record["GenderNone"] = DataNotCollected
+	}
+}
+
+// RelationshipToHoH is the HMIS code for a household member's relationship
+// to the client, who is the head of household.
+func RelationshipToHoH(relationship string) string {
+	switch relationship {
+	case "Self":
+		return RelationshipSelf
+	case "Child":
+		return RelationshipChild
+	case "Spouse":
+		return RelationshipSpouse
+	case "Parent":
+		return RelationshipOtherRelative
+	}
+	return RelationshipOther
+}
+
+// YesNo is the HMIS code for a yes or no answer.
+func YesNo(answer bool) string {
+	if answer {
+		return YesCode
+	}
+	return NoCode
+}
+
+// Income sources, named after their IncomeBenefits.csv columns.
+const (
+	SourceEarned                 = "Earned"
+	SourceUnemployment           = "Unemployment"
+	SourceSSI                    = "SSI"
+	SourceSSDI                   = "SSDI"
+	SourceVADisabilityService    = "VADisabilityService"
+	SourceVADisabilityNonService = "VADisabilityNonService"
+	SourcePrivateDisability      = "PrivateDisability"
+	SourceWorkersComp            = "WorkersComp"
+	SourceTANF                   = "TANF"
+	SourceGA                     = "GA"
+	SourceSocSecRetirement       = "SocSecRetirement"
+	SourcePension                = "Pension"
+	SourceChildSupport           = "ChildSupport"
+	SourceAlimony                = "Alimony"
+	SourceOther                  = "OtherIncomeSource"
+)
+
+// IncomeSources lists the cash income sources in column order.
+var IncomeSources = []string{
+	SourceEarned, SourceUnemployment, SourceSSI, SourceSSDI, SourceVADisabilityService,
+	SourceVADisabilityNonService, SourcePrivateDisability, SourceWorkersComp, SourceTANF,
+	SourceGA, SourceSocSecRetirement, SourcePension, SourceChildSupport, SourceAlimony,
+	SourceOther,
+}
+
+// Non-cash benefits
+const (
+	BenefitSNAP  = "SNAP"
+	BenefitWIC   = "WIC"
+	BenefitOther = "OtherBenefitsSource"
+)
+
+// sourceKeywords picks an income source by the words in what the client
+// called it. They're checked in order, so more specific words come first.
+var sourceKeywords = []struct {
+	keyword string
+	source  string
+}{
+	{"ssdi", SourceSSDI},
+	{"ssi", SourceSSI},
+	{"non service", SourceVADisabilityNonService},
+	{"nonservice", SourceVADisabilityNonService},
+	{"va", SourceVADisabilityService},
+	{"veteran", SourceVADisabilityService},
+	{"veterans", SourceVADisabilityService},
+	{"workers comp", SourceWorkersComp},
+	{"workers compensation", SourceWorkersComp},
+	{"social security disability", SourceSSDI},
+	{"social security", SourceSocSecRetirement},
+	{"unemployment", SourceUnemployment},
+	{"disability", SourcePrivateDisability},
+	{"tanf", SourceTANF},
+	{"welfare", SourceTANF},
+	{"general assistance", SourceGA},
+	{"public assistance", SourceGA},
+	{"pension", SourcePension},
+	{"retirement", SourcePension},
+	{"annuity", SourcePension},
+	{"child support", SourceChildSupport},
+	{"alimony", SourceAlimony},
+	{"spousal support", SourceAlimony},
+}
+
+var benefitKeywords = []struct {
+	keyword string
+	benefit string
+}{
+	{"snap", BenefitSNAP},
+	{"food stamps", BenefitSNAP},
+	{"wic", BenefitWIC},
+}
+
+// words lowercases text and puts single spaces around its words, so
+// keywords can be matched as whole words.
+func words(text string) string {
+	text = strings.ReplaceAll(strings.ToLower(text), "'", "")
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < '0' || r > '9')
+	})
+	return " " + strings.Join(fields, " ") + " "
+}
+
+// IncomeSource is the IncomeBenefits.csv column an income entry is counted
+// under. Benefits QRHome doesn't recognize are Other.
+func IncomeSource(entry application.IncomeAndAssetData) string {
+	if application.IncomeCategory(entry) == application.IncomeEarned {
+		return SourceEarned
+	}
+	source := words(entry.Source)
+	for _, match := range sourceKeywords {
+		if strings.Contains(source, " "+match.keyword+" ") {
+			return match.source
+		}
+	}
+	return SourceOther
+}
+
+// NonCashBenefit is the non-cash benefit an entry is for, or "" if it's
+// cash income.
+func NonCashBenefit(source string) string {
+	text := words(source)
+	for _, match := range benefitKeywords {
+		if strings.Contains(text, " "+match.keyword+" ") {
+			return match.benefit
+		}
+	}
+	return ""
+}
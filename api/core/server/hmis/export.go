@@ -0,0 +1,636 @@
+package hmis
+
+// Exporting linked clients to the HMIS CSV format, so partners reporting to
+// HMIS don't have to re-key what QRHome already has. Each housing
+// application is an enrollment of the client's household in the program's
+// project, with the client as head of household.
+
+import (
+	"api/core/server/data/application"
+	"api/db"
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	dateLayout     = "2006-01-02"
+	dateTimeLayout = "2006-01-02 15:04:05"
+)
+
+// The name QRHome goes by in the export
+const sourceName = "QRHome"
+
+// A client's application to one program.
+type Application struct {
+	ID int
+	// The program's HousingProgram id, 0 if it's been deleted
+	ProgramID       int
+	Program         string
+	Provider        string
+	Status          db.ApplicationStatus
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	StatusChangedAt time.Time
+}
+
+// Client is everything exported for one client.
+type Client struct {
+	UserID int
+	Data   application.ApplicationData
+	// The whole household from the client's family links, the client
+	// included
+	Members      []application.FamilyMember
+	Applications []Application
+	UpdatedAt    time.Time
+}
+
+type Options struct {
+	// First and last day of the export, inclusive
+	Start time.Time
+	End   time.Time
+	// The user exporting
+	UserID    int
+	UserEmail string
+	// Full SSNs are only exported with a recent 2FA re-prompt, otherwise
+	// just the last four digits are.
+	FullSSN    bool
+	ExportedAt time.Time
+}
+
+type Issue struct {
+	File     string               `json:"file"`
+	RecordID string               `json:"record_id"`
+	Client   string               `json:"client"`
+	Field    string               `json:"field"`
+	Severity application.Severity `json:"severity"`
+	Message  string               `json:"message"`
+}
+
+// Report is the validation report of an export: what's in it, and what the
+// partner's HMIS will find missing or wrong.
+type Report struct {
+	Clients     int `json:"clients"`
+	People      int `json:"people"`
+	Enrollments int `json:"enrollments"`
+	Exits       int `json:"exits"`
+	Errors      int `json:"errors"`
+	Warnings    int `json:"warnings"`
+	// Clients with nothing to export in the date range
+	Skipped      []string `json:"skipped"`
+	Issues       []Issue  `json:"issues"`
+	NotCollected []string `json:"not_collected"`
+}
+
+// Table is one CSV file of the export.
+type Table struct {
+	Name    string
+	Columns []string
+	Records []map[string]string
+}
+
+type Export struct {
+	ID     string
+	Tables []*Table
+	Report Report
+}
+
+func (table *Table) add(record map[string]string) {
+	table.Records = append(table.Records, record)
+}
+
+// Table finds a file of the export by name.
+func (export *Export) Table(name string) *Table {
+	for _, table := range export.Tables {
+		if table.Name == name {
+			return table
+		}
+	}
+	return nil
+}
+
+// exporter builds an export, keeping track of what's been added so people
+// and projects are only written once.
+type exporter struct {
+	options       Options
+	export        *Export
+	organizations map[string]string
+	projects      map[string]string
+	people        map[string]bool
+}
+
+func (e *exporter) issue(file string, recordId string, client string, field string, severity application.Severity, message string) {
+	e.export.Report.Issues = append(e.export.Report.Issues, Issue{
+		File:     file,
+		RecordID: recordId,
+		Client:   client,
+		Field:    field,
+		Severity: severity,
+		Message:  message,
+	})
+	if severity == application.SeverityError {
+		e.export.Report.Errors++
+	} else {
+		e.export.Report.Warnings++
+	}
+}
+
+// stamp adds the columns every record ends with.
+func (e *exporter) stamp(record map[string]string, created time.Time, updated time.Time) map[string]string {
+	record["DateCreated"] = created.Format(dateTimeLayout)
+	record["DateUpdated"] = updated.Format(dateTimeLayout)
+	record["UserID"] = strconv.Itoa(e.options.UserID)
+	record["ExportID"] = e.export.ID
+	return record
+}
+
+// InRange checks if an application was open at some point between the
+// first and last day of the export.
+func InRange(app Application, start time.Time, end time.Time) bool {
+	if !app.CreatedAt.Before(end.AddDate(0, 0, 1)) {
+		return false
+	}
+	return !exited(app) || !app.StatusChangedAt.Before(start)
+}
+
+// exited checks if an application has ended without a move in.
+func exited(app Application) bool {
+	return app.Status == db.ApplicationStatusDenied || app.Status == db.ApplicationStatusWithdrawn
+}
+
+// organization finds or adds the organization for a program's provider.
+func (e *exporter) organization(provider string) string {
+	provider = strings.TrimSpace(provider)
+	if provider == "" {
+		provider = sourceName
+	}
+	if id, ok := e.organizations[provider]; ok {
+		return id
+	}
+	id := strconv.Itoa(len(e.organizations) + 1)
+	e.organizations[provider] = id
+	e.export.Table("Organization.csv").add(e.stamp(map[string]string{
+		"OrganizationID":        id,
+		"OrganizationName":      provider,
+		"VictimServiceProvider": NoCode,
+	}, e.options.ExportedAt, e.options.ExportedAt))
+	return id
+}
+
+// project finds or adds the project for an application's program. Programs
+// that have been deleted are told apart by name.
+func (e *exporter) project(app Application) string {
+	key := fmt.Sprintf("program:%d", app.ProgramID)
+	if app.ProgramID == 0 {
+		key = "name:" + app.Program
+	}
+	if id, ok := e.projects[key]; ok {
+		return id
+	}
+	id := strconv.Itoa(app.ProgramID)
+	if app.ProgramID == 0 {
+		id = fmt.Sprintf("D%d", len(e.projects)+1)
+		e.issue("Project.csv", id, "", "ProjectID", application.SeverityWarning, fmt.Sprintf("The %s program has been deleted from QRHome", app.Program))
+	}
+	e.projects[key] = id
+	e.export.Table("Project.csv").add(e.stamp(map[string]string{
+		"ProjectID":        id,
+		"OrganizationID":   e.organization(app.Provider),
+		"ProjectName":      app.Program,
+		"ContinuumProject": NoCode,
+		// Other, since QRHome doesn't know what kind of project it is
+		"ProjectType": "7",
+	}, e.options.ExportedAt, e.options.ExportedAt))
+	return id
+}
+
+// person is one member of a client's household, as HMIS sees them.
+type person struct {
+	id     string
+	member application.FamilyMember
+	// Only the client is head of household
+	head bool
+}
+
+// household lists the client and the members of their household. The
+// client's own family member, if they have one, is folded into them.
+func household(client Client) ([]person, map[int]string) {
+	info := client.Data.PersonalInfo
+	headId := fmt.Sprintf("U%d", client.UserID)
+	people := []person{{
+		id: headId,
+		member: application.FamilyMember{
+			FirstName:    info.FirstName,
+			LastName:     info.LastName,
+			Birthday:     info.Dob,
+			SSN:          info.SSN,
+			Gender:       info.Gender,
+			Relationship: "Self",
+		},
+		head: true,
+	}}
+	ids := map[int]string{}
+	for _, member := range client.Members {
+		if member.Relationship == "Self" {
+			ids[member.ID] = headId
+			continue
+		}
+		id := fmt.Sprintf("M%d", member.ID)
+		ids[member.ID] = id
+		people = append(people, person{id: id, member: member})
+	}
+	return people, ids
+}
+
+// ssn is the SSN column and its data quality. Without FullSSN only the last
+// four digits are exported.
+func (e *exporter) ssn(ssn string, recordId string, email string) (string, string) {
+	if strings.TrimSpace(ssn) == "" {
+		e.issue("Client.csv", recordId, email, "SSN", application.SeverityWarning, "SSN is missing")
+		return "", DataNotCollected
+	}
+	digits, err := application.NormalizeSSN(ssn)
+	if err != nil {
+		e.issue("Client.csv", recordId, email, "SSN", application.SeverityWarning, "SSN isn't valid and is left out")
+		return "", DataNotCollected
+	}
+	if !e.options.FullSSN {
+		return "xxxxx" + digits[5:], QualityPartial
+	}
+	return digits, QualityFull
+}
+
+// addPerson writes a household member to Client.csv, once however many
+// enrollments they have.
+func (e *exporter) addPerson(client Client, p person, firstEntry time.Time) {
+	if e.people[p.id] {
+		return
+	}
+	e.people[p.id] = true
+	e.export.Report.People++
+	email := client.Data.PersonalInfo.Email
+
+	record := map[string]string{
+		"PersonalID": p.id,
+		"FirstName":  strings.TrimSpace(p.member.FirstName),
+		"LastName":   strings.TrimSpace(p.member.LastName),
+	}
+	switch {
+	case record["FirstName"] != "" && record["LastName"] != "":
+		record["NameDataQuality"] = QualityFull
+	case record["FirstName"] != "" || record["LastName"] != "":
+		record["NameDataQuality"] = QualityPartial
+		e.issue("Client.csv", p.id, email, "NameDataQuality", application.SeverityWarning, "Only part of the name is known")
+	default:
+		record["NameDataQuality"] = DataNotCollected
+		e.issue("Client.csv", p.id, email, "FirstName", application.SeverityError, "Name is missing")
+	}
+
+	record["SSN"], record["SSNDataQuality"] = e.ssn(p.member.SSN, p.id, email)
+
+	dob, dobErr := application.ParseDate(p.member.Birthday)
+	switch {
+	case dobErr != nil || dob.Year() < 1900:
+		record["DOBDataQuality"] = DataNotCollected
+		e.issue("Client.csv", p.id, email, "DOB", application.SeverityError, "Date of birth is missing")
+	case dob.After(firstEntry):
+		record["DOBDataQuality"] = DataNotCollected
+		e.issue("Client.csv", p.id, email, "DOB", application.SeverityError, "Date of birth is after the application was started")
+	default:
+		record["DOB"] = dob.Format(dateLayout)
+		record["DOBDataQuality"] = QualityFull
+	}
+
+	for _, race := range []string{"AmIndAKNative", "Asian", "BlackAfAmerican", "HispanicLatinaeo", "MidEastNAfrican", "NativeHIPacific", "White"} {
+		record[race] = NoCode
+	}
+	record["RaceNone"] = DataNotCollected
+
+	GenderFields(p.member.Gender, record)
+	if p.member.Gender == "" {
+		e.issue("Client.csv", p.id, email, "GenderNone", application.SeverityWarning, "Gender is missing")
+	}
+
+	record["VeteranStatus"] = DataNotCollected
+	if p.head {
+		record["VeteranStatus"] = YesNo(client.Data.PersonalInfo.IsVeteran)
+	}
+
+	e.export.Table("Client.csv").add(e.stamp(record, firstEntry, client.UpdatedAt))
+}
+
+// personIncome is a household member's income at the start of an
+// enrollment, by IncomeBenefits.csv column.
+type personIncome struct {
+	amounts map[string]decimal.Decimal
+	// Sources with an amount that couldn't be worked out
+	unknown      map[string]bool
+	otherSources []string
+	benefits     map[string]bool
+	otherBenefit string
+}
+
+func newPersonIncome() *personIncome {
+	return &personIncome{
+		amounts:  map[string]decimal.Decimal{},
+		unknown:  map[string]bool{},
+		benefits: map[string]bool{},
+	}
+}
+
+// incomes sorts the household's income entries by person and source.
+func (e *exporter) incomes(client Client, ids map[int]string, headId string) map[string]*personIncome {
+	incomes := map[string]*personIncome{}
+	get := func(id string) *personIncome {
+		if incomes[id] == nil {
+			incomes[id] = newPersonIncome()
+		}
+		return incomes[id]
+	}
+	email := client.Data.PersonalInfo.Email
+
+	for _, entry := range client.Data.Income.IncomeAssetEntires {
+		if application.IncomeCategory(entry) == application.IncomeAsset {
+			continue
+		}
+		id, ok := ids[entry.FamilyMember.ID]
+		if !ok {
+			id = headId
+		}
+		income := get(id)
+
+		if benefit := NonCashBenefit(entry.Source); benefit != "" {
+			income.benefits[benefit] = true
+			continue
+		}
+		source := IncomeSource(entry)
+		if source == SourceOther {
+			income.otherSources = append(income.otherSources, strings.TrimSpace(entry.Source))
+		}
+		summary := application.SummarizeIncome(application.IncomeAndAssetsData{
+			IncomeAssetEntires: []application.IncomeAndAssetData{entry},
+		})
+		if len(summary.Entries) == 0 {
+			income.unknown[source] = true
+			e.issue("IncomeBenefits.csv", id, email, source+"Amount", application.SeverityWarning, fmt.Sprintf("The amount of %s income couldn't be worked out and is left blank", entry.Source))
+			continue
+		}
+		income.amounts[source] = income.amounts[source].Add(summary.Entries[0].Monthly)
+	}
+
+	// Government assistance the client named is a benefit of the head of
+	// household's.
+	assistance := client.Data.Income
+	if assistance.ReceivesGovAssistance {
+		income := get(headId)
+		if benefit := NonCashBenefit(assistance.AssistanceProgramName); benefit != "" {
+			income.benefits[benefit] = true
+		} else {
+			income.benefits[BenefitOther] = true
+			income.otherBenefit = strings.TrimSpace(assistance.AssistanceProgramName)
+		}
+	}
+	return incomes
+}
+
+// incomeRecord is a household member's IncomeBenefits.csv record.
+func incomeRecord(income *personIncome) map[string]string {
+	if income == nil {
+		income = newPersonIncome()
+	}
+	record := map[string]string{}
+	total := decimal.Zero
+	anyIncome := false
+	for _, source := range IncomeSources {
+		amountColumn := source + "Amount"
+		if source == SourceOther {
+			amountColumn = "OtherIncomeAmount"
+		}
+		amount, hasAmount := income.amounts[source]
+		if !hasAmount && !income.unknown[source] {
+			record[source] = NoCode
+			continue
+		}
+		anyIncome = true
+		record[source] = YesCode
+		if !income.unknown[source] {
+			record[amountColumn] = amount.StringFixed(2)
+			total = total.Add(amount)
+		}
+	}
+	record["IncomeFromAnySource"] = YesNo(anyIncome)
+	if anyIncome {
+		record["TotalMonthlyIncome"] = total.StringFixed(2)
+		record["OtherIncomeSourceIdentify"] = strings.Join(income.otherSources, ", ")
+	} else {
+		record["TotalMonthlyIncome"] = "0.00"
+	}
+
+	record["BenefitsFromAnySource"] = YesNo(len(income.benefits) > 0)
+	for _, benefit := range []string{BenefitSNAP, BenefitWIC, BenefitOther} {
+		record[benefit] = YesNo(income.benefits[benefit])
+	}
+	record["OtherBenefitsSourceIdentify"] = income.otherBenefit
+	record["InsuranceFromAnySource"] = DataNotCollected
+	return record
+}
+
+// addEnrollment writes an application's enrollment of each household
+// member, with their income and disabilities at project start, and its
+// exit if it has ended.
+func (e *exporter) addEnrollment(client Client, app Application, people []person, incomes map[string]*personIncome) {
+	projectId := e.project(app)
+	entryDate := app.CreatedAt.Format(dateLayout)
+	info := client.Data.PersonalInfo
+
+	for _, p := range people {
+		enrollmentId := fmt.Sprintf("%d-%s", app.ID, p.id)
+		e.export.Report.Enrollments++
+
+		disabling := DataNotCollected
+		if p.head {
+			disabling = YesNo(info.HasDisability)
+		}
+		e.export.Table("Enrollment.csv").add(e.stamp(map[string]string{
+			"EnrollmentID":       enrollmentId,
+			"PersonalID":         p.id,
+			"ProjectID":          projectId,
+			"EntryDate":          entryDate,
+			"HouseholdID":        fmt.Sprintf("H%d", app.ID),
+			"RelationshipToHoH":  RelationshipToHoH(p.member.Relationship),
+			"LivingSituation":    DataNotCollected,
+			"DisablingCondition": disabling,
+		}, app.CreatedAt, app.UpdatedAt))
+
+		income := incomeRecord(incomes[p.id])
+		income["IncomeBenefitsID"] = enrollmentId + "-" + StageProjectStart
+		income["EnrollmentID"] = enrollmentId
+		income["PersonalID"] = p.id
+		income["InformationDate"] = entryDate
+		income["DataCollectionStage"] = StageProjectStart
+		e.export.Table("IncomeBenefits.csv").add(e.stamp(income, app.CreatedAt, app.UpdatedAt))
+
+		if p.head {
+			response := NoCode
+			if info.HasDisability {
+				response = DataNotCollected
+			}
+			for _, disabilityType := range DisabilityTypes {
+				e.export.Table("Disabilities.csv").add(e.stamp(map[string]string{
+					"DisabilitiesID":      enrollmentId + "-" + disabilityType,
+					"EnrollmentID":        enrollmentId,
+					"PersonalID":          p.id,
+					"InformationDate":     entryDate,
+					"DisabilityType":      disabilityType,
+					"DisabilityResponse":  response,
+					"DataCollectionStage": StageProjectStart,
+				}, app.CreatedAt, app.UpdatedAt))
+			}
+		}
+
+		if exited(app) && app.StatusChangedAt.Before(e.options.End.AddDate(0, 0, 1)) {
+			e.export.Report.Exits++
+			e.export.Table("Exit.csv").add(e.stamp(map[string]string{
+				"ExitID":       enrollmentId,
+				"EnrollmentID": enrollmentId,
+				"PersonalID":   p.id,
+				"ExitDate":     app.StatusChangedAt.Format(dateLayout),
+				"Destination":  DestinationNoExitInterview,
+			}, app.StatusChangedAt, app.StatusChangedAt))
+		}
+	}
+}
+
+// addClient writes a client's household and their applications in the
+// date range. Returns false if there are none.
+func (e *exporter) addClient(client Client) bool {
+	apps := []Application{}
+	for _, app := range client.Applications {
+		if InRange(app, e.options.Start, e.options.End) {
+			apps = append(apps, app)
+		}
+	}
+	if len(apps) == 0 {
+		return false
+	}
+	e.export.Report.Clients++
+
+	firstEntry := apps[0].CreatedAt
+	for _, app := range apps {
+		if app.CreatedAt.Before(firstEntry) {
+			firstEntry = app.CreatedAt
+		}
+	}
+
+	people, ids := household(client)
+	for _, p := range people {
+		e.addPerson(client, p, firstEntry)
+	}
+	if client.Data.PersonalInfo.HasDisability {
+		e.issue("Disabilities.csv", people[0].id, client.Data.PersonalInfo.Email, "DisabilityResponse", application.SeverityWarning, "The client has a disability, but which kind has to be entered in HMIS")
+	}
+
+	incomes := e.incomes(client, ids, people[0].id)
+	for _, app := range apps {
+		e.addEnrollment(client, app, people, incomes)
+	}
+	return true
+}
+
+// Build exports the clients' applications in the date range. The report
+// lists clients with none.
+func Build(clients []Client, options Options) Export {
+	export := Export{
+		ID: options.ExportedAt.Format("20060102150405"),
+		Tables: []*Table{
+			{Name: "Export.csv", Columns: ExportColumns},
+			{Name: "Organization.csv", Columns: OrganizationColumns},
+			{Name: "User.csv", Columns: UserColumns},
+			{Name: "Project.csv", Columns: ProjectColumns},
+			{Name: "Client.csv", Columns: ClientColumns},
+			{Name: "Enrollment.csv", Columns: EnrollmentColumns},
+			{Name: "Exit.csv", Columns: ExitColumns},
+			{Name: "IncomeBenefits.csv", Columns: IncomeBenefitsColumns},
+			{Name: "Disabilities.csv", Columns: DisabilitiesColumns},
+		},
+		Report: Report{Skipped: []string{}, Issues: []Issue{}, NotCollected: NotCollected},
+	}
+	e := exporter{
+		options:       options,
+		export:        &export,
+		organizations: map[string]string{},
+		projects:      map[string]string{},
+		people:        map[string]bool{},
+	}
+
+	export.Table("Export.csv").add(map[string]string{
+		"ExportID":           export.ID,
+		"SourceType":         "2", // Standalone application
+		"SourceName":         sourceName,
+		"SourceContactEmail": options.UserEmail,
+		"ExportDate":         options.ExportedAt.Format(dateTimeLayout),
+		"ExportStartDate":    options.Start.Format(dateLayout),
+		"ExportEndDate":      options.End.Format(dateLayout),
+		"SoftwareName":       sourceName,
+		"CSVVersion":         CSVVersion,
+		"ExportPeriodType":   "3", // Reporting period
+		"ExportDirective":    "2", // Full refresh
+		"HashStatus":         "1", // Unhashed
+		"ImplementationID":   sourceName,
+	})
+	export.Table("User.csv").add(map[string]string{
+		"UserID":      strconv.Itoa(options.UserID),
+		"UserEmail":   options.UserEmail,
+		"DateCreated": options.ExportedAt.Format(dateTimeLayout),
+		"DateUpdated": options.ExportedAt.Format(dateTimeLayout),
+		"ExportID":    export.ID,
+	})
+
+	for _, client := range clients {
+		if !e.addClient(client) {
+			export.Report.Skipped = append(export.Report.Skipped, client.Data.PersonalInfo.Email)
+		}
+	}
+	return export
+}
+
+// WriteCSV writes the table with a header row.
+func (table *Table) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.UseCRLF = true
+	if err := writer.Write(table.Columns); err != nil {
+		return err
+	}
+	row := make([]string, len(table.Columns))
+	for _, record := range table.Records {
+		for i, column := range table.Columns {
+			row[i] = record[column]
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteZip writes every file of the export into a zip.
+func (export *Export) WriteZip(w io.Writer) error {
+	archive := zip.NewWriter(w)
+	for _, table := range export.Tables {
+		file, err := archive.Create(table.Name)
+		if err != nil {
+			return err
+		}
+		if err := table.WriteCSV(file); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
@@ -0,0 +1,242 @@
+package hmis
+
+// Exchanging client records with HMIS, the Homeless Management Information
+// System partner agencies report to.
+
+import (
+	"api/core/server/account"
+	"api/core/server/audit"
+	"api/core/server/data/application"
+	"api/db"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+type ExportReportResponse struct {
+	Success        bool   `json:"success"`
+	Report         Report `json:"report"`
+	ReauthRequired bool   `json:"reauth_required"`
+	Error          string `json:"error"`
+}
+
+// exportOptions reads the date range from the start and end params, which
+// default to the year up to today.
+func exportOptions(c echo.Context, user *db.UserModel) (Options, string) {
+	now := time.Now().UTC()
+	options := Options{
+		End:        time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC),
+		UserID:     user.ID,
+		UserEmail:  user.Email,
+		ExportedAt: now,
+	}
+	if end := c.QueryParam("end"); end != "" {
+		parsed, err := time.Parse(application.DateLayout, end)
+		if err != nil {
+			return options, "Invalid end date"
+		}
+		options.End = parsed
+	}
+	options.Start = options.End.AddDate(-1, 0, 0)
+	if start := c.QueryParam("start"); start != "" {
+		parsed, err := time.Parse(application.DateLayout, start)
+		if err != nil {
+			return options, "Invalid start date"
+		}
+		options.Start = parsed
+	}
+	if options.Start.After(options.End) {
+		return options, "Start date is after the end date"
+	}
+	return options, ""
+}
+
+// caseloadOwner finds whose caseload is exported. Caseworkers export their
+// own, admins the one of the caseworker in the caseworker param.
+func caseloadOwner(client *db.PrismaClient, user *db.UserModel, caseworkerEmail string) (*db.UserModel, int, string) {
+	if user.Type == db.UserTypeCaseWorker {
+		return user, 200, ""
+	}
+	if user.Type != db.UserTypeAdmin {
+		return nil, 400, "Only caseworkers and admins can export to HMIS"
+	}
+	if caseworkerEmail == "" {
+		return nil, 400, "Give the caseworker whose caseload to export"
+	}
+	caseworker, err := client.User.FindUnique(
+		db.User.Email.Equals(caseworkerEmail),
+	).Exec(context.Background())
+	if err != nil || caseworker.Type != db.UserTypeCaseWorker {
+		return nil, 404, "Caseworker not found"
+	}
+	return caseworker, 200, ""
+}
+
+// loadClients loads the caseload, or with the email param just one client
+// of it. Clients without an application are returned separately.
+func loadClients(client *db.PrismaClient, caseworker *db.UserModel, clientEmail string) ([]Client, []string, error) {
+	filters := []db.UserLinkWhereParam{db.UserLink.CaseworkerID.Equals(caseworker.ID)}
+	if clientEmail != "" {
+		filters = append(filters, db.UserLink.Client.Where(db.User.Email.Equals(clientEmail)))
+	}
+	links, err := client.UserLink.FindMany(filters...).With(
+		db.UserLink.Client.Fetch().With(
+			db.User.PersonalInfo.Fetch().With(
+				db.PersonalInfo.FamilyLinks.Fetch().With(
+					db.FamilyLink.FamilyMember.Fetch(),
+				),
+			),
+			db.User.ApplicationData.Fetch().With(
+				application.ApplicationWith...,
+			),
+			db.User.HousingApplications.Fetch().With(
+				db.HousingApplication.HousingProgram.Fetch(),
+			),
+		),
+	).Exec(context.Background())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clients := []Client{}
+	skipped := []string{}
+	for _, link := range links {
+		linkClient := link.Client()
+		applicationData, ok := linkClient.ApplicationData()
+		if !ok {
+			skipped = append(skipped, linkClient.Email)
+			continue
+		}
+		personalInfo := linkClient.PersonalInfo()
+
+		data, err := application.ToApplicationData(applicationData, nil, personalInfo, linkClient.Email)
+		if err != nil {
+			return nil, nil, fmt.Errorf("application data of user %d: %w", linkClient.ID, err)
+		}
+		exported := Client{
+			UserID:    linkClient.ID,
+			Data:      data,
+			UpdatedAt: applicationData.UpdatedAt,
+		}
+		for _, familyLink := range personalInfo.FamilyLinks() {
+			member, err := application.ToFamilyMember(familyLink.FamilyMember())
+			if err != nil {
+				return nil, nil, fmt.Errorf("family member %d: %w", familyLink.FamilyMemberID, err)
+			}
+			exported.Members = append(exported.Members, member)
+		}
+		for _, housingApplication := range linkClient.HousingApplications() {
+			app := Application{
+				ID:              housingApplication.ID,
+				Program:         housingApplication.Program,
+				Status:          housingApplication.Status,
+				CreatedAt:       housingApplication.CreatedAt,
+				UpdatedAt:       housingApplication.UpdatedAt,
+				StatusChangedAt: housingApplication.StatusChangedAt,
+			}
+			if program, ok := housingApplication.HousingProgram(); ok {
+				app.ProgramID = program.ID
+				app.Program = program.Name
+				app.Provider = program.Provider
+			}
+			exported.Applications = append(exported.Applications, app)
+		}
+		clients = append(clients, exported)
+	}
+	return clients, skipped, nil
+}
+
+// buildExport exports the caseload for the request. On failure, returns the
+// status code and error message to respond with.
+func buildExport(c echo.Context, client *db.PrismaClient, user *db.UserModel, options Options) ([]Client, Export, int, string) {
+	caseworker, status, ownerErr := caseloadOwner(client, user, c.QueryParam("caseworker"))
+	if ownerErr != "" {
+		return nil, Export{}, status, ownerErr
+	}
+
+	clients, skipped, loadErr := loadClients(client, caseworker, c.QueryParam("email"))
+	if loadErr != nil {
+		fmt.Printf("[ERROR] Failed to get caseload for caseworker %d: %v\n", caseworker.ID, loadErr)
+		return nil, Export{}, 500, "Failed to get caseload"
+	}
+	if len(clients) == 0 && len(skipped) == 0 && c.QueryParam("email") != "" {
+		return nil, Export{}, 400, "Not a caseworker for account"
+	}
+
+	export := Build(clients, options)
+	export.Report.Skipped = append(export.Report.Skipped, skipped...)
+	return clients, export, 200, ""
+}
+
+// ExportHandler downloads a caseworker's linked clients in the HMIS CSV
+// format, as a zip. With full_ssn=true, full SSNs are exported, which takes
+// a recent 2FA re-prompt. Every client exported is audited.
+func ExportHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, ExportReportResponse{Success: false, Error: "Failed to authenticate"})
+	}
+	options, optionsErr := exportOptions(c, user)
+	if optionsErr != "" {
+		return c.JSON(400, ExportReportResponse{Success: false, Error: optionsErr})
+	}
+	if c.QueryParam("full_ssn") == "true" {
+		if !account.HasRecentReauth(user) {
+			return c.JSON(403, ExportReportResponse{Success: false, ReauthRequired: true, Error: "Confirm it's you with 2FA to export full SSNs"})
+		}
+		options.FullSSN = true
+	}
+
+	clients, export, status, exportErr := buildExport(c, client, user, options)
+	if exportErr != "" {
+		return c.JSON(status, ExportReportResponse{Success: false, Error: exportErr})
+	}
+
+	var archive bytes.Buffer
+	if err := export.WriteZip(&archive); err != nil {
+		fmt.Printf("[ERROR] Failed to write HMIS export for user %d: %v\n", user.ID, err)
+		return c.JSON(500, ExportReportResponse{Success: false, Error: "Failed to write export"})
+	}
+
+	// Nothing is exported without a record of it
+	target := fmt.Sprintf("hmis_export:%s", export.ID)
+	if options.FullSSN {
+		target += ":full_ssn"
+	}
+	for _, exported := range clients {
+		if slices.Contains(export.Report.Skipped, exported.Data.PersonalInfo.Email) {
+			continue
+		}
+		if auditErr := audit.Record(c, client, user, exported.UserID, audit.ActionExportHMIS, target); auditErr != nil {
+			return c.JSON(500, ExportReportResponse{Success: false, Error: "Failed to record export"})
+		}
+	}
+
+	c.Response().Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"hmis-export-%s.zip\"", export.ID))
+	return c.Blob(http.StatusOK, "application/zip", archive.Bytes())
+}
+
+// ExportReportHandler validates an export without downloading it: what's
+// in it, and what's missing or wrong for HMIS. It takes the same params as
+// ExportHandler.
+func ExportReportHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, ExportReportResponse{Success: false, Error: "Failed to authenticate"})
+	}
+	options, optionsErr := exportOptions(c, user)
+	if optionsErr != "" {
+		return c.JSON(400, ExportReportResponse{Success: false, Error: optionsErr})
+	}
+
+	_, export, status, exportErr := buildExport(c, client, user, options)
+	if exportErr != "" {
+		return c.JSON(status, ExportReportResponse{Success: false, Error: exportErr})
+	}
+	return c.JSON(200, ExportReportResponse{Success: true, Report: export.Report, Error: ""})
+}
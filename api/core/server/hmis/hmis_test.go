@@ -0,0 +1,392 @@
+package hmis
+
+// HMIS export and import unit tests
+
+import (
+	"api/core/server/data/application"
+	"api/db"
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+var mock_options = Options{
+	Start:      date(2026, time.January, 1),
+	End:        date(2026, time.June, 30),
+	UserID:     7,
+	UserEmail:  "caseworker@example.com",
+	ExportedAt: date(2026, time.July, 1),
+}
+
+func mockClient() Client {
+	self := application.FamilyMember{ID: 10, FirstName: "Ana", LastName: "Núñez", Relationship: "Self"}
+	child := application.FamilyMember{ID: 11, FirstName: "Luz", LastName: "Núñez", Gender: "Female", Relationship: "Child"}
+	return Client{
+		UserID: 3,
+		Data: application.ApplicationData{
+			PersonalInfo: application.PersonalInfo{
+				FirstName: "Ana", LastName: "Núñez", Email: "ana@example.com", Dob: "1982-09-12",
+				SSN: "123-45-6789", Gender: "Female", IsVeteran: true, HasDisability: true,
+			},
+			Income: application.IncomeAndAssetsData{
+				IncomeAssetEntires: []application.IncomeAndAssetData{
+					{FamilyMember: self, Type: "Income", Source: "Grocery store", Amount: "15", FrequencyOrLocation: "Hourly"},
+					{FamilyMember: self, Type: "Income", Source: "SSDI", Amount: "$900", FrequencyOrLocation: "Monthly"},
+					{FamilyMember: child, Type: "Income", Source: "Child support", Amount: "lots", FrequencyOrLocation: "Monthly"},
+					{FamilyMember: self, Type: "Income", Source: "SNAP benefits", Amount: "200", FrequencyOrLocation: "Monthly"},
+					{FamilyMember: self, Type: "Asset", Source: "Savings", Amount: "1000"},
+				},
+			},
+		},
+		Members: []application.FamilyMember{self, child},
+		Applications: []Application{
+			{ID: 1, ProgramID: 4, Program: "Section 8", Provider: "Housing Authority", Status: db.ApplicationStatusSubmitted, CreatedAt: date(2026, time.February, 3), UpdatedAt: date(2026, time.February, 4), StatusChangedAt: date(2026, time.February, 4)},
+			{ID: 2, Program: "Old program", Status: db.ApplicationStatusWithdrawn, CreatedAt: date(2025, time.November, 1), UpdatedAt: date(2026, time.March, 1), StatusChangedAt: date(2026, time.March, 1)},
+			{ID: 3, ProgramID: 4, Program: "Section 8", Status: db.ApplicationStatusDenied, CreatedAt: date(2025, time.June, 1), UpdatedAt: date(2025, time.July, 1), StatusChangedAt: date(2025, time.July, 1)},
+		},
+	}
+}
+
+func record(table *Table, column string, value string) map[string]string {
+	for _, record := range table.Records {
+		if record[column] == value {
+			return record
+		}
+	}
+	return nil
+}
+
+func TestIncomeSource(t *testing.T) {
+	cases := map[string]string{
+		"Walmart":                    SourceEarned,
+		"SSI":                        SourceSSI,
+		"Social Security Disability": SourceSSDI,
+		"Social Security":            SourceSocSecRetirement,
+		"VA disability":              SourceVADisabilityService,
+		"VA non service pension":     SourceVADisabilityNonService,
+		"Long term disability":       SourcePrivateDisability,
+		"Unemployment benefits":      SourceUnemployment,
+		"Child support":              SourceChildSupport,
+		"Tribal benefits":            SourceOther,
+	}
+	for source, expected := range cases {
+		entry := application.IncomeAndAssetData{Type: "Income", Source: source}
+		assert.Equal(t, expected, IncomeSource(entry), "%s should be counted under %s", source, expected)
+	}
+	assert.Equal(t, BenefitSNAP, NonCashBenefit("Food stamps"), "Food stamps should be SNAP")
+	assert.Equal(t, "", NonCashBenefit("Wages"), "Wages should not be a benefit")
+}
+
+func TestGenderFields(t *testing.T) {
+	record := map[string]string{}
+	GenderFields("Non-Binary", record)
+	assert.Equal(t, YesCode, record["NonBinary"], "Gender should be marked")
+	assert.Equal(t, NoCode, record["Woman"], "Other genders should not be")
+	assert.Equal(t, "", record["GenderNone"], "GenderNone should be blank with a gender")
+
+	GenderFields("Prefer not to say", record)
+	assert.Equal(t, NoCode, record["NonBinary"], "No gender should be marked")
+	assert.Equal(t, PrefersNotToSay, record["GenderNone"], "Refusals should be recorded")
+
+	GenderFields("", record)
+	assert.Equal(t, DataNotCollected, record["GenderNone"], "Missing genders should not be collected")
+}
+
+func TestInRange(t *testing.T) {
+	client := mockClient()
+	assert.True(t, InRange(client.Applications[0], mock_options.Start, mock_options.End), "Open applications started in the range should be included")
+	assert.True(t, InRange(client.Applications[1], mock_options.Start, mock_options.End), "Applications that ended in the range should be included")
+	assert.False(t, InRange(client.Applications[2], mock_options.Start, mock_options.End), "Applications that ended before the range should not be")
+	assert.True(t, InRange(client.Applications[0], mock_options.Start, date(2026, time.February, 3)), "The last day should be included")
+	assert.False(t, InRange(client.Applications[0], mock_options.Start, date(2026, time.February, 2)), "Applications started after the range should not be")
+}
+
+func TestBuild(t *testing.T) {
+	empty := mockClient()
+	empty.Data.PersonalInfo.Email = "empty@example.com"
+	empty.Applications = empty.Applications[2:]
+
+	export := Build([]Client{mockClient(), empty}, mock_options)
+	assert.Equal(t, 1, export.Report.Clients, "Only clients with applications in range should be exported")
+	assert.Equal(t, []string{"empty@example.com"}, export.Report.Skipped, "Clients without should be reported")
+	assert.Equal(t, 2, export.Report.People, "The household should be exported once")
+	assert.Equal(t, 4, export.Report.Enrollments, "Each member should be enrolled in each application")
+	assert.Equal(t, 2, export.Report.Exits, "Withdrawn applications should be exited")
+	assert.Len(t, export.Table("Project.csv").Records, 2, "Each program should be a project")
+
+	clients := export.Table("Client.csv")
+	head := record(clients, "PersonalID", "U3")
+	assert.Equal(t, "xxxxx6789", head["SSN"], "Only the last four digits should be exported")
+	assert.Equal(t, QualityPartial, head["SSNDataQuality"], "Partial SSNs should be reported as partial")
+	assert.Equal(t, YesCode, head["Woman"], "Gender should be mapped")
+	assert.Equal(t, YesCode, head["VeteranStatus"], "Veteran status should be mapped")
+	child := record(clients, "PersonalID", "M11")
+	assert.Equal(t, DataNotCollected, child["SSNDataQuality"], "Missing SSNs should not be collected")
+	assert.Equal(t, DataNotCollected, child["VeteranStatus"], "Members' veteran status isn't collected")
+
+	enrollment := record(export.Table("Enrollment.csv"), "EnrollmentID", "1-M11")
+	assert.Equal(t, RelationshipChild, enrollment["RelationshipToHoH"], "Relationship should be mapped")
+	assert.Equal(t, "H1", enrollment["HouseholdID"], "Members should share a household")
+	assert.Equal(t, "2026-02-03", enrollment["EntryDate"], "Entry should be when the application was started")
+	assert.Equal(t, YesCode, record(export.Table("Enrollment.csv"), "EnrollmentID", "1-U3")["DisablingCondition"], "Disability should be mapped")
+
+	income := record(export.Table("IncomeBenefits.csv"), "EnrollmentID", "1-U3")
+	assert.Equal(t, "2600.00", income["EarnedAmount"], "Hourly wages should be monthly")
+	assert.Equal(t, "900.00", income["SSDIAmount"], "Benefits should be counted by source")
+	assert.Equal(t, "3500.00", income["TotalMonthlyIncome"], "Income should be totaled")
+	assert.Equal(t, YesCode, income["SNAP"], "SNAP should be a non-cash benefit")
+	childIncome := record(export.Table("IncomeBenefits.csv"), "EnrollmentID", "1-M11")
+	assert.Equal(t, YesCode, childIncome["ChildSupport"], "Income with an unknown amount should still be marked")
+	assert.Equal(t, "", childIncome["ChildSupportAmount"], "Unknown amounts should be left blank")
+
+	assert.Len(t, export.Table("Disabilities.csv").Records, 2*len(DisabilityTypes), "Each disability type should be given for the client")
+	assert.Equal(t, 1, export.Report.Errors, "Only the child's missing birthday should be an error")
+	assert.Positive(t, export.Report.Warnings, "Missing data should be warned about")
+
+	fullSSN := mock_options
+	fullSSN.FullSSN = true
+	export = Build([]Client{mockClient()}, fullSSN)
+	assert.Equal(t, "123456789", record(export.Table("Client.csv"), "PersonalID", "U3")["SSN"], "Full SSNs should be exported when allowed")
+}
+
+func TestWriteZip(t *testing.T) {
+	export := Build([]Client{mockClient()}, mock_options)
+	var buffer bytes.Buffer
+	assert.NoError(t, export.WriteZip(&buffer), "Export should be zipped")
+
+	archive, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	assert.NoError(t, err, "Zip should be readable")
+	assert.Len(t, archive.File, len(export.Tables), "Every file should be in the zip")
+
+	for _, file := range archive.File {
+		if file.Name != "Client.csv" {
+			continue
+		}
+		reader, _ := file.Open()
+		rows, err := csv.NewReader(reader).ReadAll()
+		assert.NoError(t, err, "CSV should be readable")
+		assert.Equal(t, ClientColumns, rows[0], "The header should list every column")
+		assert.Len(t, rows, 3, "Every person should have a row")
+	}
+}
+
+// mockImport exports the mock client with full SSNs, the way an agency's
+// HMIS would have them, and reads the export back in
+func mockImport(t *testing.T, client Client) map[string]*Table {
+	fullSSN := mock_options
+	fullSSN.FullSSN = true
+	export := Build([]Client{client}, fullSSN)
+	var buffer bytes.Buffer
+	assert.NoError(t, export.WriteZip(&buffer), "Export should be zipped")
+	tables, err := ReadFiles(map[string][]byte{"export.zip": buffer.Bytes()})
+	assert.NoError(t, err, "Export should be readable")
+	return tables
+}
+
+func newClient() Existing {
+	return Existing{
+		Data: application.ApplicationData{
+			PersonalInfo: application.PersonalInfo{FirstName: "ana", LastName: "Núñez", Email: "ana@example.com", Dob: "1982-09-12"},
+		},
+		Members: []application.FamilyMember{{ID: 20, FirstName: "Ana", LastName: "Núñez", Relationship: "Self"}},
+	}
+}
+
+func TestReadFiles(t *testing.T) {
+	tables, err := ReadFiles(map[string][]byte{
+		"export/client.CSV": []byte("\xef\xbb\xbfPersonalID,FirstName\r\n1, Ana \r\n"),
+		"Notes.txt":         []byte("not HMIS"),
+	})
+	assert.NoError(t, err, "Files should be read")
+	assert.Equal(t, "Ana", records(tables, "Client.csv")[0]["FirstName"], "File names should match by any case and values should be trimmed")
+	assert.Nil(t, records(tables, "Enrollment.csv"), "Missing files should have no records")
+
+	_, err = ReadFiles(map[string][]byte{"Enrollment.csv": []byte("EnrollmentID\r\n1\r\n")})
+	assert.ErrorIs(t, err, ErrInvalidImport, "Client.csv should be required")
+	_, err = ReadFiles(map[string][]byte{"export.zip": []byte("not a zip")})
+	assert.ErrorIs(t, err, ErrInvalidImport, "Broken zips should be invalid")
+}
+
+func zipFiles(t *testing.T, files map[string][]byte) []byte {
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for name, data := range files {
+		entry, err := writer.Create(name)
+		assert.NoError(t, err, "Failed to add %s to zip", name)
+		entry.Write(data)
+	}
+	assert.NoError(t, writer.Close(), "Failed to write zip")
+	return buffer.Bytes()
+}
+
+func TestReadFilesLimits(t *testing.T) {
+	client := []byte("PersonalID,FirstName\r\n1,Ana\r\n")
+	_, err := ReadFiles(map[string][]byte{"export.zip": zipFiles(t, map[string][]byte{"Client.csv": client})})
+	assert.NoError(t, err, "Zips should be read")
+
+	inner := zipFiles(t, map[string][]byte{"Client.csv": client})
+	_, err = ReadFiles(map[string][]byte{"export.zip": zipFiles(t, map[string][]byte{"inner.zip": inner})})
+	assert.ErrorIs(t, err, ErrInvalidImport, "Zips in zips should be rejected")
+
+	many := map[string][]byte{"Client.csv": client}
+	for i := 0; i < maxZipEntries; i++ {
+		many[fmt.Sprintf("extra/%d.txt", i)] = nil
+	}
+	_, err = ReadFiles(map[string][]byte{"export.zip": zipFiles(t, many)})
+	assert.ErrorIs(t, err, ErrInvalidImport, "Zips with too many files should be rejected")
+
+	large := append(append([]byte{}, client...), bytes.Repeat([]byte(" "), maxEntrySize)...)
+	_, err = ReadFiles(map[string][]byte{"export.zip": zipFiles(t, map[string][]byte{"Client.csv": large})})
+	assert.ErrorIs(t, err, ErrInvalidImport, "Files that unzip too large should be rejected")
+}
+
+func TestMatches(t *testing.T) {
+	client := identity{firstName: "ana", lastName: "nunez", dob: "1982-09-12", ssn: "123456789"}
+	cases := []struct {
+		other    identity
+		expected bool
+		message  string
+	}{
+		{identity{firstName: "ana", lastName: "nunez", dob: "1982-09-12"}, true, "Name and DOB should match without an SSN"},
+		{identity{firstName: "anita", lastName: "nunez", dob: "1982-09-12", ssn: "123456789"}, true, "SSN and DOB should match with a different name"},
+		{identity{firstName: "ana", lastName: "nunez", dob: "1982-09-12", ssn: "987654321"}, false, "Different SSNs should never match"},
+		{identity{firstName: "ana", lastName: "nunez", dob: "1982-09-12", ssn: "xxxxx6789"}, true, "Partial SSNs should match the last four digits"},
+		{identity{firstName: "anita", lastName: "nunez", dob: "1982-09-12", ssn: "xxxxx6789"}, false, "Partial SSNs shouldn't be enough without the name"},
+		{identity{firstName: "ana", lastName: "nunez", dob: "1983-09-12"}, false, "Name alone shouldn't match"},
+	}
+	for _, test := range cases {
+		assert.Equal(t, test.expected, client.matches(test.other), test.message)
+	}
+}
+
+func TestPlanImport(t *testing.T) {
+	client := mockClient()
+	client.Members[1].Birthday = "2015-04-02"
+	client.Data.Income.IncomeAssetEntires[0].FamilyMember.Birthday = "2015-04-02"
+	tables := mockImport(t, client)
+
+	result, err := PlanImport(tables, newClient(), "", nil)
+	assert.NoError(t, err, "The client should be matched")
+	assert.Equal(t, "U3", result.PersonalID, "The client should be matched on name and DOB")
+	assert.Equal(t, "H1", result.HouseholdID, "The latest enrollment's household should be imported")
+	assert.Empty(t, result.Conflicts, "Blank answers should be filled in without conflicts")
+
+	info := result.Data.PersonalInfo
+	assert.Equal(t, "ana", info.FirstName, "Names differing only in case should be kept")
+	assert.Equal(t, "123456789", info.SSN, "SSN should be filled in")
+	assert.Equal(t, "Female", info.Gender, "Gender should be filled in")
+	assert.True(t, info.IsVeteran, "Veteran status should be filled in")
+	assert.True(t, info.HasDisability, "Disability should be filled in")
+
+	assert.Len(t, result.Members, 1, "The child should be imported")
+	child := result.Members[0]
+	assert.True(t, child.New, "The child should be added")
+	assert.Equal(t, -1, child.Member.ID, "New members should have temporary ids")
+	assert.Equal(t, "Child", child.Member.Relationship, "Relationship should be mapped back")
+	assert.Equal(t, "2015-04-02", child.Member.Birthday, "Birthday should be imported")
+
+	income := result.Data.Income
+	assert.Len(t, income.IncomeAssetEntires, 2, "Income with an amount should be imported")
+	for _, entry := range income.IncomeAssetEntires {
+		assert.Equal(t, 20, entry.FamilyMember.ID, "The client's income should be theirs")
+		assert.Contains(t, []string{SourceEarned, SourceSSDI}, IncomeSource(entry), "Imported sources should map back to HMIS's")
+	}
+	assert.True(t, income.ReceivesGovAssistance, "SNAP should be government assistance")
+	assert.Equal(t, BenefitSNAP, income.AssistanceProgramName, "The benefit should be named")
+	assert.NotEmpty(t, result.Issues, "Income without an amount should be reported")
+
+	// Importing again changes nothing
+	existing := newClient()
+	existing.Data = result.Data
+	existing.HasApplication = true
+	existing.Members = append(existing.Members, child.Member)
+	existing.Members[1].ID = 21
+	again, err := PlanImport(tables, existing, "", nil)
+	assert.NoError(t, err, "The client should be matched again")
+	assert.Empty(t, again.Changes, "Nothing should change")
+	assert.Empty(t, again.Conflicts, "Nothing should conflict")
+	assert.False(t, again.Members[0].Changed, "The child should be matched")
+}
+
+func TestPlanImportConflicts(t *testing.T) {
+	tables := mockImport(t, mockClient())
+	existing := newClient()
+	existing.HasApplication = true
+	existing.Data.PersonalInfo.Gender = "Male"
+	existing.Data.Income.IncomeAssetEntires = []application.IncomeAndAssetData{
+		{FamilyMember: existing.Members[0], Type: "Income", Source: "SSDI", Amount: "800", FrequencyOrLocation: "Monthly"},
+	}
+
+	result, err := PlanImport(tables, existing, "", nil)
+	assert.NoError(t, err, "The client should be matched")
+	keys := []string{}
+	for _, conflict := range result.Conflicts {
+		keys = append(keys, conflict.Key)
+	}
+	assert.Contains(t, keys, "U3.gender", "Different genders should conflict")
+	assert.Contains(t, keys, "U3.income."+SourceSSDI, "Different amounts should conflict")
+	assert.Contains(t, keys, "U3.is_veteran", "Different answers should conflict once there's an application")
+	assert.Equal(t, "Male", result.Data.PersonalInfo.Gender, "QRHome's answer should be kept")
+	assert.Equal(t, "800", result.Data.Income.IncomeAssetEntires[0].Amount, "QRHome's income should be kept")
+
+	result, err = PlanImport(tables, existing, "", []string{"U3.gender", "U3.income." + SourceSSDI})
+	assert.NoError(t, err, "The client should be matched")
+	assert.Equal(t, "Female", result.Data.PersonalInfo.Gender, "Accepted answers should be imported")
+	assert.False(t, result.Data.PersonalInfo.IsVeteran, "Answers not accepted should be kept")
+	for _, entry := range result.Data.Income.IncomeAssetEntires {
+		assert.NotEqual(t, "800", entry.Amount, "Accepted income should replace QRHome's")
+	}
+}
+
+func TestPlanImportMatching(t *testing.T) {
+	tables := mockImport(t, mockClient())
+	twin := map[string]string{}
+	for column, value := range records(tables, "Client.csv")[0] {
+		twin[column] = value
+	}
+	twin["PersonalID"] = "U4"
+	twin["SSN"] = ""
+	twin["SSNDataQuality"] = DataNotCollected
+	tables["Client.csv"].add(twin)
+
+	_, err := PlanImport(tables, newClient(), "", nil)
+	assert.ErrorIs(t, err, ErrAmbiguousMatch, "Two people with the same name and DOB should be ambiguous")
+	result, _ := PlanImport(tables, newClient(), "", nil)
+	assert.Len(t, result.Candidates, 2, "Both should be candidates")
+
+	withSSN := newClient()
+	withSSN.Data.PersonalInfo.SSN = "123-45-6789"
+	result, err = PlanImport(tables, withSSN, "", nil)
+	assert.NoError(t, err, "The SSN should tell them apart")
+	assert.Equal(t, "U3", result.PersonalID, "The person with the same SSN should be matched")
+
+	result, err = PlanImport(tables, newClient(), "U4", nil)
+	assert.NoError(t, err, "The caseworker should be able to pick")
+	assert.Equal(t, "U4", result.PersonalID, "The picked person should be imported")
+
+	stranger := newClient()
+	stranger.Data.PersonalInfo.Dob = "1990-01-01"
+	_, err = PlanImport(tables, stranger, "", nil)
+	assert.ErrorIs(t, err, ErrNoMatch, "Other people shouldn't match")
+
+	result, err = PlanImport(tables, stranger, "U4", nil)
+	assert.ErrorIs(t, err, ErrIdentityMismatch, "Picking someone else should need confirming")
+	assert.Empty(t, result.PersonalID, "Nothing should be imported without confirming")
+	assert.Len(t, result.Conflicts, 1, "The mismatch should be a conflict")
+	assert.Equal(t, "U4.identity", result.Conflicts[0].Key, "Bad identity conflict key")
+	assert.NotContains(t, result.Conflicts[0].Imported, "6789", "SSNs should not be shown")
+
+	result, err = PlanImport(tables, stranger, "U4", []string{"U4.identity"})
+	assert.NoError(t, err, "A confirmed pick should be imported")
+	assert.Equal(t, "U4", result.PersonalID, "The confirmed person should be imported")
+	assert.True(t, result.Conflicts[0].Accepted, "The identity conflict should be accepted")
+}
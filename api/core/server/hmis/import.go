@@ -0,0 +1,774 @@
+package hmis
+
+// Importing a client's history from an agency's HMIS export, so clients
+// already in HMIS don't have to enter it again. The client is found in
+// Client.csv by name, date of birth and SSN, and their household and income
+// come from their latest enrollment. Answers QRHome already has are kept
+// unless the caseworker accepts HMIS's, and every difference is reported as
+// a conflict.
+
+import (
+	"api/core/server/data/application"
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidImport  = errors.New("invalid HMIS files")
+	ErrNoMatch        = errors.New("no one in the HMIS files matches the client")
+	ErrAmbiguousMatch = errors.New("more than one person in the HMIS files matches the client")
+	// The personal id picked is someone with another name, date of birth
+	// or SSN, and its identity conflict hasn't been accepted
+	ErrIdentityMismatch = errors.New("the picked person in the HMIS files doesn't match the client")
+)
+
+// What each HMIS income source is called when it's imported. They're
+// worded so IncomeSource puts them back under the same source.
+var sourceLabels = map[string]string{
+	SourceEarned:                 "Earned income",
+	SourceUnemployment:           "Unemployment",
+	SourceSSI:                    "SSI",
+	SourceSSDI:                   "SSDI",
+	SourceVADisabilityService:    "VA disability (service connected)",
+	SourceVADisabilityNonService: "VA disability (non service connected)",
+	SourcePrivateDisability:      "Private disability insurance",
+	SourceWorkersComp:            "Workers compensation",
+	SourceTANF:                   "TANF",
+	SourceGA:                     "General public assistance",
+	SourceSocSecRetirement:       "Social Security retirement",
+	SourcePension:                "Pension",
+	SourceChildSupport:           "Child support",
+	SourceAlimony:                "Alimony",
+	SourceOther:                  "Other benefits",
+}
+
+// Existing is what QRHome has for the client. Without an application, Data
+// only has the personal info from their account.
+type Existing struct {
+	Data           application.ApplicationData
+	HasApplication bool
+	// The whole household from the client's family links, the client
+	// included
+	Members []application.FamilyMember
+}
+
+// Conflict is an answer HMIS has that's different from QRHome's. QRHome's
+// is kept unless the caseworker accepts it by its key.
+type Conflict struct {
+	Key        string `json:"key"`
+	PersonalID string `json:"personal_id"`
+	Name       string `json:"name"`
+	Field      string `json:"field"`
+	Current    string `json:"current"`
+	Imported   string `json:"imported"`
+	Accepted   bool   `json:"accepted"`
+}
+
+// Candidate is someone in the HMIS files who could be the client.
+type Candidate struct {
+	PersonalID string `json:"personal_id"`
+	FirstName  string `json:"first_name"`
+	LastName   string `json:"last_name"`
+	DOB        string `json:"dob"`
+}
+
+type MemberImport struct {
+	PersonalID string                   `json:"personal_id"`
+	Member     application.FamilyMember `json:"member"`
+	// New members have a negative id until they're saved, which their
+	// income entries use too
+	New     bool `json:"new"`
+	Changed bool `json:"changed"`
+}
+
+// Import is what importing would change for the client.
+type Import struct {
+	PersonalID  string `json:"personal_id"`
+	HouseholdID string `json:"household_id"`
+	// The client's application with what's imported
+	Data       application.ApplicationData `json:"data"`
+	Members    []MemberImport              `json:"members"`
+	Changes    []string                    `json:"changes"`
+	Conflicts  []Conflict                  `json:"conflicts"`
+	Issues     []Issue                     `json:"issues"`
+	Candidates []Candidate                 `json:"candidates"`
+}
+
+// Limits on what zips unpack to, so a small upload can't expand into more
+// than the server can hold. A full HMIS CSV export is a couple dozen files.
+const (
+	maxZipEntries = 100
+	maxEntrySize  = 50 << 20
+	maxImportSize = 100 << 20
+)
+
+// importFiles are the HMIS CSV files the import reads.
+var importFiles = []string{"Client.csv", "Enrollment.csv", "IncomeBenefits.csv", "Disabilities.csv"}
+
+// importFile is the HMIS CSV file a path is, or "" if it isn't one the
+// import reads.
+func importFile(name string) string {
+	for _, known := range importFiles {
+		if strings.EqualFold(path.Base(name), known) {
+			return known
+		}
+	}
+	return ""
+}
+
+func isZip(name string) bool {
+	return strings.EqualFold(path.Ext(name), ".zip")
+}
+
+// ReadFiles reads uploaded HMIS CSV files, and zips of them, by file name.
+// Files that aren't part of the import are ignored, and Client.csv is
+// required. Zips inside zips are rejected rather than unpacked.
+func ReadFiles(files map[string][]byte) (map[string]*Table, error) {
+	tables := map[string]*Table{}
+	total := 0
+	read := func(name string, data []byte) error {
+		total += len(data)
+		if total > maxImportSize {
+			return fmt.Errorf("%w: the files are too large", ErrInvalidImport)
+		}
+		known := importFile(name)
+		if known == "" {
+			return nil
+		}
+		table, err := ParseCSV(known, data)
+		if err != nil {
+			return err
+		}
+		tables[known] = table
+		return nil
+	}
+
+	for name, data := range files {
+		if !isZip(name) {
+			if err := read(name, data); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s isn't a zip: %v", ErrInvalidImport, name, err)
+		}
+		if len(archive.File) > maxZipEntries {
+			return nil, fmt.Errorf("%w: %s has more than %d files", ErrInvalidImport, name, maxZipEntries)
+		}
+		for _, entry := range archive.File {
+			if isZip(entry.Name) {
+				return nil, fmt.Errorf("%w: %s has another zip in it, upload that one instead", ErrInvalidImport, name)
+			}
+			if importFile(entry.Name) == "" {
+				continue
+			}
+			reader, err := entry.Open()
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+			}
+			contents, err := io.ReadAll(io.LimitReader(reader, maxEntrySize+1))
+			reader.Close()
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+			}
+			if len(contents) > maxEntrySize {
+				return nil, fmt.Errorf("%w: %s is too large", ErrInvalidImport, entry.Name)
+			}
+			if err := read(entry.Name, contents); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if tables["Client.csv"] == nil {
+		return nil, fmt.Errorf("%w: Client.csv is missing", ErrInvalidImport)
+	}
+	return tables, nil
+}
+
+// ParseCSV reads an HMIS CSV file into records by column name.
+func ParseCSV(name string, data []byte) (*Table, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidImport, name, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: %s is empty", ErrInvalidImport, name)
+	}
+
+	table := &Table{Name: name, Columns: rows[0]}
+	for _, row := range rows[1:] {
+		record := map[string]string{}
+		for i, column := range table.Columns {
+			if i < len(row) {
+				record[strings.TrimSpace(column)] = strings.TrimSpace(row[i])
+			}
+		}
+		table.add(record)
+	}
+	return table, nil
+}
+
+// records lists a table's records, none if the file wasn't uploaded.
+func records(tables map[string]*Table, name string) []map[string]string {
+	if table := tables[name]; table != nil {
+		return table.Records
+	}
+	return nil
+}
+
+// nameKey lowercases a name and drops anything that isn't a letter, so
+// "O'Neil" and "oneil" are the same.
+func nameKey(name string) string {
+	var key strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if r >= 'a' && r <= 'z' || r > 127 {
+			key.WriteRune(r)
+		}
+	}
+	return key.String()
+}
+
+// identity is what people are matched on.
+type identity struct {
+	firstName string
+	lastName  string
+	dob       string
+	ssn       string
+}
+
+func memberIdentity(member application.FamilyMember) identity {
+	dob := ""
+	if parsed, err := application.ParseDate(member.Birthday); err == nil {
+		dob = parsed.Format(dateLayout)
+	}
+	ssn, _ := application.NormalizeSSN(member.SSN)
+	return identity{firstName: nameKey(member.FirstName), lastName: nameKey(member.LastName), dob: dob, ssn: ssn}
+}
+
+func recordIdentity(record map[string]string) identity {
+	dob := ""
+	if parsed, err := application.ParseDate(record["DOB"]); err == nil {
+		dob = parsed.Format(dateLayout)
+	}
+	return identity{
+		firstName: nameKey(record["FirstName"]),
+		lastName:  nameKey(record["LastName"]),
+		dob:       dob,
+		ssn:       strings.ToLower(strings.ReplaceAll(record["SSN"], "-", "")),
+	}
+}
+
+// matches checks if two people are the same: either the same full SSN and
+// name or date of birth, or the same name and date of birth without SSNs
+// that disagree. HMIS SSNs with only the last four digits count if those
+// match.
+func (person identity) matches(other identity) bool {
+	sameName := person.firstName != "" && person.firstName == other.firstName && person.lastName == other.lastName
+	sameDob := person.dob != "" && person.dob == other.dob
+
+	ssnKnown := len(person.ssn) == 9 && len(other.ssn) == 9
+	sameSSN := ssnKnown && person.ssn == other.ssn
+	if ssnKnown && strings.HasPrefix(other.ssn, "xxxxx") {
+		sameSSN = person.ssn[5:] == other.ssn[5:]
+	}
+	if ssnKnown && !sameSSN {
+		return false
+	}
+	if sameSSN && !strings.HasPrefix(other.ssn, "x") && (sameName || sameDob) {
+		return true
+	}
+	return sameName && sameDob
+}
+
+// storedSSN is an SSN as its 9 digits, so it compares with HMIS's, or as
+// it is if it isn't valid.
+func storedSSN(ssn string) string {
+	if digits, err := application.NormalizeSSN(ssn); err == nil {
+		return digits
+	}
+	return ssn
+}
+
+// fullSSN is a record's SSN if HMIS has all of it.
+func fullSSN(record map[string]string) string {
+	if record["SSNDataQuality"] != QualityFull {
+		return ""
+	}
+	ssn, err := application.NormalizeSSN(record["SSN"])
+	if err != nil {
+		return ""
+	}
+	return ssn
+}
+
+// recordGender maps Client.csv's gender columns back to QRHome's genders.
+// Anything besides a single man, woman or non-binary is Other.
+func recordGender(record map[string]string) string {
+	if record["GenderNone"] == PrefersNotToSay {
+		return "Prefer not to say"
+	}
+	marked := []string{}
+	for _, name := range genderNames {
+		if record[name] == YesCode {
+			marked = append(marked, name)
+		}
+	}
+	if len(marked) == 1 {
+		for gender, column := range genderColumns {
+			if column == marked[0] {
+				return gender
+			}
+		}
+	}
+	if len(marked) > 0 {
+		return "Other"
+	}
+	return ""
+}
+
+// relationshipFromHoH maps a relationship to head of household back to
+// QRHome's, which can't tell other relatives apart.
+func relationshipFromHoH(code string) string {
+	switch code {
+	case RelationshipSelf:
+		return "Self"
+	case RelationshipChild:
+		return "Child"
+	case RelationshipSpouse:
+		return "Spouse"
+	}
+	return "Other"
+}
+
+// importer builds an import, collecting its changes and conflicts.
+type importer struct {
+	result *Import
+	accept map[string]bool
+}
+
+func (im *importer) issue(file string, recordId string, field string, message string) {
+	im.result.Issues = append(im.result.Issues, Issue{
+		File:     file,
+		RecordID: recordId,
+		Field:    field,
+		Severity: application.SeverityWarning,
+		Message:  message,
+	})
+}
+
+// merge decides on one answer. Blank answers in QRHome are filled in, and
+// different ones are conflicts that only change if accepted. Returns the
+// answer to keep.
+func (im *importer) merge(personalId string, name string, field string, current string, imported string, display func(string) string) string {
+	if imported == "" || current == imported {
+		return current
+	}
+	if current == "" {
+		im.result.Changes = append(im.result.Changes, fmt.Sprintf("Filled in %s for %s", strings.ReplaceAll(field, "_", " "), name))
+		return imported
+	}
+	if display == nil {
+		display = func(value string) string { return value }
+	}
+	key := personalId + "." + field
+	conflict := Conflict{
+		Key:        key,
+		PersonalID: personalId,
+		Name:       name,
+		Field:      field,
+		Current:    display(current),
+		Imported:   display(imported),
+		Accepted:   im.accept[key],
+	}
+	im.result.Conflicts = append(im.result.Conflicts, conflict)
+	if !conflict.Accepted {
+		return current
+	}
+	im.result.Changes = append(im.result.Changes, fmt.Sprintf("Changed %s for %s", strings.ReplaceAll(field, "_", " "), name))
+	return imported
+}
+
+// mergeName decides on a name, which only differs if it's spelled
+// differently.
+func (im *importer) mergeName(personalId string, name string, field string, current string, imported string) string {
+	if nameKey(current) == nameKey(imported) {
+		return current
+	}
+	return im.merge(personalId, name, field, current, imported, nil)
+}
+
+// mergeFlag decides on a yes or no answer, which is a conflict if QRHome
+// already has an application saying otherwise.
+func (im *importer) mergeFlag(personalId string, name string, field string, current bool, imported string, hasApplication bool) bool {
+	if imported != YesCode && imported != NoCode {
+		return current
+	}
+	answer := imported == YesCode
+	if answer == current {
+		return current
+	}
+	if !hasApplication {
+		im.result.Changes = append(im.result.Changes, fmt.Sprintf("Filled in %s for %s", strings.ReplaceAll(field, "_", " "), name))
+		return answer
+	}
+	return im.merge(personalId, name, field, fmt.Sprint(current), fmt.Sprint(answer), nil) == "true"
+}
+
+// describePerson is how a person is shown in an identity conflict. SSNs are
+// left out, as conflicts are shown to caseworkers.
+func describePerson(firstName string, lastName string, dob string) string {
+	return fmt.Sprintf("%s, born %s", strings.TrimSpace(firstName+" "+lastName), dob)
+}
+
+// findClient finds the client in Client.csv, by their HMIS personal id if
+// it's given. A picked person whose name, date of birth or SSN don't match
+// the client's is an identity conflict, which has to be accepted before
+// anything of theirs is imported.
+func (im *importer) findClient(clients []map[string]string, info application.PersonalInfo, person identity, personalId string) (map[string]string, error) {
+	if personalId != "" {
+		for _, record := range clients {
+			if record["PersonalID"] != personalId {
+				continue
+			}
+			if !person.matches(recordIdentity(record)) {
+				key := personalId + ".identity"
+				im.result.Conflicts = append(im.result.Conflicts, Conflict{
+					Key:        key,
+					PersonalID: personalId,
+					Name:       strings.TrimSpace(info.FirstName + " " + info.LastName),
+					Field:      "identity",
+					Current:    describePerson(info.FirstName, info.LastName, person.dob),
+					Imported:   describePerson(record["FirstName"], record["LastName"], recordIdentity(record).dob),
+					Accepted:   im.accept[key],
+				})
+				if !im.accept[key] {
+					return nil, fmt.Errorf("%w: personal id %s", ErrIdentityMismatch, personalId)
+				}
+			}
+			return record, nil
+		}
+		return nil, fmt.Errorf("%w: no personal id %s", ErrNoMatch, personalId)
+	}
+
+	matches := []map[string]string{}
+	for _, record := range clients {
+		if person.matches(recordIdentity(record)) {
+			matches = append(matches, record)
+		}
+	}
+	if len(matches) > 1 && len(person.ssn) == 9 {
+		// Someone with the same SSN beats someone without one
+		sameSSN := []map[string]string{}
+		for _, record := range matches {
+			if recordIdentity(record).ssn == person.ssn {
+				sameSSN = append(sameSSN, record)
+			}
+		}
+		if len(sameSSN) == 1 {
+			matches = sameSSN
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, ErrNoMatch
+	case 1:
+		return matches[0], nil
+	}
+	for _, record := range matches {
+		im.result.Candidates = append(im.result.Candidates, Candidate{
+			PersonalID: record["PersonalID"],
+			FirstName:  record["FirstName"],
+			LastName:   record["LastName"],
+			DOB:        record["DOB"],
+		})
+	}
+	return nil, ErrAmbiguousMatch
+}
+
+// latestEnrollment is the person's enrollment with the latest entry date,
+// nil if they have none.
+func latestEnrollment(enrollments []map[string]string, personalId string) map[string]string {
+	var latest map[string]string = nil
+	for _, record := range enrollments {
+		if record["PersonalID"] != personalId {
+			continue
+		}
+		if latest == nil || record["EntryDate"] > latest["EntryDate"] {
+			latest = record
+		}
+	}
+	return latest
+}
+
+// latestIncome is the latest IncomeBenefits.csv record of an enrollment.
+func latestIncome(incomes []map[string]string, enrollmentId string) map[string]string {
+	var latest map[string]string = nil
+	for _, record := range incomes {
+		if record["EnrollmentID"] != enrollmentId {
+			continue
+		}
+		if latest == nil || record["InformationDate"] >= latest["InformationDate"] {
+			latest = record
+		}
+	}
+	return latest
+}
+
+// disabled reads whether someone has a disabling condition from their
+// enrollment, or failing that from their disabilities.
+func disabled(enrollment map[string]string, disabilities []map[string]string) string {
+	if code := enrollment["DisablingCondition"]; code == YesCode || code == NoCode {
+		return code
+	}
+	answered := false
+	for _, record := range disabilities {
+		if record["EnrollmentID"] != enrollment["EnrollmentID"] {
+			continue
+		}
+		switch record["DisabilityResponse"] {
+		case YesCode, "2", "3":
+			return YesCode
+		case NoCode:
+			answered = true
+		}
+	}
+	if answered {
+		return NoCode
+	}
+	return ""
+}
+
+// importMember merges a household member from HMIS into the matching
+// family member, or adds them. Returns nil if they can't be added.
+func (im *importer) importMember(record map[string]string, relationship string, existing []application.FamilyMember, matched map[int]bool, nextId *int) *MemberImport {
+	personalId := record["PersonalID"]
+	imported := application.FamilyMember{
+		FirstName:    record["FirstName"],
+		LastName:     record["LastName"],
+		SSN:          fullSSN(record),
+		Gender:       recordGender(record),
+		Relationship: relationship,
+	}
+	if dob, err := application.ParseDate(record["DOB"]); err == nil {
+		imported.Birthday = dob.Format(dateLayout)
+	}
+	name := strings.TrimSpace(imported.FirstName + " " + imported.LastName)
+
+	person := recordIdentity(record)
+	for _, member := range existing {
+		if member.Relationship == "Self" || matched[member.ID] || !memberIdentity(member).matches(person) {
+			continue
+		}
+		matched[member.ID] = true
+		changes := len(im.result.Changes)
+		merged := member
+		merged.FirstName = im.mergeName(personalId, name, "first_name", member.FirstName, imported.FirstName)
+		merged.LastName = im.mergeName(personalId, name, "last_name", member.LastName, imported.LastName)
+		merged.Birthday = im.merge(personalId, name, "birthday", memberIdentity(member).dob, imported.Birthday, nil)
+		merged.SSN = im.merge(personalId, name, "ssn", storedSSN(member.SSN), imported.SSN, application.MaskSSN)
+		merged.Gender = im.merge(personalId, name, "gender", member.Gender, imported.Gender, nil)
+		if relationship != "Other" {
+			merged.Relationship = im.merge(personalId, name, "relationship", member.Relationship, relationship, nil)
+		}
+		return &MemberImport{PersonalID: personalId, Member: merged, Changed: len(im.result.Changes) > changes}
+	}
+
+	if imported.Birthday == "" || imported.Gender == "" {
+		im.issue("Client.csv", personalId, "DOB", fmt.Sprintf("%s isn't in the client's household and can't be added without a date of birth and gender", name))
+		return nil
+	}
+	*nextId--
+	imported.ID = *nextId
+	im.result.Changes = append(im.result.Changes, fmt.Sprintf("Added family member %s", name))
+	return &MemberImport{PersonalID: personalId, Member: imported, New: true, Changed: true}
+}
+
+// importIncome merges a person's income from HMIS into their income
+// entries. Sources QRHome has a different monthly amount for are conflicts.
+func (im *importer) importIncome(data *application.ApplicationData, member application.FamilyMember, personalId string, record map[string]string) {
+	name := strings.TrimSpace(member.FirstName + " " + member.LastName)
+	entries := data.Income.IncomeAssetEntires
+	for _, source := range IncomeSources {
+		if record[source] != YesCode {
+			continue
+		}
+		amountColumn := source + "Amount"
+		if source == SourceOther {
+			amountColumn = "OtherIncomeAmount"
+		}
+		amount, err := application.ParseAmount(record[amountColumn])
+		if err != nil || amount.IsZero() {
+			im.issue("IncomeBenefits.csv", record["IncomeBenefitsID"], amountColumn, fmt.Sprintf("%s's %s income has no amount and isn't imported", name, sourceLabels[source]))
+			continue
+		}
+
+		label := sourceLabels[source]
+		if identify := strings.TrimSpace(record["OtherIncomeSourceIdentify"]); source == SourceOther && identify != "" {
+			label = identify + " (other benefits)"
+		}
+		entry := application.IncomeAndAssetData{
+			FamilyMember:        member,
+			Type:                "Income",
+			Source:              label,
+			Amount:              amount.StringFixed(2),
+			FrequencyOrLocation: "Monthly",
+			MonthlyOrValue:      amount.StringFixed(2),
+		}
+
+		current := []application.IncomeAndAssetData{}
+		others := []application.IncomeAndAssetData{}
+		for _, existing := range entries {
+			if existing.FamilyMember.ID == member.ID && application.IncomeCategory(existing) != application.IncomeAsset && IncomeSource(existing) == source {
+				current = append(current, existing)
+			} else {
+				others = append(others, existing)
+			}
+		}
+		if len(current) == 0 {
+			entries = append(entries, entry)
+			im.result.Changes = append(im.result.Changes, fmt.Sprintf("Added %s income for %s", label, name))
+			continue
+		}
+
+		summary := application.SummarizeIncome(application.IncomeAndAssetsData{IncomeAssetEntires: current})
+		monthly := decimal.Zero
+		for _, worked := range summary.Entries {
+			monthly = monthly.Add(worked.Monthly)
+		}
+		if len(summary.Issues) == 0 && monthly.Equal(amount) {
+			continue
+		}
+		field := "income." + source
+		kept := im.merge(personalId, name, field, monthly.StringFixed(2)+" a month", amount.StringFixed(2)+" a month", nil)
+		if kept != monthly.StringFixed(2)+" a month" {
+			entries = append(others, entry)
+		}
+	}
+	data.Income.IncomeAssetEntires = entries
+
+	benefits := []string{}
+	for _, benefit := range []string{BenefitSNAP, BenefitWIC} {
+		if record[benefit] == YesCode {
+			benefits = append(benefits, benefit)
+		}
+	}
+	if record[BenefitOther] == YesCode {
+		benefits = append(benefits, strings.TrimSpace(record["OtherBenefitsSourceIdentify"]))
+	}
+	if len(benefits) > 0 && !data.Income.ReceivesGovAssistance {
+		data.Income.ReceivesGovAssistance = true
+		data.Income.AssistanceProgramName = strings.Trim(strings.Join(benefits, ", "), ", ")
+		im.result.Changes = append(im.result.Changes, fmt.Sprintf("Filled in government assistance from %s", name))
+	}
+}
+
+// PlanImport works out what importing the HMIS files changes for the
+// client, without changing anything. personalId picks the client in HMIS
+// when matching finds more than one person, and accept lists the keys of
+// conflicts to take HMIS's answer for. Picking someone who doesn't match
+// the client fails with ErrIdentityMismatch until their identity conflict
+// is accepted.
+func PlanImport(tables map[string]*Table, existing Existing, personalId string, accept []string) (Import, error) {
+	result := Import{
+		Data:       existing.Data,
+		Members:    []MemberImport{},
+		Changes:    []string{},
+		Conflicts:  []Conflict{},
+		Issues:     []Issue{},
+		Candidates: []Candidate{},
+	}
+	im := importer{result: &result, accept: map[string]bool{}}
+	for _, key := range accept {
+		im.accept[key] = true
+	}
+
+	info := existing.Data.PersonalInfo
+	person := memberIdentity(application.FamilyMember{FirstName: info.FirstName, LastName: info.LastName, Birthday: info.Dob, SSN: info.SSN})
+	clients := records(tables, "Client.csv")
+	record, err := im.findClient(clients, info, person, personalId)
+	if err != nil {
+		return result, err
+	}
+	result.PersonalID = record["PersonalID"]
+
+	name := strings.TrimSpace(info.FirstName + " " + info.LastName)
+	data := &result.Data
+	data.PersonalInfo.FirstName = im.mergeName(result.PersonalID, name, "first_name", info.FirstName, record["FirstName"])
+	data.PersonalInfo.LastName = im.mergeName(result.PersonalID, name, "last_name", info.LastName, record["LastName"])
+	data.PersonalInfo.Dob = im.merge(result.PersonalID, name, "dob", person.dob, recordIdentity(record).dob, nil)
+	data.PersonalInfo.SSN = im.merge(result.PersonalID, name, "ssn", storedSSN(info.SSN), fullSSN(record), application.MaskSSN)
+	data.PersonalInfo.Gender = im.merge(result.PersonalID, name, "gender", info.Gender, recordGender(record), nil)
+	data.PersonalInfo.IsVeteran = im.mergeFlag(result.PersonalID, name, "is_veteran", info.IsVeteran, record["VeteranStatus"], existing.HasApplication)
+
+	var self *application.FamilyMember = nil
+	for i := range existing.Members {
+		if existing.Members[i].Relationship == "Self" {
+			self = &existing.Members[i]
+		}
+	}
+
+	enrollments := records(tables, "Enrollment.csv")
+	enrollment := latestEnrollment(enrollments, result.PersonalID)
+	if enrollment == nil {
+		im.issue("Enrollment.csv", result.PersonalID, "PersonalID", "The client has no enrollments, so only their personal info is imported")
+		return result, nil
+	}
+	result.HouseholdID = enrollment["HouseholdID"]
+	data.PersonalInfo.HasDisability = im.mergeFlag(result.PersonalID, name, "has_disability", info.HasDisability, disabled(enrollment, records(tables, "Disabilities.csv")), existing.HasApplication)
+
+	head := enrollment["RelationshipToHoH"] == RelationshipSelf
+	if !head {
+		im.issue("Enrollment.csv", enrollment["EnrollmentID"], "RelationshipToHoH", "The client isn't head of household in HMIS, so household members are imported as Other")
+	}
+
+	incomes := records(tables, "IncomeBenefits.csv")
+	if self == nil {
+		im.issue("IncomeBenefits.csv", result.PersonalID, "PersonalID", "The client has no family member of their own, so their income isn't imported")
+	} else if income := latestIncome(incomes, enrollment["EnrollmentID"]); income != nil {
+		im.importIncome(data, *self, result.PersonalID, income)
+	}
+
+	byId := map[string]map[string]string{}
+	for _, client := range clients {
+		byId[client["PersonalID"]] = client
+	}
+	matched := map[int]bool{}
+	nextId := 0
+	for _, other := range enrollments {
+		if other["HouseholdID"] != result.HouseholdID || other["PersonalID"] == result.PersonalID {
+			continue
+		}
+		memberRecord, ok := byId[other["PersonalID"]]
+		if !ok {
+			im.issue("Enrollment.csv", other["EnrollmentID"], "PersonalID", "This household member isn't in Client.csv")
+			continue
+		}
+		relationship := "Other"
+		if head {
+			relationship = relationshipFromHoH(other["RelationshipToHoH"])
+		}
+		member := im.importMember(memberRecord, relationship, existing.Members, matched, &nextId)
+		if member == nil {
+			continue
+		}
+		result.Members = append(result.Members, *member)
+		if income := latestIncome(incomes, other["EnrollmentID"]); income != nil {
+			im.importIncome(data, member.Member, member.PersonalID, income)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,169 @@
+package partnerform
+
+// Filling a partner form for a client, and reporting what couldn't be.
+
+import (
+	"api/core/server/acroform"
+	"api/core/server/data/application"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type InvalidValue struct {
+	Field   string   `json:"field"`
+	Value   string   `json:"value"`
+	Options []string `json:"options"`
+}
+
+// Report is what a caseworker has to fill in by hand.
+type Report struct {
+	// Required fields nothing is mapped to
+	Unmapped []string `json:"unmapped"`
+	// Required fields the client's application doesn't have an answer for
+	Missing []string `json:"missing"`
+	// Fields left blank because the answer isn't one of the form's options
+	Invalid []InvalidValue `json:"invalid"`
+}
+
+// NewSource works out the computed figures. members is the whole household,
+// the client included, from their family links.
+func NewSource(data application.ApplicationData, members []application.FamilyMember, today time.Time) Source {
+	source := Source{ApplicationData: data, Members: []application.FamilyMember{}}
+	for _, member := range members {
+		if member.Relationship != "Self" {
+			source.Members = append(source.Members, member)
+		}
+	}
+
+	income := application.SummarizeIncome(data.Income)
+	source.Computed = Computed{
+		FullName:      strings.TrimSpace(data.PersonalInfo.FirstName + " " + data.PersonalInfo.LastName),
+		AnnualIncome:  income.Household.TotalAnnual.StringFixed(2),
+		MonthlyIncome: income.Household.TotalMonthly.StringFixed(2),
+		AssetValue:    income.Household.AssetValue.StringFixed(2),
+		HouseholdSize: max(len(members), 1),
+		Today:         today.Format(application.DateLayout),
+	}
+	return source
+}
+
+// lookup finds the value at a path in the decoded source, as text. Paths
+// into lists past their end are blank.
+func lookup(tree interface{}, path string) string {
+	current := tree
+	for _, part := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			current = node[part]
+		case []interface{}:
+			index, err := strconv.Atoi(part)
+			if err != nil || index >= len(node) {
+				return ""
+			}
+			current = node[index]
+		default:
+			return ""
+		}
+	}
+
+	switch value := current.(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case bool:
+		if value {
+			return "Yes"
+		}
+		return "No"
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return ""
+}
+
+// replace swaps a value for what the form wants, ignoring case if there's
+// no exact match.
+func (field FieldMapping) replace(value string) string {
+	if to, ok := field.Values[value]; ok {
+		return to
+	}
+	for from, to := range field.Values {
+		if strings.EqualFold(from, value) {
+			return to
+		}
+	}
+	return value
+}
+
+// value works out what goes in the field.
+func (field FieldMapping) value(tree interface{}) string {
+	values := []string{}
+	for _, path := range field.paths() {
+		value := lookup(tree, path)
+		if field.Format == FormatDate && value != "" {
+			if date, err := application.ParseDate(value); err == nil {
+				value = date.Format("01/02/2006")
+			}
+		}
+		value = field.replace(value)
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+
+	separator := " "
+	if field.Separator != nil {
+		separator = *field.Separator
+	}
+	return strings.Join(values, separator)
+}
+
+// Fill fills the form with the client's application. Fields the mapping
+// can't fill are left as they are and reported.
+func Fill(pdf []byte, mapping Mapping, source Source) ([]byte, Report, error) {
+	report := Report{Missing: []string{}, Invalid: []InvalidValue{}}
+	fields, err := acroform.Read(pdf)
+	if err != nil {
+		return nil, report, err
+	}
+	report.Unmapped = mapping.Unmapped(fields)
+
+	encoded, err := json.Marshal(source)
+	if err != nil {
+		return nil, report, err
+	}
+	var tree interface{}
+	if err := json.Unmarshal(encoded, &tree); err != nil {
+		return nil, report, err
+	}
+
+	byName := map[string]acroform.Field{}
+	for _, field := range fields {
+		byName[field.Name] = field
+	}
+	values := map[string]string{}
+	for _, mapped := range mapping.Fields {
+		field := byName[mapped.Field]
+		value := mapped.value(tree)
+		if value == "" {
+			if field.Required {
+				report.Missing = append(report.Missing, field.Name)
+			}
+			continue
+		}
+		hasOptions := field.Type == acroform.FieldRadio || field.Type == acroform.FieldChoice
+		if hasOptions && len(field.Options) > 0 && !slices.Contains(field.Options, value) {
+			report.Invalid = append(report.Invalid, InvalidValue{Field: field.Name, Value: value, Options: field.Options})
+			continue
+		}
+		values[field.Name] = value
+	}
+
+	filled, err := acroform.Fill(pdf, values)
+	if err != nil {
+		return nil, report, err
+	}
+	return filled, report, nil
+}
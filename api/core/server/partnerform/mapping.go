@@ -0,0 +1,216 @@
+package partnerform
+
+// Mappings from application fields to the fields of a partner agency's
+// fillable PDF, written in YAML or JSON by admins. For example:
+//
+//	fields:
+//	  - field: Applicant Name
+//	    paths: [personal_info.first_name, personal_info.last_name]
+//	  - field: Date of Birth
+//	    path: personal_info.dob
+//	    format: date
+//	  - field: Veteran
+//	    path: personal_info.is_veteran
+//	  - field: Gender
+//	    path: personal_info.gender
+//	    values: {Male: M, Female: F}
+//	  - field: Member 1 Name
+//	    path: members.0.first_name
+//
+// Paths are json paths into the application, with list items picked by
+// index. "members" are the household besides the client, and "computed" has
+// figures worked out from the application.
+
+import (
+	"api/core/server/acroform"
+	"api/core/server/data/application"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats a value can be written in.
+const (
+	// MM/DD/YYYY, how US forms ask for dates
+	FormatDate = "date"
+)
+
+type FieldMapping struct {
+	// Name of the form field
+	Field string `yaml:"field" json:"field"`
+	// Where the value comes from. With more than one path, the values are
+	// joined with Separator, a space by default.
+	Path      string   `yaml:"path,omitempty" json:"path,omitempty"`
+	Paths     []string `yaml:"paths,omitempty" json:"paths,omitempty"`
+	Separator *string  `yaml:"separator,omitempty" json:"separator,omitempty"`
+	Format    string   `yaml:"format,omitempty" json:"format,omitempty"`
+	// Replaces values with what the form wants, like a radio button's
+	// options. True and false are "Yes" and "No".
+	Values map[string]string `yaml:"values,omitempty" json:"values,omitempty"`
+}
+
+type Mapping struct {
+	Fields []FieldMapping `yaml:"fields" json:"fields"`
+}
+
+var ErrInvalidMapping = errors.New("invalid form mapping")
+
+// Source is everything a form is filled from. It's read as json, so paths
+// are the same as in the app.
+type Source struct {
+	application.ApplicationData
+	Members  []application.FamilyMember `json:"members"`
+	Computed Computed                   `json:"computed"`
+}
+
+type Computed struct {
+	FullName      string `json:"full_name"`
+	AnnualIncome  string `json:"annual_income"`
+	MonthlyIncome string `json:"monthly_income"`
+	AssetValue    string `json:"asset_value"`
+	HouseholdSize int    `json:"household_size"`
+	Today         string `json:"today"`
+}
+
+// paths lists where the field's value comes from.
+func (field FieldMapping) paths() []string {
+	if field.Path != "" {
+		return append([]string{field.Path}, field.Paths...)
+	}
+	return field.Paths
+}
+
+// Parse reads a mapping in YAML or JSON, and checks its paths exist.
+func Parse(source string) (Mapping, error) {
+	var mapping Mapping
+	decoder := yaml.NewDecoder(strings.NewReader(source))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&mapping); err != nil {
+		return Mapping{}, fmt.Errorf("%w: %v", ErrInvalidMapping, err)
+	}
+	if err := mapping.check(); err != nil {
+		return Mapping{}, fmt.Errorf("%w: %v", ErrInvalidMapping, err)
+	}
+	return mapping, nil
+}
+
+func (mapping Mapping) check() error {
+	if len(mapping.Fields) == 0 {
+		return errors.New("no fields")
+	}
+	seen := map[string]bool{}
+	for i, field := range mapping.Fields {
+		if field.Field == "" {
+			return fmt.Errorf("mapping %d has no field", i+1)
+		}
+		if seen[field.Field] {
+			return fmt.Errorf("%s is mapped twice", field.Field)
+		}
+		seen[field.Field] = true
+		if len(field.paths()) == 0 {
+			return fmt.Errorf("%s has no path", field.Field)
+		}
+		for _, path := range field.paths() {
+			if !knownPath(path) {
+				return fmt.Errorf("%s: unknown path %s", field.Field, path)
+			}
+		}
+		if field.Format != "" && field.Format != FormatDate {
+			return fmt.Errorf("%s: unknown format %s", field.Field, field.Format)
+		}
+	}
+	return nil
+}
+
+// CheckFields checks every mapped field is in the form and can be filled.
+// Mappings are checked against the form they're uploaded with, so a form
+// can't be saved with a mapping that fails when filling it.
+func (mapping Mapping) CheckFields(fields []acroform.Field) error {
+	byName := map[string]acroform.Field{}
+	for _, field := range fields {
+		byName[field.Name] = field
+	}
+	for _, mapped := range mapping.Fields {
+		field, ok := byName[mapped.Field]
+		if !ok {
+			return fmt.Errorf("%w: the form has no field %s", ErrInvalidMapping, mapped.Field)
+		}
+		if field.Type == acroform.FieldSignature || field.Type == acroform.FieldButton {
+			return fmt.Errorf("%w: %s is a %s and can't be filled", ErrInvalidMapping, mapped.Field, field.Type)
+		}
+	}
+	return nil
+}
+
+// Unmapped lists the form's required fields nothing is mapped to, besides
+// signatures, which are signed by hand.
+func (mapping Mapping) Unmapped(fields []acroform.Field) []string {
+	mapped := map[string]bool{}
+	for _, field := range mapping.Fields {
+		mapped[field.Field] = true
+	}
+	unmapped := []string{}
+	for _, field := range fields {
+		if field.Required && !mapped[field.Name] && field.Type != acroform.FieldSignature {
+			unmapped = append(unmapped, field.Name)
+		}
+	}
+	slices.Sort(unmapped)
+	return unmapped
+}
+
+// knownPath checks a path leads to a single value in a Source, like text or
+// a yes or no answer, rather than a section or list.
+func knownPath(path string) bool {
+	current := reflect.TypeOf(Source{})
+	for _, part := range strings.Split(path, ".") {
+		for current.Kind() == reflect.Pointer {
+			current = current.Elem()
+		}
+		switch current.Kind() {
+		case reflect.Slice:
+			if index, err := strconv.Atoi(part); err != nil || index < 0 {
+				return false
+			}
+			current = current.Elem()
+		case reflect.Map:
+			current = current.Elem()
+		case reflect.Struct:
+			field, ok := fieldByTag(current, part)
+			if !ok {
+				return false
+			}
+			current = field
+		default:
+			return false
+		}
+	}
+	switch current.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int:
+		return true
+	}
+	return false
+}
+
+// fieldByTag finds a struct field by its json name, including fields of
+// embedded structs.
+func fieldByTag(structType reflect.Type, jsonName string) (reflect.Type, bool) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.Anonymous {
+			if found, ok := fieldByTag(field.Type, jsonName); ok {
+				return found, true
+			}
+			continue
+		}
+		if strings.Split(field.Tag.Get("json"), ",")[0] == jsonName {
+			return field.Type, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,396 @@
+package partnerform
+
+// Partner agencies' own fillable applications. Admins upload each form with a
+// mapping, and caseworkers download it filled in for their clients.
+
+import (
+	"api/core/server/account"
+	"api/core/server/acroform"
+	"api/core/server/file"
+	"api/db"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+type PartnerFormInfo struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Agency    string    `json:"agency"`
+	Filename  string    `json:"filename"`
+	Mapping   string    `json:"mapping"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type GetPartnerFormsResponse struct {
+	Success bool              `json:"success"`
+	Forms   []PartnerFormInfo `json:"forms"`
+	Error   string            `json:"error"`
+}
+
+type PartnerFormResponse struct {
+	Success bool             `json:"success"`
+	Form    PartnerFormInfo  `json:"form"`
+	Fields  []acroform.Field `json:"fields"`
+	// Required fields the mapping leaves for caseworkers to fill in
+	Unmapped []string `json:"unmapped"`
+	Error    string   `json:"error"`
+}
+
+type InspectFormResponse struct {
+	Success bool             `json:"success"`
+	Fields  []acroform.Field `json:"fields"`
+	Error   string           `json:"error"`
+}
+
+type DeletePartnerFormRequest struct {
+	ID int `json:"id"`
+}
+
+type DeletePartnerFormResponse struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+}
+
+func toPartnerFormInfo(form *db.PartnerFormModel) PartnerFormInfo {
+	return PartnerFormInfo{
+		ID:        form.ID,
+		Name:      form.Name,
+		Agency:    form.Agency,
+		Filename:  form.Filename,
+		Mapping:   form.Mapping,
+		UpdatedAt: form.UpdatedAt,
+	}
+}
+
+// authAdmin checks the user is signed in as an admin. On failure, returns
+// the error message to respond with.
+func authAdmin(c echo.Context, client *db.PrismaClient) (*db.UserModel, string) {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return nil, "Failed to authenticate"
+	}
+	if user.Type != db.UserTypeAdmin {
+		return nil, "Only admins can manage partner forms"
+	}
+	return user, ""
+}
+
+func formValue(form *multipart.Form, key string) (string, bool) {
+	if values := form.Value[key]; len(values) > 0 {
+		return values[0], true
+	}
+	return "", false
+}
+
+// readUpload reads an uploaded file from the form. Returns nil without an
+// error if there isn't one.
+func readUpload(form *multipart.Form, key string) ([]byte, string, error) {
+	files := form.File[key]
+	if len(files) == 0 {
+		return nil, "", nil
+	}
+	src, err := files[0].Open()
+	if err != nil {
+		return nil, "", err
+	}
+	defer src.Close()
+	data, err := io.ReadAll(io.LimitReader(src, file.MaxFileSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > file.MaxFileSize {
+		return nil, "", errors.New("Uploaded file is too large")
+	}
+	return data, files[0].Filename, nil
+}
+
+// readForm reads the fields of an uploaded PDF. On failure, returns the
+// error message to respond with.
+func readForm(pdf []byte) ([]acroform.Field, string) {
+	if http.DetectContentType(pdf) != "application/pdf" {
+		return nil, "Form must be a PDF"
+	}
+	fields, err := acroform.Read(pdf)
+	switch {
+	case errors.Is(err, acroform.ErrNoForm):
+		return nil, "PDF has no fillable fields"
+	case errors.Is(err, acroform.ErrEncrypted):
+		return nil, "Encrypted PDFs can't be filled"
+	case err != nil:
+		return nil, "Failed to read PDF"
+	}
+	return fields, ""
+}
+
+// readMapping reads the mapping, sent as a file or as text.
+func readMapping(form *multipart.Form) (string, bool, error) {
+	data, _, err := readUpload(form, "mapping")
+	if err != nil {
+		return "", false, err
+	}
+	if data != nil {
+		return string(data), true, nil
+	}
+	mapping, ok := formValue(form, "mapping")
+	return mapping, ok, nil
+}
+
+// checkMapping checks the mapping parses and fits the form's fields. Returns
+// the unmapped required fields, or the error message to respond with.
+func checkMapping(source string, fields []acroform.Field) ([]string, string) {
+	mapping, err := Parse(source)
+	if err != nil {
+		return nil, err.Error()
+	}
+	if err := mapping.CheckFields(fields); err != nil {
+		return nil, err.Error()
+	}
+	return mapping.Unmapped(fields), ""
+}
+
+// checkNameFree checks no other form than the one with the given id goes by
+// the name. On failure, returns the status code and error message to
+// respond with.
+func checkNameFree(client *db.PrismaClient, name string, id int) (int, string) {
+	existing, err := client.PartnerForm.FindUnique(
+		db.PartnerForm.Name.Equals(name),
+	).Exec(context.Background())
+	if err != nil && err != db.ErrNotFound {
+		fmt.Printf("[ERROR] Failed to check partner form name %s: %v\n", name, err)
+		return 500, "Failed to check form name"
+	}
+	if existing != nil && existing.ID != id {
+		return 409, "A form with that name already exists"
+	}
+	return 200, ""
+}
+
+// GetPartnerFormsHandler lists the partner forms by name, for caseworkers to
+// pick one to fill.
+func GetPartnerFormsHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, GetPartnerFormsResponse{Success: false, Error: "Failed to authenticate"})
+	}
+	if user.Type != db.UserTypeCaseWorker && user.Type != db.UserTypeAdmin {
+		return c.JSON(400, GetPartnerFormsResponse{Success: false, Error: "Only caseworkers and admins can use partner forms"})
+	}
+
+	forms, formsErr := client.PartnerForm.FindMany().OrderBy(
+		db.PartnerForm.Name.Order(db.SortOrderAsc),
+	).Exec(context.Background())
+	if formsErr != nil {
+		fmt.Printf("[ERROR] Failed to get partner forms: %v\n", formsErr)
+		return c.JSON(500, GetPartnerFormsResponse{Success: false, Error: "Failed to get partner forms"})
+	}
+
+	infos := make([]PartnerFormInfo, len(forms))
+	for i := range forms {
+		infos[i] = toPartnerFormInfo(&forms[i])
+	}
+	return c.JSON(200, GetPartnerFormsResponse{Success: true, Forms: infos, Error: ""})
+}
+
+// InspectFormHandler lists the fields of an uploaded PDF without saving it,
+// so admins can write its mapping.
+func InspectFormHandler(c echo.Context, client *db.PrismaClient) error {
+	user, authErr := authAdmin(c, client)
+	if user == nil {
+		return c.JSON(400, InspectFormResponse{Success: false, Error: authErr})
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return c.JSON(500, InspectFormResponse{Success: false, Error: "Failed to get multipart form"})
+	}
+	pdf, _, uploadErr := readUpload(form, "file")
+	if uploadErr != nil {
+		return c.JSON(400, InspectFormResponse{Success: false, Error: uploadErr.Error()})
+	}
+	if pdf == nil {
+		return c.JSON(400, InspectFormResponse{Success: false, Error: "No form uploaded"})
+	}
+	fields, formErr := readForm(pdf)
+	if formErr != "" {
+		return c.JSON(400, InspectFormResponse{Success: false, Error: formErr})
+	}
+	return c.JSON(200, InspectFormResponse{Success: true, Fields: fields, Error: ""})
+}
+
+// UploadPartnerFormHandler saves a partner's form with its mapping. It's a
+// multipart form with the name and agency, the PDF as "file", and the
+// mapping as a file or text.
+func UploadPartnerFormHandler(c echo.Context, client *db.PrismaClient) error {
+	user, authErr := authAdmin(c, client)
+	if user == nil {
+		return c.JSON(400, PartnerFormResponse{Success: false, Error: authErr})
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return c.JSON(500, PartnerFormResponse{Success: false, Error: "Failed to get multipart form"})
+	}
+	name, _ := formValue(form, "name")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return c.JSON(400, PartnerFormResponse{Success: false, Error: "Missing name"})
+	}
+	agency, _ := formValue(form, "agency")
+
+	pdf, filename, uploadErr := readUpload(form, "file")
+	if uploadErr != nil {
+		return c.JSON(400, PartnerFormResponse{Success: false, Error: uploadErr.Error()})
+	}
+	if pdf == nil {
+		return c.JSON(400, PartnerFormResponse{Success: false, Error: "No form uploaded"})
+	}
+	fields, formErr := readForm(pdf)
+	if formErr != "" {
+		return c.JSON(400, PartnerFormResponse{Success: false, Error: formErr})
+	}
+	mapping, _, mappingReadErr := readMapping(form)
+	if mappingReadErr != nil {
+		return c.JSON(400, PartnerFormResponse{Success: false, Error: mappingReadErr.Error()})
+	}
+	unmapped, mappingErr := checkMapping(mapping, fields)
+	if mappingErr != "" {
+		return c.JSON(400, PartnerFormResponse{Success: false, Error: mappingErr})
+	}
+
+	if status, nameErr := checkNameFree(client, name, 0); nameErr != "" {
+		return c.JSON(status, PartnerFormResponse{Success: false, Error: nameErr})
+	}
+
+	created, createErr := client.PartnerForm.CreateOne(
+		db.PartnerForm.Name.Set(name),
+		db.PartnerForm.Filename.Set(filename),
+		db.PartnerForm.Pdf.Set(pdf),
+		db.PartnerForm.Mapping.Set(mapping),
+		db.PartnerForm.Agency.Set(strings.TrimSpace(agency)),
+	).Exec(context.Background())
+	if createErr != nil {
+		fmt.Printf("[ERROR] Failed to create partner form %s: %v\n", name, createErr)
+		return c.JSON(500, PartnerFormResponse{Success: false, Error: "Failed to save partner form"})
+	}
+
+	return c.JSON(200, PartnerFormResponse{Success: true, Form: toPartnerFormInfo(created), Fields: fields, Unmapped: unmapped, Error: ""})
+}
+
+// UpdatePartnerFormHandler changes a partner form, like UploadPartnerFormHandler
+// with the id of the form. Anything left out isn't changed, and the mapping
+// is checked again against the form either way.
+func UpdatePartnerFormHandler(c echo.Context, client *db.PrismaClient) error {
+	user, authErr := authAdmin(c, client)
+	if user == nil {
+		return c.JSON(400, PartnerFormResponse{Success: false, Error: authErr})
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return c.JSON(500, PartnerFormResponse{Success: false, Error: "Failed to get multipart form"})
+	}
+	idValue, _ := formValue(form, "id")
+	id, idErr := strconv.Atoi(idValue)
+	if idErr != nil {
+		return c.JSON(400, PartnerFormResponse{Success: false, Error: "Invalid form id"})
+	}
+
+	existing, findErr := client.PartnerForm.FindUnique(
+		db.PartnerForm.ID.Equals(id),
+	).Exec(context.Background())
+	if findErr == db.ErrNotFound {
+		return c.JSON(404, PartnerFormResponse{Success: false, Error: "Partner form not found"})
+	}
+	if findErr != nil {
+		fmt.Printf("[ERROR] Failed to get partner form %d: %v\n", id, findErr)
+		return c.JSON(500, PartnerFormResponse{Success: false, Error: "Failed to get partner form"})
+	}
+
+	params := []db.PartnerFormSetParam{}
+	if name, ok := formValue(form, "name"); ok && strings.TrimSpace(name) != "" {
+		name = strings.TrimSpace(name)
+		if status, nameErr := checkNameFree(client, name, id); nameErr != "" {
+			return c.JSON(status, PartnerFormResponse{Success: false, Error: nameErr})
+		}
+		params = append(params, db.PartnerForm.Name.Set(name))
+	}
+	if agency, ok := formValue(form, "agency"); ok {
+		params = append(params, db.PartnerForm.Agency.Set(strings.TrimSpace(agency)))
+	}
+
+	pdf, filename, uploadErr := readUpload(form, "file")
+	if uploadErr != nil {
+		return c.JSON(400, PartnerFormResponse{Success: false, Error: uploadErr.Error()})
+	}
+	if pdf != nil {
+		params = append(params, db.PartnerForm.Filename.Set(filename), db.PartnerForm.Pdf.Set(pdf))
+	} else {
+		pdf = existing.Pdf
+	}
+	fields, formErr := readForm(pdf)
+	if formErr != "" {
+		return c.JSON(400, PartnerFormResponse{Success: false, Error: formErr})
+	}
+
+	mapping, mappingSent, mappingReadErr := readMapping(form)
+	if mappingReadErr != nil {
+		return c.JSON(400, PartnerFormResponse{Success: false, Error: mappingReadErr.Error()})
+	}
+	if mappingSent {
+		params = append(params, db.PartnerForm.Mapping.Set(mapping))
+	} else {
+		mapping = existing.Mapping
+	}
+	unmapped, mappingErr := checkMapping(mapping, fields)
+	if mappingErr != "" {
+		return c.JSON(400, PartnerFormResponse{Success: false, Error: mappingErr})
+	}
+
+	updated, updateErr := client.PartnerForm.FindUnique(
+		db.PartnerForm.ID.Equals(id),
+	).Update(
+		params...,
+	).Exec(context.Background())
+	if updateErr != nil {
+		fmt.Printf("[ERROR] Failed to update partner form %d: %v\n", id, updateErr)
+		return c.JSON(500, PartnerFormResponse{Success: false, Error: "Failed to update partner form"})
+	}
+
+	return c.JSON(200, PartnerFormResponse{Success: true, Form: toPartnerFormInfo(updated), Fields: fields, Unmapped: unmapped, Error: ""})
+}
+
+// DeletePartnerFormHandler removes a partner form.
+func DeletePartnerFormHandler(c echo.Context, client *db.PrismaClient) error {
+	user, authErr := authAdmin(c, client)
+	if user == nil {
+		return c.JSON(400, DeletePartnerFormResponse{Success: false, Error: authErr})
+	}
+
+	var request DeletePartnerFormRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&request); err != nil {
+		return c.JSON(500, DeletePartnerFormResponse{Success: false, Error: "Failed to parse request body"})
+	}
+
+	_, deleteErr := client.PartnerForm.FindUnique(
+		db.PartnerForm.ID.Equals(request.ID),
+	).Delete().Exec(context.Background())
+	if deleteErr == db.ErrNotFound {
+		return c.JSON(404, DeletePartnerFormResponse{Success: false, Error: "Partner form not found"})
+	}
+	if deleteErr != nil {
+		fmt.Printf("[ERROR] Failed to delete partner form %d: %v\n", request.ID, deleteErr)
+		return c.JSON(500, DeletePartnerFormResponse{Success: false, Error: "Failed to delete partner form"})
+	}
+
+	return c.JSON(200, DeletePartnerFormResponse{Success: true, Error: ""})
+}
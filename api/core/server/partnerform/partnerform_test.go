@@ -0,0 +1,143 @@
+package partnerform
+
+// Partner form unit tests
+
+import (
+	"api/core/server/acroform"
+	"api/core/server/data/application"
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var formObjects = []string{
+	"<< /Type /Catalog /Pages 2 0 R /AcroForm 3 0 R >>",
+	"<< /Type /Pages /Kids [] /Count 0 >>",
+	"<< /Fields [4 0 R 5 0 R 6 0 R 7 0 R 8 0 R 9 0 R 10 0 R] >>",
+	"<< /FT /Tx /T (Name) /Ff 2 /Subtype /Widget >>",
+	"<< /FT /Tx /T (DOB) /Ff 2 /Subtype /Widget >>",
+	"<< /FT /Btn /T (Veteran) /Subtype /Widget /AP << /N << /On 11 0 R /Off 11 0 R >> >> >>",
+	"<< /FT /Ch /T (Sex) /Opt [(M) (F)] /Subtype /Widget >>",
+	"<< /FT /Tx /T (Member1) /Subtype /Widget >>",
+	"<< /FT /Tx /T (Phone) /Ff 2 /Subtype /Widget >>",
+	"<< /FT /Sig /T (Signature) /Ff 2 /Subtype /Widget >>",
+	"<< /Length 0 >>\nstream\n\nendstream",
+}
+
+func buildPDF() []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("%PDF-1.4\n")
+	offsets := []int{}
+	for i, object := range formObjects {
+		offsets = append(offsets, buffer.Len())
+		fmt.Fprintf(&buffer, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := buffer.Len()
+	fmt.Fprintf(&buffer, "xref\n0 %d\n0000000000 65535 f \n", len(formObjects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buffer, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buffer, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(formObjects)+1, xref)
+	return buffer.Bytes()
+}
+
+const mock_mapping = `
+fields:
+  - field: Name
+    paths: [personal_info.first_name, personal_info.last_name]
+  - field: DOB
+    path: personal_info.dob
+    format: date
+  - field: Veteran
+    path: personal_info.is_veteran
+  - field: Sex
+    path: personal_info.gender
+    values: {male: M, female: F}
+  - field: Member1
+    paths: [members.0.first_name, members.0.relationship]
+    separator: ", "
+  - field: Phone
+    path: personal_info.phone
+`
+
+func fieldValue(fields []acroform.Field, name string) string {
+	for _, field := range fields {
+		if field.Name == name {
+			return field.Value
+		}
+	}
+	return ""
+}
+
+func TestParse(t *testing.T) {
+	mapping, err := Parse(mock_mapping)
+	assert.NoError(t, err, "Mapping should parse")
+	assert.Len(t, mapping.Fields, 6, "Every field should be read")
+
+	_, err = Parse(`{"fields": [{"field": "Name", "path": "computed.full_name"}]}`)
+	assert.NoError(t, err, "Mappings should be readable as JSON")
+
+	cases := map[string]string{
+		"no fields":       `fields: []`,
+		"no path":         "fields:\n  - field: Name",
+		"unknown path":    "fields:\n  - field: Name\n    path: personal_info.nickname",
+		"section path":    "fields:\n  - field: Name\n    path: personal_info",
+		"list path":       "fields:\n  - field: Name\n    path: members",
+		"bad index":       "fields:\n  - field: Name\n    path: members.first.first_name",
+		"duplicate field": "fields:\n  - field: Name\n    path: personal_info.phone\n  - field: Name\n    path: personal_info.email",
+		"unknown format":  "fields:\n  - field: Name\n    path: personal_info.dob\n    format: roman",
+	}
+	for name, source := range cases {
+		_, err := Parse(source)
+		assert.ErrorIs(t, err, ErrInvalidMapping, "Mapping with %s should be rejected", name)
+	}
+}
+
+func TestCheckFields(t *testing.T) {
+	fields, err := acroform.Read(buildPDF())
+	assert.NoError(t, err, "Form should be read")
+
+	mapping, _ := Parse(mock_mapping)
+	assert.NoError(t, mapping.CheckFields(fields), "Mapping should fit the form")
+	assert.Empty(t, mapping.Unmapped(fields), "Signatures should not need mapping")
+
+	partial, _ := Parse("fields:\n  - field: Name\n    path: computed.full_name")
+	assert.Equal(t, []string{"DOB", "Phone"}, partial.Unmapped(fields), "Required fields should be reported")
+
+	missing, _ := Parse("fields:\n  - field: Nickname\n    path: computed.full_name")
+	assert.ErrorIs(t, missing.CheckFields(fields), ErrInvalidMapping, "Fields not in the form should be rejected")
+	signature, _ := Parse("fields:\n  - field: Signature\n    path: computed.full_name")
+	assert.ErrorIs(t, signature.CheckFields(fields), ErrInvalidMapping, "Signatures should not be filled")
+}
+
+func TestFill(t *testing.T) {
+	mapping, _ := Parse(mock_mapping)
+	data := application.ApplicationData{
+		PersonalInfo: application.PersonalInfo{FirstName: "Ana", LastName: "Núñez", Dob: "1982-09-12", Gender: "Female", IsVeteran: true},
+	}
+	members := []application.FamilyMember{
+		{ID: 1, FirstName: "Ana", Relationship: "Self"},
+		{ID: 2, FirstName: "Luz", Relationship: "Child"},
+	}
+	source := NewSource(data, members, time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, 2, source.Computed.HouseholdSize, "Household should include the client")
+
+	filled, report, err := Fill(buildPDF(), mapping, source)
+	assert.NoError(t, err, "Form should be filled")
+	fields, _ := acroform.Read(filled)
+	assert.Equal(t, "Ana Núñez", fieldValue(fields, "Name"), "Paths should be joined")
+	assert.Equal(t, "09/12/1982", fieldValue(fields, "DOB"), "Dates should be formatted")
+	assert.Equal(t, "On", fieldValue(fields, "Veteran"), "Checkbox should be checked")
+	assert.Equal(t, "F", fieldValue(fields, "Sex"), "Values should be replaced")
+	assert.Equal(t, "Luz, Child", fieldValue(fields, "Member1"), "Members should leave out the client")
+	assert.Equal(t, []string{"Phone"}, report.Missing, "Unanswered required fields should be reported")
+	assert.Empty(t, report.Invalid, "All values should fit")
+
+	data.PersonalInfo.Gender = "Other"
+	_, report, err = Fill(buildPDF(), mapping, NewSource(data, members, time.Now()))
+	assert.NoError(t, err, "Form should be filled")
+	assert.Equal(t, "Sex", report.Invalid[0].Field, "Values the form doesn't take should be reported")
+}
@@ -0,0 +1,68 @@
+package program
+
+// Checking a client's application and documents against what a program
+// needs, so they can see what's left before applying.
+
+import (
+	"api/core/server/data/application"
+	"api/db"
+	"slices"
+	"strings"
+)
+
+// SectionCheck is a section the program needs that isn't done yet.
+type SectionCheck struct {
+	Section      string                   `json:"section"`
+	Completeness int                      `json:"completeness"`
+	Missing      []application.FieldIssue `json:"missing"`
+}
+
+type ProgramCheck struct {
+	ProgramID  int    `json:"program_id"`
+	Program    string `json:"program"`
+	IntakeOpen bool   `json:"intake_open"`
+	// Nothing is missing and the program is taking applications
+	Ready            bool           `json:"ready"`
+	MissingSections  []SectionCheck `json:"missing_sections"`
+	MissingDocuments []string       `json:"missing_documents"`
+}
+
+// Check works out which of the program's required sections and documents
+// the client still needs. fileTypes are the types of the files they've
+// uploaded.
+func Check(program *db.HousingProgramModel, data *application.ApplicationData, fileTypes []string) ProgramCheck {
+	check := ProgramCheck{
+		ProgramID:        program.ID,
+		Program:          program.Name,
+		IntakeOpen:       program.IntakeOpen,
+		MissingSections:  []SectionCheck{},
+		MissingDocuments: []string{},
+	}
+
+	validation := application.Validate(data)
+	for _, section := range program.RequiredSections {
+		if validation.Completeness[section] == 100 {
+			continue
+		}
+		missing := []application.FieldIssue{}
+		for _, issue := range validation.Errors {
+			if strings.HasPrefix(issue.Field, section+".") {
+				missing = append(missing, issue)
+			}
+		}
+		check.MissingSections = append(check.MissingSections, SectionCheck{
+			Section:      section,
+			Completeness: validation.Completeness[section],
+			Missing:      missing,
+		})
+	}
+
+	for _, document := range program.RequiredDocuments {
+		if !slices.Contains(fileTypes, document) {
+			check.MissingDocuments = append(check.MissingDocuments, document)
+		}
+	}
+
+	check.Ready = check.IntakeOpen && len(check.MissingSections) == 0 && len(check.MissingDocuments) == 0
+	return check
+}
@@ -0,0 +1,295 @@
+package program
+
+// The catalog of housing programs clients can apply to. Caseworkers and
+// admins keep it up to date, anyone signed in can browse it.
+
+import (
+	"api/core/server/account"
+	"api/core/server/data/application"
+	"api/core/server/eligibility"
+	"api/db"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+type ProgramInfo struct {
+	ID                int       `json:"id"`
+	Name              string    `json:"name"`
+	Provider          string    `json:"provider"`
+	Eligibility       string    `json:"eligibility"`
+	EligibilityRules  string    `json:"eligibility_rules"`
+	RequiredSections  []string  `json:"required_sections"`
+	RequiredDocuments []string  `json:"required_documents"`
+	IntakeOpen        bool      `json:"intake_open"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+type GetProgramsResponse struct {
+	Success  bool          `json:"success"`
+	Programs []ProgramInfo `json:"programs"`
+	Error    string        `json:"error"`
+}
+
+type CreateProgramRequest struct {
+	Name              string   `json:"name"`
+	Provider          string   `json:"provider"`
+	Eligibility       string   `json:"eligibility"`
+	EligibilityRules  string   `json:"eligibility_rules"`
+	RequiredSections  []string `json:"required_sections"`
+	RequiredDocuments []string `json:"required_documents"`
+	IntakeOpen        *bool    `json:"intake_open"`
+}
+
+// Fields left out aren't changed. Lists are replaced as a whole.
+type UpdateProgramRequest struct {
+	ID                int       `json:"id"`
+	Name              string    `json:"name"`
+	Provider          *string   `json:"provider"`
+	Eligibility       *string   `json:"eligibility"`
+	EligibilityRules  *string   `json:"eligibility_rules"`
+	RequiredSections  *[]string `json:"required_sections"`
+	RequiredDocuments *[]string `json:"required_documents"`
+	IntakeOpen        *bool     `json:"intake_open"`
+}
+
+type ProgramResponse struct {
+	Success bool        `json:"success"`
+	Program ProgramInfo `json:"program"`
+	Error   string      `json:"error"`
+}
+
+func toProgramInfo(program *db.HousingProgramModel) ProgramInfo {
+	return ProgramInfo{
+		ID:                program.ID,
+		Name:              program.Name,
+		Provider:          program.Provider,
+		Eligibility:       program.Eligibility,
+		EligibilityRules:  program.EligibilityRules,
+		RequiredSections:  program.RequiredSections,
+		RequiredDocuments: program.RequiredDocuments,
+		IntakeOpen:        program.IntakeOpen,
+		UpdatedAt:         program.UpdatedAt,
+	}
+}
+
+// authStaff checks the user is signed in as a caseworker or admin. On
+// failure, returns the error message to respond with.
+func authStaff(c echo.Context, client *db.PrismaClient) (*db.UserModel, string) {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return nil, "Failed to authenticate"
+	}
+	if user.Type != db.UserTypeCaseWorker && user.Type != db.UserTypeAdmin {
+		return nil, "Only caseworkers and admins can manage programs"
+	}
+	return user, ""
+}
+
+// normalizeSections trims and dedupes the required sections, and checks
+// they're all sections of the application. Returns the first unknown one.
+func normalizeSections(sections []string) ([]string, string) {
+	normalized := []string{}
+	for _, section := range sections {
+		section = strings.TrimSpace(section)
+		if !slices.Contains(application.Sections, section) {
+			return nil, section
+		}
+		if !slices.Contains(normalized, section) {
+			normalized = append(normalized, section)
+		}
+	}
+	return normalized, ""
+}
+
+// normalizeDocuments trims and dedupes the required document types,
+// dropping empty ones.
+func normalizeDocuments(documents []string) []string {
+	normalized := []string{}
+	for _, document := range documents {
+		document = strings.TrimSpace(document)
+		if document != "" && !slices.Contains(normalized, document) {
+			normalized = append(normalized, document)
+		}
+	}
+	return normalized
+}
+
+// checkRules checks eligibility rules parse, so programs can't be saved with
+// rules that can't be evaluated. Empty rules use the defaults. Returns the
+// error message to respond with.
+func checkRules(rules string) string {
+	if strings.TrimSpace(rules) == "" {
+		return ""
+	}
+	if _, err := eligibility.Parse(rules); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// checkNameFree checks no other program than the one with the given id goes
+// by the name. On failure, returns the status code and error message to
+// respond with.
+func checkNameFree(client *db.PrismaClient, name string, id int) (int, string) {
+	existing, err := client.HousingProgram.FindUnique(
+		db.HousingProgram.Name.Equals(name),
+	).Exec(context.Background())
+	if err != nil && err != db.ErrNotFound {
+		fmt.Printf("[ERROR] Failed to check program name %s: %v\n", name, err)
+		return 500, "Failed to check program name"
+	}
+	if existing != nil && existing.ID != id {
+		return 409, "A program with that name already exists"
+	}
+	return 200, ""
+}
+
+// GetProgramsHandler lists the housing programs by name. With open=true,
+// only programs taking applications are listed.
+func GetProgramsHandler(c echo.Context, client *db.PrismaClient) error {
+	user := account.ValidateAuth(c, client)
+	if user == nil {
+		return c.JSON(400, GetProgramsResponse{Success: false, Error: "Failed to authenticate"})
+	}
+
+	filters := []db.HousingProgramWhereParam{}
+	if c.QueryParam("open") == "true" {
+		filters = append(filters, db.HousingProgram.IntakeOpen.Equals(true))
+	}
+
+	programs, programsErr := client.HousingProgram.FindMany(
+		filters...,
+	).OrderBy(
+		db.HousingProgram.Name.Order(db.SortOrderAsc),
+	).Exec(context.Background())
+	if programsErr != nil {
+		fmt.Printf("[ERROR] Failed to get programs: %v\n", programsErr)
+		return c.JSON(500, GetProgramsResponse{Success: false, Error: "Failed to get programs"})
+	}
+
+	infos := make([]ProgramInfo, len(programs))
+	for i := range programs {
+		infos[i] = toProgramInfo(&programs[i])
+	}
+	return c.JSON(200, GetProgramsResponse{Success: true, Programs: infos, Error: ""})
+}
+
+// CreateProgramHandler adds a program to the catalog. Intake is open unless
+// it says otherwise.
+func CreateProgramHandler(c echo.Context, client *db.PrismaClient) error {
+	user, authErr := authStaff(c, client)
+	if user == nil {
+		return c.JSON(400, ProgramResponse{Success: false, Error: authErr})
+	}
+
+	var request CreateProgramRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&request); err != nil {
+		return c.JSON(500, ProgramResponse{Success: false, Error: "Failed to parse request body"})
+	}
+
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return c.JSON(400, ProgramResponse{Success: false, Error: "Missing name"})
+	}
+	sections, unknown := normalizeSections(request.RequiredSections)
+	if unknown != "" {
+		return c.JSON(400, ProgramResponse{Success: false, Error: fmt.Sprintf("Unknown application section: %s", unknown)})
+	}
+	if rulesErr := checkRules(request.EligibilityRules); rulesErr != "" {
+		return c.JSON(400, ProgramResponse{Success: false, Error: rulesErr})
+	}
+
+	params := []db.HousingProgramSetParam{
+		db.HousingProgram.Provider.Set(strings.TrimSpace(request.Provider)),
+		db.HousingProgram.Eligibility.Set(strings.TrimSpace(request.Eligibility)),
+		db.HousingProgram.EligibilityRules.Set(request.EligibilityRules),
+		db.HousingProgram.RequiredSections.Set(sections),
+		db.HousingProgram.RequiredDocuments.Set(normalizeDocuments(request.RequiredDocuments)),
+	}
+	if request.IntakeOpen != nil {
+		params = append(params, db.HousingProgram.IntakeOpen.Set(*request.IntakeOpen))
+	}
+
+	if status, nameErr := checkNameFree(client, name, 0); nameErr != "" {
+		return c.JSON(status, ProgramResponse{Success: false, Error: nameErr})
+	}
+
+	program, createErr := client.HousingProgram.CreateOne(
+		db.HousingProgram.Name.Set(name),
+		params...,
+	).Exec(context.Background())
+	if createErr != nil {
+		fmt.Printf("[ERROR] Failed to create program %s: %v\n", name, createErr)
+		return c.JSON(500, ProgramResponse{Success: false, Error: "Failed to create program"})
+	}
+
+	return c.JSON(200, ProgramResponse{Success: true, Program: toProgramInfo(program), Error: ""})
+}
+
+// UpdateProgramHandler changes a program, including opening and closing its
+// intake. Applications already started keep going when intake closes.
+func UpdateProgramHandler(c echo.Context, client *db.PrismaClient) error {
+	user, authErr := authStaff(c, client)
+	if user == nil {
+		return c.JSON(400, ProgramResponse{Success: false, Error: authErr})
+	}
+
+	var request UpdateProgramRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&request); err != nil {
+		return c.JSON(500, ProgramResponse{Success: false, Error: "Failed to parse request body"})
+	}
+
+	params := []db.HousingProgramSetParam{}
+	if name := strings.TrimSpace(request.Name); name != "" {
+		if status, nameErr := checkNameFree(client, name, request.ID); nameErr != "" {
+			return c.JSON(status, ProgramResponse{Success: false, Error: nameErr})
+		}
+		params = append(params, db.HousingProgram.Name.Set(name))
+	}
+	if request.Provider != nil {
+		params = append(params, db.HousingProgram.Provider.Set(strings.TrimSpace(*request.Provider)))
+	}
+	if request.Eligibility != nil {
+		params = append(params, db.HousingProgram.Eligibility.Set(strings.TrimSpace(*request.Eligibility)))
+	}
+	if request.EligibilityRules != nil {
+		if rulesErr := checkRules(*request.EligibilityRules); rulesErr != "" {
+			return c.JSON(400, ProgramResponse{Success: false, Error: rulesErr})
+		}
+		params = append(params, db.HousingProgram.EligibilityRules.Set(*request.EligibilityRules))
+	}
+	if request.RequiredSections != nil {
+		sections, unknown := normalizeSections(*request.RequiredSections)
+		if unknown != "" {
+			return c.JSON(400, ProgramResponse{Success: false, Error: fmt.Sprintf("Unknown application section: %s", unknown)})
+		}
+		params = append(params, db.HousingProgram.RequiredSections.Set(sections))
+	}
+	if request.RequiredDocuments != nil {
+		params = append(params, db.HousingProgram.RequiredDocuments.Set(normalizeDocuments(*request.RequiredDocuments)))
+	}
+	if request.IntakeOpen != nil {
+		params = append(params, db.HousingProgram.IntakeOpen.Set(*request.IntakeOpen))
+	}
+
+	program, updateErr := client.HousingProgram.FindUnique(
+		db.HousingProgram.ID.Equals(request.ID),
+	).Update(
+		params...,
+	).Exec(context.Background())
+	if updateErr == db.ErrNotFound {
+		return c.JSON(404, ProgramResponse{Success: false, Error: "Program not found"})
+	}
+	if updateErr != nil {
+		fmt.Printf("[ERROR] Failed to update program %d: %v\n", request.ID, updateErr)
+		return c.JSON(500, ProgramResponse{Success: false, Error: "Failed to update program"})
+	}
+
+	return c.JSON(200, ProgramResponse{Success: true, Program: toProgramInfo(program), Error: ""})
+}
@@ -0,0 +1,70 @@
+package program
+
+// Housing program unit tests
+
+import (
+	"api/core/server/data/application"
+	"api/db"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var mock_program = db.HousingProgramModel{
+	InnerHousingProgram: db.InnerHousingProgram{
+		ID:                1,
+		Name:              "Section 8 Voucher",
+		RequiredSections:  []string{application.SectionPersonalInfo, application.SectionIncome},
+		RequiredDocuments: []string{"id", "pay_stub"},
+		IntakeOpen:        true,
+	},
+}
+
+func TestNormalizeSections(t *testing.T) {
+	sections, unknown := normalizeSections([]string{" income", "income", "history"})
+	assert.Equal(t, "", unknown, "All sections should be known")
+	assert.Equal(t, []string{"income", "history"}, sections, "Sections should be trimmed and deduped")
+
+	_, unknown = normalizeSections([]string{"income", "pets"})
+	assert.Equal(t, "pets", unknown, "Unknown section should be reported")
+}
+
+func TestCheckRules(t *testing.T) {
+	assert.Equal(t, "", checkRules(""), "Empty rules should use the defaults")
+	assert.Equal(t, "", checkRules("rules:\n  - id: evicted\n    if: {field: history.evicted, equals: true}\n    then: fail\n"), "Valid rules should be allowed")
+	assert.NotEqual(t, "", checkRules("rules:\n  - id: evicted\n    if: {field: history.evicted, equals: true}\n    then: deny\n"), "Invalid rules should be rejected")
+}
+
+func TestNormalizeDocuments(t *testing.T) {
+	assert.Equal(t, []string{"id", "pay_stub"}, normalizeDocuments([]string{"id ", "", "pay_stub", "id"}), "Documents should be trimmed, deduped and not empty")
+}
+
+func TestCheck(t *testing.T) {
+	data := application.ApplicationData{}
+	check := Check(&mock_program, &data, []string{"id"})
+	assert.False(t, check.Ready, "Empty application should not be ready")
+	assert.Equal(t, []string{"pay_stub"}, check.MissingDocuments, "Missing pay stub should be reported")
+	assert.Len(t, check.MissingSections, 1, "Only personal info should be missing, income has nothing required")
+	assert.Equal(t, application.SectionPersonalInfo, check.MissingSections[0].Section, "Wrong missing section")
+	assert.NotEmpty(t, check.MissingSections[0].Missing, "Missing fields should be listed")
+	for _, issue := range check.MissingSections[0].Missing {
+		assert.Contains(t, issue.Field, "personal_info.", "Missing fields should be from the section")
+	}
+
+	data.PersonalInfo = application.PersonalInfo{
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Dob:       "1990-01-01",
+		Phone:     "2085550100",
+		SSN:       "123-45-6789",
+		Address:   "1 Main St",
+		Gender:    "Female",
+	}
+	check = Check(&mock_program, &data, []string{"pay_stub", "id"})
+	assert.True(t, check.Ready, "Complete application with every document should be ready")
+
+	closed := mock_program
+	closed.InnerHousingProgram.IntakeOpen = false
+	check = Check(&closed, &data, []string{"pay_stub", "id"})
+	assert.False(t, check.Ready, "Program with closed intake should not be ready")
+}
@@ -0,0 +1,182 @@
+package rent
+
+// Works out what a household pays in subsidized housing, following HUD's
+// rules for Total Tenant Payment (24 CFR 5.609 to 5.628): yearly income less
+// the deductions the household gets is its adjusted income, and it pays the
+// most of 30% of monthly adjusted income, 10% of monthly income, and the
+// minimum rent. The utility allowance comes off what it pays the landlord.
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Params are HUD's deduction amounts and limits. HUD adjusts them every year,
+// so they're kept together to update.
+type Params struct {
+	// For each dependent
+	DependentDeduction decimal.Decimal
+	// Once, for a family whose head or spouse is elderly or disabled
+	ElderlyDisabledDeduction decimal.Decimal
+	// Medical expenses are only deducted past this share of yearly income
+	MedicalThreshold decimal.Decimal
+	// Monthly
+	MinimumRent decimal.Decimal
+	// Assets worth more than this earn income at the passbook rate
+	AssetLimit   decimal.Decimal
+	PassbookRate decimal.Decimal
+	ElderlyAge   int
+	AdultAge     int
+}
+
+// DefaultParams are HUD's amounts for 2024, after HOTMA.
+var DefaultParams = Params{
+	DependentDeduction:       decimal.NewFromInt(480),
+	ElderlyDisabledDeduction: decimal.NewFromInt(525),
+	MedicalThreshold:         decimal.RequireFromString("0.10"),
+	MinimumRent:              decimal.NewFromInt(50),
+	AssetLimit:               decimal.NewFromInt(51600),
+	PassbookRate:             decimal.RequireFromString("0.0045"),
+	ElderlyAge:               62,
+	AdultAge:                 18,
+}
+
+// Relationships of the head of household and their spouse, as family links
+// name them.
+const (
+	RelationshipSelf   = "Self"
+	RelationshipSpouse = "Spouse"
+)
+
+type Member struct {
+	Relationship string
+	Age          int
+}
+
+// Household is who lives together. Elderly and Disabled are what the client
+// answered on the application, on top of the ages of the head and spouse.
+type Household struct {
+	Members  []Member
+	Elderly  bool
+	Disabled bool
+}
+
+// Input is everything the payment is worked out from. Income and expenses
+// are yearly, rents and the utility allowance are monthly.
+type Input struct {
+	Household        Household
+	AnnualIncome     decimal.Decimal
+	EarnedIncome     decimal.Decimal
+	AssetValue       decimal.Decimal
+	ChildcareCosts   decimal.Decimal
+	MedicalCosts     decimal.Decimal
+	ContractRent     decimal.Decimal
+	UtilityAllowance decimal.Decimal
+}
+
+type Deductions struct {
+	Dependents      int             `json:"dependents"`
+	Dependent       decimal.Decimal `json:"dependent"`
+	ElderlyDisabled decimal.Decimal `json:"elderly_disabled"`
+	Childcare       decimal.Decimal `json:"childcare"`
+	Medical         decimal.Decimal `json:"medical"`
+	Total           decimal.Decimal `json:"total"`
+}
+
+type Result struct {
+	AnnualIncome decimal.Decimal `json:"annual_income"`
+	// Income assumed from assets over the asset limit, included in AnnualIncome
+	AssetIncome           decimal.Decimal `json:"asset_income"`
+	ElderlyOrDisabled     bool            `json:"elderly_or_disabled"`
+	Deductions            Deductions      `json:"deductions"`
+	AdjustedIncome        decimal.Decimal `json:"adjusted_income"`
+	MonthlyAdjustedIncome decimal.Decimal `json:"monthly_adjusted_income"`
+	TotalTenantPayment    decimal.Decimal `json:"total_tenant_payment"`
+	// What the household pays the landlord each month
+	TenantRent decimal.Decimal `json:"tenant_rent"`
+	// Paid to the household when the utility allowance is more than its TTP
+	UtilityReimbursement decimal.Decimal `json:"utility_reimbursement"`
+	// The rest of the contract rent, paid by the subsidy
+	AssistancePayment decimal.Decimal `json:"assistance_payment"`
+}
+
+// Age is how old someone born on birthday is on the day.
+func Age(birthday time.Time, day time.Time) int {
+	age := day.Year() - birthday.Year()
+	if day.Month() < birthday.Month() || (day.Month() == birthday.Month() && day.Day() < birthday.Day()) {
+		age--
+	}
+	return max(age, 0)
+}
+
+var twelve = decimal.NewFromInt(12)
+
+// isHeadOrSpouse checks the member is the head of household or their spouse.
+// They can't be dependents, and their ages decide if the family is elderly.
+func isHeadOrSpouse(member Member) bool {
+	return member.Relationship == RelationshipSelf || member.Relationship == RelationshipSpouse
+}
+
+// Calculate works out the household's Total Tenant Payment and rent.
+func Calculate(input Input, params Params) Result {
+	result := Result{}
+
+	annual := input.AnnualIncome
+	if input.AssetValue.GreaterThan(params.AssetLimit) {
+		result.AssetIncome = input.AssetValue.Mul(params.PassbookRate)
+		annual = annual.Add(result.AssetIncome)
+	}
+
+	elderly := input.Household.Elderly
+	for _, member := range input.Household.Members {
+		if isHeadOrSpouse(member) {
+			if member.Age >= params.ElderlyAge {
+				elderly = true
+			}
+		} else if member.Age < params.AdultAge {
+			result.Deductions.Dependents++
+		}
+	}
+	result.ElderlyOrDisabled = elderly || input.Household.Disabled
+
+	deductions := &result.Deductions
+	deductions.Dependent = params.DependentDeduction.Mul(decimal.NewFromInt(int64(deductions.Dependents)))
+	// Childcare is only deducted up to what it lets the household earn
+	deductions.Childcare = decimal.Min(input.ChildcareCosts, input.EarnedIncome)
+	if result.ElderlyOrDisabled {
+		deductions.ElderlyDisabled = params.ElderlyDisabledDeduction
+		deductions.Medical = decimal.Max(input.MedicalCosts.Sub(annual.Mul(params.MedicalThreshold)), decimal.Zero)
+	}
+	deductions.Total = deductions.Dependent.Add(deductions.ElderlyDisabled).Add(deductions.Childcare).Add(deductions.Medical)
+
+	adjusted := decimal.Max(annual.Sub(deductions.Total), decimal.Zero)
+	ttp := decimal.Max(
+		adjusted.Div(twelve).Mul(decimal.RequireFromString("0.30")),
+		annual.Div(twelve).Mul(decimal.RequireFromString("0.10")),
+		params.MinimumRent,
+	).Round(2)
+
+	tenantRent := ttp.Sub(input.UtilityAllowance)
+	if tenantRent.IsNegative() {
+		result.UtilityReimbursement = tenantRent.Neg()
+		tenantRent = decimal.Zero
+	}
+	// A household whose TTP covers the whole rent pays the contract rent
+	if input.ContractRent.IsPositive() {
+		tenantRent = decimal.Min(tenantRent, input.ContractRent)
+		result.AssistancePayment = input.ContractRent.Sub(tenantRent)
+	}
+
+	result.AnnualIncome = annual.Round(2)
+	result.AssetIncome = result.AssetIncome.Round(2)
+	deductions.Medical = deductions.Medical.Round(2)
+	deductions.Total = deductions.Total.Round(2)
+	result.AdjustedIncome = adjusted.Round(2)
+	result.MonthlyAdjustedIncome = adjusted.Div(twelve).Round(2)
+	result.TotalTenantPayment = ttp
+	result.TenantRent = tenantRent.Round(2)
+	result.UtilityReimbursement = result.UtilityReimbursement.Round(2)
+	result.AssistancePayment = result.AssistancePayment.Round(2)
+	return result
+}
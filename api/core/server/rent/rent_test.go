@@ -0,0 +1,100 @@
+package rent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func dollars(amount string) decimal.Decimal {
+	return decimal.RequireFromString(amount)
+}
+
+func TestAge(t *testing.T) {
+	birthday := time.Date(1962, time.March, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, 61, Age(birthday, time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)), "Age should not go up before the birthday")
+	assert.Equal(t, 62, Age(birthday, time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)), "Age should go up on the birthday")
+	assert.Equal(t, 0, Age(time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC), birthday), "Future birthdays should be 0")
+}
+
+func TestCalculateFamilyWithDependents(t *testing.T) {
+	result := Calculate(Input{
+		Household: Household{Members: []Member{
+			{Relationship: RelationshipSelf, Age: 34},
+			{Relationship: RelationshipSpouse, Age: 33},
+			{Relationship: "Child", Age: 8},
+			{Relationship: "Child", Age: 17},
+			{Relationship: "Parent", Age: 58},
+		}},
+		AnnualIncome:     dollars("30000"),
+		EarnedIncome:     dollars("30000"),
+		ChildcareCosts:   dollars("2000"),
+		MedicalCosts:     dollars("5000"),
+		ContractRent:     dollars("1100"),
+		UtilityAllowance: dollars("120"),
+	}, DefaultParams)
+
+	assert.Equal(t, 2, result.Deductions.Dependents, "Only members under 18 other than the head and spouse should be dependents")
+	assert.Equal(t, "960.00", result.Deductions.Dependent.StringFixed(2), "Each dependent should be deducted")
+	assert.True(t, result.Deductions.ElderlyDisabled.IsZero(), "Non elderly families should not get the elderly deduction")
+	assert.True(t, result.Deductions.Medical.IsZero(), "Non elderly families should not deduct medical costs")
+	assert.Equal(t, "2000.00", result.Deductions.Childcare.StringFixed(2), "Childcare should be deducted")
+	assert.Equal(t, "27040.00", result.AdjustedIncome.StringFixed(2), "Adjusted income should be income less deductions")
+	assert.Equal(t, "676.00", result.TotalTenantPayment.StringFixed(2), "TTP should be 30% of monthly adjusted income")
+	assert.Equal(t, "556.00", result.TenantRent.StringFixed(2), "Tenant rent should be TTP less the utility allowance")
+	assert.Equal(t, "544.00", result.AssistancePayment.StringFixed(2), "Subsidy should pay the rest of the contract rent")
+}
+
+func TestCalculateElderlyFamily(t *testing.T) {
+	result := Calculate(Input{
+		Household:        Household{Members: []Member{{Relationship: RelationshipSelf, Age: 70}}},
+		AnnualIncome:     dollars("12000"),
+		MedicalCosts:     dollars("3000"),
+		ChildcareCosts:   dollars("500"),
+		AssetValue:       dollars("60000"),
+		UtilityAllowance: dollars("80"),
+	}, DefaultParams)
+
+	assert.True(t, result.ElderlyOrDisabled, "Head of household 62 or older should make the family elderly")
+	assert.Equal(t, "270.00", result.AssetIncome.StringFixed(2), "Assets over the limit should earn passbook income")
+	assert.Equal(t, "12270.00", result.AnnualIncome.StringFixed(2), "Asset income should be counted")
+	assert.Equal(t, "525.00", result.Deductions.ElderlyDisabled.StringFixed(2), "Elderly families should get the deduction")
+	assert.Equal(t, "1773.00", result.Deductions.Medical.StringFixed(2), "Medical costs past 10% of income should be deducted")
+	assert.True(t, result.Deductions.Childcare.IsZero(), "Childcare should not be deducted past earned income")
+	assert.Equal(t, "9972.00", result.AdjustedIncome.StringFixed(2), "Adjusted income should be income less deductions")
+	assert.Equal(t, "249.30", result.TotalTenantPayment.StringFixed(2), "TTP should be 30% of monthly adjusted income")
+	assert.Equal(t, "169.30", result.TenantRent.StringFixed(2), "Tenant rent should be TTP less the utility allowance")
+	assert.True(t, result.AssistancePayment.IsZero(), "No contract rent should mean no subsidy worked out")
+}
+
+func TestCalculateMinimumsAndCaps(t *testing.T) {
+	noIncome := Calculate(Input{
+		Household:        Household{Members: []Member{{Relationship: RelationshipSelf, Age: 40}}, Disabled: true},
+		UtilityAllowance: dollars("90"),
+		ContractRent:     dollars("800"),
+	}, DefaultParams)
+	assert.Equal(t, "50.00", noIncome.TotalTenantPayment.StringFixed(2), "TTP should be at least the minimum rent")
+	assert.True(t, noIncome.TenantRent.IsZero(), "Tenant rent should not go below zero")
+	assert.Equal(t, "40.00", noIncome.UtilityReimbursement.StringFixed(2), "Utility allowance past TTP should be reimbursed")
+	assert.Equal(t, "800.00", noIncome.AssistancePayment.StringFixed(2), "Subsidy should pay the whole rent")
+
+	highIncome := Calculate(Input{
+		Household:    Household{Members: []Member{{Relationship: RelationshipSelf, Age: 40}}},
+		AnnualIncome: dollars("120000"),
+		ContractRent: dollars("900"),
+	}, DefaultParams)
+	assert.Equal(t, "3000.00", highIncome.TotalTenantPayment.StringFixed(2), "TTP should be 30% of monthly adjusted income")
+	assert.Equal(t, "900.00", highIncome.TenantRent.StringFixed(2), "Tenant rent should not be more than the contract rent")
+	assert.True(t, highIncome.AssistancePayment.IsZero(), "No subsidy should be paid when TTP covers the rent")
+
+	heavyDeductions := Calculate(Input{
+		Household:      Household{Members: []Member{{Relationship: RelationshipSelf, Age: 30}, {Relationship: "Child", Age: 2}}},
+		AnnualIncome:   dollars("6000"),
+		EarnedIncome:   dollars("6000"),
+		ChildcareCosts: dollars("5800"),
+	}, DefaultParams)
+	assert.True(t, heavyDeductions.AdjustedIncome.IsZero(), "Adjusted income should not go below zero")
+	assert.Equal(t, "50.00", heavyDeductions.TotalTenantPayment.StringFixed(2), "TTP should use the largest of the minimums")
+}
@@ -4,9 +4,16 @@ package server
 // as well as static assets for the web app.
 
 import (
+	"api/core/encryption"
 	"api/core/server/account"
+	"api/core/server/audit"
+	"api/core/server/card"
+	"api/core/server/casework"
 	"api/core/server/data"
 	"api/core/server/file"
+	"api/core/server/hmis"
+	"api/core/server/partnerform"
+	"api/core/server/program"
 	db "api/db"
 	"fmt"
 	"os"
@@ -72,6 +79,25 @@ func initApi() *APIServer {
 	api.e.POST("api/data/family/delete", func(c echo.Context) error { return data.DeleteFamilyMemberHandler(c, api.client) })
 	api.e.GET("api/data/application", func(c echo.Context) error { return data.GetApplicationDataHandler(c, api.client) })
 	api.e.POST("api/data/application", func(c echo.Context) error { return data.UpdateApplicationDataHandler(c, api.client) })
+	api.e.GET("api/data/applications", func(c echo.Context) error { return data.GetHousingApplicationsHandler(c, api.client) })
+	api.e.POST("api/data/applications", func(c echo.Context) error { return data.CreateHousingApplicationHandler(c, api.client) })
+	api.e.GET("api/data/application/program-check", func(c echo.Context) error { return data.CheckProgramHandler(c, api.client) })
+	api.e.GET("api/data/application/income", func(c echo.Context) error { return data.GetIncomeHandler(c, api.client) })
+	api.e.GET("api/data/application/ami", func(c echo.Context) error { return data.GetAMIEligibilityHandler(c, api.client) })
+	api.e.GET("api/data/application/eligibility", func(c echo.Context) error { return data.CheckEligibilityHandler(c, api.client) })
+	api.e.GET("api/data/application/rent", func(c echo.Context) error { return data.GetRentShareHandler(c, api.client) })
+	api.e.GET("api/data/application/pdf", func(c echo.Context) error { return data.GetApplicationPDFHandler(c, api.client) })
+	api.e.GET("api/data/application/partner-form", func(c echo.Context) error { return data.GetPartnerFormHandler(c, api.client) })
+	api.e.GET("api/data/application/partner-form/report", func(c echo.Context) error { return data.GetPartnerFormReportHandler(c, api.client) })
+	api.e.GET("api/data/application/validate", func(c echo.Context) error { return data.ValidateApplicationDataHandler(c, api.client) })
+	api.e.GET("api/data/application/status", func(c echo.Context) error { return data.GetApplicationStatusHandler(c, api.client) })
+	api.e.POST("api/data/application/status", func(c echo.Context) error { return data.TransitionApplicationHandler(c, api.client) })
+	api.e.PATCH("api/data/application/:section", func(c echo.Context) error { return data.PatchApplicationSectionHandler(c, api.client) })
+	api.e.GET("api/data/application/versions", func(c echo.Context) error { return data.GetApplicationVersionsHandler(c, api.client) })
+	api.e.GET("api/data/application/version", func(c echo.Context) error { return data.GetApplicationVersionHandler(c, api.client) })
+	api.e.GET("api/data/application/versions/diff", func(c echo.Context) error { return data.DiffApplicationVersionsHandler(c, api.client) })
+	api.e.POST("api/data/application/versions/restore", func(c echo.Context) error { return data.RestoreApplicationVersionHandler(c, api.client) })
+	api.e.POST("api/data/ssn/reveal", func(c echo.Context) error { return data.RevealSSNHandler(c, api.client) })
 	api.e.GET("/api/account/validate-2fa", func(c echo.Context) error { return account.Validate2FAHandler(c, api.client) })
 	api.e.GET("/api/account/enable-2fa", func(c echo.Context) error { return account.Enable2FAHandler(c, api.client) })
 	api.e.GET("/api/account/disable-2fa", func(c echo.Context) error { return account.Disable2FAHandler(c, api.client) })
@@ -81,10 +107,54 @@ func initApi() *APIServer {
 	api.e.GET("/api/account/linkcode", func(c echo.Context) error { return account.GetAccountLinkCode(c, api.client) })
 	api.e.GET("/api/account/linkcode/meta", func(c echo.Context) error { return account.GetAccountLinkCodeMeta(c, api.client) })
 	api.e.POST("/api/account/link", func(c echo.Context) error { return account.LinkAccountsHandler(c, api.client) })
+	api.e.GET("/api/account/link/requests", func(c echo.Context) error { return account.GetLinkRequestsHandler(c, api.client) })
+	api.e.POST("/api/account/link/approve", func(c echo.Context) error { return account.ApproveLinkRequestHandler(c, api.client) })
+	api.e.POST("/api/account/link/reject", func(c echo.Context) error { return account.RejectLinkRequestHandler(c, api.client) })
+	api.e.POST("/api/account/link/consent", func(c echo.Context) error { return account.SetEditConsentHandler(c, api.client) })
+	api.e.GET("/api/account/trusted-orgs", func(c echo.Context) error { return account.GetTrustedOrgsHandler(c, api.client) })
+	api.e.POST("/api/account/trusted-orgs", func(c echo.Context) error { return account.SetTrustedOrgsHandler(c, api.client) })
 	api.e.POST("/api/account/unlink", func(c echo.Context) error { return account.UnlinkAccountHandler(c, api.client) })
 	api.e.GET("/api/account/links", func(c echo.Context) error { return account.GetAccountConnections(c, api.client) })
+	api.e.POST("/api/account/caseload/transfer", func(c echo.Context) error { return account.TransferCaseloadHandler(c, api.client) })
+	api.e.GET("/api/account/caseload/transfers", func(c echo.Context) error { return account.GetCaseloadTransfersHandler(c, api.client) })
+	api.e.POST("/api/account/reauth/request", func(c echo.Context) error { return account.RequestReauthHandler(c, api.client) })
+	api.e.POST("/api/account/reauth/verify", func(c echo.Context) error { return account.VerifyReauthHandler(c, api.client) })
+	api.e.GET("/api/audit", func(c echo.Context) error { return audit.GetAuditEventsHandler(c, api.client) })
+	api.e.GET("/api/account/card", func(c echo.Context) error { return card.GetWalletCardHandler(c, api.client) })
 	api.e.GET("/api/seed", func(c echo.Context) error { return seedDB(c, api.client) })
 
+	// Casework routes
+	api.e.GET("/api/casework/dashboard", func(c echo.Context) error { return casework.GetDashboardHandler(c, api.client) })
+
+	api.e.GET("/api/casework/notes", func(c echo.Context) error { return casework.GetNotesHandler(c, api.client) })
+	api.e.POST("/api/casework/notes/create", func(c echo.Context) error { return casework.CreateNoteHandler(c, api.client) })
+	api.e.POST("/api/casework/notes/update", func(c echo.Context) error { return casework.UpdateNoteHandler(c, api.client) })
+	api.e.POST("/api/casework/notes/delete", func(c echo.Context) error { return casework.DeleteNoteHandler(c, api.client) })
+	api.e.GET("/api/casework/notes/history", func(c echo.Context) error { return casework.GetNoteHistoryHandler(c, api.client) })
+
+	api.e.GET("/api/casework/tasks", func(c echo.Context) error { return casework.GetTasksHandler(c, api.client) })
+	api.e.GET("/api/casework/tasks/overdue", func(c echo.Context) error { return casework.GetOverdueTasksHandler(c, api.client) })
+	api.e.GET("/api/casework/tasks/upcoming", func(c echo.Context) error { return casework.GetUpcomingTasksHandler(c, api.client) })
+	api.e.POST("/api/casework/tasks/create", func(c echo.Context) error { return casework.CreateTaskHandler(c, api.client) })
+	api.e.POST("/api/casework/tasks/update", func(c echo.Context) error { return casework.UpdateTaskHandler(c, api.client) })
+	api.e.POST("/api/casework/tasks/delete", func(c echo.Context) error { return casework.DeleteTaskHandler(c, api.client) })
+
+	api.e.GET("/api/casework/hmis/export", func(c echo.Context) error { return hmis.ExportHandler(c, api.client) })
+	api.e.GET("/api/casework/hmis/export/report", func(c echo.Context) error { return hmis.ExportReportHandler(c, api.client) })
+	api.e.POST("/api/casework/hmis/import", func(c echo.Context) error { return data.ImportHMISHandler(c, api.client) })
+
+	// Program routes
+	api.e.GET("/api/programs", func(c echo.Context) error { return program.GetProgramsHandler(c, api.client) })
+	api.e.POST("/api/programs/create", func(c echo.Context) error { return program.CreateProgramHandler(c, api.client) })
+	api.e.POST("/api/programs/update", func(c echo.Context) error { return program.UpdateProgramHandler(c, api.client) })
+
+	// Partner form routes
+	api.e.GET("/api/partner-forms", func(c echo.Context) error { return partnerform.GetPartnerFormsHandler(c, api.client) })
+	api.e.POST("/api/partner-forms/inspect", func(c echo.Context) error { return partnerform.InspectFormHandler(c, api.client) })
+	api.e.POST("/api/partner-forms/upload", func(c echo.Context) error { return partnerform.UploadPartnerFormHandler(c, api.client) })
+	api.e.POST("/api/partner-forms/update", func(c echo.Context) error { return partnerform.UpdatePartnerFormHandler(c, api.client) })
+	api.e.POST("/api/partner-forms/delete", func(c echo.Context) error { return partnerform.DeletePartnerFormHandler(c, api.client) })
+
 	// File routes
 	api.e.POST("/api/file/upload", func(c echo.Context) error { return file.UploadFileHandler(c, api.client) })
 	api.e.GET("/api/file/list", func(c echo.Context) error { return file.GetFilesHandler(c, api.client) })
@@ -92,6 +162,8 @@ func initApi() *APIServer {
 	api.e.DELETE("/api/file", func(c echo.Context) error { return file.DeleteFileHandler(c, api.client) })
 
 	api.e.GET("/api/seed", func(c echo.Context) error { return seedDB(c, api.client) })
+	// A handler that panics answers with a 500 instead of taking the server down
+	api.e.Use(middleware.Recover())
 	api.e.Use(middleware.CORS())
 	return api
 }
@@ -129,10 +201,18 @@ func (api *APIServer) Start() {
 	fmt.Printf("Cwd: %v\n", currentWorkDirectory)
 	godotenv.Load(currentWorkDirectory + "/../.env")
 
+	if err := encryption.Setup(); err != nil {
+		fmt.Printf("Failed to load encryption keys: %v\n", err)
+		return
+	}
+
 	baseUrl = os.Getenv("HTML_ROOT")
 	api.e.Static("/", "app")
 	fmt.Printf("%s -> app\n", baseUrl)
 
+	stopReminders := casework.StartReminders(api.client)
+	defer stopReminders()
+
 	addr := fmt.Sprintf(":%s", os.Getenv("API_PORT"))
 	api.e.Logger.Fatal(api.e.Start(addr))
 }
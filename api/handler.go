@@ -1,10 +1,27 @@
 package handler
 
 import (
+	"api/core/encryption"
 	"api/core/server"
+	"fmt"
 	"net/http"
+	"sync"
 )
 
+var setupOnce sync.Once
+var setupErr error = nil
+
 func Handler(w http.ResponseWriter, r *http.Request) {
+	// Nothing is served without the master keys, or sensitive fields
+	// couldn't be read or saved
+	setupOnce.Do(func() {
+		if setupErr = encryption.Setup(); setupErr != nil {
+			fmt.Printf("[ERROR] Failed to load encryption keys: %v\n", setupErr)
+		}
+	})
+	if setupErr != nil {
+		http.Error(w, "Server is not configured", http.StatusInternalServerError)
+		return
+	}
 	server.GetAPI().Serve(w, r)
 }
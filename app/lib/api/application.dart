@@ -6,18 +6,85 @@ import 'package:app/api/types.dart';
 import 'package:app/form/classes.dart';
 import 'package:app/util/result.dart';
 
+// The client whose application was last loaded. Loading another client's
+// application drops everything cached for the last one, so it can't be saved
+// over the new one's.
+String? _applicationEmail;
+
+// The version of the application last loaded or saved. Saves send it back so
+// the server can tell if someone else changed the application in between.
+int _applicationVersion = 0;
+
+// The housing application being filled in, by client email. Missing until
+// the server says or one is picked, which picks the client's only application.
+final Map<String, int> _applicationIds = {};
+
+void _switchClient(String email) {
+  if (_applicationEmail == email) {
+    return;
+  }
+  _applicationEmail = email;
+  _applicationVersion = 0;
+  _applicationIds.clear();
+}
+
+/// The housing application of the client being filled in, if one is picked.
+int? selectedApplicationId(String email) => _applicationIds[email];
+
+/// Picks which of the client's housing applications to fill in. The next
+/// getApplicationData loads it.
+void selectApplication(String email, int id) {
+  _switchClient(email);
+  _applicationIds[email] = id;
+}
+
+/// Keeps the version a change to the client's household saved the
+/// application at, so the next save isn't taken for a stale one.
+void keepApplicationVersion(String email, int version) {
+  if (_applicationEmail == email && version != 0) {
+    _applicationVersion = version;
+  }
+}
+
+Future<Result<List<HousingApplicationInfo>>> getHousingApplications(
+    String email) async {
+  final response = await requestGet(
+    '/api/data/applications',
+    {'email': email},
+    await authHeader(),
+  );
+
+  try {
+    final json = jsonDecode(response.body);
+    if ((json['error'] as String? ?? '').isNotEmpty) {
+      print('Error when getting applications: ${json['error']}');
+      return Result.error(json['error'] ?? response.statusCode.toString());
+    }
+
+    return Result.success([
+      for (final application in (json['applications'] as List<dynamic>))
+        HousingApplicationInfo.fromJson(application as Map<String, dynamic>),
+    ]);
+  } catch (e) {
+    print('Failed to get applications: $e');
+  }
+
+  return Result.error('Failed to get applications');
+}
+
 Future<Result<ApplicationData>> getApplicationData(String email) async {
+  _switchClient(email);
+  final applicationId = _applicationIds[email];
   final response = await requestGet(
     '/api/data/application',
     {
       'email': email,
+      if (applicationId != null) 'id': applicationId.toString(),
     },
     await authHeader(),
   );
 
   try {
-    print(
-        'Response: ${JsonEncoder.withIndent('  ').convert(jsonDecode(response.body))}');
     final json = jsonDecode(response.body);
     if ((json['error'] as String? ?? '').isNotEmpty) {
       print('Error when getting application data: ${json['error']}');
@@ -25,6 +92,11 @@ Future<Result<ApplicationData>> getApplicationData(String email) async {
     }
 
     if (json['success'] ?? false) {
+      _applicationVersion = json['version'] as int? ?? 0;
+      final id = json['application_id'] as int? ?? 0;
+      if (id != 0) {
+        _applicationIds[email] = id;
+      }
       if (json['has_data'] ?? false) {
         return Result.success(ApplicationData.fromJson(
             json['application_data'] as Map<String, dynamic>));
@@ -39,11 +111,21 @@ Future<Result<ApplicationData>> getApplicationData(String email) async {
   return Result.error('Failed to get application data');
 }
 
+/// Saves the application last loaded with getApplicationData.
 Future<bool> updateApplicationData(ApplicationData data) async {
+  final email = _applicationEmail;
+  if (email == null) {
+    print('No application loaded, cannot save application data.');
+    return false;
+  }
+
   final response = await requestPost(
     '/api/data/application',
     {
+      'email': email,
       'application_data': data.toJson(),
+      'version': _applicationVersion,
+      'application_id': _applicationIds[email] ?? 0,
     },
     await authHeader(),
   );
@@ -55,6 +137,14 @@ Future<bool> updateApplicationData(ApplicationData data) async {
       return false;
     }
 
+    // Ignore a late response for a client that's no longer loaded
+    if (_applicationEmail == email) {
+      _applicationVersion = json['version'] as int? ?? _applicationVersion;
+      final id = json['application_id'] as int? ?? 0;
+      if (id != 0) {
+        _applicationIds[email] = id;
+      }
+    }
     return json['success'] ?? false;
   } catch (e) {
     print('Failed to set application data: $e');
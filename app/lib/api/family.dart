@@ -1,9 +1,18 @@
 import 'dart:convert';
 
 import 'package:app/api/account.dart';
+import 'package:app/api/application.dart';
 import 'package:app/api/http_interface.dart';
 import 'package:app/form/classes.dart';
 
+// Household changes save a new version of the application too.
+Future<void> _keepVersion(dynamic json) async {
+  final email = await getCurrentEmail();
+  if (email != null) {
+    keepApplicationVersion(email, json['version'] as int? ?? 0);
+  }
+}
+
 Future<List<FamilyMember>> getFamilyMembers(String? clientEmail) async {
   final response = await requestGet(
     '/api/data/family',
@@ -50,6 +59,7 @@ Future<bool> addFamilyMember(FamilyMember member) async {
 
     if (json['success'] ?? false) {
       member.id = json['id']!;
+      await _keepVersion(json);
       return true;
     } else {
       return false;
@@ -77,6 +87,7 @@ Future<bool> updateFamilyMember(FamilyMember member) async {
       return false;
     }
 
+    await _keepVersion(json);
     return json['success'] ?? false;
   } catch (e) {
     print('Failed to update family member: $e');
@@ -99,6 +110,7 @@ Future<bool> deleteFamilyMember(int id) async {
       return false;
     }
 
+    await _keepVersion(json);
     return json['success'] ?? false;
   } catch (e) {
     print('Failed to delete family member: $e');
@@ -192,3 +192,30 @@ class GetFilesResponse {
     );
   }
 }
+
+/// One of a client's housing applications, one per program they apply to.
+class HousingApplicationInfo {
+  final int id;
+  final String program;
+  final String status;
+
+  const HousingApplicationInfo({
+    required this.id,
+    required this.program,
+    required this.status,
+  });
+
+  factory HousingApplicationInfo.fromJson(Map<String, dynamic> json) {
+    return HousingApplicationInfo(
+      id: json['id'] as int,
+      program: json['program'] as String? ?? '',
+      status: json['status'] as String? ?? '',
+    );
+  }
+
+  /// How the application is shown when picking one.
+  String get label {
+    final name = program.isEmpty ? 'Application $id' : program;
+    return '$name (${status.toLowerCase().replaceAll('_', ' ')})';
+  }
+}
@@ -1,6 +1,7 @@
 import 'package:app/api/account.dart';
 import 'package:app/api/application.dart';
 import 'package:app/api/family.dart';
+import 'package:app/api/types.dart';
 import 'package:flutter/foundation.dart';
 import 'package:app/form/files.dart';
 import 'package:flutter/material.dart';
@@ -24,6 +25,10 @@ class ApplicationForm extends StatefulWidget {
 class _ApplicationFormState extends State<ApplicationForm> {
   ApplicationData data = ApplicationData.empty();
   List<FamilyMember> familyMembers = [];
+  String? email;
+  // The client's housing applications, which they pick between when
+  // applying to more than one program
+  List<HousingApplicationInfo> applications = [];
   int selectedIndex = 0;
   final ScrollController _scrollController = ScrollController();
   final ScrollController _navScrollController = ScrollController();
@@ -91,45 +96,58 @@ class _ApplicationFormState extends State<ApplicationForm> {
       _onScroll();
     });
 
-    Future(() async {
-      // TODO: Make this variable for view only or whatever
+    _loadApplication();
+  }
 
-      final email = widget.clientEmail ?? await getCurrentEmail();
-      if (email == null) {
-        print("No email found, cannot load application data.");
-        return;
-      }
+  /// Loads the picked housing application, or the client's only one.
+  Future<void> _loadApplication() async {
+    // TODO: Make this variable for view only or whatever
 
-      final res = await getApplicationData(email);
-      if (res.isError) {
-        print("Error getting application data: ${res.error}");
-        return;
+    final email = this.email ??= widget.clientEmail ?? await getCurrentEmail();
+    if (email == null) {
+      print("No email found, cannot load application data.");
+      return;
+    }
+
+    final applicationsRes = await getHousingApplications(email);
+    if (applicationsRes.isSuccess) {
+      applications = applicationsRes.value!;
+      final selected = selectedApplicationId(email);
+      if (applications.length > 1 &&
+          !applications.any((application) => application.id == selected)) {
+        selectApplication(email, applications.first.id);
       }
+    }
 
-      familyMembers = await getFamilyMembers(email);
+    final res = await getApplicationData(email);
+    if (res.isError) {
+      print("Error getting application data: ${res.error}");
+      return;
+    }
 
-      data = res.value!;
-      if (mounted) {
-        setState(() {
-          categorySections = [
-            PersonalInfoForm(data: data, isReadOnly: widget.isReadOnly),
-            HousingPreferencesForm(data: data, isReadOnly: widget.isReadOnly),
-            HouseholdForm(
-                data: data,
-                familyMembers: familyMembers,
-                isReadOnly: widget.isReadOnly),
-            HistoryForm(
-                data: data,
-                familyMembers: familyMembers,
-                isReadOnly: widget.isReadOnly),
-            IncomeForm(
-                data: data,
-                familyMembers: familyMembers,
-                isReadOnly: widget.isReadOnly),
-          ];
-        });
-      }
-    });
+    familyMembers = await getFamilyMembers(email);
+
+    data = res.value!;
+    if (mounted) {
+      setState(() {
+        categorySections = [
+          PersonalInfoForm(data: data, isReadOnly: widget.isReadOnly),
+          HousingPreferencesForm(data: data, isReadOnly: widget.isReadOnly),
+          HouseholdForm(
+              data: data,
+              familyMembers: familyMembers,
+              isReadOnly: widget.isReadOnly),
+          HistoryForm(
+              data: data,
+              familyMembers: familyMembers,
+              isReadOnly: widget.isReadOnly),
+          IncomeForm(
+              data: data,
+              familyMembers: familyMembers,
+              isReadOnly: widget.isReadOnly),
+        ];
+      });
+    }
   }
 
   void _saveForm() async {
@@ -197,6 +215,34 @@ class _ApplicationFormState extends State<ApplicationForm> {
                 child: Column(
                   crossAxisAlignment: CrossAxisAlignment.start,
                   children: [
+                    // Application picker
+                    if (applications.length > 1 && email != null)
+                      Padding(
+                        padding: EdgeInsets.symmetric(horizontal: 16),
+                        child: DropdownButton<int>(
+                          value: selectedApplicationId(email!),
+                          isExpanded: true,
+                          items: [
+                            for (final application in applications)
+                              DropdownMenuItem(
+                                value: application.id,
+                                child: Text(application.label),
+                              ),
+                          ],
+                          onChanged: (id) {
+                            if (id == null ||
+                                id == selectedApplicationId(email!)) {
+                              return;
+                            }
+                            selectApplication(email!, id);
+                            setState(() {
+                              categorySections = null;
+                            });
+                            _loadApplication();
+                          },
+                        ),
+                      ),
+
                     // Form Sections
                     ...sections,
 